@@ -2,9 +2,12 @@ package datasource
 
 import (
 	"database/sql/driver"
+	"encoding/json"
 	"fmt"
 	"hash/fnv"
 	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	u "github.com/araddon/gou"
@@ -18,6 +21,8 @@ var (
 	_ expr.ContextReader = (*ContextSimple)(nil)
 	_ expr.ContextReader = (*SqlDriverMessageMap)(nil)
 	_ expr.ContextReader = (*ContextUrlValues)(nil)
+	_ expr.ContextReader = (*ContextCaseInsensitive)(nil)
+	_ expr.OptionsReader = (*ContextOptions)(nil)
 	// Context Writers
 	_ expr.ContextWriter = (*ContextUrlValues)(nil)
 	_ expr.ContextWriter = (*ContextSimple)(nil)
@@ -44,6 +49,43 @@ type Message interface {
 	Body() interface{}
 }
 
+// MessageReader returns the expr.ContextReader to evaluate a message
+//  against, the message itself or else its Body(), so tasks may handle
+//  any message type that can be read
+func MessageReader(msg Message) (expr.ContextReader, bool) {
+	if msg == nil {
+		return nil, false
+	}
+	if reader, ok := msg.(expr.ContextReader); ok {
+		return reader, true
+	}
+	reader, ok := msg.Body().(expr.ContextReader)
+	return reader, ok
+}
+
+// MessageTs returns the event time of a message, if it has one
+func MessageTs(msg Message) (time.Time, bool) {
+	if tsMsg, ok := msg.(interface {
+		Ts() time.Time
+	}); ok {
+		if ts := tsMsg.Ts(); !ts.IsZero() {
+			return ts, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// MessageLess orders messages for time based tasks, by event time
+//  when both have one, otherwise falls back to arrival order, Id()
+func MessageLess(a, b Message) bool {
+	ats, aok := MessageTs(a)
+	bts, bok := MessageTs(b)
+	if aok && bok && !ats.Equal(bts) {
+		return ats.Before(bts)
+	}
+	return a.Id() < b.Id()
+}
+
 type SqlDriverMessage struct {
 	Vals  []driver.Value
 	IdVal uint64
@@ -57,6 +99,7 @@ type SqlDriverMessageMap struct {
 	colindex map[string]int // Map of column names to ordinal position in row
 	IdVal    uint64         // id()
 	keyVal   string         // key   Non Hashed Key Value
+	ts       time.Time      // optional event time, see SetTs(), SetTsCol()
 }
 
 func NewSqlDriverMessageMapEmpty() *SqlDriverMessageMap {
@@ -65,6 +108,18 @@ func NewSqlDriverMessageMapEmpty() *SqlDriverMessageMap {
 func NewSqlDriverMessageMap(id uint64, row []driver.Value, colindex map[string]int) *SqlDriverMessageMap {
 	return &SqlDriverMessageMap{IdVal: id, colindex: colindex, row: row}
 }
+
+// NewSqlDriverMessageMapChecked is NewSqlDriverMessageMap() but errors if
+//  the row does not have a value at each position of the column index (see
+//  Validate()), catching a mismatched row where it is created rather than
+//  as missing values when it is read
+func NewSqlDriverMessageMapChecked(id uint64, row []driver.Value, colindex map[string]int) (*SqlDriverMessageMap, error) {
+	m := NewSqlDriverMessageMap(id, row, colindex)
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
 func NewSqlDriverMessageMapVals(id uint64, row []driver.Value, cols []string) *SqlDriverMessageMap {
 	if len(row) != len(cols) {
 		u.Errorf("Wrong row/col count: %v  vs %v", cols, row)
@@ -76,6 +131,17 @@ func NewSqlDriverMessageMapVals(id uint64, row []driver.Value, cols []string) *S
 	return &SqlDriverMessageMap{IdVal: id, colindex: colindex, row: row}
 }
 
+// Create a message from typed values, the value.Value's are stored directly
+//  without unwrapping so Get()/Row() return the same typed values.  Note
+//  that Values() will then return the value.Value's not native go types.
+func NewSqlDriverMessageMapFromValues(id uint64, row []value.Value, colindex map[string]int) *SqlDriverMessageMap {
+	vals := make([]driver.Value, len(row))
+	for i, v := range row {
+		vals[i] = v
+	}
+	return &SqlDriverMessageMap{IdVal: id, colindex: colindex, row: vals}
+}
+
 func (m *SqlDriverMessageMap) Id() uint64        { return m.IdVal }
 func (m *SqlDriverMessageMap) Key() string       { return m.keyVal }
 func (m *SqlDriverMessageMap) SetKey(key string) { m.keyVal = key }
@@ -91,7 +157,39 @@ func (m *SqlDriverMessageMap) SetKeyHashed(key string) {
 func (m *SqlDriverMessageMap) Body() interface{}         { return m }
 func (m *SqlDriverMessageMap) Values() []driver.Value    { return m.row }
 func (m *SqlDriverMessageMap) SetRow(row []driver.Value) { m.row = row }
-func (m *SqlDriverMessageMap) Ts() time.Time             { return time.Time{} }
+func (m *SqlDriverMessageMap) Ts() time.Time             { return m.ts }
+func (m *SqlDriverMessageMap) SetTs(ts time.Time)        { m.ts = ts }
+
+// SetTsCol sets the event time of this message from the value of the given
+//  time column, returns false if the column is missing or not a time
+func (m *SqlDriverMessageMap) SetTsCol(col string) bool {
+	idx, ok := m.colindex[col]
+	if !ok || idx >= len(m.row) {
+		return false
+	}
+	switch v := value.NewValue(m.row[idx]).(type) {
+	case value.TimeValue:
+		m.ts = v.Val()
+		return true
+	case value.StringValue:
+		if ts, ok := value.ParseTime(v.Val()); ok {
+			m.ts = ts
+			return true
+		}
+	}
+	return false
+}
+
+// Validate errors if a column of the index is at a position outside of
+//  the row values
+func (m *SqlDriverMessageMap) Validate() error {
+	for col, idx := range m.colindex {
+		if idx < 0 || idx >= len(m.row) {
+			return fmt.Errorf("Column %q at position %d but row has %d values", col, idx, len(m.row))
+		}
+	}
+	return nil
+}
 func (m *SqlDriverMessageMap) Get(key string) (value.Value, bool) {
 	if idx, ok := m.colindex[key]; ok {
 		return value.NewValue(m.row[idx]), true
@@ -112,9 +210,188 @@ func (m *SqlDriverMessageMap) Copy() *SqlDriverMessageMap {
 	nm.colindex = m.colindex
 	nm.IdVal = m.IdVal
 	nm.keyVal = m.keyVal
+	nm.ts = m.ts
 	return &nm
 }
 
+// Reindex creates a new message with values re-positioned for a new column
+//  index, each col with a ParentIndex >= 0 is read from its SourceIndex in
+//  this message and placed at its ParentIndex (see expr.SqlSource.Rewrite()).
+//  Positions not filled by a col, or filled by a col without a valid
+//  SourceIndex, are nil.  Id, key and ts are kept.
+func (m *SqlDriverMessageMap) Reindex(newColIndex map[string]int, cols []*expr.Column) *SqlDriverMessageMap {
+	row := make([]driver.Value, len(newColIndex))
+	for _, col := range cols {
+		if col.ParentIndex < 0 || col.ParentIndex >= len(row) ||
+			col.SourceIndex < 0 || col.SourceIndex >= len(m.row) {
+			continue
+		}
+		row[col.ParentIndex] = m.row[col.SourceIndex]
+	}
+	nm := m.Copy()
+	nm.row = row
+	nm.colindex = newColIndex
+	return nm
+}
+
+// the json wire format of a SqlDriverMessageMap, each row value carries
+//  its value.ValueType so that it can be re-created as same type
+type sqlDriverMessageMapJson struct {
+	Id   uint64           `json:"id"`
+	Key  string           `json:"key,omitempty"`
+	Cols map[string]int   `json:"cols"`
+	Row  []typedValueJson `json:"row"`
+	Ts   *time.Time       `json:"ts,omitempty"`
+}
+type typedValueJson struct {
+	Type value.ValueType `json:"t"`
+	Val  json.RawMessage `json:"v,omitempty"`
+}
+
+// MarshalJSON serializes the full message including column index and key
+//  so it can be shipped to another process and re-created with UnmarshalJSON
+func (m *SqlDriverMessageMap) MarshalJSON() ([]byte, error) {
+	mj := sqlDriverMessageMapJson{
+		Id:   m.IdVal,
+		Key:  m.keyVal,
+		Cols: m.colindex,
+		Row:  make([]typedValueJson, len(m.row)),
+	}
+	if !m.ts.IsZero() {
+		mj.Ts = &m.ts
+	}
+	for i, dv := range m.row {
+		v := value.NewValue(dv)
+		tv := typedValueJson{Type: v.Type()}
+		switch vt := v.(type) {
+		case value.NilValue:
+			// no payload, NilValue.MarshalJSON() is not valid json
+		case value.IntValue:
+			// IntValue marshals as float, losing precision on large ints
+			by, err := json.Marshal(vt.Val())
+			if err != nil {
+				return nil, err
+			}
+			tv.Val = by
+		default:
+			by, err := json.Marshal(v)
+			if err != nil {
+				return nil, err
+			}
+			tv.Val = by
+		}
+		mj.Row[i] = tv
+	}
+	return json.Marshal(&mj)
+}
+
+// UnmarshalJSON re-creates a message serialized with MarshalJSON
+func (m *SqlDriverMessageMap) UnmarshalJSON(data []byte) error {
+	mj := sqlDriverMessageMapJson{}
+	if err := json.Unmarshal(data, &mj); err != nil {
+		return err
+	}
+	row := make([]driver.Value, len(mj.Row))
+	for i, tv := range mj.Row {
+		dv, err := driverValueFromJson(tv)
+		if err != nil {
+			return err
+		}
+		row[i] = dv
+	}
+	m.IdVal = mj.Id
+	m.keyVal = mj.Key
+	m.colindex = mj.Cols
+	m.row = row
+	if mj.Ts != nil {
+		m.ts = *mj.Ts
+	}
+	return nil
+}
+
+func driverValueFromJson(tv typedValueJson) (driver.Value, error) {
+	if len(tv.Val) == 0 || tv.Type == value.NilType {
+		return nil, nil
+	}
+	var err error
+	switch tv.Type {
+	case value.NumberType:
+		var f float64
+		if err = json.Unmarshal(tv.Val, &f); err == nil {
+			return f, nil
+		}
+		// NaN, +Inf, -Inf are marshaled as strings
+		var s string
+		if err = json.Unmarshal(tv.Val, &s); err == nil {
+			return strconv.ParseFloat(s, 64)
+		}
+	case value.IntType:
+		var i int64
+		if err = json.Unmarshal(tv.Val, &i); err == nil {
+			return i, nil
+		}
+	case value.BoolType:
+		var b bool
+		if err = json.Unmarshal(tv.Val, &b); err == nil {
+			return b, nil
+		}
+	case value.TimeType:
+		var t time.Time
+		if err = json.Unmarshal(tv.Val, &t); err == nil {
+			return t, nil
+		}
+	case value.DurationType:
+		var s string
+		if err = json.Unmarshal(tv.Val, &s); err == nil {
+			return time.ParseDuration(s)
+		}
+	case value.StringType:
+		var s string
+		if err = json.Unmarshal(tv.Val, &s); err == nil {
+			return s, nil
+		}
+	case value.ErrorType:
+		// there is no go type for an error value, keep the value.Value
+		var s string
+		if err = json.Unmarshal(tv.Val, &s); err == nil {
+			return value.NewErrorValue(s), nil
+		}
+	case value.ByteSliceType:
+		var by []byte
+		if err = json.Unmarshal(tv.Val, &by); err == nil {
+			return by, nil
+		}
+	case value.StringsType:
+		var sl []string
+		if err = json.Unmarshal(tv.Val, &sl); err == nil {
+			return sl, nil
+		}
+	case value.MapIntType:
+		var mi map[string]int64
+		if err = json.Unmarshal(tv.Val, &mi); err == nil {
+			return mi, nil
+		}
+	case value.MapStringType:
+		var ms map[string]string
+		if err = json.Unmarshal(tv.Val, &ms); err == nil {
+			return ms, nil
+		}
+	case value.MapNumberType:
+		var mn map[string]float64
+		if err = json.Unmarshal(tv.Val, &mn); err == nil {
+			return mn, nil
+		}
+	case value.MapBoolType:
+		var mb map[string]bool
+		if err = json.Unmarshal(tv.Val, &mb); err == nil {
+			return mb, nil
+		}
+	default:
+		return nil, fmt.Errorf("Unsupported value type for unmarshal: %s", tv.Type)
+	}
+	return nil, err
+}
+
 type ValueContextWrapper struct {
 	*SqlDriverMessage
 	cols map[string]*expr.Column
@@ -164,6 +441,16 @@ type ContextSimple struct {
 	ts     time.Time
 	cursor int
 	keyval uint64
+	meta   []ColumnMeta
+	metaAt map[string]int // index of meta by key, built on first Put()
+}
+
+// ColumnMeta describes a column written to a ContextSimple by Put(), see
+//  ContextSimple.Meta()
+type ColumnMeta struct {
+	Key  string
+	Type value.ValueType // type of the value written, NilType for NULL
+	Pos  int             // ordinal position, the order written
 }
 
 func NewContextSimple() *ContextSimple {
@@ -176,6 +463,21 @@ func NewContextSimpleTs(data map[string]value.Value, ts time.Time) *ContextSimpl
 	return &ContextSimple{Data: data, ts: ts, cursor: 0}
 }
 
+// Copy with its own Data map, the values themselves are shared
+func (m *ContextSimple) Copy() *ContextSimple {
+	data := make(map[string]value.Value, len(m.Data))
+	for k, v := range m.Data {
+		data[k] = v
+	}
+	meta := append([]ColumnMeta(nil), m.meta...)
+	return &ContextSimple{Data: data, ts: m.ts, cursor: m.cursor, keyval: m.keyval, meta: meta}
+}
+
+// Meta is the type and position of the columns written with Put(), in
+//  order, for schema dependent consumers and tabular rendering.  Values
+//  added directly to Data have no meta.
+func (m *ContextSimple) Meta() []ColumnMeta { return m.meta }
+
 func (m *ContextSimple) All() map[string]value.Value { return m.Data }
 func (m *ContextSimple) Row() map[string]value.Value { return m.Data }
 func (m *ContextSimple) Body() interface{}           { return m }
@@ -189,7 +491,25 @@ func (m ContextSimple) Get(key string) (value.Value, bool) {
 
 func (m *ContextSimple) Put(col expr.SchemaInfo, rctx expr.ContextReader, v value.Value) error {
 	//u.Infof("put context:  %v %T:%v", col.Key(), v, v)
-	m.Data[col.Key()] = v
+	if v == nil {
+		// never store an untyped nil
+		v = value.NilValueVal
+	}
+	key := col.Key()
+	m.Data[key] = v
+	if m.metaAt == nil {
+		m.metaAt = make(map[string]int, len(m.meta)+1)
+		for i, cm := range m.meta {
+			m.metaAt[cm.Key] = i
+		}
+	}
+	if i, ok := m.metaAt[key]; ok {
+		// re-written, ie a computed column replacing a star column
+		m.meta[i].Type = v.Type()
+		return nil
+	}
+	m.metaAt[key] = len(m.meta)
+	m.meta = append(m.meta, ColumnMeta{Key: key, Type: v.Type(), Pos: len(m.meta)})
 	return nil
 }
 func (m *ContextSimple) Commit(rowInfo []expr.SchemaInfo, row expr.RowWriter) error {
@@ -307,3 +627,72 @@ func (n *NestedContextReader) Row() map[string]value.Value {
 func (n *NestedContextReader) Ts() time.Time {
 	return n.ts
 }
+
+// CaseFoldKeys maps the lower case of each key to the key, for case
+//  insensitive lookups, keys that differ only by case (name, Name) are
+//  ambiguous and map to ""
+func CaseFoldKeys(keys []string) map[string]string {
+	folded := make(map[string]string, len(keys))
+	for _, key := range keys {
+		lower := strings.ToLower(key)
+		if _, exists := folded[lower]; exists {
+			folded[lower] = ""
+			continue
+		}
+		folded[lower] = key
+	}
+	return folded
+}
+
+// NewContextCaseInsensitive wraps a reader so that a key not found is
+//  resolved by a case insensitive match of the row keys, as sql
+//  identifiers are case insensitive   SELECT Name  =>  name.  An exact
+//  match always wins, a key matching more than one row key by case is
+//  ambiguous and not resolved, see CaseFoldKeys().
+func NewContextCaseInsensitive(reader expr.ContextReader) *ContextCaseInsensitive {
+	return &ContextCaseInsensitive{ContextReader: reader}
+}
+
+type ContextCaseInsensitive struct {
+	expr.ContextReader
+	keys map[string]string // lower case to row key, built on first miss
+}
+
+func (m *ContextCaseInsensitive) Get(key string) (value.Value, bool) {
+	val, ok := m.ContextReader.Get(key)
+	if ok && val != nil {
+		return val, ok
+	}
+	if m.keys == nil {
+		row := m.ContextReader.Row()
+		keys := make([]string, 0, len(row))
+		for k := range row {
+			keys = append(keys, k)
+		}
+		m.keys = CaseFoldKeys(keys)
+	}
+	if rowKey := m.keys[strings.ToLower(key)]; rowKey != "" && rowKey != key {
+		return m.ContextReader.Get(rowKey)
+	}
+	return val, ok
+}
+
+// NewContextOptions wraps a reader to carry the evaluation options of the
+//  query, see expr.EvalOptions.  Params bound to the reader (if it is an
+//  expr.ParamReader) are still read through the wrapper.
+func NewContextOptions(reader expr.ContextReader, opts *expr.EvalOptions) *ContextOptions {
+	return &ContextOptions{ContextReader: reader, opts: opts}
+}
+
+type ContextOptions struct {
+	expr.ContextReader
+	opts *expr.EvalOptions
+}
+
+func (m *ContextOptions) EvalOptions() *expr.EvalOptions { return m.opts }
+func (m *ContextOptions) Param(n *expr.ParamNode) (value.Value, bool) {
+	if params, ok := m.ContextReader.(expr.ParamReader); ok {
+		return params.Param(n)
+	}
+	return nil, false
+}
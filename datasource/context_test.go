@@ -1,6 +1,9 @@
 package datasource
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
 	"testing"
 	"time"
 
@@ -56,3 +59,229 @@ func checkval(t *testing.T, r expr.ContextReader, key string, expected value.Val
 	assert.T(t, ok)
 	assert.Equalf(t, expected, val, "%s expected: %v  got:%v", key, expected, val)
 }
+
+func TestSqlDriverMessageMapJson(t *testing.T) {
+	ts := time.Date(2015, 7, 4, 12, 30, 0, 0, time.UTC)
+	cols := []string{"name", "age", "price", "ok", "created", "missing", "tags", "big", "elapsed"}
+	row := []driver.Value{"aaron", int64(22), 22.5, true, ts, nil, []string{"a", "b"}, int64(1<<62 + 1), 90 * time.Minute}
+	msg := NewSqlDriverMessageMapVals(12, row, cols)
+	msg.SetKey("aaron")
+
+	by, err := json.Marshal(msg)
+	assert.Tf(t, err == nil, "no error %v", err)
+
+	msg2 := NewSqlDriverMessageMapEmpty()
+	err = json.Unmarshal(by, msg2)
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Tf(t, msg2.Id() == 12, "id %v", msg2.Id())
+	assert.Tf(t, msg2.Key() == "aaron", "key %v", msg2.Key())
+
+	vals := msg2.Values()
+	assert.Tf(t, len(vals) == len(row), "should have same cols %v", vals)
+	assert.Tf(t, vals[0] == "aaron", "%#v", vals[0])
+	assert.Tf(t, vals[1] == int64(22), "%#v", vals[1])
+	assert.Tf(t, vals[2] == float64(22.5), "%#v", vals[2])
+	assert.Tf(t, vals[3] == true, "%#v", vals[3])
+	assert.Tf(t, vals[4].(time.Time).Equal(ts), "%#v", vals[4])
+	assert.Tf(t, vals[5] == nil, "%#v", vals[5])
+	assert.Tf(t, len(vals[6].([]string)) == 2, "%#v", vals[6])
+	assert.Tf(t, vals[7] == int64(1<<62+1), "should not lose precision %#v", vals[7])
+	assert.Tf(t, vals[8] == 90*time.Minute, "%#v", vals[8])
+
+	v, _ := msg2.Get("price")
+	assert.Tf(t, v.Type() == value.NumberType, "%v", v.Type())
+	v, _ = msg2.Get("created")
+	assert.Tf(t, v.Type() == value.TimeType, "%v", v.Type())
+
+	// error values stay errors rather than becoming strings
+	msg = NewSqlDriverMessageMapFromValues(1, []value.Value{value.NewErrorValue("bad"), value.NewStringValue("bad")},
+		map[string]int{"err": 0, "str": 1})
+	by, err = json.Marshal(msg)
+	assert.Tf(t, err == nil, "no error %v", err)
+	msg2 = NewSqlDriverMessageMapEmpty()
+	err = json.Unmarshal(by, msg2)
+	assert.Tf(t, err == nil, "no error %v", err)
+	v, _ = msg2.Get("err")
+	assert.Tf(t, v.Type() == value.ErrorType && v.Err() && v.ToString() == "bad", "%#v", v)
+	v, _ = msg2.Get("str")
+	assert.Tf(t, v.Type() == value.StringType && !v.Err(), "%#v", v)
+}
+
+func TestSqlDriverMessageMapFromValues(t *testing.T) {
+	ts := time.Date(2015, 7, 4, 12, 30, 0, 0, time.UTC)
+	row := []value.Value{
+		value.NewStringValue("aaron"),
+		value.NewIntValue(22),
+		value.NewNumberValue(22.5),
+		value.NewTimeValue(ts),
+		value.NewStringsValue([]string{"a", "b"}),
+	}
+	colindex := map[string]int{"name": 0, "age": 1, "price": 2, "created": 3, "tags": 4}
+	msg := NewSqlDriverMessageMapFromValues(3, row, colindex)
+	assert.Tf(t, msg.Id() == 3, "id %v", msg.Id())
+
+	v, ok := msg.Get("age")
+	assert.T(t, ok)
+	iv, isInt := v.(value.IntValue)
+	assert.Tf(t, isInt, "should be IntValue %T", v)
+	assert.Tf(t, iv.Val() == 22, "%v", iv.Val())
+
+	v, _ = msg.Get("created")
+	tv, isTime := v.(value.TimeValue)
+	assert.Tf(t, isTime, "should be TimeValue %T", v)
+	assert.Tf(t, tv.Val().Equal(ts), "%v", tv.Val())
+
+	r := msg.Row()
+	assert.Tf(t, len(r) == 5, "%v", r)
+	assert.Tf(t, r["price"].Type() == value.NumberType, "%v", r["price"].Type())
+	assert.Tf(t, r["tags"].Type() == value.StringsType, "%v", r["tags"].Type())
+	assert.Tf(t, r["name"].ToString() == "aaron", "%v", r["name"])
+}
+
+func TestSqlDriverMessageMapReindex(t *testing.T) {
+	msg := NewSqlDriverMessageMapVals(7, []driver.Value{"u1", "aaron", "a@b.com"}, []string{"user_id", "name", "email"})
+	msg.SetKey("u1")
+
+	// project name, user_id (reversed) under aliased names, drop email
+	cols := []*expr.Column{
+		{As: "name", SourceIndex: 1, ParentIndex: 0},
+		{As: "user_id", SourceIndex: 0, ParentIndex: 1},
+		{As: "email", SourceIndex: 2, ParentIndex: -1},
+	}
+	out := msg.Reindex(map[string]int{"u.name": 0, "u.user_id": 1}, cols)
+	assert.Tf(t, out.Id() == 7 && out.Key() == "u1", "%v %v", out.Id(), out.Key())
+	assert.Tf(t, len(out.Values()) == 2, "%v", out.Values())
+	v, _ := out.Get("u.name")
+	assert.Tf(t, v.ToString() == "aaron", "%v", v)
+	v, _ = out.Get("u.user_id")
+	assert.Tf(t, v.ToString() == "u1", "%v", v)
+	_, hasEmail := out.Row()["email"]
+	assert.T(t, !hasEmail)
+
+	// a col with no source position, or out of range, is nil not a panic
+	cols = []*expr.Column{
+		{As: "name", SourceIndex: -1, ParentIndex: 0},
+		{As: "user_id", SourceIndex: 9, ParentIndex: 1},
+	}
+	out = msg.Reindex(map[string]int{"name": 0, "user_id": 1}, cols)
+	assert.Tf(t, len(out.Values()) == 2, "%v", out.Values())
+	assert.Tf(t, out.Values()[0] == nil && out.Values()[1] == nil, "%v", out.Values())
+
+	// original is unchanged
+	v, _ = msg.Get("name")
+	assert.Tf(t, v.ToString() == "aaron" && len(msg.Values()) == 3, "%v", msg.Values())
+}
+
+func TestContextSimplePutNil(t *testing.T) {
+	ctx := NewContextSimple()
+	err := ctx.Put(&expr.Column{As: "nada"}, nil, nil)
+	assert.T(t, err == nil)
+	v, ok := ctx.Get("nada")
+	assert.T(t, ok)
+	assert.Tf(t, v != nil && v.Type() == value.NilType, "should store NilValue %#v", v)
+}
+
+func TestContextSimpleMeta(t *testing.T) {
+	ctx := NewContextSimple()
+	ctx.Put(&expr.Column{As: "name"}, nil, value.NewStringValue("aaron"))
+	ctx.Put(&expr.Column{As: "age"}, nil, nil)
+	ctx.Put(&expr.Column{As: "name"}, nil, value.NewIntValue(5))
+	meta := ctx.Meta()
+	assert.Tf(t, len(meta) == 2, "%v", meta)
+	assert.Tf(t, meta[0] == ColumnMeta{"name", value.IntType, 0}, "re-written keeps its position %v", meta[0])
+	assert.Tf(t, meta[1] == ColumnMeta{"age", value.NilType, 1}, "%v", meta[1])
+
+	cp := ctx.Copy()
+	cp.Put(&expr.Column{As: "email"}, nil, value.NewStringValue("a@x.com"))
+	assert.Tf(t, len(cp.Meta()) == 3 && len(ctx.Meta()) == 2, "copy has its own meta %v", cp.Meta())
+	cp.Put(&expr.Column{As: "age"}, nil, value.NewIntValue(30))
+	assert.Tf(t, len(cp.Meta()) == 3 && cp.Meta()[1] == ColumnMeta{"age", value.IntType, 1}, "%v", cp.Meta())
+	assert.Tf(t, ctx.Meta()[1].Type == value.NilType, "%v", ctx.Meta())
+}
+
+func BenchmarkContextSimplePut(b *testing.B) {
+	cols := make([]*expr.Column, 50)
+	for i := range cols {
+		cols[i] = &expr.Column{As: fmt.Sprintf("col%d", i)}
+	}
+	v := value.NewIntValue(1)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ctx := NewContextSimple()
+		for _, col := range cols {
+			ctx.Put(col, nil, v)
+		}
+	}
+}
+
+func TestSqlDriverMessageMapTs(t *testing.T) {
+	ts := time.Date(2015, 7, 4, 12, 0, 0, 0, time.UTC)
+	cols := map[string]int{"id": 0, "created": 1, "updated": 2, "name": 3}
+	m1 := NewSqlDriverMessageMap(1, []driver.Value{"a", ts, "2015-07-05T12:00:00Z", "aaron"}, cols)
+	assert.T(t, m1.Ts().IsZero())
+
+	assert.T(t, m1.SetTsCol("created"))
+	assert.Tf(t, m1.Ts().Equal(ts), "%v", m1.Ts())
+	assert.T(t, m1.SetTsCol("updated"))
+	assert.Tf(t, m1.Ts().Equal(ts.AddDate(0, 0, 1)), "should parse string time %v", m1.Ts())
+	assert.T(t, !m1.SetTsCol("name"))
+	assert.T(t, !m1.SetTsCol("not_a_col"))
+	assert.T(t, m1.Copy().Ts().Equal(m1.Ts()))
+
+	by, err := json.Marshal(m1)
+	assert.Tf(t, err == nil, "no error %v", err)
+	m2 := NewSqlDriverMessageMapEmpty()
+	err = json.Unmarshal(by, m2)
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Tf(t, m2.Ts().Equal(m1.Ts()), "ts should round trip %v", m2.Ts())
+
+	// by event time, or arrival order Id() without
+	m2.SetTs(ts)
+	m2.IdVal = 2
+	assert.T(t, MessageLess(m2, m1))
+	assert.T(t, !MessageLess(m1, m2))
+	m3 := NewSqlDriverMessageMap(3, nil, cols)
+	m4 := NewSqlDriverMessageMap(4, nil, cols)
+	assert.T(t, MessageLess(m3, m4))
+	assert.T(t, MessageLess(m1, m4))
+	_, hasTs := MessageTs(m3)
+	assert.T(t, !hasTs)
+}
+
+func TestSqlDriverMessageMapChecked(t *testing.T) {
+	cols := map[string]int{"user_id": 0, "name": 1, "email": 2}
+	m, err := NewSqlDriverMessageMapChecked(1, []driver.Value{"u1", "bob", "bob@email.com"}, cols)
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.T(t, m.Validate() == nil)
+
+	m, err = NewSqlDriverMessageMapChecked(1, []driver.Value{"u1", "bob"}, cols)
+	assert.Tf(t, err != nil && m == nil, "too few values should error")
+	assert.Tf(t, err.Error() == `Column "email" at position 2 but row has 2 values`, "%v", err)
+
+	assert.T(t, NewSqlDriverMessageMap(1, []driver.Value{"u1"}, cols).Validate() != nil)
+}
+
+func TestContextCaseInsensitive(t *testing.T) {
+	row := NewContextSimpleData(map[string]value.Value{
+		"name":  value.NewStringValue("lower"),
+		"Name":  value.NewStringValue("title"),
+		"email": value.NewStringValue("bob@email.com"),
+	})
+	ctx := NewContextCaseInsensitive(row)
+	v, _ := ctx.Get("EMAIL")
+	assert.Tf(t, v != nil && v.ToString() == "bob@email.com", "%v", v)
+
+	// exact matches win, columns differing only by case are ambiguous
+	v, _ = ctx.Get("name")
+	assert.Tf(t, v.ToString() == "lower", "%v", v)
+	v, _ = ctx.Get("Name")
+	assert.Tf(t, v.ToString() == "title", "%v", v)
+	v, _ = ctx.Get("NAME")
+	assert.Tf(t, v == nil, "ambiguous should not resolve %v", v)
+	v, _ = ctx.Get("not_a_col")
+	assert.T(t, v == nil)
+
+	v, _ = row.Get("EMAIL")
+	assert.T(t, v == nil)
+}
@@ -153,6 +153,33 @@ type Projection interface {
 	Projection() (*expr.Projection, error)
 }
 
+// Pushdown is the part of a select offered to a source to do itself,
+//  instead of in-process Where/Projection/Limit tasks
+type Pushdown struct {
+	Where   expr.Node    // where filter, nil if none
+	Columns expr.Columns // projected columns
+	Limit   int          // 0 if no limit
+}
+
+// PushdownResult reports which parts of a Pushdown a source handled
+type PushdownResult struct {
+	Where      bool
+	Projection bool
+	Limit      bool
+}
+
+// Pushdownable sources (ie sql db's) can accept a filter, projection,
+//  limit and do the work themselves, returning which parts they handled
+//  so the planner only adds tasks for the un-handled remainder
+//   - Limit applies after Where, so a source must not handle the Limit
+//     unless it also handles the Where
+//   - the Where may reference un-projected columns, so a source must not
+//     handle the Projection unless it also handles the Where
+//
+type Pushdownable interface {
+	Pushdown(p *Pushdown) (*PushdownResult, error)
+}
+
 // SourceMutation, is a statefull connetion similar to Open() connection for select
 //  - accepts the tble used in this upsert/insert/update
 //
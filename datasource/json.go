@@ -0,0 +1,155 @@
+package datasource
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"io"
+	"os"
+	"sort"
+
+	u "github.com/araddon/gou"
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/value"
+)
+
+var (
+	_ DataSource = (*JsonDataSource)(nil)
+	_ SourceConn = (*JsonDataSource)(nil)
+	_ Scanner    = (*JsonDataSource)(nil)
+)
+
+// Json DataSource, scans newline delimited json (ndjson), one object per
+//  line, emitting SqlDriverMessageMap's of typed values, see
+//  value.NewValueJSON()
+//   - forward only single pass, same as CsvDataSource
+//   - can open a file with .Open()
+//   - without configured columns the columns are the union of keys seen
+//     so far, in order first seen
+//   - ragged rows, keys missing from a row are NULL
+//   - lines that are not a json object are dropped
+type JsonDataSource struct {
+	table    string
+	exit     <-chan bool
+	r        *bufio.Reader
+	rowct    uint64
+	fixed    bool // columns are configured, other keys are ignored
+	headers  []string
+	colindex map[string]int
+	rc       io.ReadCloser
+}
+
+// Json source whose columns are the union of keys read
+func NewJsonSource(table string, ior io.Reader, exit <-chan bool) (*JsonDataSource, error) {
+	return NewJsonSourceColumns(table, nil, ior, exit)
+}
+
+// Json source with a configured set of columns, keys of other names are
+//  ignored.  If cols is empty the columns are the union of keys read.
+func NewJsonSourceColumns(table string, cols []string, ior io.Reader, exit <-chan bool) (*JsonDataSource, error) {
+	m := JsonDataSource{table: table, exit: exit, fixed: len(cols) > 0}
+	if rc, ok := ior.(io.ReadCloser); ok {
+		m.rc = rc
+	}
+	m.r = bufio.NewReader(ior)
+	m.headers = append([]string(nil), cols...)
+	m.colindex = make(map[string]int, len(cols))
+	for i, key := range cols {
+		m.colindex[key] = i
+	}
+	return &m, nil
+}
+
+func (m *JsonDataSource) Tables() []string                         { return []string{m.table} }
+func (m *JsonDataSource) Columns() []string                        { return m.headers }
+func (m *JsonDataSource) CreateIterator(filter expr.Node) Iterator { return m }
+
+func (m *JsonDataSource) Open(connInfo string) (SourceConn, error) {
+	if connInfo == "stdio" || connInfo == "stdin" {
+		connInfo = "/dev/stdin"
+	}
+	f, err := os.Open(connInfo)
+	if err != nil {
+		return nil, err
+	}
+	exit := make(<-chan bool, 1)
+	return NewJsonSource(connInfo, f, exit)
+}
+
+func (m *JsonDataSource) Close() error {
+	if m.rc != nil {
+		m.rc.Close()
+	}
+	return nil
+}
+
+func (m *JsonDataSource) MesgChan(filter expr.Node) <-chan Message {
+	iter := m.CreateIterator(filter)
+	return SourceIterChannel(iter, filter, m.exit)
+}
+
+func (m *JsonDataSource) Next() Message {
+	for {
+		select {
+		case <-m.exit:
+			return nil
+		default:
+		}
+		line, err := m.r.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			u.Warnf("could not read json line %v", err)
+			return nil
+		}
+		if len(bytes.TrimSpace(line)) == 0 {
+			if err == io.EOF {
+				return nil
+			}
+			continue
+		}
+		row := make(map[string]interface{})
+		dec := json.NewDecoder(bytes.NewReader(line))
+		dec.UseNumber()
+		if jerr := dec.Decode(&row); jerr != nil {
+			u.Warnf("dropping invalid json line %v: %s", jerr, line)
+			if err == io.EOF {
+				return nil
+			}
+			continue
+		}
+		m.rowct++
+		return m.message(row)
+	}
+}
+
+func (m *JsonDataSource) message(row map[string]interface{}) *SqlDriverMessageMap {
+	if !m.fixed {
+		newKeys := make([]string, 0)
+		for key := range row {
+			if _, ok := m.colindex[key]; !ok {
+				newKeys = append(newKeys, key)
+			}
+		}
+		if len(newKeys) > 0 {
+			// earlier messages share the old index, so copy rather than
+			//  add to it
+			sort.Strings(newKeys)
+			colindex := make(map[string]int, len(m.colindex)+len(newKeys))
+			for key, i := range m.colindex {
+				colindex[key] = i
+			}
+			for _, key := range newKeys {
+				colindex[key] = len(m.headers)
+				m.headers = append(m.headers, key)
+			}
+			m.colindex = colindex
+		}
+	}
+	vals := make([]driver.Value, len(m.headers))
+	for key, jv := range row {
+		if i, ok := m.colindex[key]; ok {
+			vals[i] = value.NewValueJSON(jv)
+		}
+	}
+	return NewSqlDriverMessageMap(m.rowct, vals, m.colindex)
+}
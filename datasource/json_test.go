@@ -0,0 +1,55 @@
+package datasource
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bmizerany/assert"
+
+	"github.com/araddon/qlbridge/value"
+)
+
+var testJsonLines = `{"user_id": 1, "name": "aaron", "score": 4.5, "tags": ["a", "b"]}
+
+{"user_id": 2, "name": "bob", "active": true}
+not json
+{"user_id": 3, "score": 5.0, "meta": {"level": 7}}
+{"user_id": 4}`
+
+func TestJsonDatasource(t *testing.T) {
+	src, err := NewJsonSource("users", strings.NewReader(testJsonLines), make(<-chan bool, 1))
+	assert.Tf(t, err == nil, "%v", err)
+
+	msgs := make([]*SqlDriverMessageMap, 0)
+	iter := src.CreateIterator(nil)
+	for msg := iter.Next(); msg != nil; msg = iter.Next() {
+		msgs = append(msgs, msg.(*SqlDriverMessageMap))
+	}
+	assert.Tf(t, len(msgs) == 4, "blank and invalid lines dropped %d", len(msgs))
+	cols := strings.Join(src.Columns(), ",")
+	assert.Tf(t, cols == "name,score,tags,user_id,active,meta", "union of keys in order seen %v", cols)
+
+	v, _ := msgs[0].Get("user_id")
+	assert.Tf(t, v.Type() == value.IntType, "whole numbers are ints %v", v.Type())
+	v, _ = msgs[0].Get("score")
+	assert.Tf(t, v.Type() == value.NumberType && v.Value() == 4.5, "%v", v)
+	v, _ = msgs[2].Get("score")
+	assert.Tf(t, v.Type() == value.NumberType, "5.0 stays a number %v", v.Type())
+	v, _ = msgs[0].Get("tags")
+	assert.Tf(t, v.Type() == value.SliceValueType, "%v", v.Type())
+	v, _ = msgs[2].Get("meta")
+	assert.Tf(t, v.Type() == value.MapValueType, "%v", v.Type())
+
+	// ragged rows, missing keys are NULL
+	v, _ = msgs[0].Get("active")
+	assert.Tf(t, value.IsNull(v), "key seen later is null %v", v)
+	v, _ = msgs[3].Get("name")
+	assert.Tf(t, value.IsNull(v), "missing key is null %v", v)
+	assert.Tf(t, len(msgs[0].Row()) == 4, "earlier message keeps its columns %v", msgs[0].Row())
+
+	// configured columns
+	src, _ = NewJsonSourceColumns("users", []string{"user_id", "name"}, strings.NewReader(testJsonLines), make(<-chan bool, 1))
+	msg := src.Next().(*SqlDriverMessageMap)
+	assert.Tf(t, len(msg.Row()) == 2, "only configured columns %v", msg.Row())
+	assert.Tf(t, strings.Join(src.Columns(), ",") == "user_id,name", "%v", src.Columns())
+}
@@ -0,0 +1,173 @@
+package datasource
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/araddon/qlbridge/expr"
+)
+
+var (
+	_ Scanner  = (*cachedScanner)(nil)
+	_ Iterator = (*cachedIter)(nil)
+)
+
+// ScanCache buffers the messages of bounded size sources scanned within
+//  one query, so that a source referenced more than once (a self join,
+//  repeated sub-queries of the same table) is scanned once and replayed
+//  from memory instead of re-scanned.  Only unfiltered scans are cached.
+//  A source with more than MaxRows messages is not buffered, the first
+//  scan reads through and later scans go to the source again.  A scan
+//  started while the first is being buffered waits for it.
+//
+//  A ScanCache is for the duration of one query, it does not see changes
+//  to the source after the first scan.
+type ScanCache struct {
+	MaxRows int
+	mu      sync.Mutex
+	scans   map[string]*cachedScan
+}
+
+// A cache of scans of sources with at most maxRows messages each
+func NewScanCache(maxRows int) *ScanCache {
+	return &ScanCache{MaxRows: maxRows, scans: make(map[string]*cachedScan)}
+}
+
+// Scanner wraps a scanner of the source named key (ie table name) so
+//  that all unfiltered scans of that key share one buffered scan
+func (m *ScanCache) Scanner(key string, scanner Scanner) Scanner {
+	key = strings.ToLower(key)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	scan, ok := m.scans[key]
+	if !ok {
+		scan = &cachedScan{maxRows: m.MaxRows}
+		m.scans[key] = scan
+	}
+	return &cachedScanner{Scanner: scanner, scan: scan, exit: make(chan bool)}
+}
+
+// Scans is the count of scans of the source named key that were read from
+//  the underlying source instead of the cache
+func (m *ScanCache) Scans(key string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if scan, ok := m.scans[strings.ToLower(key)]; ok {
+		scan.mu.Lock()
+		defer scan.mu.Unlock()
+		return scan.scans
+	}
+	return 0
+}
+
+// the shared buffered messages of one source
+type cachedScan struct {
+	mu      sync.Mutex
+	maxRows int
+	loaded  bool          // msgs holds the complete scan
+	tooBig  bool          // the source has more than maxRows, don't buffer
+	loading chan struct{} // closed when the scan being buffered is done
+	msgs    []Message
+	scans   int
+}
+
+type cachedScanner struct {
+	Scanner
+	scan     *cachedScan
+	exit     chan bool
+	exitOnce sync.Once
+}
+
+func (m *cachedScanner) CreateIterator(filter expr.Node) Iterator {
+	if filter != nil {
+		return m.Scanner.CreateIterator(filter)
+	}
+	scan := m.scan
+	scan.mu.Lock()
+	// another scan is buffering, wait for it rather than holding the lock
+	//  while the source is read
+	for scan.loading != nil {
+		loading := scan.loading
+		scan.mu.Unlock()
+		<-loading
+		scan.mu.Lock()
+	}
+	if scan.loaded {
+		scan.mu.Unlock()
+		return &cachedIter{msgs: scan.msgs}
+	}
+	scan.scans++
+	if scan.tooBig {
+		scan.mu.Unlock()
+		return m.Scanner.CreateIterator(nil)
+	}
+	loading := make(chan struct{})
+	scan.loading = loading
+	scan.mu.Unlock()
+
+	// buffer the first scan, if it turns out to be too big the buffered
+	//  messages are replayed followed by the rest of the scan
+	msgs := make([]Message, 0)
+	loaded, tooBig := false, false
+	defer func() {
+		// also on panic, so the waiting scans go on, neither loaded nor
+		//  too big the next one buffers
+		scan.mu.Lock()
+		switch {
+		case tooBig:
+			scan.tooBig = true
+		case loaded:
+			scan.msgs = msgs
+			scan.loaded = true
+		}
+		scan.loading = nil
+		close(loading)
+		scan.mu.Unlock()
+	}()
+	iter := m.Scanner.CreateIterator(nil)
+	for msg := iter.Next(); msg != nil; msg = iter.Next() {
+		msgs = append(msgs, msg)
+		if len(msgs) > scan.maxRows {
+			tooBig = true
+			return &cachedIter{msgs: msgs, rest: iter}
+		}
+	}
+	loaded = true
+	return &cachedIter{msgs: msgs}
+}
+
+func (m *cachedScanner) MesgChan(filter expr.Node) <-chan Message {
+	if filter != nil {
+		return m.Scanner.MesgChan(filter)
+	}
+	return SourceIterChannel(m.CreateIterator(nil), nil, m.exit)
+}
+
+// Close stops the MesgChan() of this scanner, and closes the source
+func (m *cachedScanner) Close() error {
+	m.exitOnce.Do(func() { close(m.exit) })
+	return m.Scanner.Close()
+}
+
+// iterate buffered messages, then the rest of a scan if any
+type cachedIter struct {
+	msgs []Message
+	pos  int
+	rest Iterator
+}
+
+func (m *cachedIter) Next() Message {
+	if m.pos < len(m.msgs) {
+		msg := m.msgs[m.pos]
+		m.pos++
+		// each scan gets its own message, they are shared by scans
+		if mm, ok := msg.(*SqlDriverMessageMap); ok {
+			return mm.Copy()
+		}
+		return msg
+	}
+	if m.rest != nil {
+		return m.rest.Next()
+	}
+	return nil
+}
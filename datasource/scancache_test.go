@@ -0,0 +1,134 @@
+package datasource
+
+import (
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+
+	"github.com/araddon/qlbridge/expr"
+)
+
+// a scanner of rows counting its scans
+type countingScanner struct {
+	rows  int
+	scans int
+}
+
+type countingIter struct {
+	pos, rows int
+}
+
+func (m *countingIter) Next() Message {
+	if m.pos >= m.rows {
+		return nil
+	}
+	m.pos++
+	return NewSqlDriverMessageMap(uint64(m.pos), []driver.Value{m.pos}, map[string]int{"id": 0})
+}
+
+func (m *countingScanner) Columns() []string { return []string{"id"} }
+func (m *countingScanner) Close() error      { return nil }
+func (m *countingScanner) CreateIterator(filter expr.Node) Iterator {
+	m.scans++
+	return &countingIter{rows: m.rows}
+}
+func (m *countingScanner) MesgChan(filter expr.Node) <-chan Message {
+	return SourceIterChannel(m.CreateIterator(filter), filter, make(chan bool))
+}
+
+func scanCount(iter Iterator) int {
+	ct := 0
+	for msg := iter.Next(); msg != nil; msg = iter.Next() {
+		ct++
+	}
+	return ct
+}
+
+func TestScanCache(t *testing.T) {
+	cache := NewScanCache(10)
+	src := &countingScanner{rows: 5}
+	s1, s2 := cache.Scanner("users", src), cache.Scanner("USERS", src)
+	assert.T(t, scanCount(s1.CreateIterator(nil)) == 5)
+	assert.T(t, scanCount(s2.CreateIterator(nil)) == 5)
+	assert.T(t, scanCount(s1.CreateIterator(nil)) == 5)
+	assert.Tf(t, src.scans == 1, "scanned once %d", src.scans)
+	assert.T(t, cache.Scans("users") == 1)
+
+	// replayed messages are not shared
+	m1 := s1.CreateIterator(nil).Next().(*SqlDriverMessageMap)
+	m2 := s2.CreateIterator(nil).Next().(*SqlDriverMessageMap)
+	assert.T(t, m1 != m2 && m1.Id() == m2.Id())
+
+	// filtered scans are not cached
+	s1.CreateIterator(&expr.IdentityNode{Text: "id"})
+	assert.T(t, src.scans == 2)
+
+	// sources larger than MaxRows are read through and re-scanned
+	big := &countingScanner{rows: 25}
+	b1 := cache.Scanner("orders", big)
+	assert.T(t, scanCount(b1.CreateIterator(nil)) == 25)
+	assert.T(t, scanCount(b1.CreateIterator(nil)) == 25)
+	assert.Tf(t, big.scans == 2, "not cached %d", big.scans)
+}
+
+// a scanner whose first message is held until release is closed
+type blockingScanner struct {
+	countingScanner
+	release chan bool
+}
+
+type blockingIter struct {
+	Iterator
+	release chan bool
+}
+
+func (m *blockingIter) Next() Message {
+	<-m.release
+	return m.Iterator.Next()
+}
+
+func (m *blockingScanner) CreateIterator(filter expr.Node) Iterator {
+	return &blockingIter{m.countingScanner.CreateIterator(filter), m.release}
+}
+
+func TestScanCacheConcurrent(t *testing.T) {
+	cache := NewScanCache(10)
+	src := &blockingScanner{countingScanner{rows: 5}, make(chan bool)}
+	s1, s2 := cache.Scanner("users", src), cache.Scanner("users", src)
+	counts := make(chan int, 2)
+	go func() { counts <- scanCount(s1.CreateIterator(nil)) }()
+	time.Sleep(time.Millisecond * 10)
+
+	// the cache is not locked while the first scan is buffered
+	scans := make(chan int)
+	go func() { scans <- cache.Scans("users") }()
+	select {
+	case ct := <-scans:
+		assert.Tf(t, ct == 1, "%d", ct)
+	case <-time.After(time.Second):
+		t.Fatalf("cache locked while buffering")
+	}
+
+	// the second scan waits for the first, then replays it
+	go func() { counts <- scanCount(s2.CreateIterator(nil)) }()
+	close(src.release)
+	assert.T(t, <-counts == 5 && <-counts == 5)
+	assert.Tf(t, src.scans == 1, "scanned once %d", src.scans)
+}
+
+func TestScanCacheMesgChanClose(t *testing.T) {
+	cache := NewScanCache(10)
+	src := &countingScanner{rows: 500}
+	s1 := cache.Scanner("users", src)
+	ch := s1.MesgChan(nil)
+	<-ch
+	assert.T(t, s1.Close() == nil)
+	time.Sleep(time.Millisecond * 10)
+	ct := 1
+	for range ch {
+		ct++
+	}
+	assert.Tf(t, ct < 500, "stopped on close %d", ct)
+}
@@ -3,8 +3,11 @@ package datasource
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	u "github.com/araddon/gou"
+
+	"github.com/araddon/qlbridge/expr"
 )
 
 // Open a datasource
@@ -29,6 +32,21 @@ type RuntimeSchema struct {
 	connInfo       string       // db.driver only allows one connection, this is default
 	db             string       // db.driver only allows one db, this is default
 	DisableRecover bool         // If disableRecover=true, we will not capture/suppress panics
+	// CaseInsensitive resolves column names of projections and joins
+	//  that differ only by case from the source columns, see
+	//  NewContextCaseInsensitive()
+	CaseInsensitive bool
+	// NumericStrings compares two strings that both parse as numbers
+	//  numerically in expressions, see expr.EvalOptions
+	NumericStrings bool
+	// StrictDivision makes divide or modulus by zero in expressions an
+	//  error rather than NULL, see expr.EvalOptions
+	StrictDivision bool
+	// TimeTolerance if > 0 joins on time values that differ by at most
+	//  this much, ie "join on timestamp within 1 second", and compares
+	//  times in expressions (==, !=) with this tolerance, see
+	//  value.TimeEqualWithin()
+	TimeTolerance time.Duration
 }
 
 func NewRuntimeSchema() *RuntimeSchema {
@@ -38,6 +56,19 @@ func NewRuntimeSchema() *RuntimeSchema {
 	return c
 }
 
+// EvalOptions are the options for evaluating the expressions of queries
+//  against this schema, nil if they are all the defaults
+func (m *RuntimeSchema) EvalOptions() *expr.EvalOptions {
+	if !m.NumericStrings && !m.StrictDivision && m.TimeTolerance == 0 {
+		return nil
+	}
+	return &expr.EvalOptions{
+		NumericStrings: m.NumericStrings,
+		TimeTolerance:  m.TimeTolerance,
+		StrictDivision: m.StrictDivision,
+	}
+}
+
 // Our RunTime configuration possibly only supports a single schema/connection
 // info.  for example, the sql/driver interface, so will be set here.
 //
@@ -133,6 +164,16 @@ func newDataSources() *DataSources {
 	}
 }
 
+// NewDataSources creates a set of sources separate from the global
+//  registry, for a RuntimeSchema limited to only these sources
+func NewDataSources(srcs map[string]DataSource) *DataSources {
+	m := newDataSources()
+	for name, src := range srcs {
+		m.sources[strings.ToLower(name)] = src
+	}
+	return m
+}
+
 func (m *DataSources) Get(sourceType string) *DataSourceFeatures {
 	if source, ok := m.sources[strings.ToLower(sourceType)]; ok {
 		//u.Debugf("found source: %v", sourceType)
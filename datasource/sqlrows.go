@@ -0,0 +1,191 @@
+package datasource
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	u "github.com/araddon/gou"
+
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/value"
+)
+
+var (
+	_ DataSource = (*SqlRowsSource)(nil)
+	_ SourceConn = (*SqlRowsSource)(nil)
+	_ Scanner    = (*SqlRowsSource)(nil)
+
+	timeType     = reflect.TypeOf(time.Time{})
+	byteType     = reflect.TypeOf([]byte(nil))
+	rawBytesType = reflect.TypeOf(sql.RawBytes(nil))
+)
+
+// SqlRowsSource wraps a database/sql *sql.Rows result set so it can be
+//  used as the source of a qlbridge query
+//   - forward only single pass, same as the underlying rows
+//   - uses ColumnTypes() ScanType to decide the value.ValueType of
+//     each column, and coerces scanned values to that type
+//   - NULL values are emitted as nil and read as value.NilValue
+type SqlRowsSource struct {
+	table    string
+	rows     *sql.Rows
+	exit     chan bool // closed by Close() to stop iterating
+	exitOnce sync.Once
+	rowct    uint64
+	cols     []string
+	types    []value.ValueType
+	colindex map[string]int
+}
+
+// Create a source from *sql.Rows, reading the column names and types
+func NewSqlRowsSource(table string, rows *sql.Rows) (*SqlRowsSource, error) {
+	colTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+	m := SqlRowsSource{
+		table:    table,
+		rows:     rows,
+		exit:     make(chan bool),
+		cols:     make([]string, len(colTypes)),
+		types:    make([]value.ValueType, len(colTypes)),
+		colindex: make(map[string]int, len(colTypes)),
+	}
+	for i, ct := range colTypes {
+		m.cols[i] = ct.Name()
+		m.types[i] = ValueTypeFromScanType(ct.ScanType())
+		m.colindex[ct.Name()] = i
+	}
+	return &m, nil
+}
+
+func (m *SqlRowsSource) Tables() []string                         { return []string{m.table} }
+func (m *SqlRowsSource) Columns() []string                        { return m.cols }
+func (m *SqlRowsSource) ColumnTypes() []value.ValueType           { return m.types }
+func (m *SqlRowsSource) CreateIterator(filter expr.Node) Iterator { return m }
+
+func (m *SqlRowsSource) Open(connInfo string) (SourceConn, error) {
+	return nil, fmt.Errorf("SqlRowsSource cannot be opened, use NewSqlRowsSource()")
+}
+
+func (m *SqlRowsSource) Close() error {
+	m.exitOnce.Do(func() { close(m.exit) })
+	return m.rows.Close()
+}
+
+func (m *SqlRowsSource) MesgChan(filter expr.Node) <-chan Message {
+	iter := m.CreateIterator(filter)
+	return SourceIterChannel(iter, filter, m.exit)
+}
+
+func (m *SqlRowsSource) Next() Message {
+	select {
+	case <-m.exit:
+		return nil
+	default:
+		if !m.rows.Next() {
+			if err := m.rows.Err(); err != nil {
+				u.Warnf("could not read rows: %v", err)
+			}
+			return nil
+		}
+		scanned := make([]interface{}, len(m.cols))
+		dest := make([]interface{}, len(m.cols))
+		for i := range scanned {
+			dest[i] = &scanned[i]
+		}
+		if err := m.rows.Scan(dest...); err != nil {
+			u.Warnf("could not scan row: %v", err)
+			return nil
+		}
+		m.rowct++
+		vals := make([]driver.Value, len(m.cols))
+		for i, v := range scanned {
+			vals[i] = coerceScanned(v, m.types[i])
+		}
+		return NewSqlDriverMessageMap(m.rowct, vals, m.colindex)
+	}
+}
+
+// ValueTypeFromScanType maps a database/sql ColumnType.ScanType()
+//  to our value type
+func ValueTypeFromScanType(rt reflect.Type) value.ValueType {
+	if rt == nil {
+		return value.UnknownType
+	}
+	switch rt {
+	case timeType:
+		return value.TimeType
+	case reflect.TypeOf(sql.NullString{}), rawBytesType:
+		return value.StringType
+	case reflect.TypeOf(sql.NullInt64{}):
+		return value.IntType
+	case reflect.TypeOf(sql.NullFloat64{}):
+		return value.NumberType
+	case reflect.TypeOf(sql.NullBool{}):
+		return value.BoolType
+	case byteType:
+		return value.ByteSliceType
+	}
+	switch rt.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return value.IntType
+	case reflect.Float32, reflect.Float64:
+		return value.NumberType
+	case reflect.Bool:
+		return value.BoolType
+	case reflect.String:
+		return value.StringType
+	}
+	return value.UnknownType
+}
+
+// coerce a scanned driver value to the column type, drivers often
+//  return []byte for text, or int64 for bools
+func coerceScanned(v interface{}, vt value.ValueType) driver.Value {
+	if v == nil {
+		return nil
+	}
+	if by, isBytes := v.([]byte); isBytes && vt != value.ByteSliceType {
+		v = string(by)
+	}
+	rv := reflect.ValueOf(v)
+	switch vt {
+	case value.IntType:
+		if iv, ok := value.ToInt64(rv); ok {
+			return iv
+		}
+	case value.NumberType:
+		if fv, ok := value.ToFloat64(rv); ok {
+			return fv
+		}
+	case value.BoolType:
+		if bv, ok := value.ToBool(rv); ok {
+			return bv
+		}
+	case value.StringType:
+		if sv, ok := value.ToString(rv); ok {
+			return sv
+		}
+	case value.TimeType:
+		switch tv := v.(type) {
+		case time.Time:
+			return tv
+		case string:
+			if t, ok := value.ParseTime(tv); ok {
+				return t
+			}
+		}
+	case value.ByteSliceType:
+		if by, ok := v.([]byte); ok {
+			// Scan may re-use the buffer
+			return append([]byte(nil), by...)
+		}
+	}
+	return v
+}
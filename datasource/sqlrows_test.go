@@ -0,0 +1,129 @@
+package datasource
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/araddon/qlbridge/value"
+	"github.com/bmizerany/assert"
+)
+
+// a minimal in-memory database/sql driver, returning a fixed result set
+//  with typed columns, the way sqlite/mysql drivers report ScanType()
+func init() {
+	sql.Register("qlbfakerows", &fakeRowsDriver{})
+}
+
+var (
+	fakeRowsTs    = time.Date(2015, 7, 4, 12, 0, 0, 0, time.UTC)
+	fakeRowsCols  = []string{"id", "name", "price", "active", "created"}
+	fakeRowsTypes = []reflect.Type{
+		reflect.TypeOf(sql.NullInt64{}),
+		reflect.TypeOf(sql.RawBytes{}),
+		reflect.TypeOf(float64(0)),
+		reflect.TypeOf(true),
+		reflect.TypeOf(time.Time{}),
+	}
+	fakeRowsData = [][]driver.Value{
+		{int64(1), []byte("aaron"), 22.5, int64(1), fakeRowsTs},
+		{nil, []byte("bob"), nil, int64(0), "2015-07-05"},
+	}
+)
+
+type fakeRowsDriver struct{}
+type fakeRowsConn struct{}
+type fakeRowsStmt struct{}
+type fakeRowsRows struct{ pos int }
+
+func (m *fakeRowsDriver) Open(name string) (driver.Conn, error) { return &fakeRowsConn{}, nil }
+
+func (m *fakeRowsConn) Prepare(query string) (driver.Stmt, error) { return &fakeRowsStmt{}, nil }
+func (m *fakeRowsConn) Close() error                              { return nil }
+func (m *fakeRowsConn) Begin() (driver.Tx, error)                 { return nil, fmt.Errorf("not implemented") }
+
+func (m *fakeRowsStmt) Close() error  { return nil }
+func (m *fakeRowsStmt) NumInput() int { return -1 }
+func (m *fakeRowsStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+func (m *fakeRowsStmt) Query(args []driver.Value) (driver.Rows, error) { return &fakeRowsRows{}, nil }
+
+func (m *fakeRowsRows) Columns() []string                         { return fakeRowsCols }
+func (m *fakeRowsRows) Close() error                              { return nil }
+func (m *fakeRowsRows) ColumnTypeScanType(index int) reflect.Type { return fakeRowsTypes[index] }
+func (m *fakeRowsRows) Next(dest []driver.Value) error {
+	if m.pos >= len(fakeRowsData) {
+		return io.EOF
+	}
+	copy(dest, fakeRowsData[m.pos])
+	m.pos++
+	return nil
+}
+
+func TestSqlRowsSource(t *testing.T) {
+	db, err := sql.Open("qlbfakerows", "")
+	assert.Tf(t, err == nil, "no error %v", err)
+	defer db.Close()
+
+	rows, err := db.Query("select * from fake")
+	assert.Tf(t, err == nil, "no error %v", err)
+
+	src, err := NewSqlRowsSource("fake", rows)
+	assert.Tf(t, err == nil, "no error %v", err)
+	defer src.Close()
+
+	assert.Tf(t, len(src.Columns()) == 5, "%v", src.Columns())
+	types := src.ColumnTypes()
+	assert.Tf(t, types[0] == value.IntType, "%v", types[0])
+	assert.Tf(t, types[1] == value.StringType, "%v", types[1])
+	assert.Tf(t, types[2] == value.NumberType, "%v", types[2])
+	assert.Tf(t, types[3] == value.BoolType, "%v", types[3])
+	assert.Tf(t, types[4] == value.TimeType, "%v", types[4])
+
+	iter := src.CreateIterator(nil)
+	msg := iter.Next()
+	assert.T(t, msg != nil)
+	row := msg.(*SqlDriverMessageMap).Row()
+	assert.Tf(t, row["id"].Value() == int64(1), "%#v", row["id"])
+	assert.Tf(t, row["name"].Value() == "aaron", "%#v", row["name"])
+	assert.Tf(t, row["price"].Value() == float64(22.5), "%#v", row["price"])
+	assert.Tf(t, row["active"].Value() == true, "%#v", row["active"])
+	assert.Tf(t, row["created"].Type() == value.TimeType, "%#v", row["created"])
+
+	msg = iter.Next()
+	assert.T(t, msg != nil)
+	row = msg.(*SqlDriverMessageMap).Row()
+	assert.Tf(t, row["id"].Type() == value.NilType, "null should be nil %#v", row["id"])
+	assert.Tf(t, row["price"].Type() == value.NilType, "null should be nil %#v", row["price"])
+	assert.Tf(t, row["name"].Value() == "bob", "%#v", row["name"])
+	assert.Tf(t, row["active"].Value() == false, "%#v", row["active"])
+	assert.Tf(t, row["created"].Type() == value.TimeType, "should parse string time %#v", row["created"])
+
+	assert.T(t, iter.Next() == nil)
+}
+
+func TestSqlRowsSourceClose(t *testing.T) {
+	db, err := sql.Open("qlbfakerows", "")
+	assert.Tf(t, err == nil, "no error %v", err)
+	defer db.Close()
+
+	rows, err := db.Query("select * from fake")
+	assert.Tf(t, err == nil, "no error %v", err)
+	src, err := NewSqlRowsSource("fake", rows)
+	assert.Tf(t, err == nil, "no error %v", err)
+
+	// Close stops iteration, and may be called again
+	assert.T(t, src.Close() == nil)
+	assert.T(t, src.CreateIterator(nil).Next() == nil)
+	ct := 0
+	for range src.MesgChan(nil) {
+		ct++
+	}
+	assert.Tf(t, ct == 0, "closed source should send nothing %d", ct)
+	src.Close()
+}
@@ -21,11 +21,39 @@ var (
 //   we can create smarter ones but this is a basic implementation for
 ///  running in-process, not distributed
 type JobBuilder struct {
-	schema   *datasource.RuntimeSchema
-	connInfo string
-	where    expr.Node
-	distinct bool
-	children Tasks
+	// BufferSize is the message channel buffer size of the projection
+	//  and join tasks, defaults to ItemDefaultChannelSize
+	BufferSize int
+	// TsColumn is the time column to use as event time of source
+	//  messages, for time ordered tasks, messages without fall back
+	//  to arrival order
+	TsColumn string
+	// ProjectionWorkers is the number of parallel projection workers,
+	//  for cpu bound expressions, <= 1 is a single worker
+	ProjectionWorkers int
+	// JoinMaxMemRows if > 0 uses a hash join (JoinHash) that holds at most
+	//  this many build side messages in memory, spilling to disk
+	JoinMaxMemRows int
+	// GroupByMaxMemGroups if > 0 is the count of groups a GroupBy holds in
+	//  memory, spilling rows of other groups to disk
+	GroupByMaxMemGroups int
+	// ErrorSink if set receives rows that fail projection or join key
+	//  evaluation as *ErrorRecord, instead of them being dropped, see
+	//  TaskBase.ErrorSink
+	ErrorSink MessageChan
+	// ScanCacheRows if > 0 scans each source of at most this many rows
+	//  once per query, replaying it to other references of the same
+	//  source (self joins, repeated sub-queries), see datasource.ScanCache
+	ScanCacheRows int
+
+	schema    *datasource.RuntimeSchema
+	connInfo  string
+	where     expr.Node
+	distinct  bool
+	children  Tasks
+	pushdown  *datasource.Pushdown       // offered to single source selects
+	pushed    *datasource.PushdownResult // what the source handled
+	scanCache *datasource.ScanCache      // created on first use, see ScanCacheRows
 }
 
 // JobBuilder
@@ -33,7 +61,7 @@ type JobBuilder struct {
 //   @connInfo = connection string info for original connection
 //
 func NewJobBuilder(schema *datasource.RuntimeSchema, connInfo string) *JobBuilder {
-	b := JobBuilder{}
+	b := JobBuilder{BufferSize: ItemDefaultChannelSize}
 	b.schema = schema
 	b.connInfo = connInfo
 	return &b
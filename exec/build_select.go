@@ -2,6 +2,7 @@ package exec
 
 import (
 	"fmt"
+	"strings"
 
 	u "github.com/araddon/gou"
 
@@ -19,8 +20,55 @@ func (m *JobBuilder) VisitSelect(stmt *expr.SqlSelect) (expr.Task, error) {
 	*/
 	tasks := make(Tasks, 0)
 
-	if len(stmt.From) == 1 {
+	if len(stmt.From) <= 1 {
+		// a join checks once its stars are expanded, below
+		if err := m.checkAliases(stmt); err != nil {
+			return nil, err
+		}
+	}
+
+	// verify func calls at plan time, not per row
+	for _, col := range stmt.Columns {
+		if err := CheckFuncs(col.Expr); err != nil {
+			return nil, err
+		}
+	}
+	if stmt.Where != nil && stmt.Where.Expr != nil {
+		if err := CheckFuncs(stmt.Where.Expr); err != nil {
+			return nil, err
+		}
+	}
+	if err := CheckFuncs(stmt.Having); err != nil {
+		return nil, err
+	}
+	if m.schema != nil {
+		if errs := ValidateTypes(stmt, m.schema); len(errs) > 0 {
+			return nil, errs[0]
+		}
+	}
+
+	if len(stmt.From) == 0 {
+		// No source, only constant expressions so evaluate once
+		tasks.Add(NewSourceScalar())
+
+	} else if len(stmt.From) == 1 {
+		if stmt.From[0].Source == nil {
+			// offer the where, projection, limit to the source, limit
+			// and projection are after the group by so not offered
+			m.pushdown = &datasource.Pushdown{}
+			if stmt.Where != nil && stmt.Where.Source == nil {
+				m.pushdown.Where = stmt.Where.Expr
+			}
+			if len(stmt.GroupBy) == 0 {
+				m.pushdown.Columns = stmt.Columns
+				if len(stmt.OrderBy) == 0 {
+					// the limit applies after the sort
+					m.pushdown.Limit = stmt.Limit
+				}
+			}
+		}
 		task, err := m.VisitSubselect(stmt.From[0])
+		m.pushdown = nil
 		if err != nil {
 			return nil, err
 		}
@@ -31,6 +79,15 @@ func (m *JobBuilder) VisitSelect(stmt *expr.SqlSelect) (expr.Task, error) {
 		var prevTask TaskRunner
 		var prevFrom *expr.SqlSource
 
+		// qualified stars must be known columns before the rewrite
+		//  splits them out to each source
+		if err := m.expandStars(stmt); err != nil {
+			return nil, err
+		}
+		if err := m.checkAliases(stmt); err != nil {
+			return nil, err
+		}
+
 		for i, from := range stmt.From {
 
 			// Need to rewrite the From statement
@@ -50,7 +107,14 @@ func (m *JobBuilder) VisitSelect(stmt *expr.SqlSelect) (expr.Task, error) {
 				tasks.Add(curMergeTask)
 
 				// fold this source into previous
-				in, err := NewJoinNaiveMerge(prevTask, curTask, prevFrom, from, m.schema)
+				// time tolerant joins match neighbouring keys, which
+				//  a partitioned hash join does not see together
+				var in TaskRunner
+				if m.JoinMaxMemRows > 0 && m.schema.TimeTolerance == 0 {
+					in, err = NewJoinHash(prevTask, curTask, prevFrom, from, m.schema, m.BufferSize, m.JoinMaxMemRows)
+				} else {
+					in, err = NewJoinNaiveMergeSize(prevTask, curTask, prevFrom, from, m.schema, m.BufferSize)
+				}
 				if err != nil {
 					return nil, err
 				}
@@ -62,7 +126,14 @@ func (m *JobBuilder) VisitSelect(stmt *expr.SqlSelect) (expr.Task, error) {
 		}
 	}
 
-	if stmt.Where != nil {
+	pushed := m.pushed
+	m.pushed = nil
+	if pushed == nil {
+		pushed = &datasource.PushdownResult{}
+	}
+
+	evalOpts := m.schema.EvalOptions()
+	if stmt.Where != nil && !pushed.Where {
 		switch {
 		case stmt.Where.Source != nil:
 			u.Warnf("Found un-supported subquery: %#v", stmt.Where)
@@ -70,6 +141,7 @@ func (m *JobBuilder) VisitSelect(stmt *expr.SqlSelect) (expr.Task, error) {
 		case stmt.Where.Expr != nil:
 			//u.Debugf("adding where: %q", stmt.Where.Expr)
 			where := NewWhereFinal(stmt.Where.Expr, stmt)
+			where.EvalOptions = evalOpts
 			tasks.Add(where)
 		default:
 			u.Warnf("Found un-supported where type: %#v", stmt.Where)
@@ -78,15 +150,188 @@ func (m *JobBuilder) VisitSelect(stmt *expr.SqlSelect) (expr.Task, error) {
 
 	}
 
+	if len(stmt.GroupBy) > 0 {
+		// The GroupBy aggregates rows into the final columns, so
+		// it takes the place of the projection
+		groupBy := NewGroupBy(stmt)
+		groupBy.MaxMemGroups = m.GroupByMaxMemGroups
+		groupBy.EvalOptions = evalOpts
+		tasks.Add(groupBy)
+		if stmt.Having != nil {
+			having := NewHaving(stmt.Having, stmt)
+			having.EvalOptions = evalOpts
+			tasks.Add(having)
+		}
+		if len(stmt.OrderBy) > 0 {
+			sortTask := NewSort(stmt)
+			sortTask.EvalOptions = evalOpts
+			tasks.Add(sortTask)
+		}
+		if stmt.Limit > 0 {
+			tasks.Add(NewLimit(stmt.Limit))
+		}
+		return NewSequential("select", tasks), nil
+	}
+
+	// Sort the source rows before the projection, so the ORDER BY may use
+	//  columns that are not selected
+	if len(stmt.OrderBy) > 0 && !pushed.Projection {
+		sortTask := NewSourceSort(stmt)
+		sortTask.EvalOptions = evalOpts
+		tasks.Add(sortTask)
+	}
+
 	// Add a Projection to choose the columns for results
-	projection := NewProjection(stmt)
-	//u.Infof("adding projection: %#v", projection)
-	tasks.Add(projection)
+	if !pushed.Projection {
+		projection := NewProjectionSize(stmt, m.BufferSize)
+		projection.Workers = m.ProjectionWorkers
+		projection.CaseInsensitive = m.schema.CaseInsensitive
+		projection.ErrorSink = m.ErrorSink
+		projection.EvalOptions = evalOpts
+		//u.Infof("adding projection: %#v", projection)
+		tasks.Add(projection)
+	}
+
+	if len(stmt.OrderBy) > 0 && pushed.Projection {
+		sortTask := NewSort(stmt)
+		sortTask.EvalOptions = evalOpts
+		tasks.Add(sortTask)
+	}
+
+	if stmt.Limit > 0 && !pushed.Limit {
+		tasks.Add(NewLimit(stmt.Limit))
+	}
 
 	return NewSequential("select", tasks), nil
 }
 
-func buildColIndex(sourceConn datasource.SourceConn, from *expr.SqlSource) error {
+// Expand qualified star columns   SELECT t1.*, t2.name
+//  into the aliased columns of that source   t1.name, t1.email, t2.name
+//  so sources with overlapping column names don't collide
+func (m *JobBuilder) expandStars(stmt *expr.SqlSelect) error {
+	cols := make(expr.Columns, 0, len(stmt.Columns))
+	for _, col := range stmt.Columns {
+		alias := col.StarAlias()
+		if alias == "" {
+			col.Index = len(cols)
+			cols = append(cols, col)
+			continue
+		}
+		var from *expr.SqlSource
+		for _, f := range stmt.From {
+			if strings.EqualFold(f.Alias, alias) || (f.Alias == "" && strings.EqualFold(f.Name, alias)) {
+				from = f
+				break
+			}
+		}
+		if from == nil {
+			return fmt.Errorf("Unknown source alias for %s", col.As)
+		}
+		names, err := m.sourceColumns(from.Name)
+		if err != nil {
+			return fmt.Errorf("%v to select %s", err, col.As)
+		}
+		for _, name := range names {
+			newCol := expr.NewColumn(alias + "." + name)
+			newCol.Expr = &expr.IdentityNode{Text: newCol.As}
+			newCol.Index = len(cols)
+			cols = append(cols, newCol)
+		}
+	}
+	stmt.Columns = cols
+	return nil
+}
+
+// checkAliases errors on duplicate output columns, output rows are keyed by
+//  alias so a duplicate would silently overwrite the earlier column.  A
+//  select * is the columns of its source, which a computed column may
+//  replace (select *, a + b AS a) but not repeat (select *, a).
+func (m *JobBuilder) checkAliases(stmt *expr.SqlSelect) error {
+	starCols := make(map[string]struct{})
+	for _, col := range stmt.Columns {
+		if !col.Star || col.StarAlias() != "" || m.schema == nil || len(stmt.From) != 1 || stmt.From[0].Source != nil {
+			continue
+		}
+		// a source without column schema errors when it is visited
+		names, _ := m.sourceColumns(stmt.From[0].Name)
+		for _, name := range names {
+			starCols[name] = struct{}{}
+		}
+	}
+	aliases := make(map[string]struct{}, len(stmt.Columns))
+	for _, col := range stmt.Columns {
+		if col.Star {
+			continue
+		}
+		if _, exists := aliases[col.As]; exists {
+			return fmt.Errorf("duplicate column alias %q in select", col.As)
+		}
+		if _, exists := starCols[col.As]; exists {
+			if in, isIdent := col.Expr.(*expr.IdentityNode); isIdent && in.Text == col.As {
+				return fmt.Errorf("duplicate column %q in select, already selected by *", col.As)
+			}
+		}
+		aliases[col.As] = struct{}{}
+	}
+	return nil
+}
+
+// The column names of a source, the connection is only opened to read
+//  them so is closed again
+func (m *JobBuilder) sourceColumns(name string) ([]string, error) {
+	conn := m.schema.Conn(name)
+	if conn == nil {
+		return nil, fmt.Errorf("Table %q not found", name)
+	}
+	defer conn.Close()
+	colSchema, ok := conn.(datasource.SchemaColumns)
+	if !ok {
+		return nil, fmt.Errorf("Must Implement SchemaColumns")
+	}
+	return colSchema.Columns(), nil
+}
+
+// closeConn closes a source connection that is not going to be used
+func closeConn(conn datasource.SourceConn) {
+	if conn != nil {
+		conn.Close()
+	}
+}
+
+// Offer the pending pushdown (if any) to the source, recording which
+//  parts it handled so VisitSelect can skip those tasks
+func (m *JobBuilder) pushdownSource(conn datasource.SourceConn) error {
+	if m.pushdown == nil {
+		return nil
+	}
+	pd, ok := conn.(datasource.Pushdownable)
+	if !ok {
+		return nil
+	}
+	res, err := pd.Pushdown(m.pushdown)
+	if err != nil {
+		return err
+	}
+	if res == nil {
+		return nil
+	}
+	if res.Limit && m.pushdown.Where != nil && !res.Where {
+		return fmt.Errorf("%T cannot handle Limit without handling Where", conn)
+	}
+	// the where may reference columns that are not projected
+	if res.Projection && m.pushdown.Where != nil && !res.Where {
+		return fmt.Errorf("%T cannot handle Projection without handling Where", conn)
+	}
+	// only the parts that were offered can be handled
+	m.pushed = &datasource.PushdownResult{
+		Where:      res.Where && m.pushdown.Where != nil,
+		Projection: res.Projection && len(m.pushdown.Columns) > 0,
+		Limit:      res.Limit && m.pushdown.Limit > 0,
+	}
+	return nil
+}
+
+func buildColIndex(sourceConn datasource.SourceConn, from *expr.SqlSource, caseInsensitive bool) error {
 
 	if from.Source == nil {
 		return nil
@@ -96,10 +341,36 @@ func buildColIndex(sourceConn datasource.SourceConn, from *expr.SqlSource) error
 		u.Errorf("Could not create column Schema for %v  %T %#v", from.Name, sourceConn, sourceConn)
 		return fmt.Errorf("Must Implement SchemaColumns")
 	}
-	from.BuildColIndex(colSchema.Columns())
+	colNames := colSchema.Columns()
+	if caseInsensitive {
+		colNames = caseFoldColumns(colNames, from.Source.Columns)
+	}
+	from.BuildColIndex(colNames)
 	return nil
 }
 
+// caseFoldColumns renames the source column names that match a column key
+//  only by case to that key, so they are found by BuildColIndex()
+func caseFoldColumns(colNames []string, cols expr.Columns) []string {
+	positions := make(map[string]int, len(colNames))
+	for i, name := range colNames {
+		positions[name] = i
+	}
+	folded := datasource.CaseFoldKeys(colNames)
+	names := make([]string, len(colNames))
+	copy(names, colNames)
+	for _, col := range cols {
+		key := col.Key()
+		if _, exact := positions[key]; exact {
+			continue
+		}
+		if name := folded[strings.ToLower(key)]; name != "" {
+			names[positions[name]] = key
+		}
+	}
+	return names
+}
+
 func (m *JobBuilder) VisitSubselect(from *expr.SqlSource) (expr.Task, error) {
 
 	if from.Source != nil {
@@ -133,12 +404,19 @@ func (m *JobBuilder) VisitSubselect(from *expr.SqlSource) (expr.Task, error) {
 
 		scanner, hasScanner := sourceConn.(datasource.Scanner)
 		if !hasScanner {
+			closeConn(sourceConn)
 			return nil, fmt.Errorf("%T Must Implement Scanner for %q", sourceConn, from.String())
 		}
-		if err := buildColIndex(scanner, from); err != nil {
+		if err := buildColIndex(scanner, from, m.schema.CaseInsensitive); err != nil {
+			scanner.Close()
 			return nil, err
 		}
-		sourceTask := NewSource(from, scanner)
+		if err := m.pushdownSource(scanner); err != nil {
+			scanner.Close()
+			return nil, err
+		}
+		sourceTask := NewSource(from, m.cachedScanner(from.Name, scanner))
+		sourceTask.TsColumn = m.TsColumn
 		tasks.Add(sourceTask)
 
 	case from.Source != nil && len(from.JoinNodes()) > 0:
@@ -171,12 +449,15 @@ func (m *JobBuilder) VisitSubselect(from *expr.SqlSource) (expr.Task, error) {
 		scanner, ok := sourceConn.(datasource.Scanner)
 		if !ok {
 			u.Errorf("Could not create scanner for %v  %T %#v", from.Name, sourceConn, sourceConn)
+			closeConn(sourceConn)
 			return nil, fmt.Errorf("Must Implement Scanner")
 		}
-		if err := buildColIndex(scanner, from); err != nil {
+		if err := buildColIndex(scanner, from, m.schema.CaseInsensitive); err != nil {
+			scanner.Close()
 			return nil, err
 		}
-		sourceTask := NewSource(from, scanner)
+		sourceTask := NewSource(from, m.cachedScanner(from.Name, scanner))
+		sourceTask.TsColumn = m.TsColumn
 		tasks.Add(sourceTask)
 
 	default:
@@ -189,6 +470,7 @@ func (m *JobBuilder) VisitSubselect(from *expr.SqlSource) (expr.Task, error) {
 		case from.Source.Where.Expr != nil:
 			//u.Debugf("adding where: %q", from.Source.Where.Expr)
 			where := NewWhereFilter(from.Source.Where.Expr, from.Source)
+			where.EvalOptions = m.schema.EvalOptions()
 			tasks.Add(where)
 		default:
 			u.Warnf("Found un-supported where type: %#v", from.Source)
@@ -201,6 +483,7 @@ func (m *JobBuilder) VisitSubselect(from *expr.SqlSource) (expr.Task, error) {
 		if err != nil {
 			return nil, err
 		}
+		joinKeyTask.ErrorSink = m.ErrorSink
 		tasks.Add(joinKeyTask)
 	}
 	// Plan?   Parallel?  hash?
@@ -230,8 +513,20 @@ func (m *JobBuilder) VisitJoin(from *expr.SqlSource) (expr.Task, error) {
 		u.Errorf("Could not create scanner for %v  %T %#v", from.Name, source, source)
 		return nil, fmt.Errorf("Must Implement Scanner")
 	}
-	if err := buildColIndex(scanner, from); err != nil {
+	if err := buildColIndex(scanner, from, m.schema.CaseInsensitive); err != nil {
 		return nil, err
 	}
-	return NewSourceJoin(from, scanner), nil
+	return NewSourceJoin(from, m.cachedScanner(from.SourceName(), scanner)), nil
+}
+
+// cachedScanner shares one scan of the named source per query if the
+//  ScanCacheRows is set, sources that handled a pushdown are not shared
+func (m *JobBuilder) cachedScanner(name string, scanner datasource.Scanner) datasource.Scanner {
+	if m.ScanCacheRows <= 0 || m.pushed != nil {
+		return scanner
+	}
+	if m.scanCache == nil {
+		m.scanCache = datasource.NewScanCache(m.ScanCacheRows)
+	}
+	return m.scanCache.Scanner(name, scanner)
 }
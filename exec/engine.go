@@ -9,6 +9,7 @@ import (
 
 	"github.com/araddon/qlbridge/datasource"
 	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/value"
 )
 
 var (
@@ -62,6 +63,10 @@ func BuildSqlJob(conf *datasource.RuntimeSchema, connInfo, sqlText string) (*Sql
 	if err != nil {
 		return nil, err
 	}
+	return buildStmtJob(conf, connInfo, stmt)
+}
+
+func buildStmtJob(conf *datasource.RuntimeSchema, connInfo string, stmt expr.SqlStatement) (*SqlJob, error) {
 
 	builder := NewJobBuilder(conf, connInfo)
 	task, err := stmt.Accept(builder)
@@ -70,7 +75,7 @@ func BuildSqlJob(conf *datasource.RuntimeSchema, connInfo, sqlText string) (*Sql
 		return nil, err
 	}
 	if task == nil {
-		return nil, fmt.Errorf("No job runner? %v", sqlText)
+		return nil, fmt.Errorf("No job runner? %v", stmt)
 	}
 	taskRunner, ok := task.(TaskRunner)
 	if !ok {
@@ -79,6 +84,96 @@ func BuildSqlJob(conf *datasource.RuntimeSchema, connInfo, sqlText string) (*Sql
 	return &SqlJob{taskRunner, stmt, conf}, nil
 }
 
+// ExecuteSelect builds and runs a SELECT query, collecting the result
+//  rows as typed values, for embedding without the sql/driver interface.
+//  A nil ctx uses a new context.
+func ExecuteSelect(ctx *expr.Context, sqlText string, conf *datasource.RuntimeSchema) ([]map[string]value.Value, error) {
+
+	job, err := BuildSqlJob(conf, "", sqlText)
+	if err != nil {
+		return nil, err
+	}
+	defer job.Close()
+	if _, ok := job.Stmt.(*expr.SqlSelect); !ok {
+		return nil, fmt.Errorf("ExecuteSelect requires a select statement but got %T", job.Stmt)
+	}
+	return runSelectJob(ctx, job)
+}
+
+// run a select job collecting its result rows
+func runSelectJob(ctx *expr.Context, job *SqlJob) ([]map[string]value.Value, error) {
+
+	msgs := make([]datasource.Message, 0)
+	job.RootTask.Add(NewResultBuffer(&msgs))
+	if err := job.Setup(); err != nil {
+		return nil, err
+	}
+	if ctx == nil {
+		ctx = expr.NewContext()
+		ctx.DisableRecover = job.Conf.DisableRecover
+	}
+	if err := job.RootTask.Run(ctx); err != nil {
+		return nil, err
+	}
+
+	rows := make([]map[string]value.Value, len(msgs))
+	for i, msg := range msgs {
+		reader, ok := datasource.MessageReader(msg)
+		if !ok {
+			return nil, fmt.Errorf("Could not read result message %T", msg)
+		}
+		rows[i] = reader.Row()
+	}
+	return rows, nil
+}
+
+// PreparedQuery is a SELECT with bind params (positional ? or named
+//  :name) that is parsed once and then run with different values bound
+//  to its params.  Each run binds the values into a copy of the parsed
+//  statement before planning, the parsed statement is not changed.
+//
+//     q, err := Prepare(conf, "", "SELECT name FROM users WHERE age > ?")
+//     rows, err := q.Execute(nil, expr.NewParams(value.NewIntValue(21)))
+//
+type PreparedQuery struct {
+	Stmt     *expr.SqlSelect
+	conf     *datasource.RuntimeSchema
+	connInfo string
+}
+
+// Prepare parses a SELECT query with bind params for running later
+func Prepare(conf *datasource.RuntimeSchema, connInfo, sqlText string) (*PreparedQuery, error) {
+	stmt, err := expr.ParseSqlVm(sqlText)
+	if err != nil {
+		return nil, err
+	}
+	sel, ok := stmt.(*expr.SqlSelect)
+	if !ok {
+		return nil, fmt.Errorf("Prepare requires a select statement but got %T", stmt)
+	}
+	return &PreparedQuery{Stmt: sel, conf: conf, connInfo: connInfo}, nil
+}
+
+// Job builds the job for running this query with the given bound params
+func (m *PreparedQuery) Job(params expr.ParamReader) (*SqlJob, error) {
+	stmt, err := m.Stmt.BindParams(params)
+	if err != nil {
+		return nil, err
+	}
+	return buildStmtJob(m.conf, m.connInfo, stmt)
+}
+
+// Execute runs this query with the given bound params, collecting the
+//  result rows as ExecuteSelect().  A nil ctx uses a new context.
+func (m *PreparedQuery) Execute(ctx *expr.Context, params expr.ParamReader) ([]map[string]value.Value, error) {
+	job, err := m.Job(params)
+	if err != nil {
+		return nil, err
+	}
+	defer job.Close()
+	return runSelectJob(ctx, job)
+}
+
 // Create a multiple error type
 type errList []error
 
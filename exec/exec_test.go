@@ -1,8 +1,13 @@
 package exec
 
 import (
+	"bytes"
 	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
 	"flag"
+	"sort"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -13,7 +18,9 @@ import (
 	"github.com/araddon/qlbridge/datasource"
 	"github.com/araddon/qlbridge/datasource/membtree"
 	"github.com/araddon/qlbridge/datasource/mockcsv"
+	"github.com/araddon/qlbridge/expr"
 	"github.com/araddon/qlbridge/expr/builtins"
+	"github.com/araddon/qlbridge/value"
 )
 
 var (
@@ -76,6 +83,452 @@ func TestEngineWhere(t *testing.T) {
 	assert.Tf(t, len(msgs) == 1, "should have filtered out 2 messages %v", len(msgs))
 }
 
+func TestExecuteSelect(t *testing.T) {
+	rows, err := ExecuteSelect(nil, `
+		SELECT user_id, email, referral_count * 2 AS rc
+		FROM users
+		WHERE email = "bob@email.com"`, rtConf)
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Tf(t, len(rows) == 1, "%v", rows)
+	assert.Tf(t, rows[0]["user_id"].ToString() == "hT2impsOPUREcVPc", "%v", rows[0])
+	assert.Tf(t, rows[0]["email"].Type() == value.StringType, "%v", rows[0])
+	assert.Tf(t, rows[0]["rc"].ToString() == "24", "%v", rows[0])
+
+	rows, err = ExecuteSelect(expr.NewContext(), `SELECT user_id FROM users`, rtConf)
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Tf(t, len(rows) == 3, "%v", rows)
+
+	_, err = ExecuteSelect(nil, `DESCRIBE users`, rtConf)
+	assert.T(t, err != nil)
+}
+
+func TestPreparedQuery(t *testing.T) {
+	q, err := Prepare(rtConf, "", `
+		SELECT user_id, referral_count * ? AS rc
+		FROM users
+		WHERE email = :email`)
+	assert.Tf(t, err == nil, "no error %v", err)
+
+	params := expr.NewParams(value.NewIntValue(2))
+	params.Named["email"] = value.NewStringValue("bob@email.com")
+	rows, err := q.Execute(nil, params)
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Tf(t, len(rows) == 1, "%v", rows)
+	assert.Tf(t, rows[0]["user_id"].ToString() == "hT2impsOPUREcVPc", "%v", rows[0])
+	assert.Tf(t, rows[0]["rc"].ToString() == "24", "%v", rows[0])
+
+	// the same prepared query with different values
+	params = expr.NewParams(value.NewIntValue(10))
+	params.Named["email"] = value.NewStringValue("aaron@email.com")
+	rows, err = q.Execute(nil, params)
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Tf(t, len(rows) == 1, "%v", rows)
+	assert.Tf(t, rows[0]["user_id"].ToString() == "9Ip1aKbeZe2njCDM", "%v", rows[0])
+	assert.Tf(t, rows[0]["rc"].ToString() == "820", "%v", rows[0])
+
+	// unbound params are an error
+	_, err = q.Execute(nil, expr.NewParams())
+	assert.T(t, err != nil)
+}
+
+func TestEngineStarPassthrough(t *testing.T) {
+	rows, err := ExecuteSelect(nil, `SELECT *, price * item_count AS total FROM orders WHERE order_id = 1`, rtConf)
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Tf(t, len(rows) == 1, "%v", rows)
+	assert.Tf(t, len(rows[0]) == 7, "all 6 input columns plus total %v", rows[0])
+	assert.Tf(t, rows[0]["user_id"].ToString() == "9Ip1aKbeZe2njCDM", "%v", rows[0])
+	assert.Tf(t, rows[0]["total"].ToString() == "1845", "%v", rows[0])
+
+	rows, err = ExecuteSelect(nil, `SELECT *, price * 2 AS price2 FROM orders WHERE order_id = 1`, rtConf)
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Tf(t, len(rows) == 1 && rows[0]["price2"].ToString() == "45", "%v", rows)
+
+	// computed columns replace input columns of the same name
+	rows, err = ExecuteSelect(nil, `SELECT *, item_count * 2 AS item_count FROM orders WHERE order_id = 1`, rtConf)
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Tf(t, len(rows) == 1 && len(rows[0]) == 6, "%v", rows)
+	assert.Tf(t, rows[0]["item_count"].ToString() == "164", "%v", rows[0])
+}
+
+func TestEngineOrderBy(t *testing.T) {
+	userIds := func(rows []map[string]value.Value, col string) string {
+		ids := make([]string, len(rows))
+		for i, row := range rows {
+			ids[i] = row[col].ToString()
+		}
+		return strings.Join(ids, ",")
+	}
+	// a column not in the select list
+	rows, err := ExecuteSelect(nil, `SELECT user_id FROM users ORDER BY email DESC`, rtConf)
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Tf(t, userIds(rows, "user_id") == "hT2impsabc345c,hT2impsOPUREcVPc,9Ip1aKbeZe2njCDM", "%v", rows)
+	assert.Tf(t, len(rows[0]) == 1, "email is not selected %v", rows[0])
+
+	// a select alias, which shadows the source column of the same name
+	rows, err = ExecuteSelect(nil, `SELECT user_id AS id, email AS user_id FROM users ORDER BY id`, rtConf)
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Tf(t, userIds(rows, "id") == "9Ip1aKbeZe2njCDM,hT2impsOPUREcVPc,hT2impsabc345c", "%v", rows)
+	rows, err = ExecuteSelect(nil, `SELECT email AS user_id FROM users ORDER BY user_id DESC`, rtConf)
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Tf(t, userIds(rows, "user_id") == "not_an_email,bob@email.com,aaron@email.com", "%v", rows)
+}
+
+func TestEngineJsonLinesSink(t *testing.T) {
+	sqlText := `select user_id, email, not_a_field FROM users WHERE yy(reg_date) > 10`
+	job, err := BuildSqlJob(rtConf, "mockcsv", sqlText)
+	assert.Tf(t, err == nil, "no error %v", err)
+
+	var buf bytes.Buffer
+	sink := NewJsonLinesSink(&buf)
+	job.RootTask.Add(sink)
+
+	err = job.Setup()
+	assert.T(t, err == nil)
+	err = job.Run()
+	time.Sleep(time.Millisecond * 10)
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Tf(t, sink.Err() == nil, "no sink error %v", sink.Err())
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Tf(t, len(lines) == 1, "should have 1 json line %v", buf.String())
+	row := make(map[string]interface{})
+	err = json.Unmarshal([]byte(lines[0]), &row)
+	assert.Tf(t, err == nil, "should be valid json %v  %s", err, lines[0])
+	assert.Tf(t, row["user_id"] == "9Ip1aKbeZe2njCDM", "%v", row)
+	assert.Tf(t, row["email"] == "aaron@email.com", "%v", row)
+	nada, hasNada := row["not_a_field"]
+	assert.Tf(t, hasNada && nada == nil, "should write null %v", row)
+}
+
+func TestEngineProjectionNil(t *testing.T) {
+	sqlText := `select user_id, not_a_field FROM users WHERE user_id = "9Ip1aKbeZe2njCDM"`
+	job, err := BuildSqlJob(rtConf, "mockcsv", sqlText)
+	assert.Tf(t, err == nil, "no error %v", err)
+
+	msgs := make([]datasource.Message, 0)
+	resultWriter := NewResultBuffer(&msgs)
+	job.RootTask.Add(resultWriter)
+
+	err = job.Setup()
+	assert.T(t, err == nil)
+	err = job.Run()
+	time.Sleep(time.Millisecond * 10)
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Tf(t, len(msgs) == 1, "should have 1 row %v", len(msgs))
+
+	row := msgs[0].Body().(*datasource.ContextSimple).Row()
+	nv, ok := row["not_a_field"]
+	assert.Tf(t, ok, "should have projected nil column %v", row)
+	assert.Tf(t, nv != nil && nv.Nil(), "should be NilValue not go nil %#v", nv)
+}
+
+func TestEngineSelectNoFrom(t *testing.T) {
+	sqlText := `SELECT 2*21 AS answer`
+	job, err := BuildSqlJob(rtConf, "mockcsv", sqlText)
+	assert.Tf(t, err == nil, "no error %v", err)
+
+	msgs := make([]datasource.Message, 0)
+	resultWriter := NewResultBuffer(&msgs)
+	job.RootTask.Add(resultWriter)
+
+	err = job.Setup()
+	assert.T(t, err == nil)
+	err = job.Run()
+	time.Sleep(time.Millisecond * 10)
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Tf(t, len(msgs) == 1, "should have 1 row %v", len(msgs))
+
+	row := msgs[0].Body().(*datasource.ContextSimple).Row()
+	answer, ok := value.ToInt64(row["answer"].Rv())
+	assert.Tf(t, ok && answer == 42, "should be 42 %v", row)
+}
+
+func TestEngineGroupByHaving(t *testing.T) {
+	sqlText := `
+		select 
+	        user_id, count(*) AS ct, sum(price) AS total
+	    FROM orders
+	    GROUP BY user_id
+	    HAVING ct > 1
+	`
+	job, err := BuildSqlJob(rtConf, "mockcsv", sqlText)
+	assert.Tf(t, err == nil, "no error %v", err)
+
+	msgs := make([]datasource.Message, 0)
+	resultWriter := NewResultBuffer(&msgs)
+	job.RootTask.Add(resultWriter)
+
+	err = job.Setup()
+	assert.T(t, err == nil)
+	err = job.Run()
+	time.Sleep(time.Millisecond * 10)
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Tf(t, len(msgs) == 1, "should have filtered out 1 group %v", len(msgs))
+
+	row := msgs[0].Body().(*datasource.ContextSimple).Row()
+	assert.Tf(t, row["user_id"].ToString() == "9Ip1aKbeZe2njCDM", "%v", row)
+	assert.Tf(t, row["ct"].Value() == int64(2), "should have 2 orders %v", row)
+	assert.Tf(t, row["total"].Value() == float64(60), "should sum price %v", row)
+}
+
+func TestEngineGroupByNonNumeric(t *testing.T) {
+	assert.T(t, canAggNumeric(value.NewStringValue("22.50")))
+	assert.T(t, canAggNumeric(value.NewIntValue(1)))
+	assert.T(t, canAggNumeric(value.NilValueVal))
+	assert.T(t, !canAggNumeric(value.NewStringValue("9Ip1aKbeZe2njCDM")))
+	assert.T(t, !canAggNumeric(value.NewStringsValue([]string{"a"})))
+
+	sqlText := `
+		select
+	        user_id, sum(user_id) AS total
+	    FROM orders
+	    GROUP BY user_id
+	`
+	job, err := BuildSqlJob(rtConf, "mockcsv", sqlText)
+	assert.Tf(t, err == nil, "no error %v", err)
+
+	msgs := make([]datasource.Message, 0)
+	resultWriter := NewResultBuffer(&msgs)
+	job.RootTask.Add(resultWriter)
+
+	err = job.Setup()
+	assert.T(t, err == nil)
+	err = job.Run()
+	time.Sleep(time.Millisecond * 10)
+	// untyped (csv) columns are only known to be non-numeric when run
+	assert.Tf(t, len(msgs) == 0, "should not aggregate non-numeric column %v", msgs)
+
+	// the job does not return task errors, run the group by on its own
+	stmt, err := expr.ParseSql(sqlText)
+	assert.Tf(t, err == nil, "no error %v", err)
+	groupBy := NewGroupBy(stmt.(*expr.SqlSelect))
+	inCh := make(MessageChan, 1)
+	inCh <- datasource.NewContextSimpleData(map[string]value.Value{"user_id": value.NewStringValue("9Ip1aKbeZe2njCDM")})
+	close(inCh)
+	groupBy.MessageInSet(inCh)
+	err = groupBy.Run(expr.NewContext())
+	assert.Tf(t, err != nil && err.Error() == "Cannot aggregate non-numeric column sum(user_id) AS total of type string",
+		"%v", err)
+
+	// a source typing the column as a string is an error at plan time
+	tbl := datasource.NewTable("typed_orders", nil)
+	tbl.AddFieldType("user_id", value.StringType)
+	tbl.SetColumns([]string{"user_id"})
+	conf := &datasource.RuntimeSchema{Sources: datasource.NewDataSources(
+		map[string]datasource.DataSource{"typed": &typedSource{tbl}})}
+	conf.SetConnInfo("typed")
+	_, err = BuildSqlJob(conf, "typed", `select user_id, sum(user_id) AS total FROM typed_orders GROUP BY user_id`)
+	assert.Tf(t, err != nil && err.Error() == `Column "user_id" is of type string, cannot use in sum`, "%v", err)
+}
+
+func TestEngineJoinNullKeys(t *testing.T) {
+	mockcsv.LoadTable("nullusers", `id,user_id,name
+1,u1,aaron`)
+	mockcsv.LoadTable("nullorders", `id,user_id,item
+1,u1,apple`)
+
+	// NULL user_id on both sides, which must not join each other
+	for tbl, row := range map[string][]driver.Value{
+		"nullusers":  {"2", nil, "bob"},
+		"nullorders": {"2", nil, "pear"},
+	} {
+		conn, err := mockcsv.MockCsvGlobal.Open(tbl)
+		assert.Tf(t, err == nil, "no error %v", err)
+		_, err = conn.(*membtree.StaticDataSource).Put(nil, nil, row)
+		assert.Tf(t, err == nil, "no error %v", err)
+	}
+
+	sqlText := `
+		SELECT u.name, o.item
+		FROM nullusers AS u
+		INNER JOIN nullorders AS o
+			ON u.user_id = o.user_id
+	`
+	job, err := BuildSqlJob(rtConf, "mockcsv", sqlText)
+	assert.Tf(t, err == nil, "no error %v", err)
+
+	msgs := make([]datasource.Message, 0)
+	resultWriter := NewResultBuffer(&msgs)
+	job.RootTask.Add(resultWriter)
+
+	err = job.Setup()
+	assert.T(t, err == nil)
+	err = job.Run()
+	time.Sleep(time.Millisecond * 10)
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Tf(t, len(msgs) == 1, "NULL keys should not join %v", msgs)
+
+	row := msgs[0].Body().(*datasource.ContextSimple).Row()
+	assert.Tf(t, row["u.name"].ToString() == "aaron", "%v", row)
+	assert.Tf(t, row["o.item"].ToString() == "apple", "%v", row)
+}
+
+func TestEngineJoinQualifiedStar(t *testing.T) {
+	mockcsv.LoadTable("starusers", `user_id,name
+u1,aaron`)
+	mockcsv.LoadTable("staritems", `item_id,user_id,name
+i1,u1,apple`)
+
+	// both tables have a name column
+	sqlText := `
+		SELECT u.*, i.*
+		FROM starusers AS u
+		INNER JOIN staritems AS i
+			ON u.user_id = i.user_id
+	`
+	job, err := BuildSqlJob(rtConf, "mockcsv", sqlText)
+	assert.Tf(t, err == nil, "no error %v", err)
+
+	msgs := make([]datasource.Message, 0)
+	resultWriter := NewResultBuffer(&msgs)
+	job.RootTask.Add(resultWriter)
+
+	err = job.Setup()
+	assert.T(t, err == nil)
+	err = job.Run()
+	time.Sleep(time.Millisecond * 10)
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Tf(t, len(msgs) == 1, "%v", msgs)
+
+	row := msgs[0].Body().(*datasource.ContextSimple).Row()
+	assert.Tf(t, len(row) == 5, "%v", row)
+	assert.Tf(t, row["u.name"].ToString() == "aaron", "%v", row)
+	assert.Tf(t, row["i.name"].ToString() == "apple", "%v", row)
+	assert.Tf(t, row["u.user_id"].ToString() == "u1", "%v", row)
+	assert.Tf(t, row["i.item_id"].ToString() == "i1", "%v", row)
+}
+
+func TestEngineCoalesce(t *testing.T) {
+	mockcsv.LoadTable("nicknames", `id,name,nickname
+1,aaron,ajr`)
+	// NULL nickname, and a present but empty nickname
+	conn, err := mockcsv.MockCsvGlobal.Open("nicknames")
+	assert.Tf(t, err == nil, "no error %v", err)
+	_, err = conn.(*membtree.StaticDataSource).Put(nil, nil, []driver.Value{"2", "bob", nil})
+	assert.Tf(t, err == nil, "no error %v", err)
+	_, err = conn.(*membtree.StaticDataSource).Put(nil, nil, []driver.Value{"3", "carol", ""})
+	assert.Tf(t, err == nil, "no error %v", err)
+
+	sqlText := `SELECT id, COALESCE(nickname, name) AS display, COALESCE(not_a_field, name) AS n FROM nicknames`
+	job, err := BuildSqlJob(rtConf, "mockcsv", sqlText)
+	assert.Tf(t, err == nil, "no error %v", err)
+
+	msgs := make([]datasource.Message, 0)
+	resultWriter := NewResultBuffer(&msgs)
+	job.RootTask.Add(resultWriter)
+
+	err = job.Setup()
+	assert.T(t, err == nil)
+	err = job.Run()
+	time.Sleep(time.Millisecond * 10)
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Tf(t, len(msgs) == 3, "should have 3 rows %v", len(msgs))
+
+	display := make(map[string]value.Value)
+	for _, msg := range msgs {
+		row := msg.Body().(*datasource.ContextSimple).Row()
+		display[row["id"].ToString()] = row["display"]
+		assert.Tf(t, row["n"] != nil && row["n"].ToString() != "", "missing field falls through %v", row)
+	}
+	assert.Tf(t, display["1"].ToString() == "ajr", "%v", display)
+	assert.Tf(t, display["2"].ToString() == "bob", "NULL should default %v", display)
+	assert.Tf(t, display["3"] != nil && display["3"].ToString() == "", "empty string is present %v", display)
+}
+
+func TestEngineDuplicateAlias(t *testing.T) {
+	_, err := BuildSqlJob(rtConf, "mockcsv", `SELECT user_id AS x, item_id AS x FROM orders`)
+	assert.Tf(t, err != nil, "duplicate alias should error")
+	assert.Tf(t, strings.Contains(err.Error(), `"x"`), "%v", err)
+
+	_, err = BuildSqlJob(rtConf, "mockcsv", `SELECT user_id AS x, item_id AS y FROM orders`)
+	assert.Tf(t, err == nil, "no error %v", err)
+
+	// the star is expanded to the source columns
+	_, err = BuildSqlJob(rtConf, "mockcsv", `SELECT *, item_id FROM orders`)
+	assert.Tf(t, err != nil && strings.Contains(err.Error(), `"item_id"`), "%v", err)
+	_, err = BuildSqlJob(rtConf, "mockcsv", `SELECT u.*, u.email FROM users AS u INNER JOIN orders AS o ON u.user_id = o.user_id`)
+	assert.Tf(t, err != nil && strings.Contains(err.Error(), `"u.email"`), "%v", err)
+
+	// a computed column replaces the star column of the same name
+	_, err = BuildSqlJob(rtConf, "mockcsv", `SELECT *, item_count * 2 AS item_count FROM orders`)
+	assert.Tf(t, err == nil, "no error %v", err)
+}
+
+func TestEngineTsColumn(t *testing.T) {
+	sqlText := `select user_id, email FROM users WHERE email = "aaron@email.com"`
+	stmt, err := expr.ParseSqlVm(sqlText)
+	assert.Tf(t, err == nil, "no error %v", err)
+
+	builder := NewJobBuilder(rtConf, "mockcsv")
+	builder.TsColumn = "reg_date"
+	task, err := stmt.Accept(builder)
+	assert.Tf(t, err == nil, "no error %v", err)
+	job := &SqlJob{task.(TaskRunner), stmt, rtConf}
+
+	msgs := make([]datasource.Message, 0)
+	resultWriter := NewResultBuffer(&msgs)
+	job.RootTask.Add(resultWriter)
+
+	err = job.Setup()
+	assert.T(t, err == nil)
+	err = job.Run()
+	time.Sleep(time.Millisecond * 10)
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Tf(t, len(msgs) == 1, "should have 1 row %v", len(msgs))
+
+	// event time comes from the configured column, not arrival time
+	regDate, _ := value.ParseTime("2012-10-17T17:29:39.738Z")
+	ts, ok := datasource.MessageTs(msgs[0])
+	assert.Tf(t, ok && ts.Equal(regDate), "should use reg_date as ts %v", ts)
+}
+
+func TestEngineTsColumnOutOfOrder(t *testing.T) {
+	// users do not arrive in reg_date order, windows and ordering of time
+	//  based tasks must follow reg_date rather than arrival order
+	stmt, err := expr.ParseSqlVm(`select user_id, reg_date FROM users`)
+	assert.Tf(t, err == nil, "no error %v", err)
+
+	builder := NewJobBuilder(rtConf, "mockcsv")
+	builder.TsColumn = "reg_date"
+	task, err := stmt.Accept(builder)
+	assert.Tf(t, err == nil, "no error %v", err)
+	job := &SqlJob{task.(TaskRunner), stmt, rtConf}
+
+	msgs := make([]datasource.Message, 0)
+	resultWriter := NewResultBuffer(&msgs)
+	job.RootTask.Add(resultWriter)
+
+	err = job.Setup()
+	assert.T(t, err == nil)
+	err = job.Run()
+	time.Sleep(time.Millisecond * 10)
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Tf(t, len(msgs) == 3, "should have 3 rows %v", len(msgs))
+	outOfOrder := false
+	for i := 1; i < len(msgs); i++ {
+		if datasource.MessageLess(msgs[i], msgs[i-1]) {
+			outOfOrder = true
+		}
+	}
+	assert.Tf(t, outOfOrder, "users should arrive out of reg_date order")
+
+	// one year windows assigned by event time
+	windows := make(map[int][]string)
+	for _, msg := range msgs {
+		ts, ok := datasource.MessageTs(msg)
+		assert.Tf(t, ok, "should have ts %#v", msg)
+		userId, _ := msg.(expr.ContextReader).Get("user_id")
+		windows[ts.Year()] = append(windows[ts.Year()], userId.ToString())
+	}
+	assert.Tf(t, len(windows) == 2 && len(windows[2009]) == 2, "%v", windows)
+	assert.Tf(t, strings.Join(windows[2012], ",") == "9Ip1aKbeZe2njCDM", "%v", windows)
+
+	sort.SliceStable(msgs, func(i, j int) bool { return datasource.MessageLess(msgs[i], msgs[j]) })
+	first, _ := datasource.MessageTs(msgs[0])
+	last, _ := datasource.MessageTs(msgs[2])
+	assert.Tf(t, first.Year() == 2009 && last.Year() == 2012, "ordered by event time, not arrival %v %v", first, last)
+}
+
 type UserEvent struct {
 	Id     string
 	UserId string
@@ -336,3 +789,61 @@ func testSubselect(t *testing.T) {
 	assert.Tf(t, len(msgs) == 1, "should have filtered out 2 messages")
 
 }
+
+func TestEngineCaseInsensitive(t *testing.T) {
+	conf := datasource.NewRuntimeSchema()
+	rows, err := ExecuteSelect(nil, `SELECT User_Id, EMAIL FROM users WHERE email = "bob@email.com"`, conf)
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Tf(t, len(rows) == 1 && rows[0]["EMAIL"].Nil(), "case sensitive by default %v", rows)
+
+	conf.CaseInsensitive = true
+	rows, err = ExecuteSelect(nil, `SELECT User_Id, EMAIL FROM users WHERE email = "bob@email.com"`, conf)
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Tf(t, len(rows) == 1, "%v", rows)
+	assert.Tf(t, rows[0]["User_Id"].ToString() == "hT2impsOPUREcVPc", "%v", rows[0])
+	assert.Tf(t, rows[0]["EMAIL"].ToString() == "bob@email.com", "%v", rows[0])
+
+	// join columns and keys
+	rows, err = ExecuteSelect(nil, `
+		SELECT u.EMAIL, o.Order_Id
+		FROM users AS u
+		INNER JOIN orders AS o
+			ON u.User_Id = o.user_id`, conf)
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Tf(t, len(rows) == 2, "%v", rows)
+	for _, row := range rows {
+		assert.Tf(t, row["u.EMAIL"].ToString() == "aaron@email.com", "%v", row)
+	}
+}
+
+func TestEngineNumericStrings(t *testing.T) {
+	sqlText := `SELECT user_id, referral_count FROM users WHERE referral_count > "9"`
+	conf := datasource.NewRuntimeSchema()
+	rows, err := ExecuteSelect(nil, sqlText, conf)
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Tf(t, len(rows) == 0, "lexical by default %v", rows)
+
+	numeric := datasource.NewRuntimeSchema()
+	numeric.NumericStrings = true
+	rows, err = ExecuteSelect(nil, sqlText, numeric)
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Tf(t, len(rows) == 3, "%v", rows)
+
+	// the option is per schema, other queries are not affected
+	rows, err = ExecuteSelect(nil, sqlText, conf)
+	assert.Tf(t, err == nil && len(rows) == 0, "%v %v", err, rows)
+}
+
+func TestEngineStrictDivision(t *testing.T) {
+	sqlText := `SELECT user_id, referral_count / 0 AS rc FROM users WHERE email = "bob@email.com"`
+	conf := datasource.NewRuntimeSchema()
+	rows, err := ExecuteSelect(nil, sqlText, conf)
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Tf(t, len(rows) == 1 && rows[0]["rc"].Nil(), "divide by zero is NULL by default %v", rows)
+
+	strict := datasource.NewRuntimeSchema()
+	strict.StrictDivision = true
+	rows, err = ExecuteSelect(nil, sqlText, strict)
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Tf(t, len(rows) == 1 && rows[0]["rc"].Err(), "divide by zero is an error %v", rows)
+}
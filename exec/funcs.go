@@ -0,0 +1,151 @@
+package exec
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/value"
+)
+
+var (
+	evalContextType = reflect.TypeOf((*expr.EvalContext)(nil)).Elem()
+	valueType       = reflect.TypeOf((*value.Value)(nil)).Elem()
+	boolType        = reflect.TypeOf(true)
+)
+
+// FuncImpl is a go func usable in sql expressions (projections, where,
+//  having), it must have the same signature as funcs for expr.FuncAdd()
+//
+//      func(ctx expr.EvalContext, args ...value.Value) (value.Value, bool)
+//      func(ctx expr.EvalContext, x value.Value) (value.NumberValue, bool)
+//
+type FuncImpl interface{}
+
+// RegisterFunc registers a custom function for use in sql statements,
+//  such that vm.Eval will dispatch to it.  The signature is validated
+//  here, instead of the panic from expr.FuncAdd()
+//
+//     exec.RegisterFunc("double", func(ctx expr.EvalContext, x value.Value) (value.Value, bool) {
+//         ...
+//     })
+//     SELECT double(price) AS dbl FROM orders
+//
+func RegisterFunc(name string, fn FuncImpl) error {
+	if err := validateFunc(name, fn); err != nil {
+		return err
+	}
+	expr.FuncAdd(name, fn)
+	return nil
+}
+
+func validateFunc(name string, fn FuncImpl) error {
+	if name == "" {
+		return fmt.Errorf("Func must have a name")
+	}
+	if fn == nil {
+		return fmt.Errorf("Func %s must not be nil", name)
+	}
+	ft := reflect.TypeOf(fn)
+	if ft.Kind() != reflect.Func {
+		return fmt.Errorf("Func %s must be a func but got %T", name, fn)
+	}
+	if ft.NumIn() < 1 || !evalContextType.AssignableTo(ft.In(0)) {
+		return fmt.Errorf("Func %s must have expr.EvalContext as first argument", name)
+	}
+	for i := 1; i < ft.NumIn(); i++ {
+		argType := ft.In(i)
+		if ft.IsVariadic() && i == ft.NumIn()-1 {
+			argType = argType.Elem()
+		}
+		if !argType.Implements(valueType) {
+			return fmt.Errorf("Func %s argument %d must be a value.Value but got %v", name, i, argType)
+		}
+	}
+	if ft.NumOut() != 2 || !ft.Out(0).Implements(valueType) || ft.Out(1) != boolType {
+		return fmt.Errorf("Func %s must return (value.Value, bool)", name)
+	}
+	return nil
+}
+
+// CheckFuncs walks an expression checking every func call at plan time
+//  for argument count, and for literal arguments the argument type, so
+//  that a bad call is an error instead of failing for every row
+func CheckFuncs(node expr.Node) error {
+	switch n := node.(type) {
+	case *expr.FuncNode:
+		if err := checkFuncArgs(n); err != nil {
+			return err
+		}
+		for _, arg := range n.Args {
+			if err := CheckFuncs(arg); err != nil {
+				return err
+			}
+		}
+	case *expr.BinaryNode:
+		for _, arg := range n.Args {
+			if err := CheckFuncs(arg); err != nil {
+				return err
+			}
+		}
+	case *expr.TriNode:
+		for _, arg := range n.Args {
+			if err := CheckFuncs(arg); err != nil {
+				return err
+			}
+		}
+	case *expr.MultiArgNode:
+		for _, arg := range n.Args {
+			if err := CheckFuncs(arg); err != nil {
+				return err
+			}
+		}
+	case *expr.UnaryNode:
+		return CheckFuncs(n.Arg)
+	}
+	return nil
+}
+
+func checkFuncArgs(n *expr.FuncNode) error {
+	if !n.F.F.IsValid() {
+		return nil
+	}
+	ft := n.F.F.Type()
+	// first arg is the context
+	numArgs := ft.NumIn() - 1
+	if ft.IsVariadic() {
+		if len(n.Args) < numArgs-1 {
+			return fmt.Errorf("Not enough arguments for %s want at least %d got %d", n.Name, numArgs-1, len(n.Args))
+		}
+	} else if len(n.Args) != numArgs {
+		return fmt.Errorf("Wrong number of arguments for %s want %d got %d", n.Name, numArgs, len(n.Args))
+	}
+
+	for i, arg := range n.Args {
+		var argType reflect.Type
+		switch {
+		case ft.IsVariadic() && i >= numArgs-1:
+			argType = ft.In(ft.NumIn() - 1).Elem()
+		default:
+			argType = ft.In(i + 1)
+		}
+		var lit value.Value
+		switch a := arg.(type) {
+		case *expr.StringNode:
+			lit = value.NewStringValue(a.Text)
+		case *expr.NumberNode:
+			if a.IsInt {
+				lit = value.NewIntValue(a.Int64)
+			} else {
+				lit = value.NewNumberValue(a.Float64)
+			}
+		default:
+			// only known at run time
+			continue
+		}
+		if !reflect.TypeOf(lit).AssignableTo(argType) {
+			return fmt.Errorf("Invalid argument %d for %s want %v got %s", i+1, n.Name, argType, lit.Type())
+		}
+	}
+	return nil
+}
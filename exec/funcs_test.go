@@ -0,0 +1,64 @@
+package exec
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/value"
+)
+
+func doubleFunc(ctx expr.EvalContext, x value.Value) (value.NumberValue, bool) {
+	fv, ok := value.ToFloat64(x.Rv())
+	if !ok {
+		return value.NewNumberValue(0), false
+	}
+	return value.NewNumberValue(fv * 2), true
+}
+
+func TestRegisterFunc(t *testing.T) {
+	err := RegisterFunc("double", doubleFunc)
+	assert.Tf(t, err == nil, "no error %v", err)
+
+	// bad signatures are errors, not panics
+	assert.T(t, RegisterFunc("bad", "not a func") != nil)
+	assert.T(t, RegisterFunc("bad", func(x value.Value) (value.Value, bool) { return x, true }) != nil)
+	assert.T(t, RegisterFunc("bad", func(ctx expr.EvalContext, x int) (value.Value, bool) { return nil, true }) != nil)
+	assert.T(t, RegisterFunc("bad", func(ctx expr.EvalContext, x value.Value) value.Value { return x }) != nil)
+
+	sqlText := `select user_id, double(referral_count) AS dbl FROM users WHERE email = "aaron@email.com"`
+	job, err := BuildSqlJob(rtConf, "mockcsv", sqlText)
+	assert.Tf(t, err == nil, "no error %v", err)
+
+	msgs := make([]datasource.Message, 0)
+	resultWriter := NewResultBuffer(&msgs)
+	job.RootTask.Add(resultWriter)
+
+	err = job.Setup()
+	assert.T(t, err == nil)
+	err = job.Run()
+	time.Sleep(time.Millisecond * 10)
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Tf(t, len(msgs) == 1, "should have 1 row %v", len(msgs))
+
+	row := msgs[0].Body().(*datasource.ContextSimple).Row()
+	assert.Tf(t, row["dbl"].Value() == float64(164), "should double 82 %v", row)
+
+	// arg count and literal types are checked at plan time
+	_, err = BuildSqlJob(rtConf, "mockcsv", `select double(referral_count, 2) AS dbl FROM users`)
+	assert.T(t, err != nil)
+	_, err = BuildSqlJob(rtConf, "mockcsv", `select user_id FROM users WHERE double() > 10`)
+	assert.T(t, err != nil)
+
+	err = RegisterFunc("strlen", func(ctx expr.EvalContext, s value.StringValue) (value.IntValue, bool) {
+		return value.NewIntValue(int64(len(s.Val()))), true
+	})
+	assert.Tf(t, err == nil, "no error %v", err)
+	_, err = BuildSqlJob(rtConf, "mockcsv", `select strlen(22) AS ct FROM users`)
+	assert.T(t, err != nil)
+	_, err = BuildSqlJob(rtConf, "mockcsv", `select strlen("abc") AS ct FROM users`)
+	assert.Tf(t, err == nil, "no error %v", err)
+}
@@ -0,0 +1,812 @@
+package exec
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	u "github.com/araddon/gou"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/value"
+	"github.com/araddon/qlbridge/vm"
+)
+
+var (
+	_ = u.EMPTY
+
+	// Ensure that we implement the Task Runner interface
+	_ TaskRunner = (*GroupBy)(nil)
+
+	// GroupByPartitions is the default number of GroupBy spill partitions
+	GroupByPartitions = 16
+)
+
+// Aggregator accumulates the values of a single column for a single
+//  group, and provides the final aggregated value once all rows are read
+type Aggregator interface {
+	Do(v value.Value)
+	Result() value.Value
+}
+
+// partialAggregator is an Aggregator whose partial state can be spilled
+//  and merged into the same group from another pass, see MaxMemGroups.
+//  The State() of an aggregator is always the same count of values.
+type partialAggregator interface {
+	Aggregator
+	State() []value.Value
+	Merge(state []value.Value)
+}
+
+// OrderedAggregator is an Aggregator whose result depends on an ordering
+//  value evaluated per message as well, ie first/last
+type OrderedAggregator interface {
+	Aggregator
+	DoOrdered(v, orderBy value.Value)
+}
+
+// Group By a set of columns, aggregating each group of messages into a
+//  single message.   As aggregation requires all of the rows, the groups
+//  are only emitted once the input channel has been closed.
+//
+//   SELECT user_id, count(*) AS ct, sum(price) AS total
+//   FROM orders
+//   GROUP BY user_id
+//
+// first/last take an optional order by expression, the value from the
+//  row with the min/max ordering value is used.  On ties the row seen
+//  first wins, for both first and last.  Rows with a nil ordering
+//  value are skipped.  Without an order by, it is arrival order.
+//
+//   SELECT device, last(status, ts) AS status FROM events GROUP BY device
+//
+// With MaxMemGroups, once that many groups are in memory the partial
+//  aggregates of every group are spilled to disk partitioned by group key
+//  hash, and memory is cleared for the next groups.  Each partition is then
+//  read back in turn merging the partial aggregates of its groups, so the
+//  results are the same, only the output order differs:  groups are emitted
+//  by partition.
+type GroupBy struct {
+	*TaskBase
+	sql *expr.SqlSelect
+	// NaNPolicy how to group rows where a group by column is NaN
+	NaNPolicy NaNPolicy
+	// MaxMemGroups if > 0 is the count of groups held in memory, the
+	//  partial aggregates are spilled to disk and merged after
+	MaxMemGroups int
+	// Partitions is the count of spill files partial aggregates are
+	//  hashed into by group key
+	Partitions int
+	// TempDir for spill files, "" is the os default temp dir
+	TempDir string
+	nanCt   int
+	spilled int // count of spill files created
+}
+
+// NaNPolicy determines how NaN group by values are grouped, as NaN
+//  is never equal to itself it can not group with other NaN rows
+type NaNPolicy uint8
+
+const (
+	// NaNSeparate each row with a NaN group by value is its own group
+	NaNSeparate NaNPolicy = 0
+	// NaNDrop rows with a NaN group by value are dropped
+	NaNDrop NaNPolicy = 1
+)
+
+// aggCol is the per-column plan of what expression to evaluate for
+//  each message, and how to create the aggregator for a new group
+type aggCol struct {
+	col     *expr.Column
+	node    expr.Node // expression evaluated per message, nil for count(*)
+	numeric bool      // does this aggregate require numeric values, ie sum/avg
+	orderBy expr.Node // ordering expression for first/last, optional
+	newAgg  func() Aggregator
+}
+
+func NewGroupBy(sqlSelect *expr.SqlSelect) *GroupBy {
+	m := &GroupBy{
+		TaskBase:   NewTaskBase("GroupBy"),
+		sql:        sqlSelect,
+		Partitions: GroupByPartitions,
+	}
+	return m
+}
+
+func (m *GroupBy) Copy() *GroupBy { return NewGroupBy(m.sql) }
+
+func (m *GroupBy) Close() error {
+	if err := m.TaskBase.Close(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *GroupBy) Run(context *expr.Context) error {
+	defer context.Recover()
+	defer close(m.msgOutCh)
+
+	inCh := m.MessageIn()
+	m.nanCt = 0
+
+	cols, err := buildAggCols(m.sql.Columns)
+	if err != nil {
+		return m.recordError(err)
+	}
+
+	gs := m.newGroupState(0)
+	defer gs.remove()
+
+msgReadLoop:
+	for {
+		select {
+		case <-m.SigChan():
+			return nil
+		case msg, ok := <-inCh:
+			if !ok {
+				break msgReadLoop
+			}
+			start := m.metricsStart()
+			mt, ok := msg.(expr.ContextReader)
+			if !ok {
+				return m.recordError(fmt.Errorf("To use GroupBy must use ContextReader message but got %T", msg))
+			}
+			if err := m.aggregate(gs, cols, mt); err != nil {
+				return m.recordError(err)
+			}
+			m.recordHandled(start, true)
+		}
+	}
+	_, err = m.emit(gs, cols)
+	return m.recordError(err)
+}
+
+// groupState is the groups held in memory, and the spill files of partial
+//  aggregates of groups that did not fit (see MaxMemGroups) partitioned
+//  by key hash
+type groupState struct {
+	depth  int      // spill pass, to re-partition spilled groups differently
+	keys   []string // the order groups were first seen, so output is deterministic
+	groups map[string][]Aggregator
+	spills []*msgSpill
+}
+
+func (m *GroupBy) newGroupState(depth int) *groupState {
+	if m.Partitions < 1 {
+		m.Partitions = 1
+	}
+	return &groupState{
+		depth:  depth,
+		keys:   make([]string, 0),
+		groups: make(map[string][]Aggregator),
+		spills: make([]*msgSpill, m.Partitions),
+	}
+}
+
+func (m *groupState) remove() {
+	for i, spill := range m.spills {
+		spill.remove()
+		m.spills[i] = nil
+	}
+}
+
+func (m *groupState) hasSpills() bool {
+	for _, spill := range m.spills {
+		if spill != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// group returns the aggregators of the group for this key, if it is a new
+//  group and there are already MaxMemGroups in memory those are spilled first
+func (m *GroupBy) group(gs *groupState, cols []*aggCol, key string) ([]Aggregator, error) {
+	if aggs, ok := gs.groups[key]; ok {
+		return aggs, nil
+	}
+	if m.MaxMemGroups > 0 && len(gs.keys) >= m.MaxMemGroups {
+		if err := m.spill(gs); err != nil {
+			return nil, err
+		}
+	}
+	aggs := make([]Aggregator, len(cols))
+	for i, ac := range cols {
+		aggs[i] = ac.newAgg()
+	}
+	gs.groups[key] = aggs
+	gs.keys = append(gs.keys, key)
+	return aggs, nil
+}
+
+// aggregate a row into its group
+func (m *GroupBy) aggregate(gs *groupState, cols []*aggCol, mt expr.ContextReader) error {
+	reader := m.evalReader(mt)
+	key, keep, err := m.groupKey(reader)
+	if err != nil {
+		return err
+	}
+	if !keep {
+		return nil
+	}
+	aggs, err := m.group(gs, cols, key)
+	if err != nil {
+		return err
+	}
+	for i, ac := range cols {
+		if ac.node == nil {
+			aggs[i].Do(value.NewIntValue(1))
+			continue
+		}
+		v, ok := vm.Eval(reader, ac.node)
+		if !ok {
+			//u.Debugf("could not evaluate: %s", ac.node)
+			continue
+		}
+		if ac.numeric && !canAggNumeric(v) {
+			return fmt.Errorf("Cannot aggregate non-numeric column %s of type %s", ac.col, v.Type())
+		}
+		if ac.orderBy != nil {
+			ov, _ := vm.Eval(reader, ac.orderBy)
+			aggs[i].(OrderedAggregator).DoOrdered(v, ov)
+			continue
+		}
+		aggs[i].Do(v)
+	}
+	return nil
+}
+
+// spill the partial aggregates of each in-memory group to the partition
+//  of its group key, and clear the groups from memory
+func (m *GroupBy) spill(gs *groupState) error {
+	for _, key := range gs.keys {
+		state := make([]value.Value, 0)
+		for _, agg := range gs.groups[key] {
+			state = append(state, agg.(partialAggregator).State()...)
+		}
+		msg := datasource.NewSqlDriverMessageMapFromValues(0, state, nil)
+		msg.SetKey(key)
+		if err := m.spillState(gs, msg); err != nil {
+			return err
+		}
+	}
+	gs.keys = make([]string, 0)
+	gs.groups = make(map[string][]Aggregator)
+	return nil
+}
+
+// spillState writes the partial aggregates of a group to the partition of
+//  its group key
+func (m *GroupBy) spillState(gs *groupState, msg *datasource.SqlDriverMessageMap) error {
+	h := fnv.New32a()
+	h.Write([]byte{byte(gs.depth)})
+	h.Write([]byte(msg.Key()))
+	p := int(h.Sum32() % uint32(len(gs.spills)))
+	if gs.spills[p] == nil {
+		spill, err := newMsgSpill(m.TempDir, "groupby")
+		if err != nil {
+			return err
+		}
+		gs.spills[p] = spill
+		m.spilled++
+	}
+	return gs.spills[p].write(msg)
+}
+
+// merge the spilled partial aggregates of a group into its group.  When
+//  merging, the groups in memory are kept until the partition is read, the
+//  partial aggregates of any other group are spilled again to the next
+//  pass so that each pass completes MaxMemGroups groups.
+func (m *GroupBy) merge(gs *groupState, cols []*aggCol, msg *datasource.SqlDriverMessageMap) error {
+	aggs, ok := gs.groups[msg.Key()]
+	if !ok {
+		if m.MaxMemGroups > 0 && len(gs.keys) >= m.MaxMemGroups {
+			return m.spillState(gs, msg)
+		}
+		var err error
+		if aggs, err = m.group(gs, cols, msg.Key()); err != nil {
+			return err
+		}
+	}
+	row := msg.Values()
+	for _, agg := range aggs {
+		pa := agg.(partialAggregator)
+		n := len(pa.State())
+		if len(row) < n {
+			return fmt.Errorf("GroupBy spilled state of group has too few values: %d", len(msg.Values()))
+		}
+		state := make([]value.Value, n)
+		for i := range state {
+			state[i] = value.NewValue(row[i])
+		}
+		pa.Merge(state)
+		row = row[n:]
+	}
+	return nil
+}
+
+// emit the in-memory groups, then merge and emit each spilled partition in
+//  turn removing its spill file once read.  Returns false if signaled to stop.
+func (m *GroupBy) emit(gs *groupState, cols []*aggCol) (bool, error) {
+	if gs.depth == 0 && gs.hasSpills() {
+		// the in-memory groups may have partial aggregates spilled too
+		//  so are merged along with them
+		if err := m.spill(gs); err != nil {
+			return false, err
+		}
+	}
+	for _, key := range gs.keys {
+		aggs := gs.groups[key]
+		row := make(map[string]value.Value, len(cols))
+		for ci, ac := range cols {
+			res := aggs[ci].Result()
+			if res.Err() {
+				return false, fmt.Errorf("Could not aggregate column %s: %s", ac.col, res.ToString())
+			}
+			row[ac.col.Key()] = res
+		}
+		msg := datasource.NewContextSimpleData(row)
+		select {
+		case <-m.SigChan():
+			return false, nil
+		case m.msgOutCh <- msg:
+			// continue
+		}
+	}
+	gs.keys, gs.groups = nil, nil
+
+	for i, spill := range gs.spills {
+		if spill == nil {
+			continue
+		}
+		next := m.newGroupState(gs.depth + 1)
+		err := spill.read(func(mt *datasource.SqlDriverMessageMap) error {
+			return m.merge(next, cols, mt)
+		})
+		spill.remove()
+		gs.spills[i] = nil
+		if err != nil {
+			next.remove()
+			return false, err
+		}
+		u.Debugf("groupby merged %d spilled groups", len(next.keys))
+		more, err := m.emit(next, cols)
+		next.remove()
+		if !more || err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// The group key is the composite of each group by column value, returns
+//  false if this row should be dropped per the NaNPolicy
+func (m *GroupBy) groupKey(mt expr.ContextReader) (string, bool, error) {
+	if len(m.sql.GroupBy) == 0 {
+		return "", true, nil
+	}
+	vals := make([]string, len(m.sql.GroupBy))
+	for i, col := range m.sql.GroupBy {
+		if col.Expr == nil {
+			return "", false, fmt.Errorf("GroupBy column has no expression: %s", col)
+		}
+		v, ok := vm.Eval(mt, col.Expr)
+		if !ok || v == nil {
+			vals[i] = ""
+			continue
+		}
+		if nv, isNum := v.(value.NumberValue); isNum && math.IsNaN(nv.Float()) {
+			if m.NaNPolicy == NaNDrop {
+				return "", false, nil
+			}
+			// NaN != NaN, so each gets its own group, prefixed so that no
+			//  string value can collide with it
+			m.nanCt++
+			vals[i] = fmt.Sprintf("\x01NaN-%d", m.nanCt)
+			continue
+		}
+		vals[i] = groupKeyString(v)
+	}
+	return strings.Join(vals, string(byte(0))), true, nil
+}
+
+// groupKeyString is the canonical string of a group by value, numeric
+//  values are normalized so 1, 1.0 and 1.00 all group together, structs
+//  are grouped by value.StructValue.EqualityKey()
+func groupKeyString(v value.Value) string {
+	switch vt := v.(type) {
+	case value.StructValue:
+		return vt.EqualityKey()
+	case value.NumberValue:
+		f := vt.Float()
+		if f == 0 {
+			f = 0 // normalize -0
+		}
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	case value.IntValue:
+		return strconv.FormatInt(vt.Val(), 10)
+	}
+	return v.ToString()
+}
+
+func buildAggCols(columns expr.Columns) ([]*aggCol, error) {
+	cols := make([]*aggCol, len(columns))
+	for i, col := range columns {
+		if col.Star {
+			return nil, fmt.Errorf("select * not supported with group by")
+		}
+		ac := &aggCol{col: col, node: col.Expr}
+		fn, isFunc := col.Expr.(*expr.FuncNode)
+		if !isFunc {
+			ac.newAgg = func() Aggregator { return &aggFirst{} }
+			cols[i] = ac
+			continue
+		}
+		if len(fn.Args) > 0 {
+			ac.node = fn.Args[0]
+		}
+		switch strings.ToLower(fn.Name) {
+		case "count":
+			if len(fn.Args) == 1 && fn.Args[0].String() == "*" {
+				ac.node = nil
+			}
+			ac.newAgg = func() Aggregator { return &aggCount{} }
+		case "sum":
+			ac.numeric = true
+			ac.newAgg = func() Aggregator { return &aggSum{} }
+		case "avg":
+			ac.numeric = true
+			ac.newAgg = func() Aggregator { return &aggAvg{} }
+		case "min":
+			ac.newAgg = func() Aggregator { return &aggMin{v: math.NaN()} }
+		case "max":
+			ac.newAgg = func() Aggregator { return &aggMax{v: math.NaN()} }
+		case "first", "last":
+			if len(fn.Args) < 1 || len(fn.Args) > 2 {
+				return nil, fmt.Errorf("%s requires a value and optional order by arg: %s", fn.Name, col)
+			}
+			if len(fn.Args) == 2 {
+				ac.orderBy = fn.Args[1]
+			}
+			last := strings.ToLower(fn.Name) == "last"
+			ac.newAgg = func() Aggregator { return &aggFirstLast{last: last} }
+		default:
+			// non-aggregate function, evaluated per group on first row
+			ac.node = col.Expr
+			ac.newAgg = func() Aggregator { return &aggFirst{} }
+		}
+		cols[i] = ac
+	}
+	return cols, nil
+}
+
+// canAggNumeric determines if this value can be used in a numeric
+//  aggregation (sum/avg).  Nil values are skipped, and strings are allowed
+//  as long as they parse to a number as many sources (csv) are untyped
+func canAggNumeric(v value.Value) bool {
+	if v == nil || v.Nil() || value.IsNumeric(v) {
+		return true
+	}
+	if v.Type() == value.StringType {
+		_, ok := value.ToFloat64(v.Rv())
+		return ok
+	}
+	return false
+}
+
+// aggFloat converts a value to float64 for sum/avg aggregation, NULL
+//  and error values are not aggregated
+func aggFloat(v value.Value) (float64, bool) {
+	if value.IsNull(v) || v.Err() {
+		return 0, false
+	}
+	switch vt := v.(type) {
+	case value.NumericValue:
+		return vt.Float(), true
+	case value.BoolValue:
+		if vt.Val() {
+			return 1, true
+		}
+		return 0, true
+	}
+	if v.Nil() {
+		return 0, false
+	}
+	return value.ToFloat64(v.Rv())
+}
+
+// aggFirst keeps the first value seen, used for the group by columns
+//  themselves and any non-aggregate expressions
+type aggFirst struct {
+	v value.Value
+}
+
+func (m *aggFirst) Do(v value.Value) {
+	if m.v == nil {
+		m.v = v
+	}
+}
+func (m *aggFirst) Result() value.Value {
+	if m.v == nil {
+		return value.NilValueVal
+	}
+	return m.v
+}
+func (m *aggFirst) State() []value.Value {
+	return []value.Value{value.NewBoolValue(m.v != nil), m.Result()}
+}
+func (m *aggFirst) Merge(state []value.Value) {
+	if stateBool(state[0]) {
+		m.Do(state[1])
+	}
+}
+
+// aggFirstLast keeps the value of the row with the min (first) or max
+//  (last) ordering value, the first row seen wins ties
+type aggFirstLast struct {
+	last    bool
+	v       value.Value
+	orderBy value.Value
+	seen    bool
+}
+
+// Do without ordering, is arrival order
+func (m *aggFirstLast) Do(v value.Value) {
+	if !m.seen || m.last {
+		m.v = v
+		m.seen = true
+	}
+}
+func (m *aggFirstLast) DoOrdered(v, orderBy value.Value) {
+	if orderBy == nil || orderBy.Nil() || orderBy.Err() {
+		return
+	}
+	if m.seen {
+		cmp := compareOrder(orderBy, m.orderBy)
+		if (m.last && cmp <= 0) || (!m.last && cmp >= 0) {
+			return
+		}
+	}
+	m.v = v
+	m.orderBy = orderBy
+	m.seen = true
+}
+func (m *aggFirstLast) Result() value.Value {
+	if m.v == nil {
+		return value.NilValueVal
+	}
+	return m.v
+}
+func (m *aggFirstLast) State() []value.Value {
+	orderBy := m.orderBy
+	if orderBy == nil {
+		orderBy = value.NilValueVal
+	}
+	return []value.Value{value.NewBoolValue(m.seen), m.Result(), orderBy}
+}
+
+// Merge a spilled state, ordered by the state's order by value if it has
+//  one, otherwise as arrival order
+func (m *aggFirstLast) Merge(state []value.Value) {
+	if !stateBool(state[0]) {
+		return
+	}
+	if state[2].Nil() {
+		m.Do(state[1])
+		return
+	}
+	m.DoOrdered(state[1], state[2])
+}
+
+// compareOrder compares two ordering values returning -1, 0, 1.  Numbers
+//  (and numeric strings) compare numerically, then times (and date
+//  strings), otherwise the string values are compared.  See value.Compare()
+//  for NaN and nil ordering.
+func compareOrder(a, b value.Value) int {
+	if af, ok := orderFloat(a); ok {
+		if bf, ok := orderFloat(b); ok {
+			return value.CompareFloat(af, bf)
+		}
+	}
+	if at, ok := orderTime(a); ok {
+		if bt, ok := orderTime(b); ok {
+			return value.Compare(value.NewTimeValue(at), value.NewTimeValue(bt))
+		}
+	}
+	return value.Compare(a, b)
+}
+
+func orderFloat(v value.Value) (float64, bool) {
+	switch vt := v.(type) {
+	case value.TimeValue:
+		return 0, false
+	case value.NumericValue:
+		return vt.Float(), true
+	case value.StringValue:
+		return value.ToFloat64(vt.Rv())
+	}
+	return 0, false
+}
+
+func orderTime(v value.Value) (time.Time, bool) {
+	switch vt := v.(type) {
+	case value.TimeValue:
+		return vt.Val(), true
+	case value.StringValue:
+		return value.ParseTime(vt.Val())
+	}
+	return time.Time{}, false
+}
+
+type aggCount struct {
+	ct int64
+}
+
+func (m *aggCount) Do(v value.Value) {
+	// count(x) counts zero values, only NULL is skipped
+	if value.IsNull(v) || v.Err() {
+		return
+	}
+	m.ct++
+}
+func (m *aggCount) Result() value.Value { return value.NewIntValue(m.ct) }
+func (m *aggCount) State() []value.Value {
+	return []value.Value{value.NewIntValue(m.ct)}
+}
+func (m *aggCount) Merge(state []value.Value) { m.ct += stateInt(state[0]) }
+
+// aggSum sums exactly as int64 while all values are ints, and as float64
+//  once any value is not.  An int sum that overflows int64 is an error
+//  rather than silently wrapping, and the sum of only NULLs is NULL.
+type aggSum struct {
+	i        int64
+	f        float64
+	hasInt   bool
+	isFloat  bool
+	overflow bool
+}
+
+func (m *aggSum) Do(v value.Value) {
+	if iv, isInt := v.(value.IntValue); isInt && !m.isFloat {
+		sum, ok := value.AddChecked(m.i, iv.Val())
+		if !ok {
+			m.overflow = true
+		}
+		m.i = sum
+		m.hasInt = true
+		return
+	}
+	if fv, ok := aggFloat(v); ok {
+		if !m.isFloat {
+			m.f = float64(m.i)
+			m.isFloat = true
+		}
+		m.f += fv
+	}
+}
+func (m *aggSum) Result() value.Value {
+	switch {
+	case !m.hasInt && !m.isFloat:
+		return value.NilValueVal
+	case m.overflow:
+		return value.NewErrorValuef("sum overflows int64")
+	case m.hasInt && !m.isFloat:
+		return value.NewIntValue(m.i)
+	}
+	return value.NewNumberValue(m.f)
+}
+func (m *aggSum) State() []value.Value {
+	return []value.Value{value.NewIntValue(m.i), value.NewNumberValue(m.f), value.NewBoolValue(m.hasInt),
+		value.NewBoolValue(m.isFloat), value.NewBoolValue(m.overflow)}
+}
+func (m *aggSum) Merge(state []value.Value) {
+	i, f, isFloat := stateInt(state[0]), stateFloat(state[1]), stateBool(state[3])
+	switch {
+	case isFloat:
+		if !m.isFloat {
+			m.f = float64(m.i)
+			m.isFloat = true
+		}
+		m.f += f
+	case m.isFloat:
+		m.f += float64(i)
+	default:
+		sum, ok := value.AddChecked(m.i, i)
+		if !ok {
+			m.overflow = true
+		}
+		m.i = sum
+	}
+	m.hasInt = m.hasInt || stateBool(state[2])
+	m.overflow = m.overflow || stateBool(state[4])
+}
+
+type aggAvg struct {
+	v  float64
+	ct int64
+}
+
+func (m *aggAvg) Do(v value.Value) {
+	if fv, ok := aggFloat(v); ok {
+		m.v += fv
+		m.ct++
+	}
+}
+
+// Result is NULL if every value in the group was NULL
+func (m *aggAvg) Result() value.Value {
+	if m.ct == 0 {
+		return value.NilValueVal
+	}
+	return value.NewNumberValue(m.v / float64(m.ct))
+}
+func (m *aggAvg) State() []value.Value {
+	return []value.Value{value.NewNumberValue(m.v), value.NewIntValue(m.ct)}
+}
+func (m *aggAvg) Merge(state []value.Value) {
+	m.v += stateFloat(state[0])
+	m.ct += stateInt(state[1])
+}
+
+type aggMin struct {
+	v float64
+}
+
+func (m *aggMin) Do(v value.Value) {
+	if v == nil {
+		return
+	}
+	// NaN is ignored, it only results if there are no other values
+	if fv, ok := value.ToFloat64(v.Rv()); ok && !math.IsNaN(fv) {
+		if math.IsNaN(m.v) || value.CompareFloat(fv, m.v) < 0 {
+			m.v = fv
+		}
+	}
+}
+func (m *aggMin) Result() value.Value       { return value.NewNumberValue(m.v) }
+func (m *aggMin) State() []value.Value      { return []value.Value{m.Result()} }
+func (m *aggMin) Merge(state []value.Value) { m.Do(state[0]) }
+
+type aggMax struct {
+	v float64
+}
+
+func (m *aggMax) Do(v value.Value) {
+	if v == nil {
+		return
+	}
+	// NaN is ignored, it only results if there are no other values
+	if fv, ok := value.ToFloat64(v.Rv()); ok && !math.IsNaN(fv) {
+		if math.IsNaN(m.v) || value.CompareFloat(fv, m.v) > 0 {
+			m.v = fv
+		}
+	}
+}
+func (m *aggMax) Result() value.Value       { return value.NewNumberValue(m.v) }
+func (m *aggMax) State() []value.Value      { return []value.Value{m.Result()} }
+func (m *aggMax) Merge(state []value.Value) { m.Do(state[0]) }
+
+// stateInt, stateFloat, stateBool read back the values of a spilled
+//  partial aggregate state, see partialAggregator
+func stateInt(v value.Value) int64 {
+	if iv, ok := v.(value.IntValue); ok {
+		return iv.Val()
+	}
+	return 0
+}
+func stateFloat(v value.Value) float64 {
+	if nv, ok := v.(value.NumericValue); ok {
+		return nv.Float()
+	}
+	return 0
+}
+func stateBool(v value.Value) bool {
+	if bv, ok := v.(value.BoolValue); ok {
+		return bv.Val()
+	}
+	return false
+}
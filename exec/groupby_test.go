@@ -0,0 +1,260 @@
+package exec
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/value"
+)
+
+// run a GroupBy task directly over a set of in-memory rows
+func runGroupBy(t *testing.T, sqlText string, policy NaNPolicy, rows []map[string]value.Value) []map[string]value.Value {
+	return runGroupByWith(t, sqlText, func(g *GroupBy) { g.NaNPolicy = policy }, rows)
+}
+
+// runGroupBy with setup of the GroupBy before it is run
+func runGroupByWith(t *testing.T, sqlText string, setup func(g *GroupBy), rows []map[string]value.Value) []map[string]value.Value {
+	stmt, err := expr.ParseSql(sqlText)
+	assert.Tf(t, err == nil, "no error %v", err)
+	sqlSelect := stmt.(*expr.SqlSelect)
+
+	groupBy := NewGroupBy(sqlSelect)
+	setup(groupBy)
+	inCh := make(MessageChan, len(rows))
+	for _, row := range rows {
+		inCh <- datasource.NewContextSimpleData(row)
+	}
+	close(inCh)
+	groupBy.MessageInSet(inCh)
+
+	err = groupBy.Run(expr.NewContext())
+	assert.Tf(t, err == nil, "no error %v", err)
+
+	results := make([]map[string]value.Value, 0)
+	for msg := range groupBy.MessageOut() {
+		results = append(results, msg.(*datasource.ContextSimple).Row())
+	}
+	return results
+}
+
+func TestGroupByFloatKeys(t *testing.T) {
+	rows := []map[string]value.Value{
+		{"val": value.NewNumberValue(1.0)},
+		{"val": value.NewNumberValue(1.00)},
+		{"val": value.NewIntValue(1)},
+		{"val": value.NewNumberValue(math.Copysign(0, -1))},
+		{"val": value.NewNumberValue(0)},
+		{"val": value.NewNumberValue(2.5)},
+		{"val": value.NewNumberValue(math.NaN())},
+		{"val": value.NewNumberValue(math.NaN())},
+	}
+	sqlText := `select val, count(*) AS ct FROM t GROUP BY val`
+
+	results := runGroupBy(t, sqlText, NaNSeparate, rows)
+	assert.Tf(t, len(results) == 5, "1, 0, 2.5 and each NaN are own groups %v", results)
+	assert.Tf(t, results[0]["ct"].Value() == int64(3), "1.0, 1.00, 1 should group %v", results[0])
+	assert.Tf(t, results[1]["ct"].Value() == int64(2), "-0 and 0 should group %v", results[1])
+	assert.Tf(t, results[2]["ct"].Value() == int64(1), "%v", results[2])
+	assert.Tf(t, results[3]["ct"].Value() == int64(1), "NaN never groups with NaN %v", results[3])
+	assert.Tf(t, results[4]["ct"].Value() == int64(1), "NaN never groups with NaN %v", results[4])
+
+	results = runGroupBy(t, sqlText, NaNDrop, rows)
+	assert.Tf(t, len(results) == 3, "NaN rows should be dropped %v", results)
+
+	// a string that looks like a NaN group key is not grouped with one
+	rows = []map[string]value.Value{
+		{"val": value.NewNumberValue(math.NaN())},
+		{"val": value.NewStringValue("NaN-1")},
+	}
+	results = runGroupBy(t, sqlText, NaNSeparate, rows)
+	assert.Tf(t, len(results) == 2, "NaN and string are own groups %v", results)
+}
+
+func TestGroupByFirstLast(t *testing.T) {
+	ts := func(hr int) value.Value {
+		return value.NewTimeValue(time.Date(2015, 7, 4, hr, 0, 0, 0, time.UTC))
+	}
+	rows := []map[string]value.Value{
+		{"device": value.NewStringValue("a"), "status": value.NewStringValue("on"), "ts": ts(3)},
+		{"device": value.NewStringValue("a"), "status": value.NewStringValue("off"), "ts": ts(5)},
+		{"device": value.NewStringValue("a"), "status": value.NewStringValue("boot"), "ts": ts(1)},
+		{"device": value.NewStringValue("a"), "status": value.NewStringValue("unknown"), "ts": value.NilValueVal},
+		{"device": value.NewStringValue("b"), "status": value.NewStringValue("on"), "ts": value.NewStringValue("2015-07-04T02:00:00Z")},
+		{"device": value.NewStringValue("b"), "status": value.NewStringValue("tie"), "ts": value.NewStringValue("2015-07-04T02:00:00Z")},
+		{"device": value.NewStringValue("b"), "status": value.NewStringValue("boot"), "ts": value.NewStringValue("2015-07-04T01:00:00Z")},
+	}
+	sqlText := `select device, last(status, ts) AS latest, first(status, ts) AS earliest, last(status) AS arrived
+		FROM events GROUP BY device`
+
+	results := runGroupBy(t, sqlText, NaNSeparate, rows)
+	assert.Tf(t, len(results) == 2, "%v", results)
+	assert.Tf(t, results[0]["latest"].ToString() == "off", "latest per device %v", results[0])
+	assert.Tf(t, results[0]["earliest"].ToString() == "boot", "%v", results[0])
+	assert.Tf(t, results[0]["arrived"].ToString() == "unknown", "no order by is arrival %v", results[0])
+	assert.Tf(t, results[1]["latest"].ToString() == "on", "first seen wins ties %v", results[1])
+	assert.Tf(t, results[1]["earliest"].ToString() == "boot", "%v", results[1])
+
+	assert.T(t, compareOrder(value.NewIntValue(2), value.NewStringValue("10")) < 0)
+	assert.T(t, compareOrder(value.NewStringValue("b"), value.NewStringValue("a")) > 0)
+}
+
+func TestGroupByMinMaxNaN(t *testing.T) {
+	rows := []map[string]value.Value{
+		{"g": value.NewStringValue("a"), "val": value.NewNumberValue(math.NaN()), "name": value.NewStringValue("nan")},
+		{"g": value.NewStringValue("a"), "val": value.NewNumberValue(4), "name": value.NewStringValue("four")},
+		{"g": value.NewStringValue("a"), "val": value.NewNumberValue(-2), "name": value.NewStringValue("neg")},
+		{"g": value.NewStringValue("a"), "val": value.NewNumberValue(math.NaN()), "name": value.NewStringValue("nan")},
+		{"g": value.NewStringValue("b"), "val": value.NewNumberValue(math.NaN()), "name": value.NewStringValue("nan")},
+	}
+	sqlText := `select g, min(val) AS lo, max(val) AS hi, first(name, val) AS earliest, last(name, val) AS latest
+		FROM t GROUP BY g`
+
+	results := runGroupBy(t, sqlText, NaNSeparate, rows)
+	assert.Tf(t, len(results) == 2, "%v", results)
+	assert.Tf(t, results[0]["lo"].Value() == float64(-2), "min ignores NaN %v", results[0])
+	assert.Tf(t, results[0]["hi"].Value() == float64(4), "max ignores NaN %v", results[0])
+	assert.Tf(t, results[0]["earliest"].ToString() == "neg", "%v", results[0])
+	assert.Tf(t, results[0]["latest"].ToString() == "nan", "NaN orders after numbers %v", results[0])
+	lo, _ := value.ToFloat64(results[1]["lo"].Rv())
+	assert.Tf(t, math.IsNaN(lo), "only NaN values is NaN %v", results[1])
+
+	nan := value.NewNumberValue(math.NaN())
+	assert.T(t, compareOrder(nan, value.NewNumberValue(math.Inf(1))) > 0)
+	assert.T(t, compareOrder(value.NewStringValue("10"), nan) < 0)
+	assert.T(t, compareOrder(nan, nan) == 0)
+}
+
+func TestGroupBySumAvgNulls(t *testing.T) {
+	rows := []map[string]value.Value{
+		{"g": value.NewStringValue("a"), "val": value.NewIntValue(2)},
+		{"g": value.NewStringValue("a"), "val": value.NilValueVal},
+		{"g": value.NewStringValue("a"), "val": value.NewNumberValue(4)},
+		{"g": value.NewStringValue("a"), "val": value.NewIntValue(0)},
+		{"g": value.NewStringValue("b"), "val": value.NilValueVal},
+		{"g": value.NewStringValue("b")},
+	}
+	sqlText := `select g, sum(val) AS total, avg(val) AS mean, count(val) AS ct FROM x GROUP BY g`
+	results := runGroupBy(t, sqlText, NaNSeparate, rows)
+	assert.Tf(t, len(results) == 2, "2 groups %v", results)
+	assert.Tf(t, results[0]["ct"].Value() == int64(3), "count includes zero, not null %v", results[0])
+	assert.Tf(t, results[1]["ct"].Value() == int64(0), "count of all nulls is 0 %v", results[1])
+	assert.Tf(t, results[0]["total"].Value() == float64(6), "sum skips nulls %v", results[0])
+	assert.Tf(t, results[0]["mean"].Value() == float64(2), "avg counts only non-null, zero included %v", results[0])
+	assert.Tf(t, results[1]["mean"].Type() == value.NilType, "avg of all nulls is NULL %v", results[1])
+	assert.Tf(t, results[1]["total"].Type() == value.NilType, "sum of all nulls is NULL %v", results[1])
+}
+
+func TestGroupBySumIntOverflow(t *testing.T) {
+	sqlText := `select g, sum(val) AS total FROM x GROUP BY g`
+	rows := []map[string]value.Value{
+		{"g": value.NewStringValue("a"), "val": value.NewIntValue(math.MaxInt64 - 1)},
+		{"g": value.NewStringValue("a"), "val": value.NewIntValue(1)},
+	}
+	results := runGroupBy(t, sqlText, NaNSeparate, rows)
+	assert.Tf(t, results[0]["total"].Value() == int64(math.MaxInt64), "int sums are exact %v", results[0])
+
+	rows = append(rows, map[string]value.Value{"g": value.NewStringValue("a"), "val": value.NewIntValue(1)})
+	stmt, err := expr.ParseSql(sqlText)
+	assert.Tf(t, err == nil, "no error %v", err)
+	groupBy := NewGroupBy(stmt.(*expr.SqlSelect))
+	inCh := make(MessageChan, len(rows))
+	for _, row := range rows {
+		inCh <- datasource.NewContextSimpleData(row)
+	}
+	close(inCh)
+	groupBy.MessageInSet(inCh)
+	err = groupBy.Run(expr.NewContext())
+	assert.Tf(t, err != nil && strings.Contains(err.Error(), "overflow"), "should not wrap %v", err)
+}
+
+func TestGroupBySpill(t *testing.T) {
+	rows := make([]map[string]value.Value, 0)
+	for i := 0; i < 400; i++ {
+		rows = append(rows, map[string]value.Value{
+			"g":    value.NewStringValue(fmt.Sprintf("g%d", i%40)),
+			"val":  value.NewIntValue(int64(i)),
+			"name": value.NewStringValue(fmt.Sprintf("n%d", i)),
+			"ts":   value.NewTimeValue(time.Unix(int64(1000-i), 0)),
+		})
+	}
+	sqlText := `select g, count(*) AS ct, sum(val) AS total, avg(val) AS av, min(val) AS lo,
+		max(val) AS hi, last(name) AS lastname, first(name, ts) AS earliest FROM x GROUP BY g`
+	byGroup := func(results []map[string]value.Value) map[string]string {
+		groups := make(map[string]string, len(results))
+		for _, row := range results {
+			groups[row["g"].ToString()] = fmt.Sprintf("%v %v %v %v %v %v %v", row["ct"].Value(), row["total"].Value(),
+				row["av"].Value(), row["lo"].Value(), row["hi"].Value(), row["lastname"].Value(), row["earliest"].Value())
+		}
+		return groups
+	}
+	expected := byGroup(runGroupBy(t, sqlText, NaNSeparate, rows))
+	assert.Tf(t, len(expected) == 40, "%v", expected)
+	assert.Tf(t, expected["g1"] == "10 1810 181 1 361 n361 n361", "%v", expected["g1"])
+
+	dir, err := ioutil.TempDir("", "qlbridge-groupby-test")
+	assert.Tf(t, err == nil, "%v", err)
+	defer os.RemoveAll(dir)
+
+	var groupBy *GroupBy
+	results := runGroupByWith(t, sqlText, func(g *GroupBy) {
+		g.MaxMemGroups = 3
+		g.Partitions = 4
+		g.TempDir = dir
+		groupBy = g
+	}, rows)
+	got := byGroup(results)
+	assert.Tf(t, len(results) == 40 && len(got) == 40, "each group once %d", len(results))
+	for g, agg := range expected {
+		assert.Tf(t, got[g] == agg, "group %s want %s got %s", g, agg, got[g])
+	}
+	files, _ := ioutil.ReadDir(dir)
+	assert.Tf(t, len(files) == 0, "spill files removed %v", files)
+	assert.Tf(t, groupBy.spilled > 4, "should spill more than one pass %v", groupBy.spilled)
+
+	// a single partition still completes MaxMemGroups groups per pass, and
+	//  each spill file is removed once merged rather than held open until
+	//  all of the passes are done
+	stmt, err := expr.ParseSql(sqlText)
+	assert.Tf(t, err == nil, "no error %v", err)
+	groupBy = NewGroupBy(stmt.(*expr.SqlSelect))
+	groupBy.MaxMemGroups = 3
+	groupBy.Partitions = 1
+	groupBy.TempDir = dir
+	inCh := make(MessageChan, len(rows))
+	for _, row := range rows {
+		inCh <- datasource.NewContextSimpleData(row)
+	}
+	close(inCh)
+	groupBy.MessageInSet(inCh)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- groupBy.Run(expr.NewContext())
+	}()
+	results = results[:0]
+	maxFiles := 0
+	for msg := range groupBy.MessageOut() {
+		results = append(results, msg.(*datasource.ContextSimple).Row())
+		files, _ := ioutil.ReadDir(dir)
+		if len(files) > maxFiles {
+			maxFiles = len(files)
+		}
+	}
+	assert.Tf(t, <-errCh == nil, "no error")
+	got = byGroup(results)
+	assert.Tf(t, len(results) == 40 && len(got) == 40, "each group once %d", len(results))
+	for g, agg := range expected {
+		assert.Tf(t, got[g] == agg, "group %s want %s got %s", g, agg, got[g])
+	}
+	assert.Tf(t, maxFiles <= 2, "merged spill files are removed, at most %d open", maxFiles)
+	files, _ = ioutil.ReadDir(dir)
+	assert.Tf(t, len(files) == 0, "spill files removed %v", files)
+}
@@ -3,13 +3,16 @@ package exec
 import (
 	"database/sql/driver"
 	"fmt"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	u "github.com/araddon/gou"
 
 	"github.com/araddon/qlbridge/datasource"
 	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/value"
 	"github.com/araddon/qlbridge/vm"
 )
 
@@ -46,11 +49,21 @@ func NewJoinKey(from *expr.SqlSource, conf *datasource.RuntimeSchema) (*JoinKey,
 		TaskBase: NewTaskBase("JoinKey"),
 		colIndex: make(map[string]int),
 		from:     from,
+		conf:     conf,
 	}
 	return m, nil
 }
 
-func (m *JoinKey) Copy() *JoinKey { return &JoinKey{} }
+// Copy creates a new JoinKey task with same source, schema and column
+//  index, but its own channels so it may run in parallel with this one
+func (m *JoinKey) Copy() *JoinKey {
+	return &JoinKey{
+		TaskBase: NewTaskBaseSize(m.TaskType, cap(m.msgOutCh)),
+		conf:     m.conf,
+		from:     m.from,
+		colIndex: copyColIndex(m.colIndex),
+	}
+}
 
 func (m *JoinKey) Close() error {
 	if err := m.TaskBase.Close(); err != nil {
@@ -79,31 +92,66 @@ func (m *JoinKey) Run(context *expr.Context) error {
 				return nil
 			} else {
 				//u.Infof("In joinkey msg %#v", msg)
-			msgTypeSwitch:
-				switch mt := msg.(type) {
-				case *datasource.SqlDriverMessageMap:
-					vals := make([]string, len(joinNodes))
-					for i, node := range joinNodes {
-						joinVal, ok := vm.Eval(mt, node)
-						//u.Debugf("evaluating: ok?%v T:%T result=%v node '%v'", ok, joinVal, joinVal.ToString(), node.String())
-						if !ok {
-							u.Errorf("could not evaluate: %T %#v   %v", joinVal, joinVal, msg)
-							break msgTypeSwitch
-						}
-						vals[i] = joinVal.ToString()
-					}
-					key := strings.Join(vals, string(byte(0)))
+				start, sent := m.metricsStart(), false
+				mt, err := joinMessage("JoinKey", msg)
+				if err != nil {
+					return m.recordError(err)
+				}
+				if key, ok := m.joinKey(mt, joinNodes); ok {
 					mt.SetKeyHashed(key)
 					outCh <- mt
-				default:
-					return fmt.Errorf("To use JoinKey must use SqlDriverMessageMap but got %T", msg)
+					sent = true
 				}
+				m.recordHandled(start, sent)
 			}
 		}
 	}
 	return nil
 }
 
+// joinKey is the composite of each join expression value of a message,
+//  returns false if the message should be dropped
+func (m *JoinKey) joinKey(mt *datasource.SqlDriverMessageMap, joinNodes []expr.Node) (string, bool) {
+	reader := joinReader(m.conf, mt)
+	tol := joinTimeTolerance(m.conf)
+	vals := make([]string, len(joinNodes))
+	for i, node := range joinNodes {
+		joinVal, ok := vm.Eval(reader, node)
+		//u.Debugf("evaluating: ok?%v T:%T result=%v node '%v'", ok, joinVal, joinVal.ToString(), node.String())
+		if !ok {
+			err := fmt.Errorf("could not evaluate join key %s: %s", node, value.Debug(joinVal))
+			if !m.sendError(mt, err) {
+				u.Errorf("could not evaluate: %s   %s", value.Debug(joinVal), value.DebugRow(mt.Row()))
+			}
+			return "", false
+		}
+		if joinVal == nil || joinVal.Type() == value.NilType {
+			// NULL never equals NULL, so a row with a NULL in its
+			//  join key can never match, drop it (inner join)
+			return "", false
+		}
+		if sv, isStruct := joinVal.(value.StructValue); isStruct {
+			vals[i] = sv.EqualityKey()
+		} else if tv, isTime := joinVal.(value.TimeValue); isTime && tol > 0 {
+			vals[i] = timeBucketKey(tv.Val(), tol)
+		} else {
+			vals[i] = joinVal.ToString()
+		}
+	}
+	return strings.Join(vals, string(byte(0))), true
+}
+
+// joinMessage is the SqlDriverMessageMap of a join input message.  Unlike
+//  the other tasks a join can not use any datasource.MessageReader, the
+//  join key is set on the message and the merge places values by their
+//  position in the source row, neither of which a ContextReader has.
+func joinMessage(task string, msg datasource.Message) (*datasource.SqlDriverMessageMap, error) {
+	if mt, ok := msg.(*datasource.SqlDriverMessageMap); ok {
+		return mt, nil
+	}
+	return nil, fmt.Errorf("To use %s must use SqlDriverMessageMap but got %T", task, msg)
+}
+
 // Scan a data source for rows, feed into runner for join sources
 //
 //  1) join  SELECT t1.name, t2.salary
@@ -131,9 +179,14 @@ type JoinMerge struct {
 //   source2   ->
 //
 func NewJoinNaiveMerge(ltask, rtask TaskRunner, lfrom, rfrom *expr.SqlSource, conf *datasource.RuntimeSchema) (*JoinMerge, error) {
+	return NewJoinNaiveMergeSize(ltask, rtask, lfrom, rfrom, conf, ItemDefaultChannelSize)
+}
+
+// Join merge with a given output channel buffer size, see NewTaskBaseSize()
+func NewJoinNaiveMergeSize(ltask, rtask TaskRunner, lfrom, rfrom *expr.SqlSource, conf *datasource.RuntimeSchema, bufferSize int) (*JoinMerge, error) {
 
 	m := &JoinMerge{
-		TaskBase: NewTaskBase("JoinNaiveMerge"),
+		TaskBase: NewTaskBaseSize("JoinNaiveMerge", bufferSize),
 		colIndex: make(map[string]int),
 	}
 
@@ -141,11 +194,29 @@ func NewJoinNaiveMerge(ltask, rtask TaskRunner, lfrom, rfrom *expr.SqlSource, co
 	m.rtask = rtask
 	m.leftStmt = lfrom
 	m.rightStmt = rfrom
+	m.conf = conf
 
+	if err := m.buildColIndex(); err != nil {
+		return nil, err
+	}
 	return m, nil
 }
 
-func (m *JoinMerge) Copy() *JoinMerge { return &JoinMerge{} }
+// Copy creates a new JoinMerge with the same join statements, schema,
+//  input tasks and column index, but its own channels.  Parallel (hash
+//  routed) joins will want to point each copy at its own partition of
+//  input tasks.
+func (m *JoinMerge) Copy() *JoinMerge {
+	return &JoinMerge{
+		TaskBase:  NewTaskBaseSize(m.TaskType, cap(m.msgOutCh)),
+		conf:      m.conf,
+		leftStmt:  m.leftStmt,
+		rightStmt: m.rightStmt,
+		ltask:     m.ltask,
+		rtask:     m.rtask,
+		colIndex:  copyColIndex(m.colIndex),
+	}
+}
 
 func (m *JoinMerge) Close() error {
 	if err := m.TaskBase.Close(); err != nil {
@@ -167,18 +238,6 @@ func (m *JoinMerge) Run(context *expr.Context) error {
 	// lhNodes := m.leftStmt.JoinNodes()
 	// rhNodes := m.rightStmt.JoinNodes()
 
-	// Build an index of source to destination column indexing
-	for _, col := range m.leftStmt.Source.Columns {
-		//u.Debugf("left col:  idx=%d  key=%q as=%q col=%v parentidx=%v", len(m.colIndex), col.Key(), col.As, col.String(), col.ParentIndex)
-		m.colIndex[m.leftStmt.Alias+"."+col.Key()] = col.ParentIndex
-		//u.Debugf("colIndex:  %15q : %d", m.leftStmt.Alias+"."+col.Key(), col.SourceIndex)
-	}
-	for _, col := range m.rightStmt.Source.Columns {
-		//u.Debugf("right col:  idx=%d  key=%q as=%q col=%v", len(m.colIndex), col.Key(), col.As, col.String())
-		m.colIndex[m.rightStmt.Alias+"."+col.Key()] = col.ParentIndex
-		//u.Debugf("colIndex:  %15q : %d", m.rightStmt.Alias+"."+col.Key(), col.SourceIndex)
-	}
-
 	// lcols := m.leftStmt.Source.AliasedColumns()
 	// rcols := m.rightStmt.Source.AliasedColumns()
 
@@ -187,77 +246,57 @@ func (m *JoinMerge) Run(context *expr.Context) error {
 	lh := make(map[string][]*datasource.SqlDriverMessageMap)
 	rh := make(map[string][]*datasource.SqlDriverMessageMap)
 
-	wg := new(sync.WaitGroup)
-	wg.Add(1)
+	// Either side may error before the other has closed, so each scanner
+	//  always marks done, and the first error closes the signal once
+	// so the other side stops waiting on its input
 	var fatalErr error
-	go func() {
+	var failOnce sync.Once
+	fail := func(err error) {
+		failOnce.Do(func() {
+			fatalErr = err
+			close(m.TaskBase.sigCh)
+		})
+	}
+	wg := new(sync.WaitGroup)
+	scan := func(side string, in MessageChan, hash map[string][]*datasource.SqlDriverMessageMap) {
+		defer wg.Done()
 		for {
-			//u.Infof("In source Scanner msg %#v", msg)
 			select {
 			case <-m.SigChan():
-				u.Warnf("got signal quit")
+				u.Debugf("got quit signal join %s", side)
 				return
-			case msg, ok := <-leftIn:
+			case msg, ok := <-in:
 				if !ok {
-					//u.Warnf("NICE, got left shutdown")
-					wg.Done()
+					//u.Warnf("NICE, got %s shutdown", side)
 					return
-				} else {
-					switch mt := msg.(type) {
-					case *datasource.SqlDriverMessageMap:
-						key := mt.Key()
-						if key == "" {
-							fatalErr = fmt.Errorf(`To use Join msgs must have keys but got "" for %+v`, mt.Row())
-							close(m.TaskBase.sigCh)
-							return
-						}
-						lh[key] = append(lh[key], mt)
-					default:
-						fatalErr = fmt.Errorf("To use Join must use SqlDriverMessageMap but got %T", msg)
-						close(m.TaskBase.sigCh)
-						return
-					}
 				}
-			}
-
-		}
-	}()
-	wg.Add(1)
-	go func() {
-		for {
-
-			//u.Infof("In source Scanner iter %#v", item)
-			select {
-			case <-m.SigChan():
-				u.Warnf("got quit signal join source 1")
-				return
-			case msg, ok := <-rightIn:
-				if !ok {
-					//u.Warnf("NICE, got right shutdown")
-					wg.Done()
+				start := m.metricsStart()
+				mt, err := joinMessage("Join", msg)
+				if err != nil {
+					fail(err)
+					return
+				}
+				key := mt.Key()
+				if key == "" {
+					fail(fmt.Errorf(`To use Join msgs must have keys but got "" for %+v`, mt.Row()))
 					return
-				} else {
-					switch mt := msg.(type) {
-					case *datasource.SqlDriverMessageMap:
-						key := mt.Key()
-						if key == "" {
-							fatalErr = fmt.Errorf(`To use Join msgs must have keys but got "" for %+v`, mt.Row())
-							close(m.TaskBase.sigCh)
-							return
-						}
-						rh[key] = append(rh[key], mt)
-					default:
-						fatalErr = fmt.Errorf("To use Join must use SqlDriverMessageMap but got %T", msg)
-						close(m.TaskBase.sigCh)
-						return
-					}
 				}
+				hash[key] = append(hash[key], mt)
+				m.recordHandled(start, true)
 			}
-
 		}
-	}()
+	}
+	wg.Add(2)
+	go scan("left", leftIn, lh)
+	go scan("right", rightIn, rh)
 	wg.Wait()
+	if fatalErr != nil {
+		return m.recordError(fatalErr)
+	}
 	//u.Info("leaving source scanner")
+	if tol := joinTimeTolerance(m.conf); tol > 0 {
+		return m.recordError(m.mergeWithin(outCh, lh, rh, tol))
+	}
 	i := uint64(0)
 	for keyLeft, valLeft := range lh {
 		//u.Debugf("compare:  key:%v  left:%#v  right:%#v  rh: %#v", keyLeft, valLeft, rh[keyLeft], rh)
@@ -270,13 +309,181 @@ func (m *JoinMerge) Run(context *expr.Context) error {
 				//u.Debugf("i:%d   msg:%#v", i, msg.Row())
 				msg.IdVal = i
 				i++
-				outCh <- msg
+				select {
+				case outCh <- msg:
+				case <-m.SigChan():
+					return nil
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// mergeWithin merges rows whose time join keys are within tol of each
+//  other.  Time keys are buckets of tol width, so matches are in the
+//  same or a neighbouring bucket, and are verified with the join values.
+func (m *JoinMerge) mergeWithin(outCh MessageChan, lh, rh map[string][]*datasource.SqlDriverMessageMap, tol time.Duration) error {
+	lnodes, rnodes := m.leftStmt.JoinNodes(), m.rightStmt.JoinNodes()
+	i := uint64(0)
+	for keyLeft, valLeft := range lh {
+		for _, lm := range valLeft {
+			matched := make([]*datasource.SqlDriverMessageMap, 0)
+			for _, key := range timeBucketNeighbors(keyLeft) {
+				for _, rm := range rh[key] {
+					if m.joinValuesWithin(lm, rm, lnodes, rnodes, tol) {
+						matched = append(matched, rm)
+					}
+				}
+			}
+			if len(matched) == 0 {
+				continue
+			}
+			for _, msg := range m.mergeValueMessages([]*datasource.SqlDriverMessageMap{lm}, matched) {
+				msg.IdVal = i
+				i++
+				select {
+				case outCh <- msg:
+				case <-m.SigChan():
+					return nil
+				}
 			}
 		}
 	}
 	return nil
 }
 
+// are the join values of two rows equal, times within tol
+func (m *JoinMerge) joinValuesWithin(lm, rm *datasource.SqlDriverMessageMap, lnodes, rnodes []expr.Node, tol time.Duration) bool {
+	if len(lnodes) != len(rnodes) {
+		return false
+	}
+	lreader, rreader := joinReader(m.conf, lm), joinReader(m.conf, rm)
+	for i := range lnodes {
+		lv, lok := vm.Eval(lreader, lnodes[i])
+		rv, rok := vm.Eval(rreader, rnodes[i])
+		if !lok || !rok || lv == nil || rv == nil {
+			return false
+		}
+		lt, lIsTime := lv.(value.TimeValue)
+		rt, rIsTime := rv.(value.TimeValue)
+		if lIsTime && rIsTime {
+			if !value.TimeEqualWithin(lt, rt, tol) {
+				return false
+			}
+		} else if lv.ToString() != rv.ToString() {
+			return false
+		}
+	}
+	return true
+}
+
+// the reader join key expressions are evaluated against
+func joinReader(conf *datasource.RuntimeSchema, mt *datasource.SqlDriverMessageMap) expr.ContextReader {
+	if conf == nil {
+		return mt
+	}
+	var reader expr.ContextReader = mt
+	if conf.CaseInsensitive {
+		reader = datasource.NewContextCaseInsensitive(mt)
+	}
+	if opts := conf.EvalOptions(); opts != nil {
+		reader = datasource.NewContextOptions(reader, opts)
+	}
+	return reader
+}
+
+func joinTimeTolerance(conf *datasource.RuntimeSchema) time.Duration {
+	if conf == nil {
+		return 0
+	}
+	return conf.TimeTolerance
+}
+
+// time join key parts are the bucket of tol width, marked with a prefix
+//  so neighbouring buckets can be found from the key
+const timeBucketPrefix = "\x01t"
+
+func timeBucketKey(t time.Time, tol time.Duration) string {
+	ns, width := t.UnixNano(), int64(tol)
+	bucket := ns / width
+	if ns%width < 0 {
+		bucket--
+	}
+	return timeBucketPrefix + strconv.FormatInt(bucket, 10)
+}
+
+// the keys with each time bucket part of key, or its neighbours
+func timeBucketNeighbors(key string) []string {
+	keys := []string{""}
+	for i, part := range strings.Split(key, string(byte(0))) {
+		sep := string(byte(0))
+		if i == 0 {
+			sep = ""
+		}
+		parts := []string{part}
+		if strings.HasPrefix(part, timeBucketPrefix) {
+			if bucket, err := strconv.ParseInt(part[len(timeBucketPrefix):], 10, 64); err == nil {
+				parts = []string{
+					timeBucketPrefix + strconv.FormatInt(bucket-1, 10),
+					part,
+					timeBucketPrefix + strconv.FormatInt(bucket+1, 10),
+				}
+			}
+		}
+		next := make([]string, 0, len(keys)*len(parts))
+		for _, k := range keys {
+			for _, p := range parts {
+				next = append(next, k+sep+p)
+			}
+		}
+		keys = next
+	}
+	return keys
+}
+
+// Build an index of source to destination column indexing, and validate
+//  each projected column maps to its own position in the merged values so
+//  a bad rewrite errors at setup instead of producing misaligned rows.
+//  Columns only needed for the join or where are not validated.
+func (m *JoinMerge) buildColIndex() error {
+	for _, from := range []*expr.SqlSource{m.leftStmt, m.rightStmt} {
+		for _, col := range from.Source.Columns {
+			// a column in both the select and where is in the source
+			//  twice, each filled from the same source value
+			m.colIndex[from.Alias+"."+col.Key()] = col.ParentIndex
+		}
+	}
+	used := make(map[int]string, len(m.colIndex))
+	for _, from := range []*expr.SqlSource{m.leftStmt, m.rightStmt} {
+		for _, col := range from.ProjectedColumns() {
+			if col.ParentIndex < 0 {
+				continue
+			}
+			key := from.Alias + "." + col.Key()
+			if col.ParentIndex >= len(m.colIndex) {
+				return fmt.Errorf("join column %q parent index %d out of range of %d columns", key, col.ParentIndex, len(m.colIndex))
+			}
+			if col.SourceIndex < 0 {
+				return fmt.Errorf("join column %q has invalid source index %d", key, col.SourceIndex)
+			}
+			if other, exists := used[col.ParentIndex]; exists && other != key {
+				return fmt.Errorf("join columns %q and %q both map to parent index %d", other, key, col.ParentIndex)
+			}
+			used[col.ParentIndex] = key
+		}
+	}
+	return nil
+}
+
+func copyColIndex(colIndex map[string]int) map[string]int {
+	out := make(map[string]int, len(colIndex))
+	for k, idx := range colIndex {
+		out[k] = idx
+	}
+	return out
+}
+
 func (m *JoinMerge) mergeValueMessages(lmsgs, rmsgs []*datasource.SqlDriverMessageMap) []*datasource.SqlDriverMessageMap {
 	// m.leftStmt.Columns, m.rightStmt.Columns, nil
 	//func mergeValuesMsgs(lmsgs, rmsgs []datasource.Message, lcols, rcols []*expr.Column, cols map[string]*expr.Column) []*datasource.SqlDriverMessageMap {
@@ -308,6 +515,10 @@ func (m *JoinMerge) valIndexing(valOut, valSource []driver.Value, cols []*expr.C
 			u.Warnf("not enough values to read col? i=%v len(vals)=%v  %#v", col.ParentIndex, len(valOut), valOut)
 			continue
 		}
+		if col.SourceIndex < 0 || col.SourceIndex >= len(valSource) {
+			u.Warnf("not enough source values to read col? i=%v len(vals)=%v  %#v", col.SourceIndex, len(valSource), valSource)
+			continue
+		}
 		//u.Infof("found: i=%v pi:%v as=%v	val=%v	source:%v", col.SourceIndex, col.ParentIndex, col.As, valSource[col.SourceIndex], valSource)
 		valOut[col.ParentIndex] = valSource[col.SourceIndex]
 	}
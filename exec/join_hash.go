@@ -0,0 +1,247 @@
+package exec
+
+import (
+	"fmt"
+	"hash/fnv"
+
+	u "github.com/araddon/gou"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/expr"
+)
+
+var (
+	// Ensure that we implement the Task Runner interface
+	_ TaskRunner = (*JoinHash)(nil)
+
+	// JoinHashPartitions is the default number of build side partitions
+	JoinHashPartitions = 16
+)
+
+// JoinHash is a hash join with bounded memory.  The build (right) side is
+//  partitioned by Key() hash, once more than MaxMemRows build messages are
+//  held in memory the largest partition is spilled to a temp file.  The
+//  probe (left) side is joined directly against in-memory partitions, and
+//  written to a matching temp file for spilled ones, which are then joined
+//  partition by partition.  A single spilled partition must fit in memory.
+//
+//   source1 (probe)  ->
+//                       \
+//                         --  hash join  -->
+//                       /
+//   source2 (build)  ->
+//
+type JoinHash struct {
+	*JoinMerge
+	// MaxMemRows is the count of build side messages held in memory
+	//  before partitions spill to disk
+	MaxMemRows int
+	// Partitions is the count of build side hash partitions
+	Partitions int
+	// TempDir for spill files, "" is the os default temp dir
+	TempDir string
+	spilled int // count of partitions spilled
+}
+
+// A build side partition, rows are in memory until spilled
+type joinPartition struct {
+	rows  map[string][]*datasource.SqlDriverMessageMap
+	ct    int
+	build *msgSpill // non-nil once spilled
+	probe *msgSpill
+}
+
+// Hash join with a budget of maxMemRows build side messages in memory,
+//  see NewJoinNaiveMergeSize() for the other args
+func NewJoinHash(ltask, rtask TaskRunner, lfrom, rfrom *expr.SqlSource, conf *datasource.RuntimeSchema,
+	bufferSize, maxMemRows int) (*JoinHash, error) {
+
+	jm, err := NewJoinNaiveMergeSize(ltask, rtask, lfrom, rfrom, conf, bufferSize)
+	if err != nil {
+		return nil, err
+	}
+	jm.TaskType = "JoinHash"
+	m := &JoinHash{
+		JoinMerge:  jm,
+		MaxMemRows: maxMemRows,
+		Partitions: JoinHashPartitions,
+	}
+	return m, nil
+}
+
+func (m *JoinHash) Run(context *expr.Context) error {
+	defer context.Recover()
+	defer close(m.msgOutCh)
+
+	if m.Partitions < 1 {
+		m.Partitions = 1
+	}
+	parts := make([]*joinPartition, m.Partitions)
+	for i := range parts {
+		parts[i] = &joinPartition{rows: make(map[string][]*datasource.SqlDriverMessageMap)}
+	}
+	defer func() {
+		for _, p := range parts {
+			p.build.remove()
+			p.probe.remove()
+		}
+	}()
+
+	// Build, partition the right side
+	memRows := 0
+	err := m.scan(m.rtask.MessageOut(), func(mt *datasource.SqlDriverMessageMap) error {
+		p := parts[m.partition(mt.Key())]
+		if p.build != nil {
+			return p.build.write(mt)
+		}
+		p.rows[mt.Key()] = append(p.rows[mt.Key()], mt)
+		p.ct++
+		memRows++
+		if m.MaxMemRows > 0 && memRows > m.MaxMemRows {
+			freed, err := m.spillLargest(parts)
+			if err != nil {
+				return err
+			}
+			memRows -= freed
+		}
+		return nil
+	})
+	if err == errJoinQuit {
+		return nil
+	} else if err != nil {
+		return m.recordError(err)
+	}
+
+	// Probe, join left side against in-memory partitions
+	i := uint64(0)
+	emit := func(lmsg *datasource.SqlDriverMessageMap, rmsgs []*datasource.SqlDriverMessageMap) bool {
+		for _, msg := range m.mergeValueMessages([]*datasource.SqlDriverMessageMap{lmsg}, rmsgs) {
+			msg.IdVal = i
+			i++
+			select {
+			case m.msgOutCh <- msg:
+			case <-m.SigChan():
+				return false
+			}
+		}
+		return true
+	}
+	err = m.scan(m.ltask.MessageOut(), func(mt *datasource.SqlDriverMessageMap) error {
+		p := parts[m.partition(mt.Key())]
+		if p.build != nil {
+			if p.probe == nil {
+				probe, err := newMsgSpill(m.TempDir, "join")
+				if err != nil {
+					return err
+				}
+				p.probe = probe
+			}
+			return p.probe.write(mt)
+		}
+		if rmsgs, ok := p.rows[mt.Key()]; ok {
+			if !emit(mt, rmsgs) {
+				return errJoinQuit
+			}
+		}
+		return nil
+	})
+	if err == errJoinQuit {
+		return nil
+	} else if err != nil {
+		return m.recordError(err)
+	}
+
+	// Join spilled partitions one at a time
+	for _, p := range parts {
+		if p.build == nil || p.probe == nil {
+			continue
+		}
+		rows := make(map[string][]*datasource.SqlDriverMessageMap)
+		err := p.build.read(func(mt *datasource.SqlDriverMessageMap) error {
+			rows[mt.Key()] = append(rows[mt.Key()], mt)
+			return nil
+		})
+		if err != nil {
+			return m.recordError(err)
+		}
+		err = p.probe.read(func(mt *datasource.SqlDriverMessageMap) error {
+			if rmsgs, ok := rows[mt.Key()]; ok {
+				if !emit(mt, rmsgs) {
+					return errJoinQuit
+				}
+			}
+			return nil
+		})
+		if err == errJoinQuit {
+			return nil
+		} else if err != nil {
+			return m.recordError(err)
+		}
+	}
+	return nil
+}
+
+var errJoinQuit = fmt.Errorf("Join quit")
+
+// scan an input until closed, validating messages are keyed
+func (m *JoinHash) scan(in MessageChan, fn func(mt *datasource.SqlDriverMessageMap) error) error {
+	for {
+		select {
+		case <-m.SigChan():
+			return errJoinQuit
+		case msg, ok := <-in:
+			if !ok {
+				return nil
+			}
+			mt, err := joinMessage("Join", msg)
+			if err != nil {
+				return err
+			}
+			if mt.Key() == "" {
+				return fmt.Errorf(`To use Join msgs must have keys but got "" for %+v`, mt.Row())
+			}
+			start := m.metricsStart()
+			if err := fn(mt); err != nil {
+				return err
+			}
+			m.recordHandled(start, true)
+		}
+	}
+}
+
+func (m *JoinHash) partition(key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(m.Partitions))
+}
+
+// spill the largest in-memory partition to disk, returns rows freed
+func (m *JoinHash) spillLargest(parts []*joinPartition) (int, error) {
+	var largest *joinPartition
+	for _, p := range parts {
+		if p.build == nil && (largest == nil || p.ct > largest.ct) {
+			largest = p
+		}
+	}
+	if largest == nil || largest.ct == 0 {
+		return 0, nil
+	}
+	spill, err := newMsgSpill(m.TempDir, "join")
+	if err != nil {
+		return 0, err
+	}
+	largest.build = spill
+	for _, msgs := range largest.rows {
+		for _, mt := range msgs {
+			if err := spill.write(mt); err != nil {
+				return 0, err
+			}
+		}
+	}
+	freed := largest.ct
+	largest.rows = nil
+	largest.ct = 0
+	m.spilled++
+	u.Debugf("join spilled partition of %d rows to %s", freed, spill.f.Name())
+	return freed, nil
+}
@@ -0,0 +1,389 @@
+package exec
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/datasource/membtree"
+	"github.com/araddon/qlbridge/datasource/mockcsv"
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/value"
+)
+
+func TestJoinMergeErrorMidStream(t *testing.T) {
+	ltask := NewTaskBase("left")
+	rtask := NewTaskBase("right")
+	lfrom := &expr.SqlSource{Alias: "l", Source: &expr.SqlSelect{}}
+	rfrom := &expr.SqlSource{Alias: "r", Source: &expr.SqlSelect{}}
+	join, err := NewJoinNaiveMerge(ltask, rtask, lfrom, rfrom, nil)
+	assert.Tf(t, err == nil, "no error %v", err)
+
+	cols := map[string]int{"user_id": 0}
+	leftStop := make(chan bool)
+	defer close(leftStop)
+	go func() {
+		// left side is mid-stream, it never closes
+		for i := 0; ; i++ {
+			msg := datasource.NewSqlDriverMessageMap(uint64(i), []driver.Value{"abc"}, cols)
+			msg.SetKey("abc")
+			select {
+			case ltask.MessageOut() <- msg:
+			case <-leftStop:
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}()
+	go func() {
+		time.Sleep(time.Millisecond * 5)
+		// no key, the right side errors
+		rtask.MessageOut() <- datasource.NewSqlDriverMessageMap(1, []driver.Value{"abc"}, cols)
+	}()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- join.Run(expr.NewContext())
+	}()
+	select {
+	case err = <-errCh:
+		assert.Tf(t, err != nil, "should return the right side error")
+	case <-time.After(time.Second):
+		t.Fatalf("JoinMerge.Run deadlocked after right side error")
+	}
+	_, open := <-join.MessageOut()
+	assert.T(t, !open)
+}
+
+func TestJoinMergeCopy(t *testing.T) {
+	ltask := NewTaskBase("left")
+	rtask := NewTaskBase("right")
+	lfrom := &expr.SqlSource{Alias: "l", Source: &expr.SqlSelect{}}
+	rfrom := &expr.SqlSource{Alias: "r", Source: &expr.SqlSelect{}}
+	join, err := NewJoinNaiveMergeSize(ltask, rtask, lfrom, rfrom, rtConf, 10)
+	assert.Tf(t, err == nil, "no error %v", err)
+	join.colIndex["l.user_id"] = 0
+
+	joinCopy := join.Copy()
+	assert.T(t, joinCopy.Type() == join.Type())
+	assert.T(t, joinCopy.leftStmt == lfrom && joinCopy.rightStmt == rfrom)
+	assert.T(t, joinCopy.ltask == ltask && joinCopy.rtask == rtask)
+	assert.T(t, joinCopy.conf == rtConf)
+	assert.Tf(t, joinCopy.colIndex["l.user_id"] == 0, "%v", joinCopy.colIndex)
+	assert.T(t, cap(joinCopy.MessageOut()) == 10)
+	assert.T(t, joinCopy.MessageOut() != join.MessageOut())
+
+	// the column index is not shared
+	joinCopy.colIndex["r.user_id"] = 1
+	_, shared := join.colIndex["r.user_id"]
+	assert.T(t, !shared)
+
+	keyTask, err := NewJoinKey(lfrom, rtConf)
+	assert.Tf(t, err == nil, "no error %v", err)
+	keyCopy := keyTask.Copy()
+	assert.T(t, keyCopy.from == lfrom && keyCopy.conf == rtConf)
+	assert.T(t, keyCopy.MessageOut() != keyTask.MessageOut())
+
+	// a join needs the positional row of a SqlDriverMessageMap, any other
+	//  message is an error rather than silently dropped
+	in := make(MessageChan, 1)
+	in <- datasource.NewContextSimpleData(map[string]value.Value{"user_id": value.NewStringValue("a")})
+	close(in)
+	keyTask.MessageInSet(in)
+	err = keyTask.Run(expr.NewContext())
+	assert.Tf(t, err != nil && strings.Contains(err.Error(), "JoinKey must use SqlDriverMessageMap"), "%v", err)
+}
+
+func TestJoinMergeColIndexValidation(t *testing.T) {
+	newFroms := func(lcols, rcols expr.Columns) (*expr.SqlSource, *expr.SqlSource) {
+		return &expr.SqlSource{Alias: "l", Source: &expr.SqlSelect{Columns: lcols}},
+			&expr.SqlSource{Alias: "r", Source: &expr.SqlSelect{Columns: rcols}}
+	}
+	lfrom, rfrom := newFroms(
+		expr.Columns{{As: "user_id", ParentIndex: 0}, {As: "name", ParentIndex: 1, SourceIndex: 1}},
+		expr.Columns{{As: "user_id", ParentIndex: -1}, {As: "item", ParentIndex: 2, SourceIndex: 2}},
+	)
+	join, err := NewJoinNaiveMerge(NewTaskBase("left"), NewTaskBase("right"), lfrom, rfrom, rtConf)
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Tf(t, len(join.colIndex) == 4 && join.colIndex["r.item"] == 2, "%v", join.colIndex)
+
+	// both sides claim parent index 1, one would overwrite the other
+	lfrom, rfrom = newFroms(
+		expr.Columns{{As: "user_id", ParentIndex: 0}, {As: "name", ParentIndex: 1, SourceIndex: 1}},
+		expr.Columns{{As: "item", ParentIndex: 1, SourceIndex: 2}},
+	)
+	_, err = NewJoinNaiveMerge(NewTaskBase("left"), NewTaskBase("right"), lfrom, rfrom, rtConf)
+	assert.Tf(t, err != nil && strings.Contains(err.Error(), "parent index 1"), "%v", err)
+
+	// parent index past the merged row
+	lfrom, rfrom = newFroms(
+		expr.Columns{{As: "user_id", ParentIndex: 0}},
+		expr.Columns{{As: "item", ParentIndex: 5}},
+	)
+	_, err = NewJoinNaiveMerge(NewTaskBase("left"), NewTaskBase("right"), lfrom, rfrom, rtConf)
+	assert.Tf(t, err != nil && strings.Contains(err.Error(), "out of range"), "%v", err)
+
+	lfrom, rfrom = newFroms(
+		expr.Columns{{As: "user_id", ParentIndex: 0, SourceIndex: -1}},
+		expr.Columns{{As: "item", ParentIndex: 1}},
+	)
+	_, err = NewJoinNaiveMerge(NewTaskBase("left"), NewTaskBase("right"), lfrom, rfrom, rtConf)
+	assert.Tf(t, err != nil && strings.Contains(err.Error(), "source index"), "%v", err)
+
+	// where columns are in the source but not projected, both sides' where
+	//  columns start at the same parent index past the projected columns
+	stmt, err := expr.ParseSql(`SELECT u.name, o.item FROM users AS u
+		INNER JOIN orders AS o ON u.user_id = o.user_id
+		WHERE u.email != "" AND o.price > 1 AND o.qty > 2 AND o.sku != ""`)
+	assert.Tf(t, err == nil, "no error %v", err)
+	sel := stmt.(*expr.SqlSelect)
+	for _, from := range sel.From {
+		from.Rewrite(sel)
+	}
+	lfrom, rfrom = sel.From[0], sel.From[1]
+	assert.Tf(t, len(rfrom.ProjectedColumns()) == 1 && len(rfrom.Source.Columns) == 5, "%v", rfrom.Source.Columns)
+	join, err = NewJoinNaiveMerge(NewTaskBase("left"), NewTaskBase("right"), lfrom, rfrom, rtConf)
+	assert.Tf(t, err == nil, "only projected columns are validated %v", err)
+	vals := join.valIndexing(make([]driver.Value, 2), []driver.Value{"apple", 7, 3, 5, "x"}, rfrom.Source.Columns)
+	assert.Tf(t, vals[1] == "apple", "projected values are placed, others past the row skipped %v", vals)
+}
+
+func findJoinHash(tasks Tasks) *JoinHash {
+	for _, task := range tasks {
+		if jh, ok := task.(*JoinHash); ok {
+			return jh
+		}
+		if jh := findJoinHash(task.Children()); jh != nil {
+			return jh
+		}
+	}
+	return nil
+}
+
+// run the join sql, with JoinMaxMemRows, returns sorted "name:item" rows
+func runJoinMaxMemRows(t *testing.T, sqlText string, maxMemRows int) ([]string, *JoinHash) {
+	stmt, err := expr.ParseSqlVm(sqlText)
+	assert.Tf(t, err == nil, "no error %v", err)
+
+	builder := NewJobBuilder(rtConf, "mockcsv")
+	builder.JoinMaxMemRows = maxMemRows
+	task, err := stmt.Accept(builder)
+	assert.Tf(t, err == nil, "no error %v", err)
+	job := &SqlJob{task.(TaskRunner), stmt, rtConf}
+
+	msgs := make([]datasource.Message, 0)
+	resultWriter := NewResultBuffer(&msgs)
+	job.RootTask.Add(resultWriter)
+
+	err = job.Setup()
+	assert.T(t, err == nil)
+	err = job.Run()
+	time.Sleep(time.Millisecond * 10)
+	assert.Tf(t, err == nil, "no error %v", err)
+
+	rows := make([]string, len(msgs))
+	for i, msg := range msgs {
+		row := msg.Body().(*datasource.ContextSimple).Row()
+		rows[i] = row["u.name"].ToString() + ":" + row["o.item"].ToString()
+	}
+	sort.Strings(rows)
+	return rows, findJoinHash(job.RootTask.Children())
+}
+
+func TestJoinHashSpill(t *testing.T) {
+	// membtree scans stop at 20 rows, so keep tables smaller
+	var users, orders bytes.Buffer
+	users.WriteString("user_id,name\n")
+	for i := 0; i < 12; i++ {
+		fmt.Fprintf(&users, "u%d,name%d\n", i, i)
+	}
+	orders.WriteString("order_id,user_id,item\n")
+	for i := 0; i < 19; i++ {
+		// some users have many orders, some orders no user
+		fmt.Fprintf(&orders, "%d,u%d,item%d\n", i, (i*7)%15, i)
+	}
+	mockcsv.LoadTable("hjusers", users.String())
+	mockcsv.LoadTable("hjorders", orders.String())
+
+	sqlText := `
+		SELECT u.name, o.item
+		FROM hjusers AS u
+		INNER JOIN hjorders AS o
+			ON u.user_id = o.user_id
+	`
+	expected, jh := runJoinMaxMemRows(t, sqlText, 0)
+	assert.T(t, jh == nil)
+	assert.Tf(t, len(expected) == 15, "should join 15 rows %v", len(expected))
+
+	// tiny memory budget forces the build side to spill
+	rows, jh := runJoinMaxMemRows(t, sqlText, 3)
+	assert.T(t, jh != nil)
+	assert.Tf(t, jh.spilled > 0, "should have spilled partitions %v", jh.spilled)
+	assert.Tf(t, len(rows) == len(expected), "expected %d rows got %d", len(expected), len(rows))
+	for i := range expected {
+		assert.Tf(t, rows[i] == expected[i], "row %d expected %s got %s", i, expected[i], rows[i])
+	}
+
+	// budget large enough, nothing spilled, same results
+	rows, jh = runJoinMaxMemRows(t, sqlText, 1000)
+	assert.Tf(t, jh != nil && jh.spilled == 0, "should not spill %v", jh)
+	assert.Tf(t, len(rows) == len(expected), "expected %d rows got %d", len(expected), len(rows))
+}
+
+// a struct join column, locations are equal if their coordinates are
+type location struct {
+	X, Y  int
+	Label string
+}
+
+func (m location) CompareTo(other interface{}) int {
+	o := other.(location)
+	switch {
+	case m.X != o.X:
+		return m.X - o.X
+	case m.Y != o.Y:
+		return m.Y - o.Y
+	}
+	return 0
+}
+func (m location) EqualityKey() string { return fmt.Sprintf("%d,%d", m.X, m.Y) }
+
+func TestJoinStructColumn(t *testing.T) {
+	stmt, err := expr.ParseSqlVm(`SELECT p.name, s.store FROM people AS p INNER JOIN stores AS s ON p.loc = s.loc`)
+	assert.Tf(t, err == nil, "no error %v", err)
+	sel := stmt.(*expr.SqlSelect)
+	lfrom, rfrom := sel.From[0], sel.From[1]
+	lfrom.Rewrite(sel)
+	rfrom.Rewrite(sel)
+
+	// feed messages of source columns through a JoinKey task
+	keyed := func(from *expr.SqlSource, rows ...[]driver.Value) *JoinKey {
+		cols := make(map[string]int)
+		for i, col := range from.Source.Columns {
+			cols[col.As] = i
+		}
+		task, err := NewJoinKey(from, rtConf)
+		assert.Tf(t, err == nil, "no error %v", err)
+		in := make(MessageChan, len(rows))
+		for i, row := range rows {
+			in <- datasource.NewSqlDriverMessageMap(uint64(i), row, cols)
+		}
+		close(in)
+		task.MessageInSet(in)
+		return task
+	}
+	row := func(from *expr.SqlSource, vals map[string]driver.Value) []driver.Value {
+		r := make([]driver.Value, len(from.Source.Columns))
+		for i, col := range from.Source.Columns {
+			r[i] = vals[col.As]
+		}
+		return r
+	}
+	ltask := keyed(lfrom,
+		row(lfrom, map[string]driver.Value{"name": "aaron", "loc": value.NewStructValue(location{1, 2, "home"})}),
+		row(lfrom, map[string]driver.Value{"name": "bob", "loc": value.NewStructValue(location{3, 4, "home"})}),
+	)
+	rtask := keyed(rfrom,
+		row(rfrom, map[string]driver.Value{"store": "corner", "loc": value.NewStructValue(location{1, 2, "store"})}),
+		row(rfrom, map[string]driver.Value{"store": "mall", "loc": value.NewStructValue(location{5, 6, "store"})}),
+	)
+
+	join, err := NewJoinNaiveMerge(ltask, rtask, lfrom, rfrom, rtConf)
+	assert.Tf(t, err == nil, "no error %v", err)
+	ctx := expr.NewContext()
+	go ltask.Run(ctx)
+	go rtask.Run(ctx)
+	go join.Run(ctx)
+
+	rows := make([]string, 0)
+	for msg := range join.MessageOut() {
+		vals := msg.(*datasource.SqlDriverMessageMap).Values()
+		rows = append(rows, fmt.Sprintf("%v:%v", vals[0], vals[1]))
+	}
+	// the labels differ, but the comparator ignores them
+	assert.Tf(t, len(rows) == 1 && rows[0] == "aaron:corner", "%v", rows)
+}
+
+func TestJoinScanCache(t *testing.T) {
+	mockcsv.LoadTable("scemployees", `user_id,name,manager_id
+u1,alice,u0
+u2,bob,u1
+u3,carol,u1`)
+
+	stmt, err := expr.ParseSqlVm(`
+		SELECT e.name, m.name
+		FROM scemployees AS e
+		INNER JOIN scemployees AS m
+			ON e.manager_id = m.user_id
+	`)
+	assert.Tf(t, err == nil, "no error %v", err)
+	builder := NewJobBuilder(rtConf, "mockcsv")
+	builder.ScanCacheRows = 100
+	task, err := stmt.Accept(builder)
+	assert.Tf(t, err == nil, "no error %v", err)
+	job := &SqlJob{task.(TaskRunner), stmt, rtConf}
+
+	msgs := make([]datasource.Message, 0)
+	job.RootTask.Add(NewResultBuffer(&msgs))
+	assert.T(t, job.Setup() == nil)
+	err = job.Run()
+	assert.Tf(t, err == nil, "no error %v", err)
+
+	rows := make([]string, len(msgs))
+	for i, msg := range msgs {
+		row := msg.Body().(*datasource.ContextSimple).Row()
+		rows[i] = row["e.name"].ToString() + ":" + row["m.name"].ToString()
+	}
+	sort.Strings(rows)
+	assert.Tf(t, strings.Join(rows, ",") == "bob:alice,carol:alice", "%v", rows)
+	assert.Tf(t, builder.scanCache.Scans("scemployees") == 1, "scanned once %d",
+		builder.scanCache.Scans("scemployees"))
+}
+
+func TestJoinTimeTolerance(t *testing.T) {
+	mockcsv.LoadTable("tolclicks", `click_id,user_id,ts
+c0,u0,none`)
+	mockcsv.LoadTable("tolviews", `view_id,user_id,ts
+v0,u0,none`)
+	ts := time.Date(2015, 7, 4, 12, 0, 0, 0, time.UTC)
+	put := func(table string, row ...driver.Value) {
+		conn, err := mockcsv.MockCsvGlobal.Open(table)
+		assert.Tf(t, err == nil, "no error %v", err)
+		_, err = conn.(*membtree.StaticDataSource).Put(nil, nil, row)
+		assert.Tf(t, err == nil, "no error %v", err)
+	}
+	put("tolclicks", "c1", "u1", ts)
+	put("tolclicks", "c2", "u2", ts.Add(time.Minute))
+	put("tolclicks", "c3", "u3", ts.Add(time.Hour))
+	// within a second, across a bucket boundary, and outside
+	put("tolviews", "v1", "u1", ts.Add(400*time.Millisecond))
+	put("tolviews", "v2", "u2", ts.Add(time.Minute-999*time.Millisecond))
+	put("tolviews", "v3", "u3", ts.Add(time.Hour+1500*time.Millisecond))
+
+	sqlText := `
+		SELECT c.click_id, v.view_id
+		FROM tolclicks AS c
+		INNER JOIN tolviews AS v
+			ON c.ts = v.ts`
+	conf := datasource.NewRuntimeSchema()
+	rows, err := ExecuteSelect(nil, sqlText, conf)
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Tf(t, len(rows) == 1, "only the string ts is exactly equal %v", rows)
+
+	conf.TimeTolerance = time.Second
+	rows, err = ExecuteSelect(nil, sqlText, conf)
+	assert.Tf(t, err == nil, "no error %v", err)
+	joined := make([]string, len(rows))
+	for i, row := range rows {
+		joined[i] = row["c.click_id"].ToString() + ":" + row["v.view_id"].ToString()
+	}
+	sort.Strings(joined)
+	assert.Tf(t, strings.Join(joined, ",") == "c0:v0,c1:v1,c2:v2", "%v", joined)
+}
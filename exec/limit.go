@@ -0,0 +1,47 @@
+package exec
+
+import (
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/expr"
+)
+
+var (
+	// Ensure that we implement the Task Runner interface
+	_ TaskRunner = (*Limit)(nil)
+)
+
+// Limit forwards only the first n messages, the rest are dropped
+//
+//    SELECT user_id FROM users LIMIT 10
+//
+type Limit struct {
+	*TaskBase
+	limit int
+	ct    int
+}
+
+func NewLimit(limit int) *Limit {
+	m := &Limit{
+		TaskBase: NewTaskBase("Limit"),
+		limit:    limit,
+	}
+	m.Handler = limitHandler(m)
+	return m
+}
+
+func limitHandler(m *Limit) MessageHandler {
+	out := m.MessageOut()
+	return func(ctx *expr.Context, msg datasource.Message) bool {
+		if m.ct >= m.limit {
+			// drain rest of input
+			return true
+		}
+		m.ct++
+		select {
+		case out <- msg:
+			return true
+		case <-m.SigChan():
+			return false
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package exec
+
+import (
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/datasource/membtree"
+	"github.com/araddon/qlbridge/expr"
+)
+
+var (
+	_ datasource.Pushdownable = (*pushdownLimitSource)(nil)
+)
+
+// a mock sql-db like source, that can do its own LIMIT
+type pushdownLimitSource struct {
+	*membtree.StaticDataSource
+	handleLimit bool
+	handleProj  bool
+	offered     *datasource.Pushdown
+	limit       int
+	ct          int
+}
+
+func newPushdownLimitSource(handleLimit bool) *pushdownLimitSource {
+	data := make([][]driver.Value, 0)
+	for _, name := range []string{"aaron", "bob", "carol", "dave", "erin"} {
+		data = append(data, []driver.Value{name, name + "@email.com"})
+	}
+	return &pushdownLimitSource{
+		StaticDataSource: membtree.NewStaticDataSource("users", 0, data, []string{"name", "email"}),
+		handleLimit:      handleLimit,
+	}
+}
+
+func (m *pushdownLimitSource) Open(connInfo string) (datasource.SourceConn, error) { return m, nil }
+func (m *pushdownLimitSource) CreateIterator(filter expr.Node) datasource.Iterator { return m }
+func (m *pushdownLimitSource) Pushdown(p *datasource.Pushdown) (*datasource.PushdownResult, error) {
+	m.offered = p
+	if m.handleProj {
+		return &datasource.PushdownResult{Projection: true}, nil
+	}
+	if !m.handleLimit {
+		return &datasource.PushdownResult{}, nil
+	}
+	m.limit = p.Limit
+	return &datasource.PushdownResult{Limit: true}, nil
+}
+func (m *pushdownLimitSource) Next() datasource.Message {
+	if m.limit > 0 && m.ct >= m.limit {
+		return nil
+	}
+	msg := m.StaticDataSource.Next()
+	if msg != nil {
+		m.ct++
+	}
+	return msg
+}
+
+func hasLimitTask(tasks Tasks) bool {
+	for _, task := range tasks {
+		if _, ok := task.(*Limit); ok {
+			return true
+		}
+		if hasLimitTask(task.Children()) {
+			return true
+		}
+	}
+	return false
+}
+
+func runPushdownLimit(t *testing.T, src *pushdownLimitSource, sqlText string) (*SqlJob, []datasource.Message) {
+	conf := &datasource.RuntimeSchema{
+		Sources: datasource.NewDataSources(map[string]datasource.DataSource{"pushdown": src}),
+	}
+	job, err := BuildSqlJob(conf, "pushdown", sqlText)
+	assert.Tf(t, err == nil, "no error %v", err)
+
+	msgs := make([]datasource.Message, 0)
+	resultWriter := NewResultBuffer(&msgs)
+	job.RootTask.Add(resultWriter)
+
+	err = job.Setup()
+	assert.T(t, err == nil)
+	err = job.Run()
+	time.Sleep(time.Millisecond * 10)
+	assert.Tf(t, err == nil, "no error %v", err)
+	return job, msgs
+}
+
+func TestPushdownLimit(t *testing.T) {
+	sqlText := `select name, email FROM users LIMIT 2`
+
+	// source handles the limit, so no in-process Limit task
+	src := newPushdownLimitSource(true)
+	job, msgs := runPushdownLimit(t, src, sqlText)
+	assert.Tf(t, src.offered != nil && src.offered.Limit == 2, "should offer limit %#v", src.offered)
+	assert.T(t, !hasLimitTask(job.RootTask.Children()))
+	assert.Tf(t, len(msgs) == 2, "source should limit to 2 %v", len(msgs))
+	assert.Tf(t, src.ct == 2, "source should stop reading %v", src.ct)
+
+	// source does not handle the limit, planner adds Limit task
+	src = newPushdownLimitSource(false)
+	job, msgs = runPushdownLimit(t, src, sqlText)
+	assert.T(t, hasLimitTask(job.RootTask.Children()))
+	assert.Tf(t, len(msgs) == 2, "limit task should limit to 2 %v", len(msgs))
+	assert.Tf(t, src.ct == 5, "source should read all %v", src.ct)
+
+	// a where is not handled by the source, so neither may the limit be
+	src = newPushdownLimitSource(true)
+	conf := &datasource.RuntimeSchema{
+		Sources: datasource.NewDataSources(map[string]datasource.DataSource{"pushdown": src}),
+	}
+	_, err := BuildSqlJob(conf, "pushdown", `select name FROM users WHERE name = "bob" LIMIT 2`)
+	assert.T(t, err != nil)
+}
+
+func TestPushdownProjectionWithoutWhere(t *testing.T) {
+	// the where column (email) is not projected, a source that drops it
+	// while leaving the where to us would break the filter
+	src := newPushdownLimitSource(false)
+	src.handleProj = true
+	conf := &datasource.RuntimeSchema{
+		Sources: datasource.NewDataSources(map[string]datasource.DataSource{"pushdown": src}),
+	}
+	_, err := BuildSqlJob(conf, "pushdown", `select name FROM users WHERE email = "bob@email.com"`)
+	assert.T(t, err != nil)
+	assert.Tf(t, strings.Contains(err.Error(), "Projection"), "wrong error %v", err)
+
+	// no where, so the projection may be handled by the source
+	src = newPushdownLimitSource(false)
+	src.handleProj = true
+	_, msgs := runPushdownLimit(t, src, `select name FROM users`)
+	assert.Tf(t, len(msgs) == 5, "should have 5 rows %v", len(msgs))
+}
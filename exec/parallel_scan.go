@@ -0,0 +1,250 @@
+package exec
+
+import (
+	"container/heap"
+	"fmt"
+	"sync"
+
+	u "github.com/araddon/gou"
+
+	"github.com/araddon/qlbridge/expr"
+)
+
+var (
+	// Ensure that we implement the Task Runner interface
+	_ TaskRunner = (*ParallelScan)(nil)
+)
+
+// ParallelScan scans a set of sources (ie the partitions/shards of a
+//  single FROM table) in parallel and merges their output into a single
+//  stream.
+//
+//  --> \
+//  --> - ->
+//  --> /
+//
+//  Unlike TaskParallel each source keeps its own output channel, without
+//  OrderBy messages are forwarded as they arrive, with OrderBy each source
+//  must already be sorted by it and the sources are k-way merged so the
+//  output is sorted as well.  Sources with equal keys are merged in the
+//  order of the tasks.
+type ParallelScan struct {
+	*TaskBase
+	tasks   Tasks
+	OrderBy expr.Columns
+	keys    []*sortKey
+}
+
+// the current (head) message of a single source in an ordered merge
+type mergeHead struct {
+	row *sortRow
+	src int
+}
+
+type mergeHeap struct {
+	keys  []*sortKey
+	heads []*mergeHead
+}
+
+func (m *mergeHeap) Len() int      { return len(m.heads) }
+func (m *mergeHeap) Swap(i, j int) { m.heads[i], m.heads[j] = m.heads[j], m.heads[i] }
+func (m *mergeHeap) Less(i, j int) bool {
+	if c := compareSortRows(m.keys, m.heads[i].row, m.heads[j].row); c != 0 {
+		return c < 0
+	}
+	return m.heads[i].src < m.heads[j].src
+}
+func (m *mergeHeap) Push(x interface{}) { m.heads = append(m.heads, x.(*mergeHead)) }
+func (m *mergeHeap) Pop() interface{} {
+	last := m.heads[len(m.heads)-1]
+	m.heads = m.heads[:len(m.heads)-1]
+	return last
+}
+
+// A parallel scan of sources, merging messages in arrival order
+func NewParallelScan(tasks Tasks) *ParallelScan {
+	return &ParallelScan{
+		TaskBase: NewTaskBase("ParallelScan"),
+		tasks:    tasks,
+	}
+}
+
+// A parallel scan of sources that are each sorted by orderBy, merged
+//  preserving that sort order
+func NewParallelScanOrdered(tasks Tasks, orderBy expr.Columns) *ParallelScan {
+	m := NewParallelScan(tasks)
+	m.OrderBy = orderBy
+	return m
+}
+
+func (m *ParallelScan) Close() error {
+	errs := make(errList, 0)
+	for _, task := range m.tasks {
+		if err := task.Close(); err != nil {
+			errs.append(err)
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (m *ParallelScan) Setup(depth int) error {
+	m.depth = depth
+	m.setup = true
+	m.keys = newSortKeys(m.OrderBy)
+	for _, task := range m.tasks {
+		if err := task.Setup(depth + 1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *ParallelScan) Add(task TaskRunner) error {
+	if m.setup {
+		return fmt.Errorf("Cannot add task after Setup() called")
+	}
+	m.tasks = append(m.tasks, task)
+	return nil
+}
+
+func (m *ParallelScan) Children() Tasks { return m.tasks }
+
+func (m *ParallelScan) Run(ctx *expr.Context) error {
+	defer ctx.Recover() // Our context can recover panics, save error msg
+	defer close(m.msgOutCh)
+
+	if !m.setup {
+		if err := m.Setup(0); err != nil {
+			return err
+		}
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	errs := make(errList, 0)
+	for _, task := range m.tasks {
+		wg.Add(1)
+		go func(task TaskRunner) {
+			defer wg.Done()
+			if err := task.Run(ctx); err != nil {
+				u.Errorf("%T.Run() errored %v", task, err)
+				mu.Lock()
+				errs.append(err)
+				mu.Unlock()
+			}
+		}(task)
+	}
+
+	merge := m.fanIn
+	if len(m.keys) > 0 {
+		merge = m.merge
+	}
+	stopped, err := merge()
+	if stopped || err != nil {
+		// the sources may be blocked sending to us, don't wait on them
+		m.stopSources()
+		return m.recordError(err)
+	}
+	wg.Wait()
+	return m.recordError(errs.error())
+}
+
+// forward each source's messages as they arrive, returns true if
+//  stopped by a signal before all sources were read
+func (m *ParallelScan) fanIn() (bool, error) {
+	var wg sync.WaitGroup
+	quit := make(chan bool)
+	for _, task := range m.tasks {
+		wg.Add(1)
+		go func(in MessageChan) {
+			defer wg.Done()
+			for {
+				select {
+				case <-quit:
+					return
+				case msg, ok := <-in:
+					if !ok {
+						return
+					}
+					start := m.metricsStart()
+					select {
+					case m.msgOutCh <- msg:
+					case <-quit:
+						return
+					}
+					m.recordHandled(start, true)
+				}
+			}
+		}(task.MessageOut())
+	}
+
+	done := make(chan bool)
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return false, nil
+	case <-m.SigChan():
+		close(quit)
+		<-done
+		return true, nil
+	}
+}
+
+// k-way merge of the sorted sources holding the head message of each,
+//  returns true if stopped by a signal before all sources were read
+func (m *ParallelScan) merge() (bool, error) {
+	h := &mergeHeap{keys: m.keys, heads: make([]*mergeHead, 0, len(m.tasks))}
+	// read the next message of a source onto the heap
+	next := func(src int) (bool, error) {
+		select {
+		case <-m.SigChan():
+			return true, nil
+		case msg, ok := <-m.tasks[src].MessageOut():
+			if !ok {
+				return false, nil
+			}
+			row, err := newSortRow(m.keys, msg, m.EvalOptions)
+			if err != nil {
+				return false, err
+			}
+			heap.Push(h, &mergeHead{row: row, src: src})
+		}
+		return false, nil
+	}
+
+	for src := range m.tasks {
+		if stopped, err := next(src); stopped || err != nil {
+			return stopped, err
+		}
+	}
+	for h.Len() > 0 {
+		start := m.metricsStart()
+		head := heap.Pop(h).(*mergeHead)
+		select {
+		case m.msgOutCh <- head.row.msg:
+		case <-m.SigChan():
+			return true, nil
+		}
+		m.recordHandled(start, true)
+		if stopped, err := next(head.src); stopped || err != nil {
+			return stopped, err
+		}
+	}
+	return false, nil
+}
+
+// signal each source to stop, not blocking on any already signaled
+func (m *ParallelScan) stopSources() {
+	for _, task := range m.tasks {
+		select {
+		case task.SigChan() <- true:
+		default:
+		}
+	}
+}
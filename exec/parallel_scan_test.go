@@ -0,0 +1,80 @@
+package exec
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bmizerany/assert"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/value"
+)
+
+// a source task sending a fixed set of messages
+type msgsTask struct {
+	*TaskBase
+	msgs []datasource.Message
+}
+
+func newMsgsTask(rows ...map[string]value.Value) *msgsTask {
+	m := &msgsTask{TaskBase: NewTaskBaseSize("msgs", 0)}
+	for _, row := range rows {
+		m.msgs = append(m.msgs, datasource.NewContextSimpleData(row))
+	}
+	return m
+}
+
+func (m *msgsTask) Run(ctx *expr.Context) error {
+	defer close(m.msgOutCh)
+	for _, msg := range m.msgs {
+		select {
+		case m.msgOutCh <- msg:
+		case <-m.SigChan():
+			return nil
+		}
+	}
+	return nil
+}
+
+func scanRow(name string, age int64) map[string]value.Value {
+	return map[string]value.Value{"name": value.NewStringValue(name), "age": value.NewIntValue(age)}
+}
+
+func runParallelScan(t *testing.T, scan *ParallelScan) []string {
+	err := scan.Setup(0)
+	assert.Tf(t, err == nil, "no error %v", err)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- scan.Run(expr.NewContext())
+	}()
+	names := make([]string, 0)
+	for msg := range scan.MessageOut() {
+		names = append(names, msg.(*datasource.ContextSimple).Row()["name"].ToString())
+	}
+	err = <-errCh
+	assert.Tf(t, err == nil, "no error %v", err)
+	return names
+}
+
+func TestParallelScanOrdered(t *testing.T) {
+	sources := func() Tasks {
+		return Tasks{
+			newMsgsTask(scanRow("a1", 1), scanRow("a4", 4), scanRow("a7", 7), scanRow("a9", 9)),
+			newMsgsTask(scanRow("b2", 2), scanRow("b4", 4), scanRow("b8", 8)),
+			newMsgsTask(),
+			newMsgsTask(scanRow("c0", 0), scanRow("c3", 3), scanRow("c5", 5), scanRow("c6", 6), scanRow("c10", 10)),
+		}
+	}
+
+	stmt, err := expr.ParseSql("SELECT name FROM users ORDER BY age")
+	assert.Tf(t, err == nil, "no error %v", err)
+	scan := NewParallelScanOrdered(sources(), stmt.(*expr.SqlSelect).OrderBy)
+	got := strings.Join(runParallelScan(t, scan), ",")
+	// equal keys keep the order of the sources
+	assert.Tf(t, got == "c0,a1,b2,c3,a4,b4,c5,c6,a7,b8,a9,c10", "sorted merge got %s", got)
+
+	// without an order the merge has every message
+	names := runParallelScan(t, NewParallelScan(sources()))
+	assert.Tf(t, len(names) == 12, "want 12 messages got %v", names)
+}
@@ -1,6 +1,12 @@
 package exec
 
 import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
 	u "github.com/araddon/gou"
 	"github.com/araddon/qlbridge/datasource"
 	"github.com/araddon/qlbridge/expr"
@@ -11,20 +17,187 @@ import (
 type Projection struct {
 	*TaskBase
 	sql *expr.SqlSelect
+	// Workers is the number of goroutines evaluating the projection, for
+	//  cpu bound expressions (regex, math).  Output order is preserved
+	//  by sequence number.  <= 1 is a single goroutine.
+	Workers int
+	// GuardPolicy for a column whose IF guard can not be evaluated
+	GuardPolicy GuardPolicy
+	// CaseInsensitive resolves column names differing only by case from
+	//  the message columns, see datasource.NewContextCaseInsensitive()
+	CaseInsensitive bool
+	// PackAs if set packs all projected columns into one MapValue column
+	//  of this name keyed by column alias, ie for a json api wanting the
+	//  row as one nested object rather than flat columns
+	PackAs     string
+	transforms map[string][]ColumnTransform
+	project    func(msg datasource.Message) datasource.Message
+	schemaMu   sync.Mutex
+	schema     map[string]value.ValueType // types of evaluated values
+	schemaRows int64                      // rows recorded, see SchemaSampleRows
 }
 
+// SchemaSampleRows is how many projected rows a Projection records the
+//  value types of for OutputSchema(), after which rows cost nothing more
+var SchemaSampleRows int64 = 100
+
+// GuardPolicy determines what a Projection does with a column whose IF
+//  guard can not be evaluated or is NULL, ie references a missing field
+//
+//     SELECT name, email IF score > 5 FROM users
+//
+type GuardPolicy uint8
+
+const (
+	// GuardExclude the column is excluded from the row, as if the guard
+	//  evaluated to false
+	GuardExclude GuardPolicy = 0
+	// GuardInclude the column is included, as if the guard passed
+	GuardInclude GuardPolicy = 1
+	// GuardDropRow the whole row is dropped from the results
+	GuardDropRow GuardPolicy = 2
+)
+
+// ColumnTransform is applied to the evaluated value of a column, see
+//  Projection.AddTransform()
+type ColumnTransform func(v value.Value) value.Value
+
 func NewProjection(sqlSelect *expr.SqlSelect) *Projection {
+	return NewProjectionSize(sqlSelect, ItemDefaultChannelSize)
+}
+
+// Projection with a given output channel buffer size, see NewTaskBaseSize()
+func NewProjectionSize(sqlSelect *expr.SqlSelect, bufferSize int) *Projection {
 	s := &Projection{
-		TaskBase: NewTaskBase("Projection"),
+		TaskBase: NewTaskBaseSize("Projection", bufferSize),
 		sql:      sqlSelect,
+		schema:   make(map[string]value.ValueType),
 	}
+	s.project = s.projector(s.evaluator())
 	s.Handler = s.projectionEvaluator()
 	return s
 }
 
+// AddTransform registers transforms applied in order to the evaluated value
+//  of the column with output name (alias) key, ie to normalize output
+//  without changing the query
+//
+//     p.AddTransform("email", trim, lower)
+//
+//  Transforms must be added before Run() and be safe for concurrent use
+//  with Workers > 1.  A NULL value is passed as value.NilValueVal, a nil
+//  result is stored as NULL.  The columns of a multi-column RowValue
+//  result are keyed by their field names.
+func (m *Projection) AddTransform(key string, fns ...ColumnTransform) {
+	if m.transforms == nil {
+		m.transforms = make(map[string][]ColumnTransform)
+	}
+	m.transforms[key] = append(m.transforms[key], fns...)
+}
+
 // Create handler function for evaluation (ie, field selection from tuples)
 func (m *Projection) projectionEvaluator() MessageHandler {
 	out := m.MessageOut()
+	return func(ctx *expr.Context, msg datasource.Message) bool {
+		outMsg := m.projectMsg(msg)
+		if outMsg == nil {
+			// dropped, see GuardDropRow
+			return true
+		}
+		//u.Debugf("completed projection for: %p %#v", out, outMsg)
+		select {
+		case out <- outMsg:
+			return true
+		case <-m.SigChan():
+			return false
+		}
+	}
+}
+
+// Create the projection func, project evaluates the columns of a message
+//  into a new message, nil if the row is dropped.  If every column is
+//  constant the output is the same for every row, so is evaluated once
+//  and copied for each message.  A message that is dropped, or is not
+//  one we can project, is not cached so the next message is evaluated.
+func (m *Projection) projector(project func(msg datasource.Message) datasource.Message) func(msg datasource.Message) datasource.Message {
+	for _, col := range m.sql.Columns {
+		if col.Star || !isConstantNode(col.Expr) || (col.Guard != nil && !isConstantNode(col.Guard)) {
+			return project
+		}
+	}
+	var mu sync.Mutex
+	var row *datasource.ContextSimple
+	return func(msg datasource.Message) datasource.Message {
+		mu.Lock()
+		if row == nil {
+			if out := project(msg); out != nil {
+				row = out.(*datasource.ContextSimple)
+			}
+		}
+		mu.Unlock()
+		if row == nil {
+			return nil
+		}
+		writeContext := datasource.NewContextSimple()
+		if mt, ok := datasource.MessageReader(msg); ok && !mt.Ts().IsZero() {
+			// keep event time of the source message
+			writeContext = datasource.NewContextSimpleTs(writeContext.Data, mt.Ts())
+		}
+		// re-put in order, keeping the column meta
+		for _, col := range row.Meta() {
+			writeContext.Put(&expr.Column{As: col.Key}, nil, row.Data[col.Key])
+		}
+		return writeContext
+	}
+}
+
+// project a message, packing the columns if PackAs
+func (m *Projection) projectMsg(msg datasource.Message) datasource.Message {
+	out := m.project(msg)
+	if out == nil || m.PackAs == "" {
+		return out
+	}
+	row, ok := out.(*datasource.ContextSimple)
+	if !ok {
+		return out
+	}
+	cols := make(map[string]interface{}, len(row.Data))
+	for k, v := range row.Data {
+		cols[k] = v
+	}
+	packed := datasource.NewContextSimpleTs(make(map[string]value.Value, 1), row.Ts())
+	packed.Put(&expr.Column{As: m.PackAs}, nil, value.NewMapValue(cols))
+	return packed
+}
+
+// Is the node constant, ie only literals and operators so evaluates the
+//  same for every row.  Functions are never constant, they may not be
+//  deterministic (now(), uuid()).
+func isConstantNode(node expr.Node) bool {
+	switch n := node.(type) {
+	case *expr.NumberNode, *expr.StringNode, *expr.NullNode, *expr.ValueNode:
+		return true
+	case *expr.IdentityNode:
+		return n.IsBooleanIdentity()
+	case *expr.UnaryNode:
+		return isConstantNode(n.Arg)
+	case *expr.BinaryNode:
+		return isConstantNode(n.Args[0]) && isConstantNode(n.Args[1])
+	case *expr.TriNode:
+		return isConstantNode(n.Args[0]) && isConstantNode(n.Args[1]) && isConstantNode(n.Args[2])
+	case *expr.MultiArgNode:
+		for _, arg := range n.Args {
+			if !isConstantNode(arg) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// Create the func evaluating the columns of a message
+func (m *Projection) evaluator() func(msg datasource.Message) datasource.Message {
 	columns := m.sql.Columns
 	// if len(m.sql.From) > 1 && m.sql.From[0].Source != nil && len(m.sql.From[0].Source.Columns) > 0 {
 	// 	// we have re-written this query, lets build new list of columns
@@ -35,7 +208,19 @@ func (m *Projection) projectionEvaluator() MessageHandler {
 	// 		}
 	// 	}
 	// }
-	return func(ctx *expr.Context, msg datasource.Message) bool {
+
+	// compile the column expressions once, rather than walking them per row
+	exprs := make([]vm.EvaluatorFunc, len(columns))
+	guards := make([]vm.EvaluatorFunc, len(columns))
+	for i, col := range columns {
+		if col.Guard != nil {
+			guards[i] = vm.Compile(col.Guard)
+		}
+		if !col.Star {
+			exprs[i] = vm.Compile(col.Expr)
+		}
+	}
+	return func(msg datasource.Message) datasource.Message {
 		// defer func() {
 		// 	if r := recover(); r != nil {
 		// 		u.Errorf("crap, %v", r)
@@ -43,99 +228,298 @@ func (m *Projection) projectionEvaluator() MessageHandler {
 		// }()
 
 		//u.Infof("got projection message: %T %#v", msg, msg.Body())
-		var outMsg datasource.Message
-		switch mt := msg.(type) {
-		case *datasource.SqlDriverMessageMap:
-			// readContext := datasource.NewContextUrlValues(uv)
-			// use our custom write context for example purposes
-			writeContext := datasource.NewContextSimple()
-			outMsg = writeContext
-			//u.Debugf("about to project: %#v", mt)
-			for _, col := range columns {
-				if col.ParentIndex < 0 {
-					continue
+		mt, ok := datasource.MessageReader(msg)
+		if !ok {
+			if !m.sendError(msg, fmt.Errorf("could not project msg: %T", msg)) {
+				u.Errorf("could not project msg:  %T", msg)
+			}
+			return nil
+		}
+		if m.CaseInsensitive {
+			mt = datasource.NewContextCaseInsensitive(mt)
+		}
+		mt = m.evalReader(mt)
+		// use our custom write context for example purposes
+		writeContext := datasource.NewContextSimple()
+		if ts := mt.Ts(); !ts.IsZero() {
+			// keep event time of the source message
+			writeContext = datasource.NewContextSimpleTs(writeContext.Data, ts)
+		}
+		//u.Debugf("about to project: %#v", mt)
+		for i, col := range columns {
+			if col.ParentIndex < 0 {
+				continue
+			}
+			//u.Debugf("col: idx:%v pidx:%v key:%v   %s", col.Index, col.ParentIndex, col.Key(), col.Expr)
+			if col.Guard != nil {
+				ifColValue, ok := guards[i](mt)
+				if !ok || value.IsNull(ifColValue) {
+					// ie the guard references a missing field
+					u.Debugf("Could not evaluate if:   %v", col.Guard.String())
+					switch m.GuardPolicy {
+					case GuardDropRow:
+						return nil
+					case GuardExclude:
+						continue
+					}
 				}
-				//u.Debugf("col: idx:%v pidx:%v key:%v   %s", col.Index, col.ParentIndex, col.Key(), col.Expr)
-				if col.Guard != nil {
-					ifColValue, ok := vm.Eval(mt, col.Guard)
-					if !ok {
-						u.Errorf("Could not evaluate if:   %v", col.Guard.String())
-						//return fmt.Errorf("Could not evaluate if clause: %v", col.Guard.String())
+				//u.Debugf("if eval val:  %T:%v", ifColValue, ifColValue)
+				switch ifColVal := ifColValue.(type) {
+				case value.BoolValue:
+					if ifColVal.Val() == false {
+						//u.Debugf("Filtering out col")
+						continue
 					}
-					//u.Debugf("if eval val:  %T:%v", ifColValue, ifColValue)
-					switch ifColVal := ifColValue.(type) {
-					case value.BoolValue:
-						if ifColVal.Val() == false {
-							//u.Debugf("Filtering out col")
-							continue
-						}
+				}
+			}
+			if col.Star {
+				// in   select *, a + b AS c   the star is first, so computed
+				//  columns replace input columns of the same name
+				alias := col.StarAlias()
+				for k, v := range mt.Row() {
+					if key, ok := starKey(alias, k); ok {
+						writeContext.Put(&expr.Column{As: key}, nil, v)
 					}
 				}
-				if col.Star {
-					for k, v := range mt.Row() {
-						writeContext.Put(&expr.Column{As: k}, nil, value.NewValue(v))
+			} else {
+				v, ok := exprs[i](mt)
+				if !ok {
+					if m.ErrorSink != nil {
+						// the whole row goes to the dead-letter sink
+						m.sendError(msg, fmt.Errorf("failed eval key=%v val=%s expr:%s", col.Key(), value.Debug(v), col.Expr))
+						return nil
 					}
-				} else {
-					v, ok := vm.Eval(mt, col.Expr)
-					if !ok {
-						u.Warnf("failed eval key=%v  val=%#v expr:%s   mt:%#v", col.Key(), v, col.Expr, mt)
-					} else if v == nil {
-						u.Debugf("evaled: key=%v  val=%v", col.Key(), v)
-						writeContext.Put(col, mt, v)
-					} else {
-						//u.Debugf("evaled: key=%v  val=%v", col.Key(), v.Value())
-						writeContext.Put(col, mt, v)
+					u.Warnf("failed eval key=%v  val=%s expr:%s   row:%s", col.Key(), value.Debug(v), col.Expr, value.DebugRow(mt.Row()))
+					continue
+				}
+				if v == nil {
+					//u.Debugf("evaled nil: key=%v  val=%v", col.Key(), v)
+					v = value.NilValueVal
+				}
+				if row, isRow := v.(value.RowValue); isRow {
+					// a multi-column result, one output column per field
+					vals := row.Values()
+					for j, key := range row.Columns() {
+						writeContext.Put(&expr.Column{As: key}, mt, m.transform(key, vals[j]))
 					}
+					continue
 				}
+				//u.Debugf("evaled: key=%v  val=%v", col.Key(), v.Value())
+				writeContext.Put(col, mt, m.transform(col.Key(), v))
 			}
+		}
+		m.recordSchema(writeContext.Data)
+		return writeContext
+	}
+}
 
-		case *datasource.ContextUrlValues:
-			// readContext := datasource.NewContextUrlValues(uv)
-			// use our custom write context for example purposes
-			writeContext := datasource.NewContextSimple()
-			outMsg = writeContext
-			//u.Infof("about to project: colsct%v %#v", len(sql.Columns), outMsg)
-			for _, col := range columns {
-				//u.Debugf("col:   %#v", col)
-				if col.Guard != nil {
-					ifColValue, ok := vm.Eval(mt, col.Guard)
-					if !ok {
-						u.Errorf("Could not evaluate if:   %v", col.Guard.String())
-						//return fmt.Errorf("Could not evaluate if clause: %v", col.Guard.String())
-					}
-					//u.Debugf("if eval val:  %T:%v", ifColValue, ifColValue)
-					switch ifColVal := ifColValue.(type) {
-					case value.BoolValue:
-						if ifColVal.Val() == false {
-							//u.Debugf("Filtering out col")
-							continue
-						}
-					}
+// apply the transforms of the column with output name key
+func (m *Projection) transform(key string, v value.Value) value.Value {
+	for _, fn := range m.transforms[key] {
+		if v = fn(v); v == nil {
+			v = value.NilValueVal
+		}
+	}
+	return v
+}
+
+// The output key for row key k of a star column, a qualified star   t1.*
+//  prefixes un-aliased keys with its alias, and skips keys aliased to
+//  another source
+func starKey(alias, k string) (string, bool) {
+	switch {
+	case alias == "":
+		return k, true
+	case strings.HasPrefix(k, alias+"."):
+		return k, true
+	case strings.Contains(k, "."):
+		return "", false
+	}
+	return alias + "." + k, true
+}
+
+// OutputSchema is the column name to ValueType of projected messages, from
+//  the types of evaluated values of the first SchemaSampleRows messages
+//  projected.  Columns not yet seen with a non-nil value are inferred from
+//  the expression, see expr.ValueTypeFromNode(), or are UnknownType.  A
+//  column with both int and number values is a number.  Packed (PackAs)
+//  messages have the one map column.
+func (m *Projection) OutputSchema() map[string]value.ValueType {
+	if m.PackAs != "" {
+		return map[string]value.ValueType{m.PackAs: value.MapValueType}
+	}
+	schema := make(map[string]value.ValueType)
+	for _, col := range m.sql.Columns {
+		if col.Star || col.Expr == nil {
+			continue
+		}
+		if fn, isFunc := col.Expr.(*expr.FuncNode); isFunc && fn.F.ReturnValueType == value.RowType {
+			// expanded into the columns of the row
+			continue
+		}
+		vt := value.UnknownType
+		if _, isIdent := col.Expr.(*expr.IdentityNode); !isIdent {
+			vt = expr.ValueTypeFromNode(col.Expr)
+		}
+		schema[col.Key()] = vt
+	}
+	m.schemaMu.Lock()
+	for k, vt := range m.schema {
+		schema[k] = vt
+	}
+	m.schemaMu.Unlock()
+	return schema
+}
+
+func (m *Projection) recordSchema(row map[string]value.Value) {
+	if atomic.AddInt64(&m.schemaRows, 1) > SchemaSampleRows {
+		return
+	}
+	m.schemaMu.Lock()
+	defer m.schemaMu.Unlock()
+	for k, v := range row {
+		if v == nil || v.Type() == value.NilType || v.Err() {
+			continue
+		}
+		vt := v.Type()
+		switch cur, ok := m.schema[k]; {
+		case !ok:
+			m.schema[k] = vt
+		case cur == value.IntType && vt == value.NumberType:
+			m.schema[k] = vt
+		}
+	}
+}
+
+type projectionSeq struct {
+	seq uint64
+	msg datasource.Message
+}
+
+// Run the projection, with Workers > 1 messages are evaluated in parallel
+//  and re-ordered by sequence number before being sent on.  As with
+//  TaskBase.Run() a signal or error stops it, and the Finalizer is called
+//  at end of stream.
+func (m *Projection) Run(ctx *expr.Context) error {
+	if m.Workers <= 1 {
+		return m.TaskBase.Run(ctx)
+	}
+	defer ctx.Recover()
+	defer close(m.msgOutCh)
+
+	// the single slot sigCh is received by one goroutine, which closes
+	//  done to stop all of them, and puts the signal back for workers
+	//  blocked sending to the ErrorSink
+	done := make(chan struct{})
+	var stopOnce sync.Once
+	stop := func() { stopOnce.Do(func() { close(done) }) }
+	defer stop()
+	var err error
+	go func() {
+		select {
+		case sig, ok := <-m.sigCh:
+			m.resignal(sig, ok)
+		case err = <-m.errCh:
+			if m.Metrics != nil {
+				m.Metrics.TaskError(m.TaskType, err)
+			}
+		case <-done:
+			return
+		}
+		stop()
+	}()
+
+	in := make(chan projectionSeq, m.Workers)
+	results := make(chan projectionSeq, m.Workers)
+
+	// read input, assigning sequence numbers
+	inputClosed := make(chan struct{})
+	go func() {
+		defer close(in)
+		seq := uint64(0)
+		for {
+			select {
+			case <-done:
+				return
+			case msg, ok := <-m.msgInCh:
+				if !ok {
+					close(inputClosed)
+					return
 				}
-				if col.Star {
-					for k, v := range mt.Row() {
-						writeContext.Put(&expr.Column{As: k}, nil, v)
-					}
+				select {
+				case in <- projectionSeq{seq, msg}:
+					seq++
+				case <-done:
+					return
+				}
+			}
+		}
+	}()
+
+	wg := new(sync.WaitGroup)
+	for i := 0; i < m.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ps := range in {
+				var out datasource.Message
+				if m.Metrics == nil {
+					out = m.projectMsg(ps.msg)
 				} else {
-					//u.Debugf("tree.Root: as?%v %#v", col.As, col.Expr)
-					v, ok := vm.Eval(mt, col.Expr)
-					//u.Debugf("evaled: ok?%v key=%v  val=%v", ok, col.Key(), v)
-					if ok {
-						writeContext.Put(col, mt, v)
-					}
+					start := time.Now()
+					out = m.projectMsg(ps.msg)
+					m.Metrics.MessageHandled(m.TaskType, time.Since(start), out != nil)
 				}
+				select {
+				case results <- projectionSeq{ps.seq, out}:
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
 
+	// re-order, pending holds results that are ahead of next
+	next := uint64(0)
+	pending := make(map[uint64]datasource.Message)
+	for ps := range results {
+		pending[ps.seq] = ps.msg
+		for {
+			msg, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+			if msg == nil {
+				// dropped, see GuardDropRow
+				continue
+			}
+			select {
+			case m.msgOutCh <- msg:
+			case <-done:
+				return err
 			}
-		default:
-			u.Errorf("could not project msg:  %T", msg)
 		}
+	}
 
-		//u.Debugf("completed projection for: %p %#v", out, outMsg)
-		select {
-		case out <- outMsg:
-			return true
-		case <-m.SigChan():
-			return false
+	select {
+	case <-done:
+		// stopped by signal or error
+		return err
+	case <-inputClosed:
+	}
+	if m.Finalizer != nil {
+		if ferr := m.Finalizer(ctx); ferr != nil {
+			if m.Metrics != nil {
+				m.Metrics.TaskError(m.TaskType, ferr)
+			}
+			return ferr
 		}
 	}
+	return nil
 }
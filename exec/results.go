@@ -8,6 +8,7 @@ import (
 
 	"github.com/araddon/qlbridge/datasource"
 	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/value"
 )
 
 var (
@@ -189,7 +190,7 @@ func msgToRow(msg datasource.Message, cols []string, dest []driver.Value) error
 				dest[i] = val.Value()
 				//u.Infof("key=%v   val=%v", key, val)
 			} else if val == nil {
-				u.Errorf("could not evaluate? %v  %#v", key, mt.Row())
+				u.Errorf("could not evaluate? %v  %s", key, value.DebugRow(mt.Row()))
 			} else {
 				u.Warnf("missing value? %v %T %v", key, val.Value(), val.Value())
 			}
@@ -0,0 +1,120 @@
+package exec
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/araddon/qlbridge/expr"
+)
+
+var (
+	// Ensure that we implement the Task Runner interface
+	_ TaskRunner = (*Sample)(nil)
+)
+
+// Sample passes a sample of messages through unchanged, for exploratory
+//  queries over huge sources (TABLESAMPLE).  Either each message passes
+//  with probability Rate, using a seedable rng so that a sample is
+//  reproducible, or every Nth message passes (systematic sampling).
+//
+//  With a Limit, it stops once Limit messages have passed, signaling the
+//  Upstream task (ie the Source) to stop scanning rather than reading the
+//  rest of the input.
+type Sample struct {
+	*TaskBase
+	Rate     float64    // probability each message passes, if Every == 0
+	Every    int        // pass every Nth message, the first, N+1th, ...
+	Limit    int        // max messages to pass, 0 for no limit
+	Upstream TaskRunner // the task feeding this one, stopped at Limit
+	seed     int64
+	rng      *rand.Rand
+}
+
+// Sample with probability rate of each message passing, the same seed
+//  gives the same sample of the same input.  A seed of 0 is random.
+func NewSample(rate float64, seed int64) *Sample {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &Sample{
+		TaskBase: NewTaskBase("Sample"),
+		Rate:     rate,
+		seed:     seed,
+		rng:      rand.New(rand.NewSource(seed)),
+	}
+}
+
+// Sample every nth message
+func NewSampleEvery(n int) *Sample {
+	return &Sample{
+		TaskBase: NewTaskBase("Sample"),
+		Every:    n,
+	}
+}
+
+func (m *Sample) Copy() *Sample {
+	s := NewSample(m.Rate, m.seed)
+	s.Every = m.Every
+	s.Limit = m.Limit
+	return s
+}
+
+func (m *Sample) pass(ct int) bool {
+	if m.Every > 0 {
+		return ct%m.Every == 0
+	}
+	return m.rng.Float64() < m.Rate
+}
+
+func (m *Sample) Run(context *expr.Context) error {
+	defer context.Recover()
+	defer close(m.msgOutCh)
+
+	outCh := m.MessageOut()
+	inCh := m.MessageIn()
+
+	ct, passed := 0, 0
+	for {
+		select {
+		case <-m.SigChan():
+			return nil
+		case msg, ok := <-inCh:
+			if !ok {
+				return nil
+			}
+			start := m.metricsStart()
+			ct++
+			if !m.pass(ct - 1) {
+				m.recordHandled(start, false)
+				continue
+			}
+			select {
+			case outCh <- msg:
+			case <-m.SigChan():
+				return nil
+			}
+			m.recordHandled(start, true)
+			passed++
+			if m.Limit > 0 && passed >= m.Limit {
+				m.stopUpstream()
+				return nil
+			}
+		}
+	}
+}
+
+// stop the upstream task, or without one drain the rest of the input
+//  so that it is not blocked sending to us
+func (m *Sample) stopUpstream() {
+	if m.Upstream != nil {
+		select {
+		case m.Upstream.SigChan() <- true:
+		default:
+		}
+		return
+	}
+	go func() {
+		for range m.MessageIn() {
+		}
+	}()
+}
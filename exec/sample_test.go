@@ -0,0 +1,46 @@
+package exec
+
+import (
+	"testing"
+
+	"github.com/bmizerany/assert"
+
+	"github.com/araddon/qlbridge/expr"
+)
+
+func runSample(sample *Sample, ct int) int {
+	sample.MessageInSet(teeMsgs(ct))
+	go sample.Run(expr.NewContext())
+	return len(drain(sample.MessageOut()))
+}
+
+func TestSample(t *testing.T) {
+	// approximately p*N pass, the same seed is the same sample
+	passed := runSample(NewSample(0.1, 42), 10000)
+	assert.Tf(t, passed > 900 && passed < 1100, "expected ~1000 got %d", passed)
+	assert.Tf(t, runSample(NewSample(0.1, 42), 10000) == passed, "seeded sample is reproducible")
+	assert.T(t, runSample(NewSample(1, 42), 100) == 100)
+	assert.T(t, runSample(NewSample(0, 42), 100) == 0)
+
+	// systematic
+	assert.T(t, runSample(NewSampleEvery(10), 100) == 10)
+	assert.T(t, runSample(NewSampleEvery(10), 101) == 11)
+}
+
+func TestSampleLimit(t *testing.T) {
+	upstream := NewTaskBase("Source")
+	sample := NewSample(0.5, 42)
+	sample.Limit = 5
+	sample.Upstream = upstream
+	assert.T(t, runSample(sample, 1000) == 5)
+	select {
+	case <-upstream.SigChan():
+	default:
+		t.Fatalf("upstream should be signaled to stop at the limit")
+	}
+
+	// without an upstream the rest of the input is drained
+	sample = NewSampleEvery(2)
+	sample.Limit = 3
+	assert.T(t, runSample(sample, 1000) == 3)
+}
@@ -0,0 +1,127 @@
+package exec
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	u "github.com/araddon/gou"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/value"
+)
+
+var (
+	_ = u.EMPTY
+
+	// Ensure that we implement the Sink, Task Runner interfaces
+	_ Sink       = (*ResultSink)(nil)
+	_ TaskRunner = (*ResultSink)(nil)
+)
+
+// Sink is the final task in a job, it consumes the result messages
+//  and writes them somewhere (slice, io.Writer, callback) so callers
+//  embedding qlbridge don't have to drain the output channel themselves
+//
+//    job, _ := BuildSqlJob(conf, "mockcsv", "SELECT user_id FROM users")
+//    job.RootTask.Add(NewJsonLinesSink(os.Stdout))
+//
+type Sink interface {
+	TaskRunner
+	// Put writes a single result message to the sink
+	Put(msg datasource.Message) error
+	// Err is the first error encountered writing to the sink
+	Err() error
+}
+
+// RowFunc is called once per result row by a callback sink
+type RowFunc func(row map[string]value.Value) error
+
+// ResultSink is the Sink implementation, the destination is the put func
+//  created by the various NewXSink() constructors
+type ResultSink struct {
+	*TaskBase
+	put func(msg datasource.Message) error
+	err error
+}
+
+func newResultSink(sinkType string, put func(msg datasource.Message) error) *ResultSink {
+	m := &ResultSink{
+		TaskBase: NewTaskBase(sinkType),
+		put:      put,
+	}
+	m.Handler = func(ctx *expr.Context, msg datasource.Message) bool {
+		if m.err != nil {
+			// keep draining so upstream tasks don't block
+			return false
+		}
+		if err := m.Put(msg); err != nil {
+			u.Warnf("could not write to sink: %v", err)
+			m.err = err
+			return false
+		}
+		return true
+	}
+	return m
+}
+
+// Collect result rows into a slice
+func NewSliceSink(writeTo *[]map[string]value.Value) *ResultSink {
+	return newResultSink("SliceSink", func(msg datasource.Message) error {
+		row, err := msgToValueRow(msg)
+		if err != nil {
+			return err
+		}
+		*writeTo = append(*writeTo, row)
+		return nil
+	})
+}
+
+// Write each result row as a single line of json to the writer
+func NewJsonLinesSink(w io.Writer) *ResultSink {
+	return newResultSink("JsonLinesSink", func(msg datasource.Message) error {
+		row, err := msgToValueRow(msg)
+		if err != nil {
+			return err
+		}
+		jsonRow := make(map[string]interface{}, len(row))
+		for k, v := range row {
+			if v == nil || v.Type() == value.NilType {
+				// NilValue does not marshal to valid json
+				jsonRow[k] = nil
+				continue
+			}
+			jsonRow[k] = v
+		}
+		by, err := json.Marshal(jsonRow)
+		if err != nil {
+			return err
+		}
+		by = append(by, '\n')
+		_, err = w.Write(by)
+		return err
+	})
+}
+
+// Call the RowFunc for each result row
+func NewFuncSink(fn RowFunc) *ResultSink {
+	return newResultSink("FuncSink", func(msg datasource.Message) error {
+		row, err := msgToValueRow(msg)
+		if err != nil {
+			return err
+		}
+		return fn(row)
+	})
+}
+
+func (m *ResultSink) Put(msg datasource.Message) error { return m.put(msg) }
+func (m *ResultSink) Err() error                       { return m.err }
+func (m *ResultSink) Close() error                     { return nil }
+
+func msgToValueRow(msg datasource.Message) (map[string]value.Value, error) {
+	if msgReader, ok := msg.Body().(expr.ContextReader); ok {
+		return msgReader.Row(), nil
+	}
+	return nil, fmt.Errorf("Sink requires ContextReader message but got %T", msg.Body())
+}
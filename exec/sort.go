@@ -0,0 +1,199 @@
+package exec
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/value"
+	"github.com/araddon/qlbridge/vm"
+)
+
+var (
+	// Ensure that we implement the Task Runner interface
+	_ TaskRunner = (*Sort)(nil)
+)
+
+// Sort the result rows by the ORDER BY columns.  As ordering requires all
+//  of the rows, the messages are buffered until the input is closed and
+//  then sent on in order.  The ORDER BY expressions are evaluated against
+//  the result row so may refer to the selected columns (or aliases).
+//
+//    SELECT user_id, name FROM users ORDER BY name DESC NULLS LAST
+//
+//  NULLs are last for ASC and first for DESC unless the column has an
+//  explicit NULLS FIRST | NULLS LAST.
+//
+//  A Sort before the projection (NewSourceSort) evaluates the keys against
+//  the source rows, so may also order by columns not selected.
+type Sort struct {
+	*TaskBase
+	sql    *expr.SqlSelect
+	keys   []*sortKey
+	source bool // before the projection, see NewSourceSort()
+}
+
+// a single ORDER BY column
+type sortKey struct {
+	node       expr.Node
+	desc       bool
+	nullsFirst bool
+}
+
+// a buffered message and its evaluated sort key values
+type sortRow struct {
+	msg  datasource.Message
+	vals []value.Value
+}
+
+func NewSort(stmt *expr.SqlSelect) *Sort {
+	return &Sort{
+		TaskBase: NewTaskBase("Sort"),
+		sql:      stmt,
+		keys:     newSortKeys(stmt.OrderBy),
+	}
+}
+
+// A Sort of the source rows, before the projection, so the ORDER BY may
+//  use columns that are not selected
+//
+//    SELECT user_id FROM users ORDER BY email DESC
+//
+//  ORDER BY select column aliases are replaced by their expressions.
+func NewSourceSort(stmt *expr.SqlSelect) *Sort {
+	m := NewSort(stmt)
+	m.source = true
+	for _, key := range m.keys {
+		ident, ok := key.node.(*expr.IdentityNode)
+		if !ok {
+			continue
+		}
+		for _, col := range stmt.Columns {
+			if !col.Star && col.Expr != nil && strings.EqualFold(col.As, ident.Text) {
+				key.node = col.Expr
+				break
+			}
+		}
+	}
+	return m
+}
+
+func newSortKeys(orderBy expr.Columns) []*sortKey {
+	keys := make([]*sortKey, len(orderBy))
+	for i, col := range orderBy {
+		key := &sortKey{node: col.Expr, desc: col.Order == "DESC"}
+		if key.node == nil {
+			key.node = &expr.IdentityNode{Text: col.As}
+		}
+		switch col.Nulls {
+		case "FIRST":
+			key.nullsFirst = true
+		case "LAST":
+			key.nullsFirst = false
+		default:
+			// matching common databases NULL is the largest value
+			key.nullsFirst = key.desc
+		}
+		keys[i] = key
+	}
+	return keys
+}
+
+// evaluate the sort keys of a message, with the options if not nil
+func newSortRow(keys []*sortKey, msg datasource.Message, opts *expr.EvalOptions) (*sortRow, error) {
+	mt, ok := msg.(expr.ContextReader)
+	if !ok {
+		return nil, fmt.Errorf("To sort must use ContextReader message but got %T", msg)
+	}
+	if opts != nil {
+		mt = datasource.NewContextOptions(mt, opts)
+	}
+	row := &sortRow{msg: msg, vals: make([]value.Value, len(keys))}
+	for i, key := range keys {
+		if v, ok := vm.Eval(mt, key.node); ok {
+			row.vals[i] = v
+		}
+	}
+	return row, nil
+}
+
+// compare two rows key by key returning -1, 0, 1
+func compareSortRows(keys []*sortKey, a, b *sortRow) int {
+	for i, key := range keys {
+		if c := key.compare(a.vals[i], b.vals[i]); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+func (m *Sort) Copy() *Sort {
+	if m.source {
+		return NewSourceSort(m.sql)
+	}
+	return NewSort(m.sql)
+}
+
+func (m *Sort) Run(context *expr.Context) error {
+	defer context.Recover()
+	defer close(m.msgOutCh)
+
+	outCh := m.MessageOut()
+	inCh := m.MessageIn()
+
+	rows := make([]*sortRow, 0)
+
+msgReadLoop:
+	for {
+		select {
+		case <-m.SigChan():
+			return nil
+		case msg, ok := <-inCh:
+			if !ok {
+				break msgReadLoop
+			}
+			start := m.metricsStart()
+			row, err := newSortRow(m.keys, msg, m.EvalOptions)
+			if err != nil {
+				return m.recordError(err)
+			}
+			rows = append(rows, row)
+			m.recordHandled(start, true)
+		}
+	}
+
+	// stable, so rows with equal keys keep their arrival order
+	sort.SliceStable(rows, func(i, j int) bool {
+		return compareSortRows(m.keys, rows[i], rows[j]) < 0
+	})
+
+	for _, row := range rows {
+		select {
+		case outCh <- row.msg:
+		case <-m.SigChan():
+			return nil
+		}
+	}
+	return nil
+}
+
+// compare the values of a single key, NULLs are placed per the key's
+//  nulls placement independent of direction, else see value.Compare()
+func (m *sortKey) compare(a, b value.Value) int {
+	an, bn := value.IsNull(a), value.IsNull(b)
+	switch {
+	case an && bn:
+		return 0
+	case an && m.nullsFirst, bn && !m.nullsFirst:
+		return -1
+	case an, bn:
+		return 1
+	}
+	c := value.Compare(a, b)
+	if m.desc {
+		return -c
+	}
+	return c
+}
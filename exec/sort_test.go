@@ -0,0 +1,66 @@
+package exec
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bmizerany/assert"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/value"
+)
+
+// run a Sort task over in-memory rows, returns the "name" of each row
+//  in output order
+func runSort(t *testing.T, sqlText string, rows []map[string]value.Value) string {
+	stmt, err := expr.ParseSql(sqlText)
+	assert.Tf(t, err == nil, "no error %v", err)
+
+	sortTask := NewSort(stmt.(*expr.SqlSelect))
+	inCh := make(MessageChan, len(rows))
+	for _, row := range rows {
+		inCh <- datasource.NewContextSimpleData(row)
+	}
+	close(inCh)
+	sortTask.MessageInSet(inCh)
+
+	err = sortTask.Run(expr.NewContext())
+	assert.Tf(t, err == nil, "no error %v", err)
+
+	names := make([]string, 0, len(rows))
+	for msg := range sortTask.MessageOut() {
+		names = append(names, msg.(*datasource.ContextSimple).Row()["name"].ToString())
+	}
+	return strings.Join(names, ",")
+}
+
+func TestSortNulls(t *testing.T) {
+	rows := []map[string]value.Value{
+		{"name": value.NewStringValue("b"), "age": value.NewIntValue(20)},
+		{"name": value.NewStringValue("null1"), "age": value.NilValueVal},
+		{"name": value.NewStringValue("a"), "age": value.NewIntValue(10)},
+		{"name": value.NewStringValue("missing")},
+		{"name": value.NewStringValue("zero"), "age": value.NewIntValue(0)},
+	}
+	tests := []struct {
+		sql    string
+		expect string
+	}{
+		{"SELECT name FROM users ORDER BY age", "zero,a,b,null1,missing"},
+		{"SELECT name FROM users ORDER BY age ASC", "zero,a,b,null1,missing"},
+		{"SELECT name FROM users ORDER BY age DESC", "null1,missing,b,a,zero"},
+		{"SELECT name FROM users ORDER BY age NULLS FIRST", "null1,missing,zero,a,b"},
+		{"SELECT name FROM users ORDER BY age ASC NULLS FIRST", "null1,missing,zero,a,b"},
+		{"SELECT name FROM users ORDER BY age ASC NULLS LAST", "zero,a,b,null1,missing"},
+		{"SELECT name FROM users ORDER BY age DESC NULLS FIRST", "null1,missing,b,a,zero"},
+		{"SELECT name FROM users ORDER BY age DESC NULLS LAST", "b,a,zero,null1,missing"},
+		{"SELECT name FROM users ORDER BY age DESC NULLS LAST, name DESC", "b,a,zero,null1,missing"},
+		{"SELECT name FROM users ORDER BY age NULLS LAST, name DESC LIMIT 3", "zero,a,b,null1,missing"},
+		{"SELECT name FROM users ORDER BY age NULLS FIRST, name", "missing,null1,zero,a,b"},
+	}
+	for _, tt := range tests {
+		got := runSort(t, tt.sql, rows)
+		assert.Tf(t, got == tt.expect, "%s\n\twant %s got %s", tt.sql, tt.expect, got)
+	}
+}
@@ -1,6 +1,7 @@
 package exec
 
 import (
+	"database/sql/driver"
 	"fmt"
 
 	u "github.com/araddon/gou"
@@ -15,6 +16,7 @@ var (
 	// Ensure that we implement the Task Runner interface
 	// to ensure this can run in exec engine
 	_ TaskRunner = (*Source)(nil)
+	_ TaskRunner = (*SourceScalar)(nil)
 
 	// Ensure that our source plan implements Subvisitor
 	_ expr.SubVisitor = (*SourcePlan)(nil)
@@ -59,6 +61,9 @@ type Source struct {
 	from    *expr.SqlSource
 	source  datasource.Scanner
 	JoinKey KeyEvaluator
+	// TsColumn if set is the time column used as event time of
+	//  each message, see SqlDriverMessageMap.SetTsCol()
+	TsColumn string
 }
 
 // A scanner to read from data source
@@ -111,6 +116,11 @@ func (m *Source) Run(context *expr.Context) error {
 	for item := iter.Next(); item != nil; item = iter.Next() {
 
 		//u.Infof("In source Scanner iter %#v", item)
+		if m.TsColumn != "" {
+			if mm, ok := item.(*datasource.SqlDriverMessageMap); ok {
+				mm.SetTsCol(m.TsColumn)
+			}
+		}
 		select {
 		case <-sigChan:
 			return nil
@@ -122,3 +132,30 @@ func (m *Source) Run(context *expr.Context) error {
 	//u.Debugf("leaving source scanner")
 	return nil
 }
+
+// A source for a select with no FROM, ie only constant expressions
+//   emits a single empty message so the projection is evaluated once
+//
+//   SELECT 2*21 AS answer, now();
+//
+type SourceScalar struct {
+	*TaskBase
+}
+
+func NewSourceScalar() *SourceScalar {
+	return &SourceScalar{TaskBase: NewTaskBase("SourceScalar")}
+}
+
+func (m *SourceScalar) Copy() *SourceScalar { return NewSourceScalar() }
+
+func (m *SourceScalar) Run(context *expr.Context) error {
+	defer context.Recover()
+	defer close(m.msgOutCh)
+
+	msg := datasource.NewSqlDriverMessageMap(0, []driver.Value{}, map[string]int{})
+	select {
+	case <-m.SigChan():
+	case m.msgOutCh <- msg:
+	}
+	return nil
+}
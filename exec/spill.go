@@ -0,0 +1,66 @@
+package exec
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/araddon/qlbridge/datasource"
+)
+
+// A temp file of json serialized messages, one per line, for tasks that
+//  spill to disk when over their memory budget (JoinHash, GroupBy)
+type msgSpill struct {
+	name string
+	f    *os.File
+	w    *bufio.Writer
+	enc  *json.Encoder
+}
+
+// create a spill file in dir, "" is the os default temp dir, name is the
+//  task for the file name and errors ie "join"
+func newMsgSpill(dir, name string) (*msgSpill, error) {
+	f, err := ioutil.TempFile(dir, "qlbridge-"+name)
+	if err != nil {
+		return nil, fmt.Errorf("Could not create %s spill file: %v", name, err)
+	}
+	w := bufio.NewWriter(f)
+	return &msgSpill{name: name, f: f, w: w, enc: json.NewEncoder(w)}, nil
+}
+
+func (m *msgSpill) write(mt *datasource.SqlDriverMessageMap) error {
+	return m.enc.Encode(mt)
+}
+
+// read back all messages written
+func (m *msgSpill) read(fn func(mt *datasource.SqlDriverMessageMap) error) error {
+	if err := m.w.Flush(); err != nil {
+		return err
+	}
+	if _, err := m.f.Seek(0, 0); err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bufio.NewReader(m.f))
+	for {
+		mt := &datasource.SqlDriverMessageMap{}
+		if err := dec.Decode(mt); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("Could not read %s spill file: %v", m.name, err)
+		}
+		if err := fn(mt); err != nil {
+			return err
+		}
+	}
+}
+
+func (m *msgSpill) remove() {
+	if m == nil {
+		return
+	}
+	m.f.Close()
+	os.Remove(m.f.Name())
+}
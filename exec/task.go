@@ -2,6 +2,8 @@ package exec
 
 import (
 	"fmt"
+	"sync/atomic"
+	"time"
 
 	u "github.com/araddon/gou"
 
@@ -48,11 +50,106 @@ func (m *Tasks) Add(task TaskRunner) {
 	*m = append(*m, task)
 }
 
-type TaskBase struct {
-	depth    int
-	setup    bool
+// TaskMetrics is an optional instrumentation hook on a TaskBase, ie to
+//  export per task counters to prometheus.  It is called from the task's
+//  goroutines so must be safe for concurrent use.
+type TaskMetrics interface {
+	// A message was handled taking dur, ok is the handler's result, ie
+	//  false if it was not forwarded
+	MessageHandled(taskType string, dur time.Duration, ok bool)
+	// The task stopped on an error
+	TaskError(taskType string, err error)
+}
+
+// metricsStart is the start time of handling a message for Metrics, zero
+//  without Metrics so tasks with their own Run() loop pay nothing
+func (m *TaskBase) metricsStart() time.Time {
+	if m.Metrics == nil {
+		return time.Time{}
+	}
+	return time.Now()
+}
+
+// recordHandled records a message handled since start to Metrics, for
+//  tasks with their own Run() loop rather than a Handler.  Tasks that
+//  buffer their input (group by, sort) record ok if it was kept.
+func (m *TaskBase) recordHandled(start time.Time, ok bool) {
+	if m.Metrics != nil {
+		m.Metrics.MessageHandled(m.TaskType, time.Since(start), ok)
+	}
+}
+
+// recordError records the error a task stopped on to Metrics, and
+//  returns it
+func (m *TaskBase) recordError(err error) error {
+	if err != nil && m.Metrics != nil {
+		m.Metrics.TaskError(m.TaskType, err)
+	}
+	return err
+}
+
+// TaskStats is a TaskMetrics counting messages in/out, errors and
+//  handler time.
+type TaskStats struct {
+	in, out, errors, nanos int64
+}
+
+func NewTaskStats() *TaskStats { return &TaskStats{} }
+
+func (m *TaskStats) MessageHandled(taskType string, dur time.Duration, ok bool) {
+	atomic.AddInt64(&m.in, 1)
+	if ok {
+		atomic.AddInt64(&m.out, 1)
+	}
+	atomic.AddInt64(&m.nanos, int64(dur))
+}
+func (m *TaskStats) TaskError(taskType string, err error) { atomic.AddInt64(&m.errors, 1) }
+func (m *TaskStats) In() int64                            { return atomic.LoadInt64(&m.in) }
+func (m *TaskStats) Out() int64                           { return atomic.LoadInt64(&m.out) }
+func (m *TaskStats) Errors() int64                        { return atomic.LoadInt64(&m.errors) }
+func (m *TaskStats) Duration() time.Duration              { return time.Duration(atomic.LoadInt64(&m.nanos)) }
+
+// Finalizer is called once by TaskBase.Run() at end of stream, when the
+//  input channel is closed and every message has been handled, so tasks
+//  that buffer their input (aggregates, sort, distinct) have a place to
+//  emit their results.  It is not called if the task is stopped by a
+//  signal or error.
+type Finalizer func(ctx *expr.Context) error
+
+// ErrorRecord is a message that failed evaluation in a task, ie a bad row
+//  in projection or join, carrying the original message and the error.
+//  They are sent to the task's ErrorSink (dead-letter) rather than being
+//  dropped, so the rest of the rows flow.
+type ErrorRecord struct {
 	TaskType string
-	Handler  MessageHandler
+	Msg      datasource.Message
+	Err      error
+}
+
+func (m *ErrorRecord) Id() uint64 {
+	if m.Msg == nil {
+		return 0
+	}
+	return m.Msg.Id()
+}
+func (m *ErrorRecord) Body() interface{} { return m.Msg }
+func (m *ErrorRecord) Error() string     { return fmt.Sprintf("%s: %v", m.TaskType, m.Err) }
+
+type TaskBase struct {
+	depth     int
+	setup     bool
+	TaskType  string
+	Handler   MessageHandler
+	Finalizer Finalizer
+	// ErrorSink if set receives an *ErrorRecord for each message that
+	//  fails evaluation, else they are dropped.  It is shared by tasks so
+	//  is never closed by them.
+	ErrorSink MessageChan
+	// EvalOptions if set are the options for evaluating the expressions
+	//  of this task, see RuntimeSchema.EvalOptions()
+	EvalOptions *expr.EvalOptions
+	// Metrics if set is called for each message handled, nil costs nothing
+	Metrics  TaskMetrics
 	msgInCh  MessageChan
 	msgOutCh MessageChan
 	errCh    ErrChan
@@ -63,9 +160,20 @@ type TaskBase struct {
 }
 
 func NewTaskBase(taskType string) *TaskBase {
+	return NewTaskBaseSize(taskType, ItemDefaultChannelSize)
+}
+
+// Create a TaskBase with given output channel buffer size, larger buffers
+//  allow producer/consumer tasks to run without blocking on each other.
+//  On shutdown the output channel is closed, and any buffered messages
+//  are still delivered to the consumer before it sees the close.
+func NewTaskBaseSize(taskType string, bufferSize int) *TaskBase {
+	if bufferSize < 0 {
+		bufferSize = 0
+	}
 	return &TaskBase{
 		// All Tasks Get output channels by default, but NOT input
-		msgOutCh: make(MessageChan, ItemDefaultChannelSize),
+		msgOutCh: make(MessageChan, bufferSize),
 		sigCh:    make(SigChan, 1),
 		errCh:    make(ErrChan, 10),
 		TaskType: taskType,
@@ -90,6 +198,43 @@ func (m *TaskBase) SigChan() SigChan             { return m.sigCh }
 func (m *TaskBase) Type() string                 { return m.TaskType }
 func (m *TaskBase) Close() error                 { return nil }
 
+// Send a message that failed evaluation to the ErrorSink, returns true if
+//  it was sent, false if there is no sink (dropped) or on signal
+func (m *TaskBase) sendError(msg datasource.Message, err error) bool {
+	if m.ErrorSink == nil {
+		return false
+	}
+	select {
+	case m.ErrorSink <- &ErrorRecord{TaskType: m.TaskType, Msg: msg, Err: err}:
+		return true
+	case sig, ok := <-m.sigCh:
+		m.resignal(sig, ok)
+		return false
+	}
+}
+
+// resignal puts back a signal received from sigCh outside of the Run
+//  loop, so the loop (or other goroutines of the task) also see it and
+//  stop.  A closed sigCh is already seen by everyone.
+func (m *TaskBase) resignal(sig, ok bool) {
+	if !ok {
+		return
+	}
+	select {
+	case m.sigCh <- sig:
+	default:
+	}
+}
+
+// evalReader wraps the reader of a message to carry the EvalOptions (if
+//  any) of this task for evaluating expressions against it
+func (m *TaskBase) evalReader(reader expr.ContextReader) expr.ContextReader {
+	if m.EvalOptions == nil {
+		return reader
+	}
+	return datasource.NewContextOptions(reader, m.EvalOptions)
+}
+
 func MakeHandler(task TaskRunner) MessageHandler {
 	out := task.MessageOut()
 	return func(ctx *expr.Context, msg datasource.Message) bool {
@@ -125,6 +270,9 @@ msgLoop:
 		select {
 		case err = <-m.errCh:
 			//m.errors = append(m.errors, err)
+			if m.Metrics != nil {
+				m.Metrics.TaskError(m.TaskType, err)
+			}
 			break msgLoop
 		case <-m.sigCh: // Signal, ie quit etc
 			u.Debugf("got taskbase sig")
@@ -137,9 +285,20 @@ msgLoop:
 		case msg, ok = <-m.msgInCh:
 			if ok {
 				//u.Debugf("sending to handler: %v %T  %+v", m.Type(), msg, msg)
-				m.Handler(ctx, msg)
+				if m.Metrics == nil {
+					m.Handler(ctx, msg)
+				} else {
+					start := time.Now()
+					handled := m.Handler(ctx, msg)
+					m.Metrics.MessageHandled(m.TaskType, time.Since(start), handled)
+				}
 			} else {
 				//u.Debugf("msg in closed shutting down: %s", m.TaskType)
+				if m.Finalizer != nil {
+					if err = m.Finalizer(ctx); err != nil && m.Metrics != nil {
+						m.Metrics.TaskError(m.TaskType, err)
+					}
+				}
 				break msgLoop
 			}
 		case <-m.sigCh:
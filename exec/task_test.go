@@ -0,0 +1,745 @@
+package exec
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"database/sql/driver"
+	"encoding/hex"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/value"
+)
+
+/*
+Benchmark Projection throughput across output channel buffer sizes
+
+go test -bench="Projection" --run="Projection"
+
+Benchmark Projection across workers, of a cpu bound expression and a
+  latency bound one (a lookup).  The cpu bound speedup scales with
+  available cores, the latency bound one overlaps the waits
+
+go test -bench="ProjectionWorkers" --run="ProjectionWorkers"
+*/
+
+var projectionBenchCols = map[string]int{"user_id": 0, "email": 1, "referral_count": 2}
+
+func projectionBenchMsgs(ct int) []datasource.Message {
+	msgs := make([]datasource.Message, ct)
+	for i := 0; i < ct; i++ {
+		row := []driver.Value{"9Ip1aKbeZe2njCDM", "aaron@email.com", int64(i)}
+		msgs[i] = datasource.NewSqlDriverMessageMap(uint64(i), row, projectionBenchCols)
+	}
+	return msgs
+}
+
+// run msgs through a Projection, with given buffer size on both the
+//  input and output channels, returns count of messages received
+func runProjectionBuffered(bufferSize int, msgs []datasource.Message) int {
+	return len(runProjection(`select user_id, email, referral_count * 2 AS rc FROM users`, bufferSize, 1, msgs))
+}
+
+// run msgs through a Projection of sqlText with given workers, returns
+//  the projected messages in the order received
+func runProjection(sqlText string, bufferSize, workers int, msgs []datasource.Message) []*datasource.ContextSimple {
+	return runProjectionMetrics(sqlText, bufferSize, workers, nil, msgs)
+}
+
+func runProjectionMetrics(sqlText string, bufferSize, workers int, metrics TaskMetrics, msgs []datasource.Message) []*datasource.ContextSimple {
+	return runProjectionWith(sqlText, bufferSize, workers, func(p *Projection) { p.Metrics = metrics }, msgs)
+}
+
+// runProjection with setup of the Projection before it is run
+func runProjectionWith(sqlText string, bufferSize, workers int, setup func(p *Projection), msgs []datasource.Message) []*datasource.ContextSimple {
+	stmt, err := expr.ParseSql(sqlText)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	projection := NewProjectionSize(stmt.(*expr.SqlSelect), bufferSize)
+	projection.Workers = workers
+	setup(projection)
+	inCh := make(MessageChan, cap(projection.MessageOut()))
+	projection.MessageInSet(inCh)
+
+	go func() {
+		for _, msg := range msgs {
+			inCh <- msg
+		}
+		close(inCh)
+	}()
+	go projection.Run(expr.NewContext())
+
+	out := make([]*datasource.ContextSimple, 0, len(msgs))
+	for msg := range projection.MessageOut() {
+		if cs, ok := msg.(*datasource.ContextSimple); ok {
+			out = append(out, cs)
+		}
+	}
+	return out
+}
+
+// a cpu bound func, to make projection evaluation the bottleneck
+func spinFunc(ctx expr.EvalContext, x value.Value) (value.StringValue, bool) {
+	sum := sha1.Sum([]byte(x.ToString()))
+	for i := 0; i < 200; i++ {
+		sum = sha1.Sum(sum[:])
+	}
+	return value.NewStringValue(hex.EncodeToString(sum[:])), true
+}
+
+// a latency bound func, ie a lookup against a remote service
+func lookupFunc(ctx expr.EvalContext, x value.Value) (value.StringValue, bool) {
+	time.Sleep(100 * time.Microsecond)
+	return value.NewStringValue(x.ToString()), true
+}
+
+// counts its calls, to assert how often a projection is evaluated
+var constCalls int64
+
+func constFunc(ctx expr.EvalContext) (value.StringValue, bool) {
+	atomic.AddInt64(&constCalls, 1)
+	return value.NewStringValue("v1"), true
+}
+
+// a multi-column func, splits a name into first and last
+func splitNameFunc(ctx expr.EvalContext, name value.Value) (value.RowValue, bool) {
+	parts := strings.SplitN(name.ToString(), " ", 2)
+	last := value.Value(value.NilValueVal)
+	if len(parts) == 2 {
+		last = value.NewStringValue(parts[1])
+	}
+	return value.NewRowValue([]string{"first", "last"}, []value.Value{value.NewStringValue(parts[0]), last}), true
+}
+
+func init() {
+	if err := RegisterFunc("split_name", splitNameFunc); err != nil {
+		panic(err.Error())
+	}
+	if err := RegisterFunc("spin", spinFunc); err != nil {
+		panic(err.Error())
+	}
+	if err := RegisterFunc("lookup", lookupFunc); err != nil {
+		panic(err.Error())
+	}
+	if err := RegisterFunc("constcalls", constFunc); err != nil {
+		panic(err.Error())
+	}
+}
+
+func TestProjectionWorkers(t *testing.T) {
+	msgs := projectionBenchMsgs(500)
+	sqlText := `select referral_count, spin(referral_count) AS h FROM users`
+	expected := runProjection(sqlText, 10, 1, msgs)
+	assert.Tf(t, len(expected) == len(msgs), "should get all %d msgs but got %d", len(msgs), len(expected))
+	for _, workers := range []int{2, 4, 16} {
+		out := runProjection(sqlText, 10, workers, msgs)
+		assert.Tf(t, len(out) == len(msgs), "workers=%d should get all %d msgs but got %d", workers, len(msgs), len(out))
+		for i, cs := range out {
+			// order must be preserved, same as a single worker
+			row, expectedRow := cs.Row(), expected[i].Row()
+			assert.Tf(t, row["referral_count"].Value() == int64(i), "workers=%d out of order at %d %v", workers, i, row)
+			assert.Tf(t, row["h"].Value() == expectedRow["h"].Value(), "workers=%d %v", workers, row)
+		}
+	}
+}
+
+func TestProjectionWorkersStop(t *testing.T) {
+	stmt, _ := expr.ParseSql(`select user_id, lookup(email) AS h FROM users`)
+	projection := NewProjection(stmt.(*expr.SqlSelect))
+	projection.Workers = 4
+	finalized := false
+	projection.Finalizer = func(ctx *expr.Context) error {
+		finalized = true
+		return nil
+	}
+	// the input is never closed, the signal stops every worker
+	inCh := make(MessageChan)
+	go func() {
+		for msg := range teeMsgs(1000) {
+			inCh <- msg
+		}
+	}()
+	projection.MessageInSet(inCh)
+	errCh := make(chan error, 1)
+	go func() { errCh <- projection.Run(expr.NewContext()) }()
+	<-projection.MessageOut()
+	projection.SigChan() <- true
+	go drain(projection.MessageOut())
+	select {
+	case err := <-errCh:
+		assert.Tf(t, err == nil, "no error %v", err)
+	case <-time.After(time.Second):
+		t.Fatalf("Projection workers did not stop on signal")
+	}
+	assert.T(t, !finalized)
+
+	// end of stream calls the finalizer
+	projection = NewProjection(stmt.(*expr.SqlSelect))
+	projection.Workers = 4
+	projection.Finalizer = func(ctx *expr.Context) error {
+		finalized = true
+		return nil
+	}
+	projection.MessageInSet(teeMsgs(20))
+	go projection.Run(expr.NewContext())
+	assert.T(t, len(drain(projection.MessageOut())) == 20)
+	assert.T(t, finalized)
+}
+
+// a message type tasks know nothing about, other than it is a reader
+type readerMsg struct {
+	id  uint64
+	row map[string]value.Value
+}
+
+func (m *readerMsg) Id() uint64                         { return m.id }
+func (m *readerMsg) Body() interface{}                  { return m }
+func (m *readerMsg) Row() map[string]value.Value        { return m.row }
+func (m *readerMsg) Ts() time.Time                      { return time.Time{} }
+func (m *readerMsg) Get(key string) (value.Value, bool) { v, ok := m.row[key]; return v, ok }
+
+// a message whose Body() is a reader
+type bodyReaderMsg struct {
+	body *datasource.ContextSimple
+}
+
+func (m *bodyReaderMsg) Id() uint64        { return 0 }
+func (m *bodyReaderMsg) Body() interface{} { return m.body }
+
+func TestProjectionMessageReader(t *testing.T) {
+	row := map[string]value.Value{
+		"user_id":        value.NewStringValue("9Ip1aKbeZe2njCDM"),
+		"referral_count": value.NewIntValue(3),
+	}
+	msgs := []datasource.Message{
+		&readerMsg{id: 1, row: row},
+		&bodyReaderMsg{datasource.NewContextSimpleData(row)},
+	}
+	out := runProjection(`select user_id, referral_count * 2 AS rc FROM users`, 10, 1, msgs)
+	assert.Tf(t, len(out) == 2, "should project both msgs %v", out)
+	for _, cs := range out {
+		row := cs.Row()
+		assert.Tf(t, row["user_id"].ToString() == "9Ip1aKbeZe2njCDM", "%v", row)
+		assert.Tf(t, row["rc"].ToString() == "6", "%v", row)
+	}
+}
+
+func TestProjectionIndexed(t *testing.T) {
+	row := map[string]value.Value{
+		"items": value.NewSliceValues([]value.Value{value.NewStringValue("apple"), value.NewIntValue(2)}),
+		"attrs": value.NewMapValue(map[string]interface{}{"color": "red"}),
+	}
+	msgs := []datasource.Message{&readerMsg{id: 1, row: row}}
+	out := runProjection(`select items[0] AS first, items[1] AS second, items[5] AS missing, attrs["color"] AS color FROM users`, 10, 1, msgs)
+	assert.Tf(t, len(out) == 1, "should project msg %v", out)
+	row = out[0].Row()
+	assert.Tf(t, row["first"].ToString() == "apple", "%v", row)
+	assert.Tf(t, row["second"].Value() == int64(2), "%v", row)
+	assert.Tf(t, row["missing"].Type() == value.NilType, "out of range is null %v", row)
+	assert.Tf(t, row["color"].ToString() == "red", "%v", row)
+}
+
+func TestProjectionGuardPolicy(t *testing.T) {
+	msgs := []datasource.Message{
+		datasource.NewContextSimpleData(map[string]value.Value{"user_id": value.NewIntValue(1), "email": value.NewStringValue("a@x.com"), "score": value.NewIntValue(10)}),
+		datasource.NewContextSimpleData(map[string]value.Value{"user_id": value.NewIntValue(2), "email": value.NewStringValue("b@x.com"), "score": value.NewIntValue(1)}),
+		datasource.NewContextSimpleData(map[string]value.Value{"user_id": value.NewIntValue(3), "email": value.NewStringValue("c@x.com")}),
+	}
+	sqlText := `select user_id, email IF score > 5 FROM users`
+	hasEmail := func(row *datasource.ContextSimple) bool {
+		_, ok := row.Row()["email"]
+		return ok
+	}
+	for _, workers := range []int{1, 4} {
+		out := runProjection(sqlText, 10, workers, msgs)
+		assert.Tf(t, len(out) == 3, "workers=%d %v", workers, out)
+		assert.Tf(t, hasEmail(out[0]) && !hasEmail(out[1]), "guard true includes, false excludes %v", out)
+		assert.Tf(t, !hasEmail(out[2]), "a guard on a missing field excludes by default %v", out[2].Row())
+
+		out = runProjectionWith(sqlText, 10, workers, func(p *Projection) { p.GuardPolicy = GuardInclude }, msgs)
+		assert.Tf(t, len(out) == 3 && hasEmail(out[2]), "GuardInclude includes %v", out)
+
+		out = runProjectionWith(sqlText, 10, workers, func(p *Projection) { p.GuardPolicy = GuardDropRow }, msgs)
+		assert.Tf(t, len(out) == 2, "GuardDropRow drops the row %v", out)
+		assert.Tf(t, out[1].Row()["user_id"].Value() == int64(2), "%v", out[1].Row())
+	}
+}
+
+// a message that is not a reader, so can not be projected
+type opaqueMsg struct{}
+
+func (m *opaqueMsg) Id() uint64        { return 0 }
+func (m *opaqueMsg) Body() interface{} { return "opaque" }
+
+func TestProjectionConstant(t *testing.T) {
+	msgs := projectionBenchMsgs(50)
+	for _, workers := range []int{1, 4} {
+		out := runProjection(`select 1 + 2 AS three, "v1" AS version FROM users`, 10, workers, msgs)
+		assert.Tf(t, len(out) == len(msgs), "workers=%d should get all %d msgs but got %d", workers, len(msgs), len(out))
+		for _, cs := range out {
+			row := cs.Row()
+			assert.Tf(t, len(row) == 2 && row["three"].Value() == int64(3) && row["version"].Value() == "v1", "%v", row)
+		}
+		// each message is its own copy
+		out[0].Row()["three"] = value.NewIntValue(4)
+		assert.Tf(t, out[1].Row()["three"].Value() == int64(3), "%v", out[1].Row())
+	}
+
+	// the constant row is evaluated once per query, not per message
+	for _, workers := range []int{1, 4} {
+		evaluated := int64(0)
+		out := runProjectionWith(`select 1 + 2 AS three, "v1" AS version FROM users`, 10, workers, func(p *Projection) {
+			evaluate := p.evaluator()
+			p.project = p.projector(func(msg datasource.Message) datasource.Message {
+				atomic.AddInt64(&evaluated, 1)
+				return evaluate(msg)
+			})
+		}, msgs)
+		assert.Tf(t, len(out) == len(msgs), "workers=%d %d", workers, len(out))
+		assert.Tf(t, atomic.LoadInt64(&evaluated) == 1, "workers=%d evaluated %d times", workers, evaluated)
+	}
+
+	// a message that can't be projected doesn't drop the rest
+	out := runProjection(`select 1 + 2 AS three FROM users`, 10, 1, append([]datasource.Message{&opaqueMsg{}}, msgs...))
+	assert.Tf(t, len(out) == len(msgs), "should get %d msgs but got %d", len(msgs), len(out))
+
+	// funcs are evaluated per row, they may not be deterministic
+	atomic.StoreInt64(&constCalls, 0)
+	out = runProjection(`select constcalls() AS version FROM users`, 10, 1, msgs)
+	assert.Tf(t, len(out) == len(msgs) && atomic.LoadInt64(&constCalls) == int64(len(msgs)), "%d", constCalls)
+	out = runProjection(`select uuid() AS id FROM users`, 10, 1, msgs)
+	assert.Tf(t, len(out) == len(msgs), "%v", out)
+	assert.Tf(t, out[0].Row()["id"].ToString() != out[1].Row()["id"].ToString(), "a uuid per row %v %v", out[0].Row(), out[1].Row())
+}
+
+func TestProjectionTransform(t *testing.T) {
+	msgs := []datasource.Message{
+		datasource.NewContextSimpleData(map[string]value.Value{"user_id": value.NewIntValue(1), "name": value.NewStringValue("  Aaron ")}),
+		datasource.NewContextSimpleData(map[string]value.Value{"user_id": value.NewIntValue(2), "name": value.NewStringValue("BOB")}),
+	}
+	trim := func(v value.Value) value.Value { return value.NewStringValue(strings.TrimSpace(v.ToString())) }
+	lower := func(v value.Value) value.Value { return value.NewStringValue(strings.ToLower(v.ToString())) }
+	for _, workers := range []int{1, 4} {
+		out := runProjectionWith(`select user_id, name AS n, name FROM users`, 10, workers, func(p *Projection) {
+			p.AddTransform("n", trim)
+			p.AddTransform("n", lower)
+		}, msgs)
+		assert.Tf(t, len(out) == 2, "workers=%d %v", workers, out)
+		assert.Tf(t, out[0].Row()["n"].Value() == "aaron", "trim then lower %v", out[0].Row())
+		assert.Tf(t, out[1].Row()["n"].Value() == "bob", "%v", out[1].Row())
+		assert.Tf(t, out[0].Row()["name"].Value() == "  Aaron ", "other columns untouched %v", out[0].Row())
+		assert.Tf(t, out[0].Row()["user_id"].Value() == int64(1), "%v", out[0].Row())
+	}
+}
+
+func TestProjectionForceString(t *testing.T) {
+	msgs := []datasource.Message{
+		datasource.NewContextSimpleData(map[string]value.Value{"zip": value.NewStringValue("00123"), "id": value.NewIntValue(7)}),
+	}
+	out := runProjectionWith(`select zip, zip AS zip2, id FROM users`, 10, 1, func(p *Projection) {
+		p.AddTransform("zip2", value.ForceString)
+		p.AddTransform("id", value.ForceString)
+	}, msgs)
+	assert.Tf(t, len(out) == 1, "%v", out)
+
+	var buf bytes.Buffer
+	sink := NewJsonLinesSink(&buf)
+	err := sink.Put(out[0])
+	assert.Tf(t, err == nil, "no error %v", err)
+	line := strings.TrimSpace(buf.String())
+	assert.Tf(t, line == `{"id":"7","zip":"00123","zip2":"00123"}`, "leading zeros kept %s", line)
+}
+
+func TestProjectionRowValue(t *testing.T) {
+	msgs := []datasource.Message{
+		datasource.NewContextSimpleData(map[string]value.Value{"user_id": value.NewIntValue(1), "name": value.NewStringValue("aaron smith")}),
+		datasource.NewContextSimpleData(map[string]value.Value{"user_id": value.NewIntValue(2), "name": value.NewStringValue("bob")}),
+	}
+	sqlText := `select user_id, split_name(name) FROM users`
+	out := runProjectionWith(sqlText, 10, 1, func(p *Projection) {
+		p.AddTransform("last", value.ForceString)
+	}, msgs)
+	assert.Tf(t, len(out) == 2, "%v", out)
+	meta := out[0].Meta()
+	assert.Tf(t, len(meta) == 3 && meta[1].Key == "first" && meta[2].Key == "last", "expanded in order %v", meta)
+	row := out[0].Row()
+	assert.Tf(t, row["first"].Value() == "aaron" && row["last"].Value() == "smith", "%v", row)
+	row = out[1].Row()
+	assert.Tf(t, row["first"].Value() == "bob" && value.IsNull(row["last"]), "%v", row)
+
+	stmt, _ := expr.ParseSql(sqlText)
+	p := NewProjection(stmt.(*expr.SqlSelect))
+	schema := p.OutputSchema()
+	assert.Tf(t, len(schema) == 1, "only user_id before rows are projected %v", schema)
+}
+
+func TestProjectionPackAs(t *testing.T) {
+	msgs := []datasource.Message{
+		datasource.NewContextSimpleData(map[string]value.Value{"user_id": value.NewIntValue(1), "name": value.NewStringValue("aaron smith")}),
+	}
+	sqlText := `select user_id AS id, name, split_name(name), 1 + 2 AS three FROM users`
+	for _, workers := range []int{1, 4} {
+		out := runProjectionWith(sqlText, 10, workers, func(p *Projection) { p.PackAs = "user" }, msgs)
+		assert.Tf(t, len(out) == 1, "workers=%d %v", workers, out)
+		row := out[0].Row()
+		assert.Tf(t, len(row) == 1, "one packed column %v", row)
+		packed, ok := row["user"].(value.MapValue)
+		assert.Tf(t, ok, "packed into a map %T", row["user"])
+		cols := packed.Val()
+		assert.Tf(t, len(cols) == 5, "all projected aliases %v", cols)
+		assert.Tf(t, cols["id"].Value() == int64(1) && cols["name"].Value() == "aaron smith", "%v", cols)
+		assert.Tf(t, cols["first"].Value() == "aaron" && cols["last"].Value() == "smith", "%v", cols)
+		assert.Tf(t, cols["three"].Value() == int64(3), "%v", cols)
+	}
+
+	stmt, _ := expr.ParseSql(sqlText)
+	p := NewProjection(stmt.(*expr.SqlSelect))
+	p.PackAs = "user"
+	schema := p.OutputSchema()
+	assert.Tf(t, len(schema) == 1 && schema["user"] == value.MapValueType, "%v", schema)
+}
+
+func TestProjectionColumnMeta(t *testing.T) {
+	msgs := []datasource.Message{
+		datasource.NewContextSimpleData(map[string]value.Value{"user_id": value.NewStringValue("9Ip1aKbeZe2njCDM"), "score": value.NewStringValue("22")}),
+	}
+	sqlText := `select user_id, toint(score) AS rc, tonumber(score) AS n, score > 20 AS high, not_a_field AS nf FROM users`
+	for _, sqlText := range []string{sqlText, `select 1 AS one, "a" AS a FROM users`} {
+		out := runProjection(sqlText, 10, 1, msgs)
+		assert.Tf(t, len(out) == 1, "%v", out)
+		stmt, _ := expr.ParseSql(sqlText)
+		cols := stmt.(*expr.SqlSelect).Columns
+		meta := out[0].Meta()
+		assert.Tf(t, len(meta) == len(cols), "%s %v", sqlText, meta)
+		for i, col := range meta {
+			assert.Tf(t, col.Key == cols[i].As && col.Pos == i, "in projection order %v", meta)
+			v := out[0].Row()[col.Key]
+			assert.Tf(t, col.Type == v.Type(), "%s type %s of value %s", col.Key, col.Type, v.Type())
+		}
+		if len(meta) == 5 {
+			assert.Tf(t, meta[1].Type == value.IntType && meta[2].Type == value.NumberType && meta[4].Type == value.NilType, "%v", meta)
+		}
+	}
+}
+
+func TestProjectionErrorSink(t *testing.T) {
+	row := func(id int64, score string) datasource.Message {
+		return datasource.NewContextSimpleData(map[string]value.Value{"user_id": value.NewIntValue(id), "score": value.NewStringValue(score)})
+	}
+	msgs := []datasource.Message{row(1, "10"), row(2, "hello"), row(3, "2.5"), row(4, "7")}
+	sqlText := `select user_id, tonumber(score) AS n FROM users`
+	for _, workers := range []int{1, 4} {
+		errCh := make(MessageChan, len(msgs))
+		out := runProjectionWith(sqlText, 10, workers, func(p *Projection) { p.ErrorSink = errCh }, msgs)
+		assert.Tf(t, len(out) == 3, "workers=%d good rows flow %v", workers, out)
+		for i, id := range []int64{1, 3, 4} {
+			assert.Tf(t, out[i].Row()["user_id"].Value() == id, "%v", out[i].Row())
+		}
+		assert.Tf(t, len(errCh) == 1, "bad row in the error sink %d", len(errCh))
+		rec := (<-errCh).(*ErrorRecord)
+		assert.Tf(t, rec.TaskType == "Projection" && rec.Err != nil, "%v", rec)
+		bad := rec.Msg.(*datasource.ContextSimple).Row()
+		assert.Tf(t, bad["user_id"].Value() == int64(2), "the original row %v", bad)
+	}
+
+	// without a sink the failed column is left out
+	out := runProjection(sqlText, 10, 1, msgs)
+	assert.Tf(t, len(out) == 4, "%v", out)
+	_, hasN := out[1].Row()["n"]
+	assert.Tf(t, !hasN, "%v", out[1].Row())
+}
+
+func TestProjectionEvalOptions(t *testing.T) {
+	ts := time.Date(2015, 7, 4, 12, 0, 0, 0, time.UTC)
+	msgs := []datasource.Message{
+		datasource.NewContextSimpleData(map[string]value.Value{
+			"a":    value.NewTimeValue(ts),
+			"near": value.NewTimeValue(ts.Add(300 * time.Millisecond)),
+		}),
+	}
+	sqlText := `select a == near AS eq FROM users`
+	for _, workers := range []int{1, 4} {
+		out := runProjection(sqlText, 10, workers, msgs)
+		assert.Tf(t, len(out) == 1 && out[0].Row()["eq"].Value() == false, "exact by default %v", out)
+		out = runProjectionWith(sqlText, 10, workers, func(p *Projection) {
+			p.EvalOptions = &expr.EvalOptions{TimeTolerance: time.Second}
+		}, msgs)
+		assert.Tf(t, len(out) == 1 && out[0].Row()["eq"].Value() == true, "within tolerance %v", out)
+	}
+}
+
+func TestProjectionErrorSinkStop(t *testing.T) {
+	// nobody reads the sink, a signal must still stop the projection
+	bad := datasource.NewContextSimpleData(map[string]value.Value{"score": value.NewStringValue("hello")})
+	stmt, _ := expr.ParseSql(`select tonumber(score) AS n FROM users`)
+	for _, workers := range []int{1, 4} {
+		projection := NewProjection(stmt.(*expr.SqlSelect))
+		projection.Workers = workers
+		projection.ErrorSink = make(MessageChan)
+		inCh := make(MessageChan, 1)
+		inCh <- bad
+		projection.MessageInSet(inCh)
+
+		done := make(chan error)
+		go func() {
+			done <- projection.Run(expr.NewContext())
+		}()
+		time.Sleep(time.Millisecond * 20)
+		projection.SigChan() <- true
+		select {
+		case err := <-done:
+			assert.Tf(t, err == nil, "workers=%d %v", workers, err)
+		case <-time.After(time.Second):
+			t.Fatalf("workers=%d projection blocked on a full error sink did not stop", workers)
+		}
+	}
+}
+
+func TestProjectionJsonSource(t *testing.T) {
+	lines := `{"user_id": 1, "name": "aaron", "score": 4.5}
+{"user_id": 2, "name": "bob", "tags": ["x", "y"]}
+{"user_id": 3}`
+	src, err := datasource.NewJsonSource("users", strings.NewReader(lines), make(<-chan bool, 1))
+	assert.Tf(t, err == nil, "%v", err)
+	msgs := make([]datasource.Message, 0)
+	for msg := src.Next(); msg != nil; msg = src.Next() {
+		msgs = append(msgs, msg)
+	}
+	out := runProjection(`select user_id * 10 AS uid, name, score, tags[1] AS tag FROM users`, 10, 1, msgs)
+	assert.Tf(t, len(out) == 3, "%v", out)
+	row := out[0].Row()
+	assert.Tf(t, row["uid"].Value() == int64(10), "ints stay ints %v", row)
+	assert.Tf(t, row["name"].ToString() == "aaron" && row["score"].Value() == 4.5, "%v", row)
+	row = out[1].Row()
+	assert.Tf(t, row["tag"].ToString() == "y", "%v", row)
+	assert.Tf(t, value.IsNull(row["score"]), "missing key is null %v", row)
+	row = out[2].Row()
+	assert.Tf(t, value.IsNull(row["name"]), "missing key is null %v", row)
+}
+
+func TestProjectionStats(t *testing.T) {
+	msgs := projectionBenchMsgs(25)
+	for _, workers := range []int{1, 4} {
+		stats := NewTaskStats()
+		out := runProjectionMetrics(`select user_id, referral_count * 2 AS rc FROM users`, 10, workers, stats, msgs)
+		assert.Tf(t, len(out) == len(msgs), "workers=%d should get all %d msgs but got %d", workers, len(msgs), len(out))
+		assert.Tf(t, stats.In() == int64(len(msgs)), "workers=%d in %d", workers, stats.In())
+		assert.Tf(t, stats.Out() == int64(len(msgs)), "workers=%d out %d", workers, stats.Out())
+		assert.Tf(t, stats.Errors() == 0, "workers=%d errors %d", workers, stats.Errors())
+		assert.Tf(t, stats.Duration() > 0, "workers=%d duration %v", workers, stats.Duration())
+	}
+}
+
+func TestTaskStatsRunLoops(t *testing.T) {
+	// tasks with their own Run() loop rather than a Handler also record
+	stmt, err := expr.ParseSql(`select n FROM t GROUP BY n ORDER BY n DESC`)
+	assert.Tf(t, err == nil, "%v", err)
+	sel := stmt.(*expr.SqlSelect)
+
+	sample, throttle, sort, groupBy := NewSampleEvery(2), NewThrottleDelay(0), NewSort(sel), NewGroupBy(sel)
+	tests := []struct {
+		task  TaskRunner
+		base  *TaskBase
+		in    int64
+		out   int64
+		stats *TaskStats
+	}{
+		{sample, sample.TaskBase, 10, 5, NewTaskStats()},
+		{throttle, throttle.TaskBase, 10, 10, NewTaskStats()},
+		{sort, sort.TaskBase, 10, 10, NewTaskStats()},
+		{groupBy, groupBy.TaskBase, 10, 10, NewTaskStats()},
+	}
+	for _, test := range tests {
+		test.base.Metrics = test.stats
+		test.task.MessageInSet(teeMsgs(10))
+		go test.task.Run(expr.NewContext())
+		drain(test.task.MessageOut())
+		assert.Tf(t, test.stats.In() == test.in, "%s in %d", test.base.TaskType, test.stats.In())
+		assert.Tf(t, test.stats.Out() == test.out, "%s out %d", test.base.TaskType, test.stats.Out())
+		assert.Tf(t, test.stats.Errors() == 0, "%s errors %d", test.base.TaskType, test.stats.Errors())
+	}
+
+	// the error a task stops on is recorded
+	stats := NewTaskStats()
+	groupBy = NewGroupBy(sel)
+	groupBy.Metrics = stats
+	in := make(MessageChan, 1)
+	in <- &opaqueMsg{}
+	close(in)
+	groupBy.MessageInSet(in)
+	err = groupBy.Run(expr.NewContext())
+	assert.Tf(t, err != nil && stats.Errors() == 1, "%v errors %d", err, stats.Errors())
+}
+
+func findProjection(tasks Tasks) *Projection {
+	for _, task := range tasks {
+		if p, ok := task.(*Projection); ok {
+			return p
+		}
+		if p := findProjection(task.Children()); p != nil {
+			return p
+		}
+	}
+	return nil
+}
+
+func TestProjectionOutputSchema(t *testing.T) {
+	sqlText := `select user_id, toint(referral_count) AS rc, tonumber(referral_count) AS score,
+		todate(reg_date) AS reg, contains(email, "@") AS has_email, not_a_field AS nf, 5.5 AS lit
+		FROM users`
+	job, err := BuildSqlJob(rtConf, "mockcsv", sqlText)
+	assert.Tf(t, err == nil, "no error %v", err)
+	projection := findProjection(job.RootTask.Children())
+	assert.T(t, projection != nil)
+
+	// before any rows only the expression analysis is known
+	schema := projection.OutputSchema()
+	assert.Tf(t, schema["lit"] == value.NumberType, "%v", schema)
+	assert.Tf(t, schema["user_id"] == value.UnknownType, "%v", schema)
+
+	msgs := make([]datasource.Message, 0)
+	resultWriter := NewResultBuffer(&msgs)
+	job.RootTask.Add(resultWriter)
+	err = job.Setup()
+	assert.T(t, err == nil)
+	err = job.Run()
+	time.Sleep(time.Millisecond * 10)
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Tf(t, len(msgs) == 3, "should have 3 rows %v", len(msgs))
+
+	schema = projection.OutputSchema()
+	expected := map[string]value.ValueType{
+		"user_id":   value.StringType,
+		"rc":        value.IntType,
+		"score":     value.NumberType,
+		"reg":       value.TimeType,
+		"has_email": value.BoolType,
+		"nf":        value.UnknownType,
+		"lit":       value.NumberType,
+	}
+	assert.Tf(t, len(schema) == len(expected), "%v", schema)
+	for col, vt := range expected {
+		assert.Tf(t, schema[col] == vt, "%s expected %s got %s", col, vt, schema[col])
+	}
+}
+
+func TestProjectionOutputSchemaSample(t *testing.T) {
+	stmt, err := expr.ParseSql(`select n FROM t`)
+	assert.Tf(t, err == nil, "%v", err)
+	projection := NewProjection(stmt.(*expr.SqlSelect))
+
+	// only the first SchemaSampleRows rows are recorded
+	for i := int64(0); i < SchemaSampleRows; i++ {
+		projection.recordSchema(map[string]value.Value{"n": value.NewIntValue(i)})
+	}
+	projection.recordSchema(map[string]value.Value{"n": value.NewNumberValue(1.5), "late": value.NewIntValue(1)})
+	schema := projection.OutputSchema()
+	assert.Tf(t, schema["n"] == value.IntType, "%v", schema)
+	_, hasLate := schema["late"]
+	assert.Tf(t, !hasLate, "%v", schema)
+}
+
+func TestTaskBufferShutdown(t *testing.T) {
+	msgs := projectionBenchMsgs(200)
+	for _, size := range []int{-1, 0, 1, 50, 500} {
+		// once input closes, every buffered message must still be
+		// delivered before the output channel close is seen
+		ct := runProjectionBuffered(size, msgs)
+		assert.Tf(t, ct == len(msgs), "buffer=%d should get all %d msgs but got %d", size, len(msgs), ct)
+	}
+	tb := NewTaskBaseSize("test", 25)
+	assert.T(t, cap(tb.MessageOut()) == 25)
+	assert.T(t, cap(NewTaskBase("test").MessageOut()) == ItemDefaultChannelSize)
+}
+
+func TestTaskFinalize(t *testing.T) {
+	msgs := projectionBenchMsgs(20)
+	tb := NewTaskBase("count")
+	handled, finalized := 0, 0
+	tb.Handler = func(ctx *expr.Context, msg datasource.Message) bool {
+		handled++
+		return true
+	}
+	tb.Finalizer = func(ctx *expr.Context) error {
+		finalized++
+		// emit the buffered result at end of stream
+		tb.MessageOut() <- datasource.NewContextSimpleData(map[string]value.Value{"ct": value.NewIntValue(int64(handled))})
+		return nil
+	}
+	inCh := make(MessageChan, len(msgs))
+	for _, msg := range msgs {
+		inCh <- msg
+	}
+	close(inCh)
+	tb.MessageInSet(inCh)
+
+	err := tb.Run(expr.NewContext())
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Tf(t, finalized == 1, "finalize once after input close but was %d", finalized)
+	out := make([]datasource.Message, 0)
+	for msg := range tb.MessageOut() {
+		out = append(out, msg)
+	}
+	assert.Tf(t, len(out) == 1, "%v", out)
+	ct := out[0].(*datasource.ContextSimple).Row()["ct"]
+	assert.Tf(t, ct.Value() == int64(len(msgs)), "finalize after all messages handled %v", ct)
+
+	// a task stopped by signal is not finalized
+	tb = NewTaskBase("count")
+	tb.Handler = func(ctx *expr.Context, msg datasource.Message) bool { return true }
+	tb.Finalizer = func(ctx *expr.Context) error {
+		finalized++
+		return nil
+	}
+	tb.MessageInSet(make(MessageChan))
+	tb.SigChan() <- true
+	err = tb.Run(expr.NewContext())
+	assert.Tf(t, err == nil && finalized == 1, "not finalized on signal %v %d", err, finalized)
+}
+
+func benchProjectionBuffer(b *testing.B, bufferSize int) {
+	msgs := projectionBenchMsgs(10000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if ct := runProjectionBuffered(bufferSize, msgs); ct != len(msgs) {
+			b.Fatalf("expected %d msgs got %d", len(msgs), ct)
+		}
+	}
+}
+
+func BenchmarkProjectionBuffer0(b *testing.B)   { benchProjectionBuffer(b, 0) }
+func BenchmarkProjectionBuffer10(b *testing.B)  { benchProjectionBuffer(b, 10) }
+func BenchmarkProjectionBuffer50(b *testing.B)  { benchProjectionBuffer(b, 50) }
+func BenchmarkProjectionBuffer500(b *testing.B) { benchProjectionBuffer(b, 500) }
+
+func benchProjectionWorkers(b *testing.B, sqlText string, workers int) {
+	msgs := projectionBenchMsgs(2000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		out := runProjection(sqlText, 50, workers, msgs)
+		if len(out) != len(msgs) {
+			b.Fatalf("expected %d msgs got %d", len(msgs), len(out))
+		}
+	}
+}
+
+func BenchmarkProjectionWorkers1(b *testing.B) {
+	benchProjectionWorkers(b, `select user_id, spin(email) AS h FROM users`, 1)
+}
+func BenchmarkProjectionWorkers4(b *testing.B) {
+	benchProjectionWorkers(b, `select user_id, spin(email) AS h FROM users`, 4)
+}
+func BenchmarkProjectionWorkersLookup1(b *testing.B) {
+	benchProjectionWorkers(b, `select user_id, lookup(email) AS h FROM users`, 1)
+}
+func BenchmarkProjectionWorkersLookup4(b *testing.B) {
+	benchProjectionWorkers(b, `select user_id, lookup(email) AS h FROM users`, 4)
+}
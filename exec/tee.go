@@ -0,0 +1,164 @@
+package exec
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"sync/atomic"
+
+	u "github.com/araddon/gou"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/expr"
+)
+
+var (
+	// Ensure that we implement the Task Runner interface
+	_ TaskRunner = (*Tee)(nil)
+)
+
+// TeePolicy is what a Tee does when an output's buffer is full, ie
+//  its consumer is slower than the others
+type TeePolicy int
+
+const (
+	TeeBlock TeePolicy = iota // wait for the slow consumer, backpressure to all
+	TeeDrop                   // drop the message for the slow consumer only
+	TeeError                  // stop the tee with an error
+)
+
+func (m TeePolicy) String() string {
+	switch m {
+	case TeeBlock:
+		return "block"
+	case TeeDrop:
+		return "drop"
+	case TeeError:
+		return "error"
+	}
+	return "unknown"
+}
+
+// Tee duplicates each message to N outputs, to feed the same stream to
+//  multiple downstream tasks (ie a projection and a side aggregate).
+//  Each output has its own buffer, output 0 is MessageOut() so a Tee can
+//  be part of a sequential task list, the rest are wired with Output(i):
+//
+//    tee := NewTee(2, 100, TeeDrop)
+//    sideAgg.MessageInSet(tee.Output(1))
+//
+//  The policy for a full output is per output, ie block on the primary
+//  output but drop for a side consumer:
+//
+//    tee.Policies[0] = TeeBlock
+//
+//  Every output gets its own copy of each message so a consumer mutating
+//  it doesn't affect the others.
+type Tee struct {
+	*TaskBase
+	Policies []TeePolicy // per output, defaults to the NewTee() policy
+	outs     []MessageChan
+	dropped  []int64
+}
+
+func NewTee(outputs, bufferSize int, policy TeePolicy) *Tee {
+	if outputs < 1 {
+		outputs = 1
+	}
+	m := &Tee{
+		TaskBase: NewTaskBaseSize("Tee", bufferSize),
+		Policies: make([]TeePolicy, outputs),
+		outs:     make([]MessageChan, outputs),
+		dropped:  make([]int64, outputs),
+	}
+	m.outs[0] = m.msgOutCh
+	for i := 0; i < outputs; i++ {
+		if i > 0 {
+			m.outs[i] = make(MessageChan, cap(m.msgOutCh))
+		}
+		m.Policies[i] = policy
+	}
+	return m
+}
+
+func (m *Tee) Copy() *Tee {
+	nm := NewTee(len(m.outs), cap(m.msgOutCh), TeeBlock)
+	copy(nm.Policies, m.Policies)
+	return nm
+}
+
+// Output channel i, 0 is MessageOut()
+func (m *Tee) Output(i int) MessageChan { return m.outs[i] }
+
+// Dropped is the count of messages dropped for output i by TeeDrop policy
+func (m *Tee) Dropped(i int) int64 { return atomic.LoadInt64(&m.dropped[i]) }
+
+// The output channel for MessageOut(), replaces output 0
+func (m *Tee) MessageOutSet(ch MessageChan) {
+	m.TaskBase.MessageOutSet(ch)
+	m.outs[0] = ch
+}
+
+func (m *Tee) Run(ctx *expr.Context) error {
+	defer ctx.Recover()
+	defer func() {
+		for _, out := range m.outs {
+			close(out)
+		}
+	}()
+
+	for {
+		select {
+		case err := <-m.errCh:
+			return m.recordError(err)
+		case <-m.sigCh:
+			return nil
+		case msg, ok := <-m.msgInCh:
+			if !ok {
+				return nil
+			}
+			start := m.metricsStart()
+			for i, out := range m.outs {
+				outMsg := msg
+				if i > 0 {
+					outMsg = cloneMessage(msg)
+				}
+				switch m.Policies[i] {
+				case TeeDrop:
+					select {
+					case out <- outMsg:
+					default:
+						atomic.AddInt64(&m.dropped[i], 1)
+					}
+				case TeeError:
+					select {
+					case out <- outMsg:
+					default:
+						u.Warnf("tee output %d is full", i)
+						return m.recordError(fmt.Errorf("tee output %d is full, consumer is too slow", i))
+					}
+				default:
+					select {
+					case out <- outMsg:
+					case <-m.sigCh:
+						return nil
+					}
+				}
+			}
+			m.recordHandled(start, true)
+		}
+	}
+}
+
+// cloneMessage copies the row of the known mutable message types, other
+//  messages are shared
+func cloneMessage(msg datasource.Message) datasource.Message {
+	switch mt := msg.(type) {
+	case *datasource.ContextSimple:
+		return mt.Copy()
+	case *datasource.SqlDriverMessageMap:
+		nm := mt.Copy()
+		nm.SetRow(append([]driver.Value(nil), mt.Values()...))
+		return nm
+	}
+	return msg
+}
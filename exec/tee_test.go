@@ -0,0 +1,80 @@
+package exec
+
+import (
+	"testing"
+
+	"github.com/bmizerany/assert"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/value"
+)
+
+func teeMsgs(ct int) MessageChan {
+	in := make(MessageChan, ct)
+	for i := 0; i < ct; i++ {
+		in <- datasource.NewContextSimpleData(map[string]value.Value{"n": value.NewIntValue(int64(i))})
+	}
+	close(in)
+	return in
+}
+
+func drain(ch MessageChan) []datasource.Message {
+	msgs := make([]datasource.Message, 0)
+	for msg := range ch {
+		msgs = append(msgs, msg)
+	}
+	return msgs
+}
+
+func TestTeeSlowConsumer(t *testing.T) {
+	// the slow consumer doesn't read until the fast one has everything
+	for _, policy := range []TeePolicy{TeeDrop, TeeError} {
+		tee := NewTee(2, 5, policy)
+		tee.Policies[0] = TeeBlock
+		tee.MessageInSet(teeMsgs(50))
+		errCh := make(chan error, 1)
+		go func() { errCh <- tee.Run(expr.NewContext()) }()
+
+		fast := drain(tee.Output(0))
+		err := <-errCh
+		slow := drain(tee.Output(1))
+		switch policy {
+		case TeeDrop:
+			assert.Tf(t, err == nil, "drop should not error %v", err)
+			assert.Tf(t, len(fast) == 50, "fast consumer gets all %d", len(fast))
+			assert.Tf(t, len(slow) == 5, "slow consumer gets its buffer %d", len(slow))
+			assert.Tf(t, tee.Dropped(1) == 45 && tee.Dropped(0) == 0, "dropped %d %d", tee.Dropped(0), tee.Dropped(1))
+		case TeeError:
+			assert.Tf(t, err != nil, "slow consumer should error")
+			assert.Tf(t, len(fast) == 6 && len(slow) == 5, "stops when full %d %d", len(fast), len(slow))
+		}
+	}
+
+	// block waits for the slowest consumer, both get everything
+	tee := NewTee(2, 5, TeeBlock)
+	tee.MessageInSet(teeMsgs(50))
+	go tee.Run(expr.NewContext())
+	slowCh := make(chan []datasource.Message)
+	go func() {
+		slowCh <- drain(tee.Output(1))
+	}()
+	fast := drain(tee.Output(0))
+	slow := <-slowCh
+	assert.Tf(t, len(fast) == 50 && len(slow) == 50, "both get all %d %d", len(fast), len(slow))
+	for i, msg := range slow {
+		v, _ := msg.(*datasource.ContextSimple).Get("n")
+		assert.Tf(t, v.Value() == int64(i), "in order %d %v", i, v)
+	}
+}
+
+func TestTeeClonesMessages(t *testing.T) {
+	tee := NewTee(2, 5, TeeBlock)
+	tee.MessageInSet(teeMsgs(1))
+	go tee.Run(expr.NewContext())
+	a := drain(tee.Output(0))[0].(*datasource.ContextSimple)
+	b := drain(tee.Output(1))[0].(*datasource.ContextSimple)
+	a.Data["n"] = value.NewIntValue(99)
+	v, _ := b.Get("n")
+	assert.Tf(t, v.Value() == int64(0), "mutating one output does not change the other %v", v)
+}
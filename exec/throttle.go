@@ -0,0 +1,83 @@
+package exec
+
+import (
+	"time"
+
+	"github.com/araddon/qlbridge/expr"
+)
+
+var (
+	// Ensure that we implement the Task Runner interface
+	_ TaskRunner = (*Throttle)(nil)
+)
+
+// Throttle passes messages through unchanged, paced to at most one per
+//  Delay, for rate limited sources (apis) and for testing backpressure
+//  and timing in downstream tasks.  The first message is sent at once,
+//  a consumer slower than the pacing does not build up a burst.  A
+//  signal stops it, even while waiting.
+type Throttle struct {
+	*TaskBase
+	Delay time.Duration
+}
+
+// Throttle to a rate of messages per second, a rate <= 0 is unthrottled
+func NewThrottle(perSecond float64) *Throttle {
+	var delay time.Duration
+	if perSecond > 0 {
+		delay = time.Duration(float64(time.Second) / perSecond)
+	}
+	return NewThrottleDelay(delay)
+}
+
+// Throttle with a delay between each message
+func NewThrottleDelay(delay time.Duration) *Throttle {
+	return &Throttle{
+		TaskBase: NewTaskBase("Throttle"),
+		Delay:    delay,
+	}
+}
+
+func (m *Throttle) Copy() *Throttle { return NewThrottleDelay(m.Delay) }
+
+func (m *Throttle) Run(context *expr.Context) error {
+	defer context.Recover()
+	defer close(m.msgOutCh)
+
+	outCh := m.MessageOut()
+	inCh := m.MessageIn()
+
+	// the time the next message may be sent
+	var next time.Time
+	for {
+		select {
+		case <-m.SigChan():
+			return nil
+		case msg, ok := <-inCh:
+			if !ok {
+				return nil
+			}
+			start := m.metricsStart()
+			if wait := next.Sub(time.Now()); wait > 0 {
+				timer := time.NewTimer(wait)
+				select {
+				case <-timer.C:
+				case <-m.SigChan():
+					timer.Stop()
+					return nil
+				}
+			}
+			select {
+			case outCh <- msg:
+			case <-m.SigChan():
+				return nil
+			}
+			m.recordHandled(start, true)
+			now := time.Now()
+			if next.Before(now) {
+				next = now
+			}
+			next = next.Add(m.Delay)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package exec
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+
+	"github.com/araddon/qlbridge/expr"
+)
+
+func TestThrottleRate(t *testing.T) {
+	// 200/sec, 11 messages are 10 intervals of 5ms
+	throttle := NewThrottle(200)
+	assert.Tf(t, throttle.Delay == 5*time.Millisecond, "%v", throttle.Delay)
+	throttle.MessageInSet(teeMsgs(11))
+	go throttle.Run(expr.NewContext())
+
+	start := time.Now()
+	msgs := drain(throttle.MessageOut())
+	elapsed := time.Since(start)
+	assert.Tf(t, len(msgs) == 11, "all messages pass through %d", len(msgs))
+	assert.Tf(t, elapsed >= 50*time.Millisecond, "too fast %v", elapsed)
+	assert.Tf(t, elapsed < 500*time.Millisecond, "too slow %v", elapsed)
+
+	// unthrottled
+	throttle = NewThrottle(0)
+	throttle.MessageInSet(teeMsgs(100))
+	go throttle.Run(expr.NewContext())
+	assert.T(t, len(drain(throttle.MessageOut())) == 100)
+}
+
+func TestThrottleCancel(t *testing.T) {
+	throttle := NewThrottleDelay(time.Hour)
+	throttle.MessageInSet(teeMsgs(3))
+	errCh := make(chan error, 1)
+	go func() { errCh <- throttle.Run(expr.NewContext()) }()
+
+	// the first is sent at once, then it waits on the second
+	<-throttle.MessageOut()
+	throttle.SigChan() <- true
+	select {
+	case err := <-errCh:
+		assert.Tf(t, err == nil, "no error %v", err)
+	case <-time.After(time.Second):
+		t.Fatalf("Throttle did not stop on signal while waiting")
+	}
+	_, open := <-throttle.MessageOut()
+	assert.T(t, !open)
+}
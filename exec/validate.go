@@ -0,0 +1,241 @@
+package exec
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/lex"
+	"github.com/araddon/qlbridge/value"
+)
+
+// Validate checks a select against the schema before it is run, so that
+//  problems surface up front instead of as per-row warnings during
+//  execution.  All problems are returned, not only the first:
+//
+//     - tables that do not exist
+//     - columns of the select, where, join, group by, having and order by
+//       expressions that are not columns of their tables, group by,
+//       having and order by may also use select column aliases
+//     - sum/avg of, or arithmetic on, a column the source types as
+//       non-numeric
+//
+//  Columns are only checked for sources implementing SchemaColumns, types
+//  only for sources implementing SchemaProvider with typed Fields.
+func Validate(sql *expr.SqlSelect, conf *datasource.RuntimeSchema) []error {
+	v := &validator{}
+	v.validate(sql, conf)
+	return v.errs
+}
+
+// ValidateTypes is only the type checks of Validate(), sum/avg of and
+//  arithmetic on columns typed as non-numeric.  Unlike missing columns
+//  these are certain to fail, so are checked when a select is planned.
+func ValidateTypes(sql *expr.SqlSelect, conf *datasource.RuntimeSchema) []error {
+	v := &validator{typesOnly: true}
+	v.validate(sql, conf)
+	return v.errs
+}
+
+type validateSource struct {
+	alias  string
+	cols   map[string]bool // nil if the columns are not known
+	fields map[string]*datasource.Field
+}
+
+type validator struct {
+	sources      []*validateSource
+	aliases      map[string]bool // select column aliases
+	allowAliases bool            // may identities be select column aliases?
+	typesOnly    bool            // only check types, see ValidateTypes()
+	errs         []error
+}
+
+func (m *validator) errorf(format string, args ...interface{}) {
+	m.errs = append(m.errs, fmt.Errorf(format, args...))
+}
+
+func (m *validator) validate(sql *expr.SqlSelect, conf *datasource.RuntimeSchema) {
+	for _, from := range sql.From {
+		if from.SubQuery != nil {
+			sub := &validator{typesOnly: m.typesOnly}
+			sub.validate(from.SubQuery, conf)
+			m.errs = append(m.errs, sub.errs...)
+			// columns of a sub-query are not known
+			m.sources = append(m.sources, &validateSource{alias: from.Alias})
+			continue
+		}
+		m.addSource(from, conf)
+	}
+
+	m.aliases = make(map[string]bool)
+	for _, col := range sql.Columns {
+		if col.Star || col.Expr == nil {
+			continue
+		}
+		m.walk(col.Expr)
+		m.aliases[strings.ToLower(col.As)] = true
+	}
+	for _, from := range sql.From {
+		if from.JoinExpr != nil {
+			m.walk(from.JoinExpr)
+		}
+	}
+	if sql.Where != nil {
+		if sql.Where.Expr != nil {
+			m.walk(sql.Where.Expr)
+		}
+		if sql.Where.Source != nil {
+			sub := &validator{typesOnly: m.typesOnly}
+			sub.validate(sql.Where.Source, conf)
+			m.errs = append(m.errs, sub.errs...)
+		}
+	}
+	for _, col := range sql.GroupBy {
+		if col.Expr != nil {
+			m.walkAliased(col.Expr)
+		}
+	}
+	if sql.Having != nil {
+		m.walkAliased(sql.Having)
+	}
+	for _, col := range sql.OrderBy {
+		if col.Expr != nil {
+			m.walkAliased(col.Expr)
+		}
+	}
+}
+
+func (m *validator) addSource(from *expr.SqlSource, conf *datasource.RuntimeSchema) {
+	src := &validateSource{alias: from.Alias}
+	if src.alias == "" {
+		src.alias = from.Name
+	}
+	m.sources = append(m.sources, src)
+
+	conn := conf.Conn(from.Name)
+	if conn == nil {
+		if !m.typesOnly {
+			m.errorf("Table %q not found", from.Name)
+		}
+		return
+	}
+	// only opened to read the schema
+	defer conn.Close()
+	if colSchema, ok := conn.(datasource.SchemaColumns); ok {
+		src.cols = make(map[string]bool)
+		for _, col := range colSchema.Columns() {
+			src.cols[strings.ToLower(col)] = true
+		}
+	}
+	if provider, ok := conn.(datasource.SchemaProvider); ok {
+		if tbl, err := provider.Table(strings.ToLower(from.Name)); err == nil && tbl != nil {
+			src.fields = make(map[string]*datasource.Field, len(tbl.Fields))
+			for _, fld := range tbl.Fields {
+				src.fields[strings.ToLower(fld.Name)] = fld
+			}
+		}
+	}
+}
+
+// walk an expression whose identities may also be select column aliases
+func (m *validator) walkAliased(node expr.Node) {
+	m.allowAliases = true
+	m.walk(node)
+	m.allowAliases = false
+}
+
+func (m *validator) walk(node expr.Node) {
+	switch n := node.(type) {
+	case *expr.IdentityNode:
+		m.checkIdentity(n)
+	case *expr.FuncNode:
+		switch strings.ToLower(n.Name) {
+		case "sum", "avg":
+			for _, arg := range n.Args {
+				m.checkNumeric(arg, n.Name)
+			}
+		}
+		for _, arg := range n.Args {
+			m.walk(arg)
+		}
+	case *expr.BinaryNode:
+		switch n.Operator.T {
+		case lex.TokenMultiply, lex.TokenMinus, lex.TokenDivide, lex.TokenModulus:
+			for _, arg := range n.Args {
+				m.checkNumeric(arg, n.Operator.V)
+			}
+		}
+		for _, arg := range n.Args {
+			m.walk(arg)
+		}
+	case *expr.TriNode:
+		for _, arg := range n.Args {
+			m.walk(arg)
+		}
+	case *expr.UnaryNode:
+		m.walk(n.Arg)
+	case *expr.MultiArgNode:
+		for _, arg := range n.Args {
+			m.walk(arg)
+		}
+	}
+}
+
+func (m *validator) checkIdentity(n *expr.IdentityNode) {
+	if m.typesOnly || n.Text == "*" || n.IsBooleanIdentity() {
+		return
+	}
+	if m.allowAliases && m.aliases[strings.ToLower(n.Text)] {
+		return
+	}
+	left, right, hasLeft := n.LeftRight()
+	if hasLeft {
+		for _, src := range m.sources {
+			if strings.EqualFold(src.alias, left) {
+				if src.cols != nil && !src.cols[strings.ToLower(right)] {
+					m.errorf("Column %q not found in %q", right, src.alias)
+				}
+				return
+			}
+		}
+	}
+	for _, src := range m.sources {
+		if src.cols == nil || src.cols[strings.ToLower(n.Text)] {
+			return
+		}
+	}
+	if len(m.sources) > 0 {
+		m.errorf("Column %q not found", n.Text)
+	}
+}
+
+// checkNumeric errors if the node is a column the source types as
+//  non-numeric
+func (m *validator) checkNumeric(node expr.Node, op string) {
+	ident, ok := node.(*expr.IdentityNode)
+	if !ok {
+		return
+	}
+	fld := m.field(ident)
+	if fld == nil || fld.Type == value.UnknownType || fld.Type.IsNumeric() {
+		return
+	}
+	m.errorf("Column %q is of type %s, cannot use in %s", ident.Text, fld.Type, op)
+}
+
+func (m *validator) field(n *expr.IdentityNode) *datasource.Field {
+	left, right, hasLeft := n.LeftRight()
+	for _, src := range m.sources {
+		if hasLeft && strings.EqualFold(src.alias, left) {
+			return src.fields[strings.ToLower(right)]
+		}
+		if !hasLeft {
+			if fld, ok := src.fields[strings.ToLower(n.Text)]; ok {
+				return fld
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package exec
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bmizerany/assert"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/value"
+)
+
+// typedSource is a source providing typed fields for its one table
+type typedSource struct {
+	tbl *datasource.Table
+}
+
+func (m *typedSource) Tables() []string                                 { return []string{m.tbl.Name} }
+func (m *typedSource) Open(table string) (datasource.SourceConn, error) { return m, nil }
+func (m *typedSource) Close() error                                     { return nil }
+func (m *typedSource) Columns() []string                                { return m.tbl.Columns() }
+func (m *typedSource) Table(table string) (*datasource.Table, error)    { return m.tbl, nil }
+
+func validateSql(t *testing.T, conf *datasource.RuntimeSchema, sql string) []error {
+	stmt, err := expr.ParseSql(sql)
+	assert.Tf(t, err == nil, "no parse error %v", err)
+	return Validate(stmt.(*expr.SqlSelect), conf)
+}
+
+func TestValidate(t *testing.T) {
+	conf := datasource.NewRuntimeSchema()
+	conf.SetConnInfo("mockcsv")
+
+	errs := validateSql(t, conf, `
+		SELECT user_id, email, referral_count * 2 AS rc
+		FROM users
+		WHERE email = "bob@email.com"
+		ORDER BY rc`)
+	assert.Tf(t, len(errs) == 0, "valid query %v", errs)
+
+	errs = validateSql(t, conf, `SELECT user_id, emial FROM users WHERE nme = "bob"`)
+	assert.Tf(t, len(errs) == 2, "all problems are returned %v", errs)
+	assert.Tf(t, strings.Contains(errs[0].Error(), `"emial"`), "%v", errs[0])
+	assert.Tf(t, strings.Contains(errs[1].Error(), `"nme"`), "%v", errs[1])
+
+	// qualified columns of a join
+	errs = validateSql(t, conf, `
+		SELECT u.user_id, o.item_id, o.total
+		FROM users AS u
+		INNER JOIN orders AS o ON u.user_id = o.user_id`)
+	assert.Tf(t, len(errs) == 1, "%v", errs)
+	assert.Tf(t, errs[0].Error() == `Column "total" not found in "o"`, "%v", errs[0])
+
+	errs = validateSql(t, conf, `SELECT user_id FROM not_a_table`)
+	assert.Tf(t, len(errs) == 1, "%v", errs)
+	assert.Tf(t, strings.Contains(errs[0].Error(), `"not_a_table"`), "%v", errs[0])
+}
+
+func TestValidateTypes(t *testing.T) {
+	tbl := datasource.NewTable("typed_orders", nil)
+	tbl.AddFieldType("user_id", value.StringType)
+	tbl.AddFieldType("price", value.NumberType)
+	tbl.SetColumns([]string{"user_id", "price"})
+	conf := &datasource.RuntimeSchema{Sources: datasource.NewDataSources(
+		map[string]datasource.DataSource{"typed": &typedSource{tbl}})}
+	conf.SetConnInfo("typed")
+
+	errs := validateSql(t, conf, `SELECT user_id, sum(price) AS total FROM typed_orders GROUP BY user_id`)
+	assert.Tf(t, len(errs) == 0, "valid query %v", errs)
+
+	errs = validateSql(t, conf, `SELECT sum(user_id) AS total, price * user_id FROM typed_orders`)
+	assert.Tf(t, len(errs) == 2, "%v", errs)
+	assert.Tf(t, errs[0].Error() == `Column "user_id" is of type string, cannot use in sum`, "%v", errs[0])
+}
+
+// closeCountSource opens a new connection each time, counting the closes
+type closeCountSource struct {
+	*typedSource
+	opened, closed int
+}
+
+type closeCountConn struct {
+	*typedSource
+	src *closeCountSource
+}
+
+func (m *closeCountSource) Open(table string) (datasource.SourceConn, error) {
+	m.opened++
+	return &closeCountConn{m.typedSource, m}, nil
+}
+func (m *closeCountConn) Close() error {
+	m.src.closed++
+	return nil
+}
+
+func TestValidateClosesConn(t *testing.T) {
+	tbl := datasource.NewTable("users", nil)
+	tbl.AddFieldType("user_id", value.StringType)
+	tbl.SetColumns([]string{"user_id"})
+	src := &closeCountSource{typedSource: &typedSource{tbl}}
+	conf := &datasource.RuntimeSchema{Sources: datasource.NewDataSources(
+		map[string]datasource.DataSource{"users": src})}
+
+	errs := validateSql(t, conf, `SELECT user_id FROM users`)
+	assert.Tf(t, len(errs) == 0, "%v", errs)
+	assert.Tf(t, src.opened == 1 && src.closed == 1, "opened %d closed %d", src.opened, src.closed)
+
+	// expanding u.* reads the columns, the source is not a Scanner so the
+	//  build fails, neither connection may leak
+	src.opened, src.closed = 0, 0
+	_, err := BuildSqlJob(conf, "users", `SELECT u.* FROM users AS u`)
+	assert.T(t, err != nil)
+	assert.Tf(t, src.opened >= 2 && src.opened == src.closed, "opened %d closed %d", src.opened, src.closed)
+}
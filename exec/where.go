@@ -58,7 +58,22 @@ func NewWhereFilter(where expr.Node, stmt *expr.SqlSelect) *Where {
 	return s
 }
 
-func whereFilter(where expr.Node, task TaskRunner, cols map[string]*expr.Column) MessageHandler {
+// Having-Filter, evaluates the HAVING expression against the aggregated
+//  group rows, so aggregate aliases such as `count(*) AS ct` may be used
+//
+//     SELECT user_id, count(*) AS ct FROM orders GROUP BY user_id HAVING ct > 1
+//
+func NewHaving(having expr.Node, stmt *expr.SqlSelect) *Where {
+	s := &Where{
+		TaskBase: NewTaskBase("Having"),
+		where:    having,
+	}
+	cols := stmt.AliasedColumns()
+	s.Handler = whereFilter(having, s, cols)
+	return s
+}
+
+func whereFilter(where expr.Node, task *Where, cols map[string]*expr.Column) MessageHandler {
 	out := task.MessageOut()
 	evaluator := vm.Evaluator(where)
 	return func(ctx *expr.Context, msg datasource.Message) bool {
@@ -71,14 +86,14 @@ func whereFilter(where expr.Node, task TaskRunner, cols map[string]*expr.Column)
 			//u.Debugf("WHERE:  T:%T  vals:%#v", msg, mt.Vals)
 			//u.Debugf("cols:  %#v", cols)
 			msgReader := datasource.NewValueContextWrapper(mt, cols)
-			whereValue, ok = evaluator(msgReader)
+			whereValue, ok = evaluator(task.evalReader(msgReader))
 		case *datasource.SqlDriverMessageMap:
-			whereValue, ok = evaluator(mt)
+			whereValue, ok = evaluator(task.evalReader(mt))
 			//u.Debugf("WHERE: result:%v T:%T  \n\trow:%#v \n\tvals:%#v", whereValue, msg, mt.Row(), mt.Values())
 			//u.Debugf("cols:  %#v", cols)
 		default:
-			if msgReader, ok := msg.(expr.ContextReader); ok {
-				whereValue, ok = evaluator(msgReader)
+			if msgReader, isReader := datasource.MessageReader(msg); isReader {
+				whereValue, ok = evaluator(task.evalReader(msgReader))
 			} else {
 				u.Errorf("could not convert to message reader: %T", msg)
 			}
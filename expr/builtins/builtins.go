@@ -48,12 +48,29 @@ func LoadAllBuiltins() {
 	expr.FuncAdd("uuid", UuidGenerate)
 	expr.FuncAdd("contains", ContainsFunc)
 	expr.FuncAdd("tolower", Lower)
+	expr.FuncAdd("lower", Lower)
+	expr.FuncAdd("toupper", Upper)
+	expr.FuncAdd("upper", Upper)
+	expr.FuncAdd("trim", TrimFunc)
+	expr.FuncAdd("length", LengthFunc)
+	expr.FuncAdd("char_length", CharLengthFunc)
+	expr.FuncAdd("character_length", CharLengthFunc)
+	expr.FuncAdd("substr", Substr)
+	expr.FuncAdd("substring", Substr)
+	expr.FuncAdd("concat", ConcatFunc)
+	expr.FuncAdd("editdistance", EditDistanceFunc)
+	expr.FuncAdd("soundex", SoundexFunc)
 	expr.FuncAdd("toint", ToInt)
+	expr.FuncAdd("cast", CastFunc)
 	expr.FuncAdd("tonumber", ToNumber)
 	expr.FuncAdd("split", SplitFunc)
 	expr.FuncAdd("replace", Replace)
 	expr.FuncAdd("join", JoinFunc)
+	expr.FuncAdd("flatten", FlattenFunc)
 	expr.FuncAdd("oneof", OneOfFunc)
+	expr.FuncAdd("coalesce", CoalesceFunc)
+	expr.FuncAdd("nullif", NullIfFunc)
+	expr.FuncAdd("ifnull", IfNullFunc)
 	expr.FuncAdd("match", Match)
 	expr.FuncAdd("any", AnyFunc)
 	expr.FuncAdd("all", AllFunc)
@@ -61,12 +78,16 @@ func LoadAllBuiltins() {
 	expr.FuncAdd("emaildomain", EmailDomainFunc)
 	expr.FuncAdd("emailname", EmailNameFunc)
 	expr.FuncAdd("host", HostFunc)
+	expr.FuncAdd("insubnet", InSubnetFunc)
+	expr.FuncAdd("hex", HexFunc)
+	expr.FuncAdd("unhex", UnhexFunc)
 	expr.FuncAdd("path", UrlPath)
 	expr.FuncAdd("qs", Qs)
 	expr.FuncAdd("urlmain", UrlMain)
 	expr.FuncAdd("urlminusqs", UrlMinusQs)
 	expr.FuncAdd("urldecode", UrlDecode)
 	expr.FuncAdd("extract", TimeExtractFunc)
+	expr.FuncAdd("date_trunc", DateTruncFunc)
 }
 
 // Count:   count occurences of value, ignores the value and ensures it is non null
@@ -358,6 +379,254 @@ func Lower(ctx expr.EvalContext, item value.Value) (value.StringValue, bool) {
 	return value.NewStringValue(strings.ToLower(val)), true
 }
 
+// String upper function
+//   must be able to convert to string
+//
+func Upper(ctx expr.EvalContext, item value.Value) (value.StringValue, bool) {
+	val, ok := value.ToString(item.Rv())
+	if !ok {
+		return value.EmptyStringValue, false
+	}
+	return value.NewStringValue(strings.ToUpper(val)), true
+}
+
+// Length in bytes of a string, multibyte characters count each byte, see
+//   char_length()
+//
+//     length("apple")   =>  5
+//     length("école")   =>  6
+//
+func LengthFunc(ctx expr.EvalContext, item value.Value) (value.IntValue, bool) {
+	val, ok := value.ToString(item.Rv())
+	if !ok {
+		return value.NewIntValue(0), false
+	}
+	return value.NewIntValue(int64(value.NewStringValue(val).Len())), true
+}
+
+// Char Length in characters (runes) of a string
+//
+//     char_length("école")   =>  5
+//
+func CharLengthFunc(ctx expr.EvalContext, item value.Value) (value.IntValue, bool) {
+	val, ok := value.ToString(item.Rv())
+	if !ok {
+		return value.NewIntValue(0), false
+	}
+	return value.NewIntValue(int64(value.NewStringValue(val).RuneLen())), true
+}
+
+// Trim leading and trailing whitespace, or the characters of the optional
+//  cutset
+//
+//     trim("  apple ")      =>  "apple"
+//     trim("--apple-", "-") =>  "apple"
+//
+func TrimFunc(ctx expr.EvalContext, item value.Value, cutset ...value.Value) (value.StringValue, bool) {
+	val, ok := value.ToString(item.Rv())
+	if !ok {
+		return value.EmptyStringValue, false
+	}
+	if len(cutset) == 0 {
+		return value.NewStringValue(strings.TrimSpace(val)), true
+	}
+	cut, ok := value.ToString(cutset[0].Rv())
+	if !ok {
+		return value.EmptyStringValue, false
+	}
+	return value.NewStringValue(strings.Trim(val, cut)), true
+}
+
+// Substring of a string, start is 1 based (sql) and in characters not bytes,
+//  a negative start counts from the end.  A start or length out of range
+//  is truncated to the string, so may be ""
+//
+//     substr("apple", 2)        =>  "pple"
+//     substr("apple", 2, 3)     =>  "ppl"
+//     substr("crème", -3, 2)    =>  "èm"
+//     substr("apple", 10)       =>  ""
+//
+func Substr(ctx expr.EvalContext, item value.Value, args ...value.Value) (value.StringValue, bool) {
+	val, ok := value.ToString(item.Rv())
+	if !ok || len(args) == 0 || len(args) > 2 {
+		return value.EmptyStringValue, false
+	}
+	start, ok := value.ToInt64(args[0].Rv())
+	if !ok {
+		return value.EmptyStringValue, false
+	}
+	runes := []rune(val)
+	ct := int64(len(runes))
+	switch {
+	case start > 0:
+		start--
+	case start < 0:
+		start += ct
+	default:
+		// sql substr(x, 0) is empty
+		start = ct
+	}
+	if start < 0 {
+		start = 0
+	}
+	if start > ct {
+		start = ct
+	}
+	end := ct
+	if len(args) == 2 {
+		length, ok := value.ToInt64(args[1].Rv())
+		if !ok {
+			return value.EmptyStringValue, false
+		}
+		if length < 0 {
+			length = 0
+		}
+		if length < end-start {
+			end = start + length
+		}
+	}
+	return value.NewStringValue(string(runes[start:end])), true
+}
+
+// Concat strings together, any NULL arg makes the result NULL, see join()
+//  to skip empty values or use a separator
+//
+//     concat("apple", "-", 5)     =>  "apple-5"
+//     concat("apple", not_field)  =>  NULL
+//
+func ConcatFunc(ctx expr.EvalContext, items ...value.Value) (value.Value, bool) {
+	if len(items) == 0 {
+		return value.EmptyStringValue, false
+	}
+	args := make([]string, len(items))
+	for i, item := range items {
+		if item == nil || item.Type() == value.NilType {
+			return value.NilValueVal, true
+		}
+		if item.Err() {
+			return value.EmptyStringValue, false
+		}
+		args[i] = item.ToString()
+	}
+	return value.NewStringValue(strings.Join(args, "")), true
+}
+
+// editdistance:  the Levenshtein edit distance between two strings, ie the
+//  number of single character inserts, deletes, substitutions to change one
+//  into the other
+//
+//     editdistance("jon", "john")     =>  1, true
+//     editdistance(name, "jon") <= 2  =>  true
+//
+func EditDistanceFunc(ctx expr.EvalContext, lv, rv value.Value) (value.IntValue, bool) {
+	left, leftOk := value.ToString(lv.Rv())
+	right, rightOk := value.ToString(rv.Rv())
+	if !leftOk || !rightOk {
+		return value.NewIntValue(0), false
+	}
+	return value.NewIntValue(int64(EditDistance(left, right))), true
+}
+
+// EditDistance calculates the Levenshtein distance between a, b
+//  comparing runes not bytes
+func EditDistance(a, b string) int {
+	ar, br := []rune(a), []rune(b)
+	if len(ar) == 0 {
+		return len(br)
+	}
+	if len(br) == 0 {
+		return len(ar)
+	}
+	// only keep previous, current row of the distance matrix
+	prev := make([]int, len(br)+1)
+	cur := make([]int, len(br)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ar); i++ {
+		cur[0] = i
+		for j := 1; j <= len(br); j++ {
+			cost := 1
+			if ar[i-1] == br[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(prev[j]+1, minInt(cur[j-1]+1, prev[j-1]+cost))
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(br)]
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// soundex:  the american soundex phonetic code of a string
+//
+//     soundex("Robert")    =>  "R163", true
+//     soundex("Rupert")    =>  "R163", true
+//
+func SoundexFunc(ctx expr.EvalContext, item value.Value) (value.StringValue, bool) {
+	val, ok := value.ToString(item.Rv())
+	if !ok || val == "" {
+		return value.EmptyStringValue, false
+	}
+	code := Soundex(val)
+	if code == "" {
+		return value.EmptyStringValue, false
+	}
+	return value.NewStringValue(code), true
+}
+
+var soundexCodes = map[rune]byte{
+	'B': '1', 'F': '1', 'P': '1', 'V': '1',
+	'C': '2', 'G': '2', 'J': '2', 'K': '2', 'Q': '2', 'S': '2', 'X': '2', 'Z': '2',
+	'D': '3', 'T': '3',
+	'L': '4',
+	'M': '5', 'N': '5',
+	'R': '6',
+}
+
+// Soundex calculates the american soundex code of s, non ascii letters
+//  are ignored.  Returns empty string if there are no letters.
+func Soundex(s string) string {
+	code := make([]byte, 0, 4)
+	var last byte
+	for _, r := range strings.ToUpper(s) {
+		if r < 'A' || r > 'Z' {
+			continue
+		}
+		c, hasCode := soundexCodes[r]
+		if len(code) == 0 {
+			code = append(code, byte(r))
+			last = c
+			continue
+		}
+		switch {
+		case hasCode && c != last:
+			code = append(code, c)
+			last = c
+		case r == 'H' || r == 'W':
+			// h, w do not separate consonants with same code
+		default:
+			last = c
+		}
+		if len(code) == 4 {
+			break
+		}
+	}
+	if len(code) == 0 {
+		return ""
+	}
+	for len(code) < 4 {
+		code = append(code, '0')
+	}
+	return string(code)
+}
+
 // choose OneOf these fields, first non-null
 func OneOfFunc(ctx expr.EvalContext, vals ...value.Value) (value.Value, bool) {
 	for _, v := range vals {
@@ -370,6 +639,91 @@ func OneOfFunc(ctx expr.EvalContext, vals ...value.Value) (value.Value, bool) {
 	return value.NilValueVal, true
 }
 
+// InSubnet:  Answers True/False if the ip address is in the CIDR subnet,
+//   IPv4 or IPv6, a malformed ip or subnet is not ok
+//
+//     insubnet("10.1.2.3", "10.0.0.0/8")     => true, true
+//     insubnet(ip, "2001:db8::/32")
+//     insubnet("10.1.2", "10.0.0.0/8")       => false, false
+//
+func InSubnetFunc(ctx expr.EvalContext, ip, cidr value.Value) (value.BoolValue, bool) {
+	if ip.Nil() || cidr.Nil() {
+		return value.BoolValueFalse, false
+	}
+	in, err := value.IPInCIDR(ip.ToString(), cidr.ToString())
+	if err != nil {
+		return value.BoolValueFalse, false
+	}
+	return value.NewBoolValue(in), true
+}
+
+// Hex:  the lower case hex encoding of bytes, or of the bytes of a string
+//
+//     hex(unhex("DEADBEEF"))   => "deadbeef", true
+//     hex("abc")               => "616263", true
+//
+func HexFunc(ctx expr.EvalContext, item value.Value) (value.StringValue, bool) {
+	if value.IsNull(item) {
+		return value.EmptyStringValue, false
+	}
+	return value.Hex(item), true
+}
+
+// Unhex:  the bytes of a hex encoded string, an optional 0x prefix is
+//   allowed, odd length or invalid hex is not ok
+//
+//     unhex("deadbeef")     => []byte{0xde, 0xad, 0xbe, 0xef}, true
+//     unhex("0xdead")       => []byte{0xde, 0xad}, true
+//     unhex("xyz1")         => nil, false
+//
+func UnhexFunc(ctx expr.EvalContext, item value.Value) (value.ByteSliceValue, bool) {
+	if value.IsNull(item) {
+		return value.NewByteSliceValue(nil), false
+	}
+	b, err := value.Unhex(item.ToString())
+	if err != nil {
+		return b, false
+	}
+	return b, true
+}
+
+// Coalesce:  first argument that is not NULL, sql semantics so unlike oneof()
+//   an empty string that is present is returned, only missing fields and
+//   nil values are skipped
+//
+//     coalesce(nickname, name)     => nickname if not null, else name
+//     coalesce(not_field, "anon")  => "anon"
+//
+func CoalesceFunc(ctx expr.EvalContext, vals ...value.Value) (value.Value, bool) {
+	for _, v := range vals {
+		if v == nil || v.Err() || v.Type() == value.NilType {
+			continue
+		}
+		return v, true
+	}
+	return value.NilValueVal, true
+}
+
+// NullIf:  NULL if the two arguments are equal, else the first, an empty
+//   string is not NULL
+//
+//     nullif(score, 0)     => NULL if score is 0, else score
+//     nullif("", "")       => NULL
+//
+func NullIfFunc(ctx expr.EvalContext, a, b value.Value) (value.Value, bool) {
+	return value.NullIf(a, b), true
+}
+
+// IfNull:  the second argument if the first is NULL, else the first, an
+//   empty string is not NULL
+//
+//     ifnull(nickname, "anon")   => "anon" if nickname is missing
+//     ifnull("", "anon")         => ""
+//
+func IfNullFunc(ctx expr.EvalContext, a, b value.Value) (value.Value, bool) {
+	return value.IfNull(a, b), true
+}
+
 // Any:  Answers True/False if any of the arguments evaluate to truish (javascripty)
 //       type definintion of true
 //
@@ -451,29 +805,30 @@ func SplitFunc(ctx expr.EvalContext, input value.Value, splitByV value.StringVal
 	return value.NewStringsValue(vals), true
 }
 
-// Replace a string(s), accepts any number of parameters to replace
-//    replaces with ""
+// Replace all occurrences of a string, with the optional replacement
+//    string, or with "" if there is none
 //
-//     replace("/blog/index.html", "/blog","M2")  =>  /index.html
+//     replace("/blog/index.html", "/blog")        =>  /index.html
+//     replace("/blog/index.html", "/blog", "/b")  =>  /b/index.html
 //     replace(item, "M")
 //
 func Replace(ctx expr.EvalContext, vals ...value.Value) (value.StringValue, bool) {
-	if len(vals) < 2 {
+	if len(vals) < 2 || len(vals) > 3 {
 		return value.EmptyStringValue, false
 	}
-	val1 := vals[0].ToString()
-	for _, v := range vals[1:] {
-		if v.Err() || v.Nil() {
-			return value.EmptyStringValue, false
-		} else if value.IsNilIsh(v.Rv()) {
+	from := vals[1]
+	if from.Err() || from.Nil() || value.IsNilIsh(from.Rv()) {
+		return value.EmptyStringValue, false
+	}
+	to := ""
+	if len(vals) == 3 {
+		// an empty replacement is valid, NULL is not
+		if vals[2].Err() || vals[2].Type() == value.NilType {
 			return value.EmptyStringValue, false
 		}
-		v2 := v.ToString()
-		if len(v2) > 0 {
-			val1 = strings.Replace(val1, v2, "", -1)
-		}
+		to = vals[2].ToString()
 	}
-	return value.NewStringValue(val1), true
+	return value.NewStringValue(strings.Replace(vals[0].ToString(), from.ToString(), to, -1)), true
 }
 
 // Join items together (string concatenation)
@@ -519,6 +874,37 @@ func JoinFunc(ctx expr.EvalContext, items ...value.Value) (value.StringValue, bo
 	return value.NewStringValue(strings.Join(args, sep)), true
 }
 
+// flatten:  flatten an array of arrays by one level, elements that are
+//   not arrays are kept as is, see value.SliceValue.Flatten()
+//
+//     flatten(nested)     => [1,2,3,4], true    // nested = [[1,2],[3,4]]
+//     flatten("apple")    => not ok, not an array
+//
+func FlattenFunc(ctx expr.EvalContext, item value.Value) (value.SliceValue, bool) {
+	sv, ok := item.(value.SliceValue)
+	if !ok {
+		return value.NewSliceValues(nil), false
+	}
+	return sv.Flatten(), true
+}
+
+// Cast:  sql CAST(x AS type), strict conversion (see value.Cast), unlike
+//   toint() a value that does not convert is an error not a best attempt
+//
+//     cast("5" AS int)        => 5
+//     cast(5.75 AS INTEGER)   => 5
+//     cast("hello" AS int)    => error
+//     cast(score, "varchar")  => "22"
+//
+func CastFunc(ctx expr.EvalContext, item, typeName value.Value) (value.Value, bool) {
+	vt, ok := value.CastType(typeName.ToString())
+	if !ok {
+		return value.NewErrorValuef("unknown cast type %q", typeName.ToString()), false
+	}
+	v := value.Cast(item, vt)
+	return v, !v.Err()
+}
+
 // Convert to Integer:   Best attempt at converting to integer
 //
 //   toint("5") => 5
@@ -773,6 +1159,25 @@ func ToDate(ctx expr.EvalContext, items ...value.Value) (value.TimeValue, bool)
 	return value.TimeZeroValue, false
 }
 
+// date_trunc:  truncate a time to the start of its year, month, day, hour,
+//   minute or second, in the zone of the time, ie for time bucketing
+//
+//     date_trunc("hour", "2014-04-07T16:58:55Z")   => 2014-04-07 16:00:00, true
+//     date_trunc("day", todate(field))             => midnight of that day, true
+//     date_trunc("fortnight", field)               => not ok, unknown unit
+//
+func DateTruncFunc(ctx expr.EvalContext, unit, item value.Value) (value.TimeValue, bool) {
+	unitStr, ok := value.ToString(unit.Rv())
+	if !ok {
+		return value.TimeZeroValue, false
+	}
+	t, ok := value.ToTime(item)
+	if !ok {
+		return value.TimeZeroValue, false
+	}
+	return value.NewTimeValue(t).Trunc(unitStr)
+}
+
 // email a string, parses email
 //
 //     email("Bob <bob@bob.com>")  =>  bob@bob.com, true
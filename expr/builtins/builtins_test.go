@@ -101,19 +101,91 @@ var builtinTests = []testBuiltins{
 	{`contains(url,"membership/all.html")`, value.BoolValueTrue},
 
 	{`tolower("Apple")`, value.NewStringValue("apple")},
+	{`lower("ÉCOLE")`, value.NewStringValue("école")},
+	{`upper("crème")`, value.NewStringValue("CRÈME")},
+	{`toupper(event)`, value.NewStringValue("HELLO")},
+
+	{`trim("  apple ")`, value.NewStringValue("apple")},
+	{`trim("--apple-", "-")`, value.NewStringValue("apple")},
+	{`trim(" ñ ")`, value.NewStringValue("ñ")},
+	{`length("apple")`, value.NewIntValue(5)},
+	{`length("école")`, value.NewIntValue(6)},
+	{`char_length("école")`, value.NewIntValue(5)},
+	{`character_length("日本語")`, value.NewIntValue(3)},
+	{`length("日本語")`, value.NewIntValue(9)},
+
+	{`substr("apple", 2)`, value.NewStringValue("pple")},
+	{`substr("apple", 2, 3)`, value.NewStringValue("ppl")},
+	{`substring(event, 1, 4)`, value.NewStringValue("hell")},
+	{`substr("crème", -3, 2)`, value.NewStringValue("èm")},
+	{`substr("日本語", 2, 1)`, value.NewStringValue("本")},
+	{`substr("apple", 10)`, value.NewStringValue("")},
+	{`substr("apple", 4, 10)`, value.NewStringValue("le")},
+	{`substr("apple", 0)`, value.NewStringValue("")},
+	{`substr("apple", -10, 2)`, value.NewStringValue("ap")},
+	{`substr("apple", 2, -1)`, value.NewStringValue("")},
+	{`substr("apple")`, value.ErrValue},
+
+	{`concat("apple", "-", 5)`, value.NewStringValue("apple-5")},
+	{`concat(event, "ñ")`, value.NewStringValue("helloñ")},
+	{`concat("apple", not_a_field)`, value.NilValueVal},
+	{`concat(nullif(event, "hello"), "-", 5)`, value.NilValueVal},
+
+	{`editdistance("jon", "john")`, value.NewIntValue(1)},
+	{`editdistance(tag_name, "bob")`, value.NewIntValue(0)},
+	{`le(editdistance(tag_name, "rob"), 2)`, value.BoolValueTrue},
+	{`soundex("Robert")`, value.NewStringValue("R163")},
+	{`soundex(tag_name)`, value.NewStringValue("B100")},
 
 	{`join("apple", event, "oranges", "--")`, value.NewStringValue("apple--hello--oranges")},
 	{`join(["apple","peach"], ",")`, value.NewStringValue("apple,peach")},
 	{`join("apple","","peach",",")`, value.NewStringValue("apple,peach")},
 
 	{`split("apples,oranges",",")`, value.NewStringsValue([]string{"apples", "oranges"})},
+	{`split("añb,ñc","ñ")`, value.NewStringsValue([]string{"a", "b,", "c"})},
 
 	{`replace("M20:30","M")`, value.NewStringValue("20:30")},
 	{`replace("/search/for+stuff","/search/")`, value.NewStringValue("for+stuff")},
+	{`replace("crème brûlée","è")`, value.NewStringValue("crme brûlée")},
+	{`replace("crème brûlée","è","e")`, value.NewStringValue("creme brûlée")},
+	{`replace("/blog/index.html","/blog","/b")`, value.NewStringValue("/b/index.html")},
+	{`replace("/blog/index.html","/blog","")`, value.NewStringValue("/index.html")},
+	{`replace("M20:30","M","",":")`, value.ErrValue},
 
 	{`oneof("apples","oranges")`, value.NewStringValue("apples")},
 	{`oneof(notincontext,event)`, value.NewStringValue("hello")},
 
+	{`coalesce(notincontext,event)`, value.NewStringValue("hello")},
+	{`coalesce(event,"anon")`, value.NewStringValue("hello")},
+	{`coalesce(notincontext,"")`, value.NewStringValue("")},
+	{`coalesce("","anon")`, value.NewStringValue("")},
+	{`coalesce(notincontext,toint(score_amount))`, value.NewIntValue(22)},
+
+	{`nullif(event, "hello")`, value.NilValueVal},
+	{`nullif(event, "world")`, value.NewStringValue("hello")},
+	{`nullif(5, 5)`, value.NilValueVal},
+	{`nullif("", "")`, value.NilValueVal},
+	{`nullif("", notincontext)`, value.NewStringValue("")},
+	{`ifnull(notincontext, "anon")`, value.NewStringValue("anon")},
+	{`ifnull(event, "anon")`, value.NewStringValue("hello")},
+	{`ifnull("", "anon")`, value.NewStringValue("")},
+
+	{`insubnet("10.1.2.3", "10.0.0.0/8")`, value.BoolValueTrue},
+	{`insubnet("11.1.2.3", "10.0.0.0/8")`, value.BoolValueFalse},
+	{`insubnet("2001:db8::1", "2001:db8::/32")`, value.BoolValueTrue},
+	{`insubnet("10.1.2", "10.0.0.0/8")`, value.ErrValue},
+	{`insubnet("not_an_ip", "10.0.0.0/8")`, value.ErrValue},
+	{`insubnet("10.1.2.3", "10.0.0.0")`, value.ErrValue},
+	{`insubnet("10.1.2.3", "10.0.0.0/33")`, value.ErrValue},
+	{`insubnet("10.1.2.3", "not_a_cidr")`, value.ErrValue},
+	{`insubnet(notincontext, "10.0.0.0/8")`, value.ErrValue},
+
+	{`hex(unhex("DEADBEEF"))`, value.NewStringValue("deadbeef")},
+	{`hex(unhex("0x00ff"))`, value.NewStringValue("00ff")},
+	{`hex("abc")`, value.NewStringValue("616263")},
+	{`unhex("abc")`, value.ErrValue},
+	{`unhex("zz")`, value.ErrValue},
+
 	{`any(5)`, value.BoolValueTrue},
 	// TODO: {`any(0)`, value.BoolValueFalse},
 	{`any("value")`, value.BoolValueTrue},
@@ -180,6 +252,15 @@ var builtinTests = []testBuiltins{
 
 	{`toint("5")`, value.NewIntValue(5)},
 	{`toint("hello")`, value.ErrValue},
+
+	{`cast("5" AS int)`, value.NewIntValue(5)},
+	{`cast(score_amount AS INTEGER)`, value.NewIntValue(22)},
+	{`cast(5.75 AS int)`, value.NewIntValue(5)},
+	{`cast(score_amount AS varchar)`, value.NewStringValue("22")},
+	{`cast("true" AS boolean)`, value.BoolValueTrue},
+	{`cast(score_amount, "double")`, value.NewNumberValue(22)},
+	{`cast("hello" AS int)`, value.ErrValue},
+	{`cast("hello" AS blob)`, value.ErrValue},
 	{`toint("$ 5.22")`, value.NewIntValue(5)},
 	{`toint("5.56")`, value.NewIntValue(5)},
 	{`toint("$5.56")`, value.NewIntValue(5)},
@@ -220,6 +301,9 @@ var builtinTests = []testBuiltins{
 	{`totimestamp("Apr 7, 2014 4:58:55 PM")`, value.NewIntValue(1396889935)},
 
 	{`todate("Apr 7, 2014 4:58:55 PM")`, value.NewTimeValue(ts)},
+	{`date_trunc("day", todate("Apr 7, 2014 4:58:55 PM"))`, value.NewTimeValue(ts2)},
+	{`date_trunc("hour", todate("Apr 7, 2014 4:58:55 PM"))`, value.NewTimeValue(time.Date(2014, 4, 7, 16, 0, 0, 0, time.UTC))},
+	{`date_trunc("fortnight", todate("Apr 7, 2014 4:58:55 PM"))`, value.ErrValue},
 
 	{`exists(event)`, value.BoolValueTrue},
 	{`exists(price)`, value.BoolValueTrue},
@@ -234,6 +318,21 @@ var builtinTests = []testBuiltins{
 	{`sqrt(4)`, value.NewNumberValue(2)},
 	{`sqrt(25)`, value.NewNumberValue(5)},
 	{`sqrt(NotAField)`, value.ErrValue},
+	{`sqrt(-4)`, value.ErrValue},
+	{`pow(-8, 0.5)`, value.ErrValue},
+
+	{`abs(-5.5)`, value.NewNumberValue(5.5)},
+	{`abs(5)`, value.NewNumberValue(5)},
+	{`abs(NotAField)`, value.ErrValue},
+	{`ceil(5.2)`, value.NewNumberValue(6)},
+	{`ceil(-5.2)`, value.NewNumberValue(-5)},
+	{`ceil(NotAField)`, value.ErrValue},
+	{`floor(5.8)`, value.NewNumberValue(5)},
+	{`floor(-5.2)`, value.NewNumberValue(-6)},
+	{`floor(NotAField)`, value.ErrValue},
+	{`round(5.5)`, value.NewNumberValue(6)},
+	{`round(2.345, 2)`, value.NewNumberValue(2.35)},
+	{`round(NotAField)`, value.ErrValue},
 
 	{`count(4)`, value.NewIntValue(1)},
 	{`count(not_a_field)`, value.ErrValue},
@@ -310,3 +409,73 @@ func TestBuiltins(t *testing.T) {
 
 	}
 }
+
+var editDistanceTests = []struct {
+	a, b string
+	dist int
+}{
+	{"", "", 0},
+	{"", "abc", 3},
+	{"abc", "", 3},
+	{"jon", "jon", 0},
+	{"jon", "john", 1},
+	{"kitten", "sitting", 3},
+	{"flaw", "lawn", 2},
+	{"saturday", "sunday", 3},
+	{"héllo", "hello", 1},
+}
+
+func TestEditDistance(t *testing.T) {
+	for _, et := range editDistanceTests {
+		dist := EditDistance(et.a, et.b)
+		assert.Tf(t, dist == et.dist, "expected editdistance(%q,%q)=%d but got %d", et.a, et.b, et.dist, dist)
+	}
+}
+
+var soundexTests = []struct {
+	in, code string
+}{
+	{"Robert", "R163"},
+	{"Rupert", "R163"},
+	{"Rubin", "R150"},
+	{"Ashcraft", "A261"},
+	{"Tymczak", "T522"},
+	{"Pfister", "P236"},
+	{"Honeyman", "H555"},
+	{"a", "A000"},
+	{"123", ""},
+}
+
+func TestSoundex(t *testing.T) {
+	for _, st := range soundexTests {
+		code := Soundex(st.in)
+		assert.Tf(t, code == st.code, "expected soundex(%q)=%q but got %q", st.in, st.code, code)
+	}
+}
+
+func TestFlatten(t *testing.T) {
+	ints := func(vals ...int64) value.Value {
+		sv := make([]value.Value, len(vals))
+		for i, v := range vals {
+			sv[i] = value.NewIntValue(v)
+		}
+		return value.NewSliceValues(sv)
+	}
+	ctx := datasource.NewContextSimpleData(map[string]value.Value{
+		"nested": value.NewSliceValues([]value.Value{ints(1, 2), ints(3, 4)}),
+		"mixed":  value.NewSliceValues([]value.Value{value.NewIntValue(1), ints(2, 3)}),
+	})
+	for qlText, expect := range map[string]string{
+		`flatten(nested)`: "1,2,3,4",
+		`flatten(mixed)`:  "1,2,3",
+	} {
+		exprVm, err := vm.NewVm(qlText)
+		assert.Tf(t, err == nil, "parse err: %v on %s", err, qlText)
+		v, ok := vm.Eval(ctx, exprVm.Tree.Root)
+		assert.Tf(t, ok, "%s should eval", qlText)
+		assert.Tf(t, v.ToString() == expect, "%s expected %s got %v", qlText, expect, v)
+	}
+	exprVm, _ := vm.NewVm(`flatten(event)`)
+	_, ok := vm.Eval(readContext, exprVm.Tree.Root)
+	assert.T(t, !ok)
+}
@@ -21,6 +21,10 @@ type testBuiltins struct {
 
 var builtinTests = []testBuiltins{
 	{`count(nonfield)`},
+	{`sum(price)`},
+	{`avg(price, 2)`},
+	{`min(price)`},
+	{`max(price)`},
 }
 
 func TestBuiltins(t *testing.T) {
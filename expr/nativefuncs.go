@@ -14,8 +14,18 @@ const yymmTimeLayout = "0601"
 func init() {
 	// agregate ops
 	FuncAdd("count", CountFunc)
+	FuncAdd("sum", SumFunc)
+	FuncAdd("avg", AvgFunc)
+	FuncAdd("min", MinFunc)
+	FuncAdd("max", MaxFunc)
+	FuncAdd("first", FirstFunc)
+	FuncAdd("last", LastFunc)
 
 	// math
+	FuncAdd("abs", AbsFunc)
+	FuncAdd("ceil", CeilFunc)
+	FuncAdd("floor", FloorFunc)
+	FuncAdd("round", RoundFunc)
 	FuncAdd("sqrt", SqrtFunc)
 	FuncAdd("pow", PowFunc)
 }
@@ -29,38 +39,209 @@ func CountFunc(ctx EvalContext, val value.Value) (value.IntValue, bool) {
 	return value.NewIntValue(1), true
 }
 
-// Sqrt
-func SqrtFunc(ctx EvalContext, val value.Value) (value.NumberValue, bool) {
-	//func Sqrt(x float64) float64
-	nv, ok := val.(value.NumericValue)
-	if !ok {
-		return value.NewNumberValue(math.NaN()), false
+// Sum of each numeric arg, non-numeric args are ignored
+func SumFunc(ctx EvalContext, vals ...value.Value) (value.NumberValue, bool) {
+	sumval := float64(0)
+	ct := 0
+	for _, val := range vals {
+		if val.Err() || val.Nil() {
+			continue
+		}
+		fv, ok := value.ToFloat64(val.Rv())
+		if !ok || math.IsNaN(fv) {
+			continue
+		}
+		sumval += fv
+		ct++
 	}
-	if val.Err() || val.Nil() {
+	if ct == 0 {
 		return value.NewNumberValue(0), false
 	}
-	fv := nv.Float()
-	fv = math.Sqrt(fv)
-	//u.Infof("???   vals=[%v]", val.Value())
+	return value.NewNumberValue(sumval), true
+}
+
+// Avg of each numeric arg, non-numeric args are ignored
+func AvgFunc(ctx EvalContext, vals ...value.Value) (value.NumberValue, bool) {
+	sumval := float64(0)
+	ct := 0
+	for _, val := range vals {
+		if val.Err() || val.Nil() {
+			continue
+		}
+		fv, ok := value.ToFloat64(val.Rv())
+		if !ok || math.IsNaN(fv) {
+			continue
+		}
+		sumval += fv
+		ct++
+	}
+	if ct == 0 {
+		return value.NumberNaNValue, false
+	}
+	return value.NewNumberValue(sumval / float64(ct)), true
+}
+
+// Min of each numeric arg
+func MinFunc(ctx EvalContext, vals ...value.Value) (value.NumberValue, bool) {
+	minval := math.NaN()
+	for _, val := range vals {
+		fv, ok := value.ToFloat64(val.Rv())
+		if !ok || math.IsNaN(fv) {
+			continue
+		}
+		if math.IsNaN(minval) || fv < minval {
+			minval = fv
+		}
+	}
+	if math.IsNaN(minval) {
+		return value.NumberNaNValue, false
+	}
+	return value.NewNumberValue(minval), true
+}
+
+// Max of each numeric arg
+func MaxFunc(ctx EvalContext, vals ...value.Value) (value.NumberValue, bool) {
+	maxval := math.NaN()
+	for _, val := range vals {
+		fv, ok := value.ToFloat64(val.Rv())
+		if !ok || math.IsNaN(fv) {
+			continue
+		}
+		if math.IsNaN(maxval) || fv > maxval {
+			maxval = fv
+		}
+	}
+	if math.IsNaN(maxval) {
+		return value.NumberNaNValue, false
+	}
+	return value.NewNumberValue(maxval), true
+}
+
+// First value, ordered by optional 2nd arg.  For a single row this is
+//  the value itself, the GroupBy task aggregates across rows
+//
+//     first(status, ts)
+//
+func FirstFunc(ctx EvalContext, val value.Value, orderBy ...value.Value) (value.Value, bool) {
+	if val == nil {
+		return value.NilValueVal, false
+	}
+	return val, true
+}
+
+// Last value, ordered by optional 2nd arg, see FirstFunc
+//
+//     last(status, ts)
+//
+func LastFunc(ctx EvalContext, val value.Value, orderBy ...value.Value) (value.Value, bool) {
+	if val == nil {
+		return value.NilValueVal, false
+	}
+	return val, true
+}
+
+// toNumeric converts a value to NumericValue for the math funcs, numeric
+//  strings are converted to NumberValue
+func toNumeric(val value.Value) (value.NumericValue, bool) {
+	if val == nil || val.Err() || val.Nil() {
+		return nil, false
+	}
+	if nv, ok := val.(value.NumericValue); ok {
+		return nv, true
+	}
+	fv, ok := value.ToFloat64(val.Rv())
+	if !ok || math.IsNaN(fv) {
+		return nil, false
+	}
 	return value.NewNumberValue(fv), true
 }
 
-// Pow
-func PowFunc(ctx EvalContext, val, toPower value.Value) (value.NumberValue, bool) {
-	//Pow(x, y float64) float64
-	//u.Infof("powFunc:  %T:%v %T:%v ", val, val.Value(), toPower, toPower.Value())
-	if val.Err() || val.Nil() {
-		return value.NewNumberValue(0), false
+// mathResult converts the value math helper result to func return
+func mathResult(v value.Value) (value.Value, bool) {
+	if v.Err() {
+		return v, false
+	}
+	return v, true
+}
+
+// Abs absolute value
+//
+//     abs(-5.5)  =>  5.5
+//
+func AbsFunc(ctx EvalContext, val value.Value) (value.Value, bool) {
+	nv, ok := toNumeric(val)
+	if !ok {
+		return value.NumberNaNValue, false
+	}
+	return mathResult(value.Abs(nv))
+}
+
+// Ceil round up to nearest integer
+//
+//     ceil(5.2)  =>  6
+//
+func CeilFunc(ctx EvalContext, val value.Value) (value.Value, bool) {
+	nv, ok := toNumeric(val)
+	if !ok {
+		return value.NumberNaNValue, false
+	}
+	return mathResult(value.Ceil(nv))
+}
+
+// Floor round down to nearest integer
+//
+//     floor(5.8)  =>  5
+//
+func FloorFunc(ctx EvalContext, val value.Value) (value.Value, bool) {
+	nv, ok := toNumeric(val)
+	if !ok {
+		return value.NumberNaNValue, false
+	}
+	return mathResult(value.Floor(nv))
+}
+
+// Round to optional number of decimal places, defaults to 0
+//
+//     round(5.5)         =>  6
+//     round(2.345, 2)    =>  2.35
+//
+func RoundFunc(ctx EvalContext, val value.Value, places ...value.Value) (value.Value, bool) {
+	nv, ok := toNumeric(val)
+	if !ok {
+		return value.NumberNaNValue, false
+	}
+	p := int64(0)
+	if len(places) > 0 {
+		pv, ok := toNumeric(places[0])
+		if !ok {
+			return value.NumberNaNValue, false
+		}
+		p = pv.Int()
+	}
+	return mathResult(value.Round(nv, int(p)))
+}
+
+// Sqrt square root, negative numbers are an error
+func SqrtFunc(ctx EvalContext, val value.Value) (value.Value, bool) {
+	nv, ok := toNumeric(val)
+	if !ok {
+		return value.NumberNaNValue, false
 	}
-	if toPower.Err() || toPower.Nil() {
+	return mathResult(value.Sqrt(nv))
+}
+
+// Pow
+//
+//     pow(5, 2)  =>  25
+//
+func PowFunc(ctx EvalContext, val, toPower value.Value) (value.Value, bool) {
+	nv, ok := toNumeric(val)
+	if !ok {
 		return value.NewNumberValue(0), false
 	}
-	fv, _ := value.ToFloat64(val.Rv())
-	pow, _ := value.ToFloat64(toPower.Rv())
-	if math.IsNaN(fv) || math.IsNaN(pow) {
+	pow, ok := toNumeric(toPower)
+	if !ok {
 		return value.NewNumberValue(0), false
 	}
-	fv = math.Pow(fv, pow)
-	//u.Infof("pow ???   vals=[%v]", fv, pow)
-	return value.NewNumberValue(fv), true
+	return mathResult(value.Pow(nv, pow))
 }
@@ -48,6 +48,7 @@ const (
 	TriNodeType         NodeType = 13
 	MultiArgNodeType    NodeType = 14
 	NullNodeType        NodeType = 15
+	ParamNodeType       NodeType = 16
 	SqlPreparedType     NodeType = 29
 	SqlSelectNodeType   NodeType = 30
 	SqlInsertNodeType   NodeType = 31
@@ -90,6 +91,8 @@ func (nt NodeType) String() string {
 		return "multiarg"
 	case NullNodeType:
 		return "null"
+	case ParamNodeType:
+		return "param"
 	case SqlPreparedType:
 		return "sql prepared"
 	case SqlSelectNodeType:
@@ -166,6 +169,18 @@ type (
 		Ts() time.Time
 	}
 
+	// Param Reader is interface to read the values bound to the params
+	//  of a prepared statement, see Params
+	ParamReader interface {
+		Param(n *ParamNode) (value.Value, bool)
+	}
+
+	// Options Reader is interface to read the evaluation options of
+	//  the query being evaluated, see EvalOptions
+	OptionsReader interface {
+		EvalOptions() *EvalOptions
+	}
+
 	// For evaluation storage
 	ContextWriter interface {
 		Put(col SchemaInfo, readCtx ContextReader, v value.Value) error
@@ -222,6 +237,14 @@ type (
 
 	NullNode struct{}
 
+	// ParamNode is a bind parameter of a prepared statement, positional
+	//  ? params are numbered in order starting at 1, named :name params
+	//  have a Name, its value is bound at execution
+	ParamNode struct {
+		Name string
+		Pos  int
+	}
+
 	// NumberNode holds a number: signed or unsigned integer or float.
 	// The value is parsed and stored under all the types that can represent the value.
 	// This simulates in a small amount of code the behavior of Go's ideal constants.
@@ -370,7 +393,8 @@ func ValueTypeFromNode(n Node) value.ValueType {
 			return value.BoolType
 		case lex.TokenMultiply, lex.TokenMinus, lex.TokenAdd, lex.TokenDivide:
 			return value.NumberType
-		case lex.TokenModulus:
+		case lex.TokenModulus, lex.TokenBitAnd, lex.TokenBitOr, lex.TokenBitXor,
+			lex.TokenLShift, lex.TokenRShift:
 			return value.IntType
 		default:
 			u.Warnf("NoValueType? %T", n)
@@ -596,6 +620,24 @@ func (n *NullNode) Check() error              { return nil }
 func (m *NullNode) NodeType() NodeType        { return NullNodeType }
 func (m *NullNode) Type() reflect.Value       { return nilRv }
 
+func NewParamNode(tok lex.Token, pos int) *ParamNode {
+	if strings.HasPrefix(tok.V, ":") {
+		return &ParamNode{Name: tok.V[1:]}
+	}
+	return &ParamNode{Pos: pos}
+}
+
+func (m *ParamNode) FingerPrint(r rune) string { return string(r) }
+func (m *ParamNode) String() string {
+	if m.Name != "" {
+		return ":" + m.Name
+	}
+	return "?"
+}
+func (m *ParamNode) Check() error        { return nil }
+func (m *ParamNode) NodeType() NodeType  { return ParamNodeType }
+func (m *ParamNode) Type() reflect.Value { return nilRv }
+
 // BinaryNode holds two arguments and an operator
 /*
 binary_op  = "||" | "&&" | rel_op | add_op | mul_op .
@@ -0,0 +1,22 @@
+package expr
+
+import (
+	"time"
+)
+
+// EvalOptions are the evaluation policies of a query, carried by the
+//  EvalContext (see OptionsReader) rather than set process wide, so
+//  queries with different options can run side by side.  The zero value
+//  is the default behavior.
+type EvalOptions struct {
+	// NumericStrings if true compares two strings that both parse as
+	//  numbers numerically so "100" > "9", see value.NumericString()
+	NumericStrings bool
+	// TimeTolerance if > 0 is the tolerance of time equality, == and !=
+	//  of two times differing by at most this much are equal, see
+	//  value.TimeEqualWithin()
+	TimeTolerance time.Duration
+	// StrictDivision if true makes divide or modulus by zero an error,
+	//  by default it is NULL as in sql, see value.Divide()
+	StrictDivision bool
+}
@@ -0,0 +1,200 @@
+package expr
+
+import (
+	"fmt"
+
+	"github.com/araddon/qlbridge/value"
+)
+
+var (
+	_ ParamReader = (*Params)(nil)
+)
+
+// Params are the values bound to the params of a prepared statement,
+//  positional ? params by Args in order, named :name params by Named
+type Params struct {
+	Args  []value.Value
+	Named map[string]value.Value
+}
+
+// Params of positional values
+func NewParams(args ...value.Value) *Params {
+	return &Params{Args: args, Named: make(map[string]value.Value)}
+}
+
+func (m *Params) Param(n *ParamNode) (value.Value, bool) {
+	if n.Name != "" {
+		v, ok := m.Named[n.Name]
+		return v, ok
+	}
+	if n.Pos < 1 || n.Pos > len(m.Args) {
+		return nil, false
+	}
+	return m.Args[n.Pos-1], true
+}
+
+// BindParams returns a copy of the node with each ParamNode replaced by
+//  a ValueNode of its bound value, nodes without params are not copied.
+//  It is an error for a param to have no bound value.
+//
+//     age > ? AND name = :name     Args: 21   Named: name="bob"
+//        =>  age > 21 AND name = "bob"
+//
+func BindParams(node Node, params ParamReader) (Node, error) {
+	switch n := node.(type) {
+	case *ParamNode:
+		v, ok := params.Param(n)
+		if !ok {
+			return nil, fmt.Errorf("No value bound for param %s", paramName(n))
+		}
+		if v == nil {
+			v = value.NilValueVal
+		}
+		return NewValueNode(v), nil
+	case *FuncNode:
+		args, changed, err := bindArgs(n.Args, params)
+		if err != nil || !changed {
+			return node, err
+		}
+		fn := *n
+		fn.Args = args
+		return &fn, nil
+	case *BinaryNode:
+		args, changed, err := bindArgs(n.Args[:], params)
+		if err != nil || !changed {
+			return node, err
+		}
+		bn := *n
+		copy(bn.Args[:], args)
+		return &bn, nil
+	case *TriNode:
+		args, changed, err := bindArgs(n.Args[:], params)
+		if err != nil || !changed {
+			return node, err
+		}
+		tn := *n
+		copy(tn.Args[:], args)
+		return &tn, nil
+	case *UnaryNode:
+		arg, err := BindParams(n.Arg, params)
+		if err != nil || arg == n.Arg {
+			return node, err
+		}
+		un := *n
+		un.Arg = arg
+		return &un, nil
+	case *MultiArgNode:
+		args, changed, err := bindArgs(n.Args, params)
+		if err != nil || !changed {
+			return node, err
+		}
+		mn := *n
+		mn.Args = args
+		return &mn, nil
+	}
+	return node, nil
+}
+
+// bind each of a list of nodes, returning true if any were changed
+func bindArgs(nodes []Node, params ParamReader) ([]Node, bool, error) {
+	args := make([]Node, len(nodes))
+	changed := false
+	for i, arg := range nodes {
+		if arg == nil {
+			continue
+		}
+		bound, err := BindParams(arg, params)
+		if err != nil {
+			return nil, false, err
+		}
+		args[i] = bound
+		changed = changed || bound != arg
+	}
+	return args, changed, nil
+}
+
+func paramName(n *ParamNode) string {
+	if n.Name != "" {
+		return ":" + n.Name
+	}
+	return fmt.Sprintf("?%d", n.Pos)
+}
+
+// BindParams returns a copy of this select with the params of its
+//  columns, sources, where and having replaced by their bound values.
+//  The statement itself is not changed so may be bound again, it
+//  should not have been Finalized.
+func (m *SqlSelect) BindParams(params ParamReader) (*SqlSelect, error) {
+	if m == nil {
+		return nil, nil
+	}
+	var err error
+	stmt := *m
+	stmt.Columns, err = bindColumns(m.Columns, params)
+	if err != nil {
+		return nil, err
+	}
+	stmt.From = make([]*SqlSource, len(m.From))
+	for i, from := range m.From {
+		src := *from
+		if from.JoinExpr != nil {
+			if src.JoinExpr, err = BindParams(from.JoinExpr, params); err != nil {
+				return nil, err
+			}
+		}
+		if src.SubQuery, err = from.SubQuery.BindParams(params); err != nil {
+			return nil, err
+		}
+		if src.Source, err = from.Source.BindParams(params); err != nil {
+			return nil, err
+		}
+		stmt.From[i] = &src
+	}
+	if m.Where != nil {
+		where := *m.Where
+		if m.Where.Expr != nil {
+			if where.Expr, err = BindParams(m.Where.Expr, params); err != nil {
+				return nil, err
+			}
+		}
+		if where.Source, err = m.Where.Source.BindParams(params); err != nil {
+			return nil, err
+		}
+		stmt.Where = &where
+	}
+	if m.Having != nil {
+		if stmt.Having, err = BindParams(m.Having, params); err != nil {
+			return nil, err
+		}
+	}
+	if stmt.GroupBy, err = bindColumns(m.GroupBy, params); err != nil {
+		return nil, err
+	}
+	if stmt.OrderBy, err = bindColumns(m.OrderBy, params); err != nil {
+		return nil, err
+	}
+	return &stmt, nil
+}
+
+func bindColumns(cols Columns, params ParamReader) (Columns, error) {
+	if cols == nil {
+		return nil, nil
+	}
+	var err error
+	bound := make(Columns, len(cols))
+	for i, col := range cols {
+		c := *col
+		if col.Expr != nil {
+			if c.Expr, err = BindParams(col.Expr, params); err != nil {
+				return nil, err
+			}
+		}
+		if col.Guard != nil {
+			if c.Guard, err = BindParams(col.Guard, params); err != nil {
+				return nil, err
+			}
+		}
+		bound[i] = &c
+	}
+	return bound, nil
+}
@@ -99,6 +99,18 @@ func (m *LexTokenPager) Lexer() *lex.Lexer {
 	return m.lex
 }
 
+// the 1 based position of a positional ? param at the cursor, ie one
+//  more than the count of ? params before it
+func (m *LexTokenPager) paramPos() int {
+	pos := 1
+	for _, tok := range m.tokens[:m.cursor] {
+		if tok.T == lex.TokenParam && tok.V == "?" {
+			pos++
+		}
+	}
+	return pos
+}
+
 // backup backs the input stream up one token.
 func (m *LexTokenPager) Backup() {
 	if m.cursor > 0 {
@@ -388,7 +400,7 @@ func (t *Tree) P(depth int) Node {
 	//u.Debugf("%s t.P: AFTER %v", strings.Repeat("→ ", depth), t.Cur())
 	for {
 		switch cur := t.Cur(); cur.T {
-		case lex.TokenPlus, lex.TokenMinus:
+		case lex.TokenPlus, lex.TokenMinus, lex.TokenBitOr, lex.TokenBitXor:
 			t.Next()
 			n = NewBinaryNode(cur, n, t.M(depth+1))
 		default:
@@ -403,7 +415,8 @@ func (t *Tree) M(depth int) Node {
 	//u.Debugf("%s t.M after: %v  %s", strings.Repeat("→ ", depth), t.Cur(), n.NodeType())
 	for {
 		switch cur := t.Cur(); cur.T {
-		case lex.TokenStar, lex.TokenMultiply, lex.TokenDivide, lex.TokenModulus:
+		case lex.TokenStar, lex.TokenMultiply, lex.TokenDivide, lex.TokenModulus,
+			lex.TokenBitAnd, lex.TokenLShift, lex.TokenRShift:
 			t.Next()
 			n = NewBinaryNode(cur, n, t.F(depth+1))
 		default:
@@ -462,6 +475,8 @@ func (t *Tree) F(depth int) Node {
 		return t.v(depth)
 	case lex.TokenNull:
 		return t.v(depth)
+	case lex.TokenParam:
+		return t.v(depth)
 	// case lex.TokenLeftBrace:
 	// 	// {
 	// 	return t.v(depth)
@@ -524,6 +539,15 @@ func (t *Tree) v(depth int) Node {
 	case lex.TokenNull:
 		t.Next()
 		return NewNull(cur)
+	case lex.TokenParam:
+		pos := 0
+		if pp, ok := t.TokenPager.(interface {
+			paramPos() int
+		}); ok {
+			pos = pp.paramPos()
+		}
+		t.Next()
+		return NewParamNode(cur, pos)
 	case lex.TokenStar:
 		n := NewStringNoQuoteNode(cur.V)
 		t.Next()
@@ -637,7 +661,19 @@ func (t *Tree) Func(depth int, funcTok lex.Token) (fn *FuncNode) {
 			t.Next()
 			//u.Warnf("found right paren %v", t.Cur())
 			return
-		case lex.TokenEOF, lex.TokenEOS, lex.TokenFrom, lex.TokenAs:
+		case lex.TokenAs:
+			// CAST(field AS int), the type name is a string arg
+			if node != nil {
+				fn.append(node)
+			}
+			t.Next()
+			typeTok := t.expect(lex.TokenIdentity, "func AS type")
+			fn.append(NewStringNode(typeTok.V))
+			t.Next()
+			t.expect(lex.TokenRightParenthesis, "func AS type")
+			t.Next()
+			return
+		case lex.TokenEOF, lex.TokenEOS, lex.TokenFrom:
 			if node != nil {
 				fn.append(node)
 			}
@@ -544,13 +544,19 @@ func (m *Sqlbridge) parseColumns(stmt *SqlSelect) error {
 		case lex.TokenIdentity:
 			//u.Warnf("?? %v", m.Cur())
 			col = NewColumnFromToken(m.Cur())
+			if strings.HasSuffix(col.As, ".*") {
+				// qualified star   select t1.*, t2.name from ...
+				col.Star = true
+				m.Next()
+				break
+			}
 			tree := NewTree(m.SqlTokenPager)
 			if err := m.parseNode(tree); err != nil {
 				u.Errorf("could not parse: %v", err)
 				return err
 			}
 			col.Expr = tree.Root
-		case lex.TokenValue:
+		case lex.TokenValue, lex.TokenInteger, lex.TokenFloat:
 			// Value Literal
 			col = NewColumnFromToken(m.Cur())
 			tree := NewTree(m.SqlTokenPager)
@@ -974,6 +980,12 @@ func (m *Sqlbridge) parseSelectStar(req *SqlSelect) error {
 	req.Columns = append(req.Columns, col)
 
 	m.Next()
+	if m.Cur().T == lex.TokenComma {
+		// select *, a + b AS c   passes through all columns then
+		//  adds the computed ones
+		m.Next()
+		return m.parseColumns(req)
+	}
 	return nil
 }
 
@@ -1119,6 +1131,9 @@ func (m *Sqlbridge) parseGroupBy(req *SqlSelect) (err error) {
 		case lex.TokenIdentity:
 			//u.Warnf("?? %v", m.Cur())
 			col = NewColumnFromToken(m.Cur())
+			if strings.HasSuffix(col.As, ".*") {
+				return fmt.Errorf("qualified star not allowed in GROUP BY: %v", m.Cur().V)
+			}
 			tree := NewTree(m.SqlTokenPager)
 			if err := m.parseNode(tree); err != nil {
 				return err
@@ -1251,6 +1266,9 @@ func (m *Sqlbridge) parseOrderBy(req *SqlSelect) (err error) {
 		case lex.TokenIdentity:
 			//u.Warnf("?? %v", m.Cur())
 			col = NewColumnFromToken(m.Cur())
+			if strings.HasSuffix(col.As, ".*") {
+				return fmt.Errorf("qualified star not allowed in ORDER BY: %v", m.Cur().V)
+			}
 			tree := NewTree(m.SqlTokenPager)
 			if err := m.parseNode(tree); err != nil {
 				u.Warnf("could not parse: %v", err)
@@ -1264,6 +1282,10 @@ func (m *Sqlbridge) parseOrderBy(req *SqlSelect) (err error) {
 		switch m.Cur().T {
 		case lex.TokenAsc, lex.TokenDesc:
 			col.Order = strings.ToUpper(m.Cur().V)
+		case lex.TokenNullsFirst:
+			col.Nulls = "FIRST"
+		case lex.TokenNullsLast:
+			col.Nulls = "LAST"
 
 		case lex.TokenInto, lex.TokenLimit, lex.TokenEOS, lex.TokenEOF:
 			// This indicates we have come to the End of the columns
@@ -1,6 +1,7 @@
 package expr
 
 import (
+	"strings"
 	"testing"
 
 	u "github.com/araddon/gou"
@@ -52,6 +53,9 @@ func TestSqlLexOnly(t *testing.T) {
 		INNER JOIN orders AS t3
 			ON t3.id = t2.fake_id;`)
 
+	parseSqlTest(t, `SELECT 2*21 AS answer`)
+	parseSqlTest(t, `SELECT 1.5, 2 AS two`)
+
 	// TODO:
 	//parseSqlTest(t, `INSERT INTO events (id,event_date,event) SELECT id,last_logon,"last_logon" FROM users;`)
 	// parseSqlTest(t, `REPLACE INTO tbl_3 (id,lastname) SELECT id,lastname FROM tbl_1;`)
@@ -130,6 +134,16 @@ func TestSqlParseAstCheck(t *testing.T) {
 	assert.Tf(t, n.String() == "repository.language", "%v", n)
 	assert.Tf(t, n.String() == "repository.language", "%v", n)
 
+	// CAST(x AS type), the type is a string arg of the cast func
+	sql = `select name, cast(score AS int) AS s FROM employee`
+	req, err = ParseSql(sql)
+	assert.Tf(t, err == nil && req != nil, "Must parse: %s  \n\t%v", sql, err)
+	sel = req.(*SqlSelect)
+	assert.Tf(t, len(sel.Columns) == 2 && sel.Columns[1].As == "s", "%v", sel.Columns)
+	fn, ok := sel.Columns[1].Expr.(*FuncNode)
+	assert.Tf(t, ok && len(fn.Args) == 2, "%#v", sel.Columns[1].Expr)
+	assert.Tf(t, fn.Args[1].(*StringNode).Text == "int", "%v", fn.Args[1])
+
 	sql = `select @@version_comment limit 7`
 	req, err = ParseSql(sql)
 	assert.Tf(t, err == nil && req != nil, "Must parse: %s  \n\t%v", sql, err)
@@ -149,6 +163,17 @@ func TestSqlParseAstCheck(t *testing.T) {
 	assert.Tf(t, sel.OrderBy[0].Order == "ASC", "%v", sel.OrderBy[0].String())
 	assert.Tf(t, sel.OrderBy[1].Order == "DESC", "%v", sel.OrderBy[1].String())
 
+	sql = "select a, b from t ORDER BY a NULLS FIRST, b DESC NULLS LAST limit 10"
+	req, err = ParseSql(sql)
+	assert.Tf(t, err == nil && req != nil, "Must parse: %s  \n\t%v", sql, err)
+	sel, ok = req.(*SqlSelect)
+	assert.Tf(t, ok, "is SqlSelect: %T", req)
+	assert.Tf(t, sel.Limit == 10, "want limit = 10 but have %v", sel.Limit)
+	assert.Tf(t, len(sel.OrderBy) == 2, "want 2 orderby but has %v", len(sel.OrderBy))
+	assert.Tf(t, sel.OrderBy[0].Order == "" && sel.OrderBy[0].Nulls == "FIRST", "%v", sel.OrderBy[0].String())
+	assert.Tf(t, sel.OrderBy[1].Order == "DESC" && sel.OrderBy[1].Nulls == "LAST", "%v", sel.OrderBy[1].String())
+	assert.Tf(t, sel.OrderBy[1].String() == "b DESC NULLS LAST", "%v", sel.OrderBy[1].String())
+
 	sql = "select `actor.id`, `actor.login` from github_watch where `actor.id` < 1000"
 	req, err = ParseSql(sql)
 	assert.Tf(t, err == nil && req != nil, "Must parse: %s  \n\t%v", sql, err)
@@ -188,6 +213,15 @@ func TestSqlParseAstCheck(t *testing.T) {
 	assert.Tf(t, err != nil, "Must fail parse: %v", err)
 	//assert.Tf(t, reqNil == nil, "Must fail parse: %v", reqNil)
 
+	// qualified star is only a select column
+	for _, sql := range []string{
+		"select t1.name from t1 GROUP BY t1.*",
+		"select t1.name from t1 ORDER BY t1.* DESC",
+	} {
+		_, err = ParseSql(sql)
+		assert.Tf(t, err != nil && strings.Contains(err.Error(), "qualified star"), "Must fail parse: %s %v", sql, err)
+	}
+
 	sql = `select repository.name, respository.language, repository.stargazers 
 		FROM github_fork 
 		WHERE 
@@ -93,6 +93,7 @@ type (
 		cols        map[string]*Column // Un-aliased columns
 		colIndex    map[string]int     // Key(alias) to index in []driver.Value positions
 		joinNodes   []Node             // x.y = q.y AND x.z = q.z  --- []Node{Identity{x},Identity{z}}
+		projected   Columns            // Source columns in the parent projection, written by Rewrite
 		Source      *SqlSelect         // Sql Select Source query, written by Rewrite
 		Raw         string             // Raw Partial Query
 		Name        string             // From Name (optional, empty if join, subselect)
@@ -174,6 +175,7 @@ type (
 		As              string // As field, auto-populate the Field Name if exists
 		Comment         string // optional in-line comments
 		Order           string // (ASC | DESC)
+		Nulls           string // (FIRST | LAST) optional NULLS placement in ORDER BY
 		Star            bool   // *
 		Expr            Node   // Expression, optional, often Identity.Node
 		Guard           Node   // column If guard, non-standard sql column guard
@@ -351,6 +353,9 @@ func (m *Column) String() string {
 }
 func (m *Column) writeBuf(buf *bytes.Buffer) {
 	if m.Star {
+		if alias := m.StarAlias(); alias != "" {
+			buf.WriteString(alias + ".")
+		}
 		buf.WriteByte('*')
 		return
 	}
@@ -377,9 +382,15 @@ func (m *Column) writeBuf(buf *bytes.Buffer) {
 	if m.Order != "" {
 		buf.WriteString(fmt.Sprintf(" %s", m.Order))
 	}
+	if m.Nulls != "" {
+		buf.WriteString(fmt.Sprintf(" NULLS %s", m.Nulls))
+	}
 }
 func (m *Column) FingerPrint(r rune) string {
 	if m.Star {
+		if alias := m.StarAlias(); alias != "" {
+			return alias + ".*"
+		}
 		return "*"
 	}
 	buf := bytes.Buffer{}
@@ -406,9 +417,21 @@ func (m *Column) FingerPrint(r rune) string {
 	if m.Order != "" {
 		buf.WriteString(fmt.Sprintf(" %s", m.Order))
 	}
+	if m.Nulls != "" {
+		buf.WriteString(fmt.Sprintf(" NULLS %s", m.Nulls))
+	}
 	return buf.String()
 }
 
+// The source alias of a qualified star column, ie "t1" for  select t1.*
+//  or "" if this is not a qualified star
+func (m *Column) StarAlias() string {
+	if m.Star && strings.HasSuffix(m.As, ".*") {
+		return m.As[:len(m.As)-2]
+	}
+	return ""
+}
+
 // Is this a select count(*) column
 func (m *Column) CountStar() bool {
 	if m.Expr == nil {
@@ -457,6 +480,7 @@ func (m *Column) Copy() *Column {
 		As:              m.right,
 		Comment:         m.Comment,
 		Order:           m.Order,
+		Nulls:           m.Nulls,
 		Star:            m.Star,
 		Expr:            m.Expr,
 		Guard:           m.Guard,
@@ -915,6 +939,7 @@ func (m *SqlSource) Rewrite(parentStmt *SqlSelect) *SqlSelect {
 	//  - rewrite the Sort
 	//  - rewrite the group-by
 	sql2 := &SqlSelect{Columns: newCols, Star: parentStmt.Star}
+	m.projected = newCols[:len(newCols):len(newCols)]
 	m.joinNodes = make([]Node, 0)
 	if m.SubQuery != nil {
 		if len(m.SubQuery.From) != 1 {
@@ -1277,6 +1302,22 @@ func (m *SqlSource) UnAliasedColumns() map[string]*Column {
 	return cols
 }
 
+// ProjectedColumns are the Source columns that are in the parent
+//  projection, ie not those only needed for the join or where.  If not
+//  written by Rewrite, the columns with a parent index.
+func (m *SqlSource) ProjectedColumns() Columns {
+	if m.projected != nil || m.Source == nil {
+		return m.projected
+	}
+	cols := make(Columns, 0, len(m.Source.Columns))
+	for _, col := range m.Source.Columns {
+		if col.ParentIndex >= 0 {
+			cols = append(cols, col)
+		}
+	}
+	return cols
+}
+
 // Get a list of Column names to position
 func (m *SqlSource) ColumnPositions() map[string]int {
 	if len(m.colIndex) > 0 {
@@ -44,6 +44,21 @@ func TestLexExprDialect(t *testing.T) {
 			tv(TokenRightParenthesis, ")"),
 		})
 
+	// unary minus, not a comment or a negative number
+	verifyExprTokens(t, `-(price)`,
+		[]Token{
+			tv(TokenMinus, "-"),
+			tv(TokenLeftParenthesis, "("),
+			tv(TokenIdentity, "price"),
+		})
+	verifyExprTokens(t, `-price`,
+		[]Token{
+			tv(TokenMinus, "-"),
+			tv(TokenIdentity, "price"),
+			tv(TokenEOF, ""),
+		})
+	verifyExprTokens(t, `-5`, []Token{tv(TokenInteger, "-5")})
+
 	// verifyExprTokens(t, `10 > 5`,
 	// 	[]Token{
 	// 		tv(TokenInteger, "10"),
@@ -68,6 +83,30 @@ func TestLexLogicalDialect(t *testing.T) {
 			tv(TokenInteger, "5"),
 		})
 
+	verifyExpr2Tokens(t, `flags & 4 = 4`,
+		[]Token{
+			tv(TokenIdentity, "flags"),
+			tv(TokenBitAnd, "&"),
+			tv(TokenInteger, "4"),
+			tv(TokenEqual, "="),
+			tv(TokenInteger, "4"),
+		})
+
+	verifyExpr2Tokens(t, `(flags | 1 ^ 2) << 3 >> 1`,
+		[]Token{
+			tv(TokenLeftParenthesis, "("),
+			tv(TokenIdentity, "flags"),
+			tv(TokenBitOr, "|"),
+			tv(TokenInteger, "1"),
+			tv(TokenBitXor, "^"),
+			tv(TokenInteger, "2"),
+			tv(TokenRightParenthesis, ")"),
+			tv(TokenLShift, "<<"),
+			tv(TokenInteger, "3"),
+			tv(TokenRShift, ">>"),
+			tv(TokenInteger, "1"),
+		})
+
 	verifyExpr2Tokens(t, `10 > 5`,
 		[]Token{
 			tv(TokenInteger, "10"),
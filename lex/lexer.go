@@ -299,6 +299,12 @@ func (l *Lexer) IsEnd() bool {
 	return false
 }
 
+// Is this the start of a comment, including a multi-line /* comment,
+//  rather than a minus or divide operator
+func (l *Lexer) isCommentStart() bool {
+	return l.IsComment() || strings.HasPrefix(l.input[l.pos:], "/*")
+}
+
 // Is this a comment?
 func (l *Lexer) IsComment() bool {
 	r := l.Peek()
@@ -661,10 +667,8 @@ func LexDialectForStatement(l *Lexer) StateFn {
 
 	l.SkipWhiteSpaces()
 
-	r := l.Peek()
-
-	switch r {
-	case '/', '-', '#':
+	switch {
+	case l.isCommentStart():
 		// ensure we have consumed all initial pre-statement comments
 		l.Push("LexDialectForStatement", LexDialectForStatement)
 		return LexComment(l)
@@ -718,10 +722,8 @@ func LexStatement(l *Lexer) StateFn {
 
 	l.SkipWhiteSpaces()
 
-	r := l.Peek()
-
-	switch r {
-	case '/', '-', '#':
+	switch {
+	case l.isCommentStart():
 		// ensure we have consumed all comments
 		l.Push("LexStatement", LexStatement)
 		return LexComment(l)
@@ -1076,6 +1078,18 @@ func LexExpressionOrIdentity(l *Lexer) StateFn {
 		l.Emit(TokenLeftParenthesis)
 		return LexExpressionOrIdentity
 	}
+	if r == '-' && !l.IsComment() {
+		// unary minus of an identity or expression   -price  -(a + b)
+		//  negative numbers   -5  are lexed as a value
+		if p2 := l.PeekX(2); len(p2) == 2 && !isDigit(rune(p2[1])) && p2[1] != '.' {
+			l.Next()
+			l.Emit(TokenMinus)
+			return LexExpressionOrIdentity
+		}
+	}
+	if r == '?' || r == ':' {
+		return lexParam(l)
+	}
 	//u.Debugf("LexExpressionOrIdentity identity?%v expr?%v %v peek5='%v'", l.isIdentity(), l.isExpr(), string(l.Peek()), string(l.PeekX(5)))
 	// Expressions end in Parens:     LOWER(item)
 	if l.isExpr() {
@@ -1093,6 +1107,24 @@ func LexExpressionOrIdentity(l *Lexer) StateFn {
 	return nil
 }
 
+// lex a bind parameter, either positional or named
+//
+//    ?
+//    :user_id
+//
+func lexParam(l *Lexer) StateFn {
+	if l.Next() == ':' {
+		if !isIdentifierFirstRune(l.Peek()) {
+			return l.errorToken("expected parameter name after : " + l.PeekX(5))
+		}
+		for isIdentCh(l.Peek()) {
+			l.Next()
+		}
+	}
+	l.Emit(TokenParam)
+	return nil
+}
+
 // lex Expression looks for an expression, identified by parenthesis, may be nested
 //
 //           |--expr----|
@@ -1186,7 +1218,7 @@ func LexListOfArgs(l *Lexer) StateFn {
 			l.backup()
 			return nil
 		}
-	case '!', '=', '>', '<', '-', '+', '%', '&', '/', '|':
+	case '!', '=', '>', '<', '-', '+', '%', '&', '/', '|', '^':
 		l.backup()
 		return nil
 	case ';':
@@ -1330,16 +1362,29 @@ func LexIdentifierOfType(forToken TokenType) StateFn {
 				return l.errorToken("identifier must begin with a letter " + string(l.input[l.start:l.pos]))
 			}
 			allDigits := isDigit(firstChar)
-			for rune := l.Next(); isIdentifierRune(rune); rune = l.Next() {
+			var r rune
+			for r = l.Next(); isIdentifierRune(r); r = l.Next() {
 				// iterate until we find non-identifer character
-				if allDigits && !isDigit(rune) {
+				if allDigits && !isDigit(r) {
 					allDigits = false
 				}
 			}
 			if allDigits {
 				return l.errorToken("identifier must begin with a letter " + string(l.input[l.start:l.pos]))
 			}
-			l.backup()
+			// element index   tags[0], attrs["color"], is part of the identity
+			for r == '[' {
+				n := indexSuffixLen(l.input[l.pos:])
+				if n == 0 {
+					break
+				}
+				l.pos += n
+				r = l.Next()
+			}
+			// qualified star   select t1.*, t2.* from ...
+			if r != '*' || !strings.HasSuffix(l.input[l.start:l.pos-1], ".") {
+				l.backup()
+			}
 		}
 
 		//u.Debugf("about to emit: %v", forToken)
@@ -1355,6 +1400,28 @@ func LexIdentifierOfType(forToken TokenType) StateFn {
 	}
 }
 
+// indexSuffixLen is length of an element index such as 0] or "color"]
+//  following a [ on an identity, or 0 if it isn't one
+func indexSuffixLen(s string) int {
+	end := strings.IndexByte(s, ']')
+	if end < 1 {
+		return 0
+	}
+	idx := s[:end]
+	if q := idx[0]; q == '"' || q == '\'' {
+		if len(idx) > 2 && idx[len(idx)-1] == q && strings.IndexByte(idx[1:len(idx)-1], q) < 0 {
+			return end + 1
+		}
+		return 0
+	}
+	for _, r := range idx {
+		if !isDigit(r) {
+			return 0
+		}
+	}
+	return end + 1
+}
+
 var LexDataTypeIdentity = LexDataType(TokenDataType)
 
 // LexDataType scans and finds datatypes
@@ -1442,7 +1509,7 @@ func LexSelectClause(l *Lexer) StateFn {
 			l.ConsumeWord(word)
 			l.Emit(TokenDistinct)
 		} // DISTINCTROW?
-	case "* ":
+	case "* ", "*,":
 		// Look for keyword, ie something like FROM, or possibly end of statement
 		l.Next()           // consume the *
 		pw := l.PeekWord() // this will skip whitespace
@@ -1452,6 +1519,11 @@ func LexSelectClause(l *Lexer) StateFn {
 			l.Emit(TokenStar)
 			return nil
 		}
+		if strings.HasPrefix(strings.TrimLeftFunc(l.input[l.pos:], unicode.IsSpace), ",") {
+			//   select *, a + b AS c from
+			l.Emit(TokenStar)
+			return LexExpression
+		}
 		l.backup()
 		//u.Warnf("What is this? %v", l.PeekX(10))
 	case "@@": //  mysql system variables start with @@
@@ -2102,7 +2174,7 @@ func LexExpression(l *Lexer) StateFn {
 		l.backup()
 		l.Push("LexExpression", l.clauseState())
 		return LexIdentifier
-	case '!', '=', '>', '<', '(', ')', ',', ';', '-', '*', '+', '%', '&', '/', '|':
+	case '!', '=', '>', '<', '(', ')', ',', ';', '-', '*', '+', '%', '&', '/', '|', '^':
 		foundLogical := false
 		foundOperator := false
 		switch r {
@@ -2155,18 +2227,28 @@ func LexExpression(l *Lexer) StateFn {
 			if r2 := l.Peek(); r2 == '|' {
 				l.Next()
 				l.Emit(TokenOr)
-				foundOperator = true
+			} else {
+				l.Emit(TokenBitOr)
 			}
+			foundOperator = true
 		case '&':
 			if r2 := l.Peek(); r2 == '&' {
 				l.Next()
 				l.Emit(TokenAnd)
-				foundOperator = true
+			} else {
+				l.Emit(TokenBitAnd)
 			}
+			foundOperator = true
+		case '^':
+			l.Emit(TokenBitXor)
+			foundOperator = true
 		case '>':
 			if r2 := l.Peek(); r2 == '=' {
 				l.Next()
 				l.Emit(TokenGE)
+			} else if r2 == '>' { //   >>
+				l.Next()
+				l.Emit(TokenRShift)
 			} else {
 				l.Emit(TokenGT)
 			}
@@ -2176,6 +2258,10 @@ func LexExpression(l *Lexer) StateFn {
 				l.Next()
 				l.Emit(TokenLE)
 				foundLogical = true
+			} else if r2 == '<' { //   <<
+				l.Next()
+				l.Emit(TokenLShift)
+				foundOperator = true
 			} else if r2 == '>' { //   <>
 				l.Next()
 				l.Emit(TokenNE)
@@ -2354,6 +2440,23 @@ func LexOrderByColumn(l *Lexer) StateFn {
 		l.ConsumeWord(word)
 		l.Emit(TokenDesc)
 		return LexOrderByColumn
+	case "nulls":
+		// NULLS FIRST | NULLS LAST, emitted as a single token
+		l.ConsumeWord(word)
+		placement := strings.ToLower(l.PeekWord())
+		if placement != "first" && placement != "last" {
+			return l.errorf("expected NULLS FIRST or NULLS LAST but got %q", placement)
+		}
+		for unicode.IsSpace(l.Next()) {
+		}
+		l.backup()
+		l.ConsumeWord(placement)
+		if placement == "first" {
+			l.Emit(TokenNullsFirst)
+		} else {
+			l.Emit(TokenNullsLast)
+		}
+		return LexOrderByColumn
 	default:
 		if len(l.stack) < 2 {
 			l.Push("LexOrderByColumn", LexOrderByColumn)
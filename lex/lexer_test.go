@@ -58,6 +58,10 @@ func TestLexIdentity(t *testing.T) {
 	assert.T(t, tok.T == TokenError)
 	tok = token("dostuff(arg1)", LexIdentifier)
 	assert.Tf(t, tok.T == TokenIdentity && tok.V == "dostuff", "%v", tok.V)
+	tok = token("tags[0]", LexIdentifier)
+	assert.Tf(t, tok.T == TokenIdentity && tok.V == "tags[0]", "%v", tok.V)
+	tok = token(`attrs["color"] = 1`, LexIdentifier)
+	assert.Tf(t, tok.T == TokenIdentity && tok.V == `attrs["color"]`, "%v", tok.V)
 	tempIdentityQuotes := IdentityQuoting
 	IdentityQuoting = []byte{'\''}
 	tok = token("'first_name'", LexIdentifier)
@@ -567,6 +571,28 @@ func TestLexSqlJoin(t *testing.T) {
 			TokenInner, TokenJoin, TokenIdentity, TokenAs, TokenIdentity,
 			TokenOn, TokenIdentity, TokenEqual, TokenIdentity,
 		})
+
+	// qualified stars are a single identity
+	verifyTokens(t, `SELECT t1.*, t2.* FROM employee AS t1 INNER JOIN info AS t2 ON t1.name = t2.name`,
+		[]Token{
+			tv(TokenSelect, "SELECT"),
+			tv(TokenIdentity, "t1.*"),
+			tv(TokenComma, ","),
+			tv(TokenIdentity, "t2.*"),
+			tv(TokenFrom, "FROM"),
+			tv(TokenIdentity, "employee"),
+			tv(TokenAs, "AS"),
+			tv(TokenIdentity, "t1"),
+			tv(TokenInner, "INNER"),
+			tv(TokenJoin, "JOIN"),
+			tv(TokenIdentity, "info"),
+			tv(TokenAs, "AS"),
+			tv(TokenIdentity, "t2"),
+			tv(TokenOn, "ON"),
+			tv(TokenIdentity, "t1.name"),
+			tv(TokenEqual, "="),
+			tv(TokenIdentity, "t2.name"),
+		})
 }
 
 func TestLexSqlSubQuery(t *testing.T) {
@@ -600,6 +626,31 @@ func TestLexSqlPreparedStmt(t *testing.T) {
 		})
 }
 
+func TestLexSqlParams(t *testing.T) {
+	verifyTokens(t, `SELECT POW(?,2) AS p FROM users WHERE age > ? AND name = :name`,
+		[]Token{
+			tv(TokenSelect, "SELECT"),
+			tv(TokenUdfExpr, "POW"),
+			tv(TokenLeftParenthesis, "("),
+			tv(TokenParam, "?"),
+			tv(TokenComma, ","),
+			tv(TokenInteger, "2"),
+			tv(TokenRightParenthesis, ")"),
+			tv(TokenAs, "AS"),
+			tv(TokenIdentity, "p"),
+			tv(TokenFrom, "FROM"),
+			tv(TokenIdentity, "users"),
+			tv(TokenWhere, "WHERE"),
+			tv(TokenIdentity, "age"),
+			tv(TokenGT, ">"),
+			tv(TokenParam, "?"),
+			tv(TokenLogicAnd, "AND"),
+			tv(TokenIdentity, "name"),
+			tv(TokenEqual, "="),
+			tv(TokenParam, ":name"),
+		})
+}
+
 func TestLexGroupBy(t *testing.T) {
 	verifyTokens(t, `SELECT x FROM p
 	GROUP BY company, category
@@ -704,6 +755,24 @@ func TestLexOrderBy(t *testing.T) {
 			tv(TokenAsc, "ASC"),
 			tv(TokenEOS, ";"),
 		})
+
+	verifyTokens(t, "SELECT a FROM t ORDER BY a ASC NULLS FIRST, b desc nulls  last LIMIT 10",
+		[]Token{
+			tv(TokenSelect, "SELECT"),
+			tv(TokenIdentity, "a"),
+			tv(TokenFrom, "FROM"),
+			tv(TokenIdentity, "t"),
+			tv(TokenOrderBy, "ORDER BY"),
+			tv(TokenIdentity, "a"),
+			tv(TokenAsc, "ASC"),
+			tv(TokenNullsFirst, "NULLS FIRST"),
+			tv(TokenComma, ","),
+			tv(TokenIdentity, "b"),
+			tv(TokenDesc, "desc"),
+			tv(TokenNullsLast, "nulls  last"),
+			tv(TokenLimit, "LIMIT"),
+			tv(TokenInteger, "10"),
+		})
 }
 
 func TestLexTSQL(t *testing.T) {
@@ -744,6 +813,21 @@ func TestLexSelectStar(t *testing.T) {
 			tv(TokenFrom, "FROM"),
 			tv(TokenIdentity, "github.user"),
 		})
+
+	// star passthrough with computed columns
+	verifyTokens(t, `SELECT *, price * qty AS total FROM orders`,
+		[]Token{
+			tv(TokenSelect, "SELECT"),
+			tv(TokenStar, "*"),
+			tv(TokenComma, ","),
+			tv(TokenIdentity, "price"),
+			tv(TokenMultiply, "*"),
+			tv(TokenIdentity, "qty"),
+			tv(TokenAs, "AS"),
+			tv(TokenIdentity, "total"),
+			tv(TokenFrom, "FROM"),
+			tv(TokenIdentity, "orders"),
+		})
 }
 
 func TestLexSelectExpressions(t *testing.T) {
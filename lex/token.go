@@ -116,6 +116,11 @@ const (
 	TokenFalse            TokenType = 86 // False
 	TokenIs               TokenType = 87 // IS
 	TokenNull             TokenType = 88 // NULL
+	TokenBitAnd           TokenType = 89 // &
+	TokenBitOr            TokenType = 90 // |
+	TokenBitXor           TokenType = 91 // ^
+	TokenLShift           TokenType = 92 // <<
+	TokenRShift           TokenType = 93 // >>
 
 	// ql top-level keywords, these first keywords determine parser
 	TokenPrepare   TokenType = 200
@@ -173,6 +178,9 @@ const (
 	TokenDesc TokenType = 503 // descending
 	TokenUse  TokenType = 504 // use
 
+	TokenNullsFirst TokenType = 505 // nulls first
+	TokenNullsLast  TokenType = 506 // nulls last
+
 	// User defined function/expression
 	TokenUdfExpr TokenType = 550
 
@@ -182,6 +190,7 @@ const (
 	TokenValueWithSingleQuote TokenType = 602 // '' becomes ' inside the string, parser will need to replace the string
 	TokenRegex                TokenType = 603 // regex
 	TokenDuration             TokenType = 604 // 14d , 22w, 3y, 45ms, 45us, 24hr, 2h, 45m, 30s
+	TokenParam                TokenType = 605 // bind parameter, positional ? or named :name
 
 	// Scalar literal data-types
 	TokenDataType TokenType = 1000 // A generic Identifier of DataTypes
@@ -262,6 +271,11 @@ var (
 		TokenBetween:    {Kw: "between", Description: "between"},
 		TokenIs:         {Kw: "is", Description: "IS"},
 		TokenNull:       {Kw: "null", Description: "NULL"},
+		TokenBitAnd:     {Kw: "&", Description: "Bitwise And &"},
+		TokenBitOr:      {Kw: "|", Description: "Bitwise Or |"},
+		TokenBitXor:     {Kw: "^", Description: "Bitwise Xor ^"},
+		TokenLShift:     {Kw: "<<", Description: "Left Shift <<"},
+		TokenRShift:     {Kw: ">>", Description: "Right Shift >>"},
 
 		// Identity ish bools
 		TokenTrue:  {Kw: "true", Description: "True"},
@@ -331,12 +345,16 @@ var (
 		TokenDesc: {Description: "desc"},
 		TokenUse:  {Description: "use"},
 
+		TokenNullsFirst: {Description: "nulls first"},
+		TokenNullsLast:  {Description: "nulls last"},
+
 		// value types
 		TokenIdentity:             {Description: "identity"},
 		TokenValue:                {Description: "value"},
 		TokenValueWithSingleQuote: {Description: "valueWithSingleQuote"},
 		TokenRegex:                {Description: "regex"},
 		TokenDuration:             {Description: "duration"},
+		TokenParam:                {Description: "param"},
 
 		// scalar literals.
 		TokenBool:    {Description: "Bool"},
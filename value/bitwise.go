@@ -0,0 +1,72 @@
+package value
+
+// Integer bitwise operations, for flag columns
+//
+//     WHERE flags & 4 = 4
+//
+// Operands are coerced with NumericValue.Int() (so floats truncate),
+// non-numeric operands return an ErrorValue.  The modulus is Modulo().
+
+// And is the bitwise a & b
+func And(a, b Value) Value {
+	return operateInt64("&", a, b, func(x, y int64) Value { return NewIntValue(x & y) })
+}
+
+// Or is the bitwise a | b
+func Or(a, b Value) Value {
+	return operateInt64("|", a, b, func(x, y int64) Value { return NewIntValue(x | y) })
+}
+
+// Xor is the bitwise a ^ b
+func Xor(a, b Value) Value {
+	return operateInt64("^", a, b, func(x, y int64) Value { return NewIntValue(x ^ y) })
+}
+
+// Shl is the left shift a << b, b must not be negative
+func Shl(a, b Value) Value {
+	return operateInt64("<<", a, b, func(x, y int64) Value {
+		if y < 0 {
+			return NewErrorValuef("Negative shift count %d", y)
+		}
+		return NewIntValue(x << uint64(y))
+	})
+}
+
+// Shr is the arithmetic right shift a >> b, b must not be negative
+func Shr(a, b Value) Value {
+	return operateInt64(">>", a, b, func(x, y int64) Value {
+		if y < 0 {
+			return NewErrorValuef("Negative shift count %d", y)
+		}
+		return NewIntValue(x >> uint64(y))
+	})
+}
+
+func operateInt64(op string, a, b Value, fn func(x, y int64) Value) Value {
+	x, ok := valueInt64(a)
+	if !ok {
+		return NewErrorValuef("Invalid operand for %s: %s", op, typeName(a))
+	}
+	y, ok := valueInt64(b)
+	if !ok {
+		return NewErrorValuef("Invalid operand for %s: %s", op, typeName(b))
+	}
+	return fn(x, y)
+}
+
+func valueInt64(v Value) (int64, bool) {
+	if v == nil || v.Err() {
+		return 0, false
+	}
+	if nv, ok := v.(NumericValue); ok {
+		return nv.Int(), true
+	}
+	return 0, false
+}
+
+func typeName(v Value) string {
+	if v == nil {
+		return "nil"
+	}
+	return v.Type().String()
+}
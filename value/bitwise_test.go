@@ -0,0 +1,46 @@
+package value
+
+import (
+	"testing"
+
+	"github.com/bmizerany/assert"
+)
+
+func TestBitwise(t *testing.T) {
+	flags := NewIntValue(13) // 1101
+	tests := []struct {
+		name   string
+		result Value
+		expect int64
+	}{
+		{"and", And(flags, NewIntValue(4)), 4},
+		{"and mask miss", And(flags, NewIntValue(2)), 0},
+		{"or", Or(flags, NewIntValue(2)), 15},
+		{"xor", Xor(flags, NewIntValue(5)), 8},
+		{"shl", Shl(NewIntValue(1), NewIntValue(4)), 16},
+		{"shr", Shr(flags, NewIntValue(2)), 3},
+		{"shr negative", Shr(NewIntValue(-8), NewIntValue(1)), -4},
+		{"float coerce", And(NewNumberValue(13.9), NewIntValue(4)), 4},
+	}
+	for _, test := range tests {
+		iv, ok := test.result.(IntValue)
+		assert.Tf(t, ok, "%s: should be IntValue %T %v", test.name, test.result, test.result)
+		assert.Tf(t, iv.Val() == test.expect, "%s: expected %d got %v", test.name, test.expect, iv.Val())
+	}
+
+	// mask is set
+	assert.T(t, And(flags, NewIntValue(8)).(IntValue).Val() == 8)
+
+	errs := []Value{
+		Shl(flags, NewIntValue(-1)),
+		Shr(flags, NewIntValue(-1)),
+		And(NewStringValue("abc"), flags),
+		Or(flags, NewStringsValue([]string{"a"})),
+		Xor(nil, flags),
+		Or(NewBoolValue(true), flags),
+		And(NewErrorValue("bad"), flags),
+	}
+	for i, ev := range errs {
+		assert.Tf(t, ev.Err(), "%d: should be error %v", i, ev)
+	}
+}
@@ -0,0 +1,199 @@
+package value
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cast converts a value to the target type with sql CAST(x AS type)
+//  semantics, returning an ErrorValue if it cannot be converted.  It is
+//  stricter than coercion, only scalar values cast, and strings must
+//  parse completely (after trimming whitespace):
+//
+//     int      numbers truncate towards zero, NaN, Inf and out of
+//              range numbers are errors, bools are 1/0
+//     number   ints and numeric strings
+//     string   any scalar, times are RFC3339
+//     bool     1/0 numbers and true/false strings
+//     time     date strings, and numbers as unix epoch seconds or
+//              milliseconds, with the same rules as ToTime()
+//
+// Nil casts to nil (NULL), and error values are returned as is.
+func Cast(v Value, target ValueType) Value {
+	return cast(v, target, false)
+}
+
+// CastStrict is Cast() for data quality sensitive uses, where a cast
+//  that would lose information is an ErrorValue instead of silently
+//  truncating:
+//
+//     CastStrict(NumberValue(3.0), IntType)   =>  3
+//     CastStrict(NumberValue(3.7), IntType)   =>  error
+//     CastStrict(StringValue("3.7"), IntType) =>  error
+//
+func CastStrict(v Value, target ValueType) Value {
+	return cast(v, target, true)
+}
+
+func cast(v Value, target ValueType, strict bool) Value {
+	if v == nil || v.Type() == NilType {
+		return NilValueVal
+	}
+	if v.Err() || v.Type() == target {
+		return v
+	}
+	switch target {
+	case IntType:
+		return castInt(v, strict)
+	case NumberType:
+		return castNumber(v)
+	case StringType:
+		return castString(v)
+	case BoolType:
+		return castBool(v)
+	case TimeType:
+		return castTime(v)
+	}
+	return NewErrorValuef("cannot cast %s to %s", v.Type(), target)
+}
+
+// CastType is the ValueType of a sql CAST(x AS type) type name, case
+//  insensitive, false if it is not a type that Cast() converts to
+//
+//     CastType("INTEGER")  =>  IntType
+//     CastType("varchar")  =>  StringType
+//
+func CastType(name string) (ValueType, bool) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "int", "integer", "bigint", "smallint", "tinyint":
+		return IntType, true
+	case "number", "float", "double", "real", "decimal", "numeric":
+		return NumberType, true
+	case "string", "varchar", "char", "text":
+		return StringType, true
+	case "bool", "boolean":
+		return BoolType, true
+	case "time", "date", "datetime", "timestamp":
+		return TimeType, true
+	}
+	return NilType, false
+}
+
+// ForceString keeps a column a string when written out, for strings of
+//  digits that must not become numbers (zip codes, ids with leading
+//  zeros).  Strings are returned as is, so never re-parsed, other values
+//  are cast to string, and nil stays nil.  It has the signature of a
+//  projection column transform.
+//
+//     ForceString(StringValue("00123"))  =>  "00123"
+//     ForceString(IntValue(42))          =>  "42"
+//
+func ForceString(v Value) Value {
+	if v == nil || v.Type() == NilType {
+		return NilValueVal
+	}
+	sv := Cast(v, StringType)
+	if sv.Err() && !v.Err() {
+		return NewStringValue(v.ToString())
+	}
+	return sv
+}
+
+func castInt(v Value, strict bool) Value {
+	switch vt := v.(type) {
+	case NumberValue:
+		return floatToInt(vt.Val(), strict)
+	case BoolValue:
+		if vt.Val() {
+			return NewIntValue(1)
+		}
+		return NewIntValue(0)
+	case StringValue:
+		s := strings.TrimSpace(vt.Val())
+		if iv, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return NewIntValue(iv)
+		}
+		if fv, err := strconv.ParseFloat(s, 64); err == nil {
+			return floatToInt(fv, strict)
+		}
+	case NumericValue:
+		// time, duration
+		return NewIntValue(vt.Int())
+	}
+	return NewErrorValuef("cannot cast %q to int", v.ToString())
+}
+
+func floatToInt(fv float64, strict bool) Value {
+	if math.IsNaN(fv) || fv >= math.MaxInt64 || fv < math.MinInt64 {
+		return NewErrorValuef("cannot cast %v to int, out of range", fv)
+	}
+	if strict && fv != math.Trunc(fv) {
+		return NewErrorValuef("cannot cast %v to int, loses fractional part", fv)
+	}
+	return NewIntValue(int64(fv))
+}
+
+func castNumber(v Value) Value {
+	switch vt := v.(type) {
+	case IntValue:
+		return NewNumberValue(vt.Float())
+	case BoolValue:
+		if vt.Val() {
+			return NewNumberValue(1)
+		}
+		return NewNumberValue(0)
+	case StringValue:
+		if fv, err := strconv.ParseFloat(strings.TrimSpace(vt.Val()), 64); err == nil {
+			return NewNumberValue(fv)
+		}
+	case NumericValue:
+		return NewNumberValue(vt.Float())
+	}
+	return NewErrorValuef("cannot cast %q to number", v.ToString())
+}
+
+func castString(v Value) Value {
+	switch vt := v.(type) {
+	case TimeValue:
+		return NewStringValue(vt.Val().Format(time.RFC3339Nano))
+	case IntValue, NumberValue, BoolValue, ByteSliceValue, DurationValue:
+		return NewStringValue(vt.ToString())
+	}
+	return NewErrorValuef("cannot cast %s to string", v.Type())
+}
+
+func castBool(v Value) Value {
+	switch vt := v.(type) {
+	case IntValue:
+		switch vt.Val() {
+		case 0:
+			return BoolValueFalse
+		case 1:
+			return BoolValueTrue
+		}
+	case NumberValue:
+		switch vt.Val() {
+		case 0:
+			return BoolValueFalse
+		case 1:
+			return BoolValueTrue
+		}
+	case StringValue:
+		if bv, err := strconv.ParseBool(strings.TrimSpace(vt.Val())); err == nil {
+			return NewBoolValue(bv)
+		}
+	}
+	return NewErrorValuef("cannot cast %q to bool", v.ToString())
+}
+
+func castTime(v Value) Value {
+	switch v.(type) {
+	case IntValue, NumberValue, StringValue:
+		if t, ok := ToTime(v); ok {
+			return NewTimeValue(t)
+		}
+	}
+	return NewErrorValuef("cannot cast %q to time", v.ToString())
+}
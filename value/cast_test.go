@@ -0,0 +1,127 @@
+package value
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+)
+
+func TestCast(t *testing.T) {
+	ts := time.Date(2015, 7, 4, 1, 2, 3, 0, time.UTC)
+	tests := []struct {
+		v      Value
+		target ValueType
+		expect interface{}
+	}{
+		{NewIntValue(5), IntType, int64(5)},
+		{NewNumberValue(5.9), IntType, int64(5)},
+		{NewNumberValue(-5.9), IntType, int64(-5)},
+		{NewStringValue(" 42 "), IntType, int64(42)},
+		{NewStringValue("4.7"), IntType, int64(4)},
+		{BoolValueTrue, IntType, int64(1)},
+		{NewIntValue(5), NumberType, float64(5)},
+		{NewStringValue("1.5"), NumberType, float64(1.5)},
+		{NewIntValue(5), StringType, "5"},
+		{NewNumberValue(1.5), StringType, "1.5"},
+		{BoolValueFalse, StringType, "false"},
+		{NewTimeValue(ts), StringType, "2015-07-04T01:02:03Z"},
+		{NewIntValue(1), BoolType, true},
+		{NewNumberValue(0), BoolType, false},
+		{NewStringValue("true"), BoolType, true},
+		{NewStringValue("0"), BoolType, false},
+		{NewStringValue("2015-07-04T01:02:03Z"), TimeType, ts},
+		{NewIntValue(ts.UnixNano() / 1e6), TimeType, ts},
+		// same epoch rules as ToTime, seconds or milliseconds by magnitude
+		{NewIntValue(ts.Unix()), TimeType, ts},
+		{NewNumberValue(float64(ts.Unix())), TimeType, ts},
+	}
+	for _, test := range tests {
+		v := Cast(test.v, test.target)
+		assert.Tf(t, !v.Err(), "cast %v to %s error %v", test.v, test.target, v)
+		assert.Tf(t, v.Type() == test.target, "cast %v to %s got %s", test.v, test.target, v.Type())
+		if tv, ok := v.(TimeValue); ok {
+			assert.Tf(t, tv.Val().Equal(test.expect.(time.Time)), "cast %v to time got %v", test.v, tv.Val())
+			continue
+		}
+		assert.Tf(t, v.Value() == test.expect, "cast %v to %s expected %v got %v", test.v, test.target, test.expect, v.Value())
+	}
+
+	// stricter than coercion, these are errors not zero values
+	for _, bad := range []struct {
+		v      Value
+		target ValueType
+	}{
+		{NewStringValue("abc"), IntType},
+		{NewStringValue("12abc"), IntType},
+		{NewStringValue(""), IntType},
+		{NewNumberValue(math.NaN()), IntType},
+		{NewNumberValue(math.Inf(1)), IntType},
+		{NewNumberValue(1e20), IntType},
+		{NewStringValue("abc"), NumberType},
+		{NewIntValue(2), BoolType},
+		{NewStringValue("yes"), BoolType},
+		{NewStringValue("not a date"), TimeType},
+		{NewNumberValue(math.NaN()), TimeType},
+		{BoolValueTrue, TimeType},
+		{NewStringsValue([]string{"a"}), StringType},
+		{NewIntValue(1), MapIntType},
+	} {
+		v := Cast(bad.v, bad.target)
+		assert.Tf(t, v.Err(), "cast %v to %s should error but got %v", bad.v, bad.target, v)
+	}
+
+	// NULL stays NULL
+	assert.T(t, Cast(NilValueVal, IntType).Type() == NilType)
+	assert.T(t, Cast(nil, StringType).Type() == NilType)
+}
+
+func TestCastType(t *testing.T) {
+	for name, vt := range map[string]ValueType{
+		"INTEGER": IntType, "bigint": IntType, "float": NumberType, "VARCHAR": StringType,
+		"boolean": BoolType, "timestamp": TimeType, " date ": TimeType,
+	} {
+		got, ok := CastType(name)
+		assert.Tf(t, ok && got == vt, "%q expected %s got %s", name, vt, got)
+	}
+	_, ok := CastType("blob")
+	assert.T(t, !ok)
+}
+
+func TestCastStrict(t *testing.T) {
+	v := CastStrict(NewNumberValue(3.0), IntType)
+	assert.Tf(t, !v.Err() && v.Value() == int64(3), "exact conversion %v", v)
+	v = CastStrict(NewStringValue("-4.0"), IntType)
+	assert.Tf(t, !v.Err() && v.Value() == int64(-4), "exact conversion %v", v)
+
+	for _, lossy := range []Value{NewNumberValue(3.7), NewNumberValue(-0.5), NewStringValue("3.7")} {
+		v = CastStrict(lossy, IntType)
+		assert.Tf(t, v.Err(), "lossy cast of %v should error but got %v", lossy, v)
+		assert.Tf(t, Cast(lossy, IntType).Type() == IntType, "not strict truncates %v", lossy)
+	}
+
+	// other casts are the same as Cast
+	assert.T(t, CastStrict(NewNumberValue(1.5), StringType).Value() == "1.5")
+	assert.T(t, CastStrict(NilValueVal, IntType).Type() == NilType)
+}
+
+func TestForceString(t *testing.T) {
+	zip := NewStringValue("00123")
+	assert.T(t, ForceString(zip) == zip)
+	assert.T(t, ForceString(NewIntValue(42)).Value() == "42")
+	assert.T(t, ForceString(NewBoolValue(true)).Value() == "true")
+	assert.T(t, ForceString(NewStringsValue([]string{"a", "b"})).Type() == StringType)
+	assert.T(t, ForceString(nil).Type() == NilType)
+
+	// digit strings are never marshaled as numbers
+	row := map[string]Value{
+		"zip":  ForceString(zip),
+		"id":   ForceString(NewIntValue(7)),
+		"code": NewValue("0042"),
+	}
+	by, err := json.Marshal(row)
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Tf(t, string(by) == `{"code":"0042","id":"7","zip":"00123"}`, "%s", by)
+}
@@ -7,7 +7,9 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/araddon/dateparse"
 	u "github.com/araddon/gou"
 )
 
@@ -276,6 +278,91 @@ func ToString(v reflect.Value) (string, bool) {
 	return fmt.Sprint(v.Interface()), true
 }
 
+// ParseTime attempts to parse a string date in any of the known formats
+//  (see dateparse), returns false if it is not a date
+func ParseTime(s string) (time.Time, bool) {
+	if s == "" {
+		return time.Time{}, false
+	}
+	t, err := dateparse.ParseAny(s)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// ParseTimeIn is ParseTime() for strings without a zone or offset being in
+//  loc instead of UTC, strings with a zone or offset keep it
+//
+//     ParseTimeIn("2015-07-04 12:00:00", denver)        => 12:00 MDT
+//     ParseTimeIn("2015-07-04T12:00:00Z", denver)       => 12:00 UTC
+func ParseTimeIn(s string, loc *time.Location) (time.Time, bool) {
+	if s == "" {
+		return time.Time{}, false
+	}
+	t, err := dateparse.ParseIn(s, loc)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// EpochUnit is the unit of numeric epoch values converted by ToTimeEpoch()
+type EpochUnit int
+
+const (
+	// EpochAuto guesses seconds or milliseconds from the magnitude, values
+	//  under 1e11 (in seconds, the year 5138) are seconds
+	EpochAuto EpochUnit = iota
+	EpochSeconds
+	EpochMillis
+)
+
+// epochMillisMin is the magnitude at which EpochAuto treats a value as
+//  milliseconds, 1e11 ms is early 1973
+const epochMillisMin = 1e11
+
+// ToTime converts a value to time, TimeValue as is, numbers as unix
+//  epoch seconds or milliseconds (see EpochAuto) and strings parseable
+//  as a date.  Nil and error values are false.
+func ToTime(v Value) (time.Time, bool) {
+	return ToTimeEpoch(v, EpochAuto)
+}
+
+// ToTimeEpoch is ToTime() with the unit of numeric epoch values forced
+func ToTimeEpoch(v Value, unit EpochUnit) (time.Time, bool) {
+	if v == nil || v.Err() {
+		return time.Time{}, false
+	}
+	switch vt := v.(type) {
+	case TimeValue:
+		return vt.Val(), true
+	case IntValue:
+		i := vt.Val()
+		if unit == EpochMillis || (unit == EpochAuto && (i >= epochMillisMin || i <= -epochMillisMin)) {
+			return time.Unix(i/1e3, (i%1e3)*1e6).UTC(), true
+		}
+		return time.Unix(i, 0).UTC(), true
+	case NumberValue:
+		f := vt.Val()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return time.Time{}, false
+		}
+		return epochTime(f, unit), true
+	case StringValue:
+		return ParseTime(strings.TrimSpace(vt.Val()))
+	}
+	return time.Time{}, false
+}
+
+func epochTime(f float64, unit EpochUnit) time.Time {
+	if unit == EpochMillis || (unit == EpochAuto && math.Abs(f) >= epochMillisMin) {
+		f = f / 1e3
+	}
+	sec, frac := math.Modf(f)
+	return time.Unix(int64(sec), int64(frac*1e9)).UTC()
+}
+
 func ToStringUnchecked(v reflect.Value) string {
 	if v.Kind() == reflect.Interface {
 		v = v.Elem()
@@ -4,7 +4,9 @@ import (
 	u "github.com/araddon/gou"
 	"github.com/bmizerany/assert"
 	//"reflect"
+	"math"
 	"testing"
+	"time"
 )
 
 var _ = u.EMPTY
@@ -80,3 +82,55 @@ func TestCoerceNumbers(t *testing.T) {
 		assert.Tf(t, CloseEnuf(floatVal, cv.f), "should be == expect %v but was: %v", cv.f, floatVal)
 	}
 }
+
+func TestToTime(t *testing.T) {
+	ts := time.Date(2015, 7, 4, 1, 2, 3, 0, time.UTC)
+	tsMs := ts.Add(456 * time.Millisecond)
+	tests := []struct {
+		v      Value
+		expect time.Time
+	}{
+		{NewIntValue(ts.Unix()), ts},
+		{NewIntValue(tsMs.UnixNano() / 1e6), tsMs},
+		{NewNumberValue(float64(ts.Unix())), ts},
+		{NewNumberValue(float64(ts.Unix()) + 0.5), ts.Add(500 * time.Millisecond)},
+		{NewStringValue("2015-07-04T01:02:03Z"), ts},
+		{NewTimeValue(ts), ts},
+	}
+	for _, test := range tests {
+		tm, ok := ToTime(test.v)
+		assert.Tf(t, ok, "should convert %v", test.v)
+		assert.Tf(t, tm.Equal(test.expect), "%v expected %v got %v", test.v, test.expect, tm)
+	}
+
+	// forced units, a small value would otherwise be seconds
+	tm, ok := ToTimeEpoch(NewIntValue(1500), EpochMillis)
+	assert.Tf(t, ok && tm.Equal(time.Unix(1, 5e8)), "%v", tm)
+	tm, ok = ToTimeEpoch(NewIntValue(tsMs.UnixNano()/1e6), EpochSeconds)
+	assert.Tf(t, ok && tm.Unix() == tsMs.UnixNano()/1e6, "%v", tm)
+
+	for _, bad := range []Value{nil, NilValueVal, NewStringValue("not a date"), NewNumberValue(math.NaN()), BoolValueTrue, NewErrorValuef("bad")} {
+		_, ok := ToTime(bad)
+		assert.Tf(t, !ok, "should not convert %v", bad)
+	}
+}
+
+func TestTimeZones(t *testing.T) {
+	denver, err := time.LoadLocation("America/Denver")
+	assert.Tf(t, err == nil, "%v", err)
+
+	utc := NewTimeValue(time.Date(2015, 7, 4, 3, 0, 0, 0, time.UTC))
+	local := utc.InZone(denver)
+	assert.Tf(t, local.Int() == utc.Int(), "same instant %v %v", local.Int(), utc.Int())
+	assert.Tf(t, local.Val().Day() == 3 && local.Val().Hour() == 21, "calendar fields in zone %v", local.Val())
+	assert.T(t, local.InZone(time.UTC).Val().Equal(utc.Val()))
+
+	tm, ok := ParseTimeIn("2015-07-04 12:00:00", denver)
+	assert.Tf(t, ok && tm.Equal(time.Date(2015, 7, 4, 18, 0, 0, 0, time.UTC)), "no zone is in loc %v", tm)
+	tm, ok = ParseTimeIn("2015-07-04T12:00:00Z", denver)
+	assert.Tf(t, ok && tm.Equal(time.Date(2015, 7, 4, 12, 0, 0, 0, time.UTC)), "explicit zone kept %v", tm)
+	tm, ok = ParseTimeIn("2015-07-04T12:00:00+01:00", denver)
+	assert.Tf(t, ok && tm.Equal(time.Date(2015, 7, 4, 11, 0, 0, 0, time.UTC)), "explicit offset kept %v", tm)
+	_, ok = ParseTimeIn("not a date", denver)
+	assert.T(t, !ok)
+}
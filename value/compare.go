@@ -0,0 +1,192 @@
+package value
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Compare two values returning -1, 0, 1 for a < b, a == b, a > b, used for
+//  ORDER BY and ordered aggregates.  Numbers compare numerically, times
+//  compare as times, structs as StructValue.Compare(), otherwise the
+//  string values are compared.
+//
+// NaN policy:  NaN is greater than every number and equal to NaN, so a
+//  column with NaN sorts consistently with NaN last in ascending order
+//  (first in descending).  Nil (and error) values are greater still, so
+//  ascending order is:
+//
+//     numbers, NaN, nil
+//
+// MIN/MAX aggregates ignore NaN (and nil), only returning NaN if there
+//  are no other values.
+func Compare(a, b Value) int {
+	an, bn := compareNil(a), compareNil(b)
+	switch {
+	case an && bn:
+		return 0
+	case an:
+		return 1
+	case bn:
+		return -1
+	}
+	if sa, sb, ok := ScalarArgs(a, b); ok {
+		a, b = sa, sb
+	}
+	if as, ok := a.(StructValue); ok {
+		if bs, ok := b.(StructValue); ok {
+			return as.Compare(bs)
+		}
+	}
+	if af, ok := compareFloat(a); ok {
+		if bf, ok := compareFloat(b); ok {
+			return CompareFloat(af, bf)
+		}
+	}
+	if at, ok := a.(TimeValue); ok {
+		if bt, ok := b.(TimeValue); ok {
+			switch {
+			case at.Val().Before(bt.Val()):
+				return -1
+			case at.Val().After(bt.Val()):
+				return 1
+			}
+			return 0
+		}
+	}
+	return strings.Compare(a.ToString(), b.ToString())
+}
+
+// CompareNumericAware is Compare() but with strings that parse as numbers
+//  compared numerically, for numeric data that arrives as strings:
+//
+//     Compare("100", "9")               =>  -1   lexical
+//     CompareNumericAware("100", "9")   =>   1
+//     CompareNumericAware("100", 9)     =>   1
+//
+//  If either is not a number, ie "abc", the values are compared as
+//  Compare() does.
+func CompareNumericAware(a, b Value) int {
+	if af, ok := NumericString(a); ok {
+		if bf, ok := NumericString(b); ok {
+			return CompareFloat(af, bf)
+		}
+	}
+	return Compare(a, b)
+}
+
+// NumericString is the float of a numeric value, or of a StringValue that
+//  parses as a finite number, see CompareNumericAware()
+func NumericString(v Value) (float64, bool) {
+	if sv, ok := v.(StringValue); ok {
+		f, err := strconv.ParseFloat(strings.TrimSpace(sv.Val()), 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return 0, false
+		}
+		return f, true
+	}
+	if v == nil {
+		return 0, false
+	}
+	return compareFloat(v)
+}
+
+// CompareFloat compares two floats returning -1, 0, 1, NaN is greater than
+//  all numbers and equal to NaN, see Compare()
+func CompareFloat(a, b float64) int {
+	aNaN, bNaN := math.IsNaN(a), math.IsNaN(b)
+	switch {
+	case aNaN && bNaN:
+		return 0
+	case aNaN:
+		return 1
+	case bNaN:
+		return -1
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// ScalarArgs coerces a single element StringsValue compared to a scalar
+//  (ie a source that returns a value as a 1 length array) to its element,
+//  of the scalar's type if it parses as one, so IntValue(5) compares equal
+//  to StringsValue{"5"}.  ok is false for a multi element (or empty) array
+//  compared to a scalar as it has no scalar value.  Two arrays, or args
+//  that are not arrays, are returned as is.
+func ScalarArgs(a, b Value) (Value, Value, bool) {
+	as, aok := a.(StringsValue)
+	bs, bok := b.(StringsValue)
+	switch {
+	case aok == bok, aok && compareNil(b), bok && compareNil(a):
+		return a, b, true
+	case aok:
+		if as.Len() != 1 {
+			return a, b, false
+		}
+		return scalarLike(as.Val()[0], b), b, true
+	}
+	if bs.Len() != 1 {
+		return a, b, false
+	}
+	return a, scalarLike(bs.Val()[0], a), true
+}
+
+// the string element of an array as a value of the type of like
+func scalarLike(s string, like Value) Value {
+	switch like.(type) {
+	case IntValue:
+		if iv, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return NewIntValue(iv)
+		}
+		if fv, err := strconv.ParseFloat(s, 64); err == nil {
+			return NewNumberValue(fv)
+		}
+	case NumberValue:
+		if fv, err := strconv.ParseFloat(s, 64); err == nil {
+			return NewNumberValue(fv)
+		}
+	}
+	return NewStringValue(s)
+}
+
+// NullIf is sql NULLIF(a, b), NULL if a equals b (see Compare()) else a.
+//  NULL is not equal to anything, and an empty string is not NULL, so
+//  NullIf("", "") is NULL but NullIf("", NULL) is "".
+func NullIf(a, b Value) Value {
+	if IsNull(a) {
+		return NilValueVal
+	}
+	if !IsNull(b) && Compare(a, b) == 0 {
+		return NilValueVal
+	}
+	return a
+}
+
+// IfNull is sql IFNULL(a, b), b if a is NULL else a.  Unlike Value.Nil()
+//  an empty string or 0 is not NULL, see IsNull().
+func IfNull(a, b Value) Value {
+	if !IsNull(a) {
+		return a
+	}
+	if b == nil {
+		return NilValueVal
+	}
+	return b
+}
+
+func compareNil(v Value) bool {
+	return v == nil || v.Err() || v.Type() == NilType
+}
+
+func compareFloat(v Value) (float64, bool) {
+	switch vt := v.(type) {
+	case TimeValue:
+		return 0, false
+	case NumericValue:
+		return vt.Float(), true
+	}
+	return 0, false
+}
@@ -0,0 +1,120 @@
+package value
+
+import (
+	"math"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+)
+
+func TestCompare(t *testing.T) {
+	nan := NewNumberValue(math.NaN())
+	assert.T(t, Compare(NewIntValue(1), NewNumberValue(2.5)) < 0)
+	assert.T(t, Compare(NewNumberValue(2.5), NewIntValue(1)) > 0)
+	assert.T(t, Compare(NewIntValue(2), NewNumberValue(2)) == 0)
+	assert.T(t, Compare(nan, NewNumberValue(math.Inf(1))) > 0)
+	assert.T(t, Compare(NewNumberValue(math.Inf(-1)), nan) < 0)
+	assert.T(t, Compare(nan, nan) == 0)
+	assert.T(t, Compare(NilValueVal, nan) > 0)
+	assert.T(t, Compare(nan, nil) < 0)
+	assert.T(t, Compare(NilValueVal, nil) == 0)
+	assert.T(t, Compare(NewStringValue("a"), NewStringValue("b")) < 0)
+
+	t1 := NewTimeValue(time.Date(2015, 7, 4, 1, 0, 0, 0, time.UTC))
+	t2 := NewTimeValue(time.Date(2015, 7, 4, 2, 0, 0, 0, time.UTC))
+	assert.T(t, Compare(t1, t2) < 0)
+	assert.T(t, Compare(t2, t1) > 0)
+
+	assert.T(t, CompareFloat(1, 2) < 0)
+	assert.T(t, CompareFloat(math.NaN(), 2) > 0)
+	assert.T(t, CompareFloat(math.NaN(), math.NaN()) == 0)
+}
+
+func TestCompareSortNaN(t *testing.T) {
+	vals := []Value{
+		NewNumberValue(3),
+		NewNumberValue(math.NaN()),
+		NilValueVal,
+		NewNumberValue(-1),
+		NewNumberValue(math.NaN()),
+		NewIntValue(2),
+	}
+	sort.SliceStable(vals, func(i, j int) bool { return Compare(vals[i], vals[j]) < 0 })
+	assert.Tf(t, vals[0].Value() == float64(-1), "%v", vals)
+	assert.Tf(t, vals[1].Value() == int64(2), "%v", vals)
+	assert.Tf(t, vals[2].Value() == float64(3), "%v", vals)
+	assert.Tf(t, math.IsNaN(vals[3].(NumberValue).Float()), "NaN sorts after numbers %v", vals)
+	assert.Tf(t, math.IsNaN(vals[4].(NumberValue).Float()), "NaN sorts after numbers %v", vals)
+	assert.Tf(t, vals[5].Type() == NilType, "nil sorts last %v", vals)
+
+	// descending is the reverse, NaN first
+	sort.SliceStable(vals, func(i, j int) bool { return Compare(vals[i], vals[j]) > 0 })
+	assert.Tf(t, vals[0].Type() == NilType, "%v", vals)
+	assert.Tf(t, math.IsNaN(vals[1].(NumberValue).Float()), "%v", vals)
+	assert.Tf(t, vals[5].Value() == float64(-1), "%v", vals)
+}
+
+func TestCompareNumericAware(t *testing.T) {
+	s := NewStringValue
+	// lexical "100" < "9"
+	assert.T(t, Compare(s("100"), s("9")) < 0)
+	assert.T(t, CompareNumericAware(s("100"), s("9")) > 0)
+	assert.T(t, Compare(s("20"), s("100")) > 0)
+	assert.T(t, CompareNumericAware(s("20"), s("100")) < 0)
+	assert.T(t, CompareNumericAware(s(" 1.5"), s("1.50")) == 0)
+	assert.T(t, CompareNumericAware(s("100"), NewIntValue(9)) > 0)
+	assert.T(t, CompareNumericAware(NewNumberValue(-1), s("-2")) > 0)
+	// not both numbers is lexical
+	assert.T(t, CompareNumericAware(s("100"), s("abc")) < 0)
+	assert.T(t, CompareNumericAware(s("NaN"), s("9")) > 0)
+	assert.T(t, CompareNumericAware(s("b"), s("a")) > 0)
+	assert.T(t, CompareNumericAware(NilValueVal, s("9")) > 0)
+
+	_, ok := NumericString(s("inf"))
+	assert.T(t, !ok)
+}
+
+func TestScalarArgs(t *testing.T) {
+	one, many := NewStringsValue([]string{"5"}), NewStringsValue([]string{"5", "6"})
+
+	a, b, ok := ScalarArgs(NewIntValue(5), one)
+	assert.Tf(t, ok && a.Value() == int64(5) && b.Value() == int64(5), "single element is its element %v %v", a, b)
+	a, b, ok = ScalarArgs(one, NewNumberValue(4.5))
+	assert.Tf(t, ok && a.Value() == float64(5) && b.Value() == float64(4.5), "%T %v %v", a, a, b)
+	a, _, ok = ScalarArgs(NewStringsValue([]string{"abc"}), NewIntValue(5))
+	assert.Tf(t, ok && a.Value() == "abc", "not a number is a string %T %v", a, a)
+
+	_, _, ok = ScalarArgs(NewIntValue(5), many)
+	assert.Tf(t, !ok, "multi element has no scalar")
+	_, _, ok = ScalarArgs(NewStringsValue(nil), NewStringValue(""))
+	assert.Tf(t, !ok, "empty has no scalar")
+	_, _, ok = ScalarArgs(one, many)
+	assert.Tf(t, ok, "two arrays are as is")
+	_, _, ok = ScalarArgs(many, NilValueVal)
+	assert.Tf(t, ok, "nil is as is")
+
+	assert.T(t, Compare(NewIntValue(5), one) == 0)
+	assert.T(t, Compare(NewIntValue(10), one) > 0)
+	assert.T(t, Compare(one, NewNumberValue(10)) < 0)
+}
+
+func TestNullIfIfNull(t *testing.T) {
+	empty := NewStringValue("")
+	assert.T(t, NullIf(NewIntValue(0), NewIntValue(0)).Type() == NilType)
+	assert.T(t, NullIf(NewIntValue(1), NewIntValue(0)).Value() == int64(1))
+	assert.T(t, NullIf(NewIntValue(5), NewNumberValue(5)).Type() == NilType)
+	assert.T(t, NullIf(NilValueVal, NewIntValue(0)).Type() == NilType)
+	assert.T(t, NullIf(nil, nil).Type() == NilType)
+	// empty string is a value, not NULL
+	assert.T(t, NullIf(empty, empty).Type() == NilType)
+	assert.Tf(t, NullIf(empty, NilValueVal).Type() == StringType, "empty string is not equal to NULL")
+	assert.T(t, NullIf(empty, NewStringValue("a")).Type() == StringType)
+
+	assert.T(t, IfNull(NilValueVal, NewIntValue(1)).Value() == int64(1))
+	assert.T(t, IfNull(nil, NewIntValue(1)).Value() == int64(1))
+	assert.T(t, IfNull(NewIntValue(0), NewIntValue(1)).Value() == int64(0))
+	assert.Tf(t, IfNull(empty, NewStringValue("a")).Value() == "", "empty string is not NULL")
+	assert.T(t, IfNull(nil, nil).Type() == NilType)
+}
@@ -0,0 +1,82 @@
+package value
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Debug is a readable type(value) representation of a value for logging,
+//  instead of %#v which dumps the reflect.Value internals
+//
+//     int(5)
+//     string("hi")
+//     []string["a","b"]
+//     []value[int(1), string("a")]
+//
+func Debug(v Value) string {
+	if v == nil {
+		return "nil"
+	}
+	switch vt := v.(type) {
+	case NilValue:
+		return "nil"
+	case StringValue:
+		return "string(" + strconv.Quote(vt.Val()) + ")"
+	case ErrorValue:
+		return "error(" + strconv.Quote(vt.ToString()) + ")"
+	case IntValue:
+		return "int(" + strconv.FormatInt(vt.Val(), 10) + ")"
+	case NumberValue:
+		return "number(" + strconv.FormatFloat(vt.Val(), 'g', -1, 64) + ")"
+	case BoolValue:
+		return "bool(" + strconv.FormatBool(vt.Val()) + ")"
+	case TimeValue:
+		return "time(" + vt.Val().Format(time.RFC3339Nano) + ")"
+	case DurationValue:
+		return "duration(" + vt.ToString() + ")"
+	case ByteSliceValue:
+		return "[]byte(" + strconv.Quote(string(vt.Val())) + ")"
+	case StringsValue:
+		strs := make([]string, len(vt.Val()))
+		for i, s := range vt.Val() {
+			strs[i] = strconv.Quote(s)
+		}
+		return "[]string[" + strings.Join(strs, ",") + "]"
+	case SliceValue:
+		vals := make([]string, len(vt.Val()))
+		for i, sv := range vt.Val() {
+			vals[i] = Debug(sv)
+		}
+		return "[]value[" + strings.Join(vals, ", ") + "]"
+	case MapValue:
+		return "map[string]value" + DebugRow(vt.Val())
+	case StructValue:
+		return fmt.Sprintf("struct(%+v)", vt.Value())
+	}
+	// maps of primitives, and custom types
+	if by, err := json.Marshal(v.Value()); err == nil {
+		return v.Type().String() + string(by)
+	}
+	return v.Type().String() + "(" + v.ToString() + ")"
+}
+
+// DebugRow is Debug for a row of values, sorted by key
+//
+//     {email:string("aaron@email.com"), id:int(5)}
+//
+func DebugRow(row map[string]Value) string {
+	keys := make([]string, 0, len(row))
+	for k := range row {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	vals := make([]string, len(keys))
+	for i, k := range keys {
+		vals[i] = k + ":" + Debug(row[k])
+	}
+	return "{" + strings.Join(vals, ", ") + "}"
+}
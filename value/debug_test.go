@@ -0,0 +1,35 @@
+package value
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+)
+
+var debugTests = []struct {
+	v      Value
+	expect string
+}{
+	{nil, `nil`},
+	{NilValueVal, `nil`},
+	{NewIntValue(5), `int(5)`},
+	{NewNumberValue(2.5), `number(2.5)`},
+	{NewStringValue("hi"), `string("hi")`},
+	{NewBoolValue(true), `bool(true)`},
+	{NewErrorValue("bad"), `error("bad")`},
+	{NewStringsValue([]string{"a", "b"}), `[]string["a","b"]`},
+	{NewByteSliceValue([]byte("by")), `[]byte("by")`},
+	{NewTimeValue(time.Date(2015, 7, 4, 12, 0, 0, 0, time.UTC)), `time(2015-07-04T12:00:00Z)`},
+	{NewDurationValue(90 * time.Minute), `duration(1h30m)`},
+	{NewSliceValues([]Value{NewIntValue(1), NewStringValue("a")}), `[]value[int(1), string("a")]`},
+	{NewMapIntValue(map[string]int64{"a": 1}), `map[string]int{"a":1}`},
+	{NewMapValue(map[string]interface{}{"b": "x", "a": 1}), `map[string]value{a:int(1), b:string("x")}`},
+}
+
+func TestDebug(t *testing.T) {
+	for _, dt := range debugTests {
+		out := Debug(dt.v)
+		assert.Tf(t, out == dt.expect, "expected %s got %s", dt.expect, out)
+	}
+}
@@ -0,0 +1,88 @@
+package value
+
+import (
+	"encoding/json"
+	"reflect"
+	"sync"
+)
+
+var (
+	// Ensure dictionary strings are values
+	_ Value = (*DictStringValue)(nil)
+)
+
+// Dictionary is a shared table of distinct strings, for dictionary encoding
+//  low cardinality string columns (status codes, countries) where many rows
+//  hold the same few values.  Safe for concurrent use.
+type Dictionary struct {
+	mu   sync.RWMutex
+	ids  map[string]int
+	vals []string
+}
+
+func NewDictionary() *Dictionary {
+	return &Dictionary{ids: make(map[string]int)}
+}
+
+// Encode returns the DictStringValue for s, adding it to the dictionary
+//  if not already present
+func (m *Dictionary) Encode(s string) DictStringValue {
+	m.mu.RLock()
+	id, ok := m.ids[s]
+	m.mu.RUnlock()
+	if ok {
+		return DictStringValue{dict: m, id: id}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if id, ok = m.ids[s]; !ok {
+		id = len(m.vals)
+		m.vals = append(m.vals, s)
+		m.ids[s] = id
+	}
+	return DictStringValue{dict: m, id: id}
+}
+
+// Decode returns the string for id, false if id is not in the dictionary
+func (m *Dictionary) Decode(id int) (string, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if id < 0 || id >= len(m.vals) {
+		return "", false
+	}
+	return m.vals[id], true
+}
+
+// Len is the number of distinct strings
+func (m *Dictionary) Len() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.vals)
+}
+
+// DictStringValue is a string stored as an id into a shared Dictionary, it
+//  is a StringType so is transparent to the vm, see StringValue()
+type DictStringValue struct {
+	dict *Dictionary
+	id   int
+}
+
+func (m DictStringValue) Nil() bool                    { return m.Val() == "" }
+func (m DictStringValue) Err() bool                    { return false }
+func (m DictStringValue) Type() ValueType              { return StringType }
+func (m DictStringValue) Rv() reflect.Value            { return reflect.ValueOf(m.Val()) }
+func (m DictStringValue) Value() interface{}           { return m.Val() }
+func (m DictStringValue) ToString() string             { return m.Val() }
+func (m DictStringValue) MarshalJSON() ([]byte, error) { return json.Marshal(m.Val()) }
+func (m DictStringValue) Id() int                      { return m.id }
+func (m DictStringValue) Dictionary() *Dictionary      { return m.dict }
+func (m DictStringValue) Val() string {
+	if m.dict == nil {
+		return ""
+	}
+	s, _ := m.dict.Decode(m.id)
+	return s
+}
+
+// StringValue decodes to a plain StringValue
+func (m DictStringValue) StringValue() StringValue { return NewStringValue(m.Val()) }
@@ -0,0 +1,29 @@
+package value
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/bmizerany/assert"
+)
+
+func TestDictStringValue(t *testing.T) {
+	dict := NewDictionary()
+	active := dict.Encode("active")
+	deleted := dict.Encode("deleted")
+	assert.Tf(t, dict.Encode("active").Id() == active.Id(), "same string same id %v", active)
+	assert.Tf(t, active.Id() != deleted.Id(), "%v %v", active, deleted)
+	assert.Tf(t, dict.Len() == 2, "%v", dict.Len())
+
+	s, ok := dict.Decode(deleted.Id())
+	assert.Tf(t, ok && s == "deleted", "%v", s)
+	_, ok = dict.Decode(5)
+	assert.T(t, !ok)
+
+	assert.T(t, active.Type() == StringType)
+	assert.Tf(t, active.ToString() == "active" && active.Value() == "active", "%v", active)
+	assert.T(t, active.StringValue().Val() == "active")
+	assert.T(t, !active.Nil() && dict.Encode("").Nil())
+	by, err := json.Marshal(active)
+	assert.Tf(t, err == nil && string(by) == `"active"`, "%s %v", by, err)
+}
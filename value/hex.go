@@ -0,0 +1,45 @@
+package value
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Unhex parses a hex encoded string into its bytes, an optional 0x prefix
+//  and either case are allowed
+//
+//     Unhex("deadbeef")    =>  ByteSliceValue{0xde, 0xad, 0xbe, 0xef}, nil
+//     Unhex("0xDEAD")      =>  ByteSliceValue{0xde, 0xad}, nil
+//     Unhex("abc")         =>  error, odd length
+//
+func Unhex(s string) (ByteSliceValue, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		s = s[2:]
+	}
+	if len(s)%2 != 0 {
+		return NewByteSliceValue(nil), fmt.Errorf("Invalid hex %q, odd length", s)
+	}
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return NewByteSliceValue(nil), fmt.Errorf("Invalid hex %q: %v", s, err)
+	}
+	return NewByteSliceValue(b), nil
+}
+
+// Hex encodes the bytes of a value as a lower case hex string, a
+//  ByteSliceValue its bytes, other values the bytes of their string
+//
+//     Hex(ByteSliceValue{0xde, 0xad})   =>  "dead"
+//     Hex(StringValue("abc"))           =>  "616263"
+//
+func Hex(v Value) StringValue {
+	switch vt := v.(type) {
+	case nil:
+		return EmptyStringValue
+	case ByteSliceValue:
+		return NewStringValue(hex.EncodeToString(vt.Val()))
+	}
+	return NewStringValue(hex.EncodeToString([]byte(v.ToString())))
+}
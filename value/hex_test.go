@@ -0,0 +1,28 @@
+package value
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bmizerany/assert"
+)
+
+func TestHex(t *testing.T) {
+	for _, in := range [][]byte{{}, {0}, {0xde, 0xad, 0xbe, 0xef}, []byte("hello")} {
+		hs := Hex(NewByteSliceValue(in))
+		b, err := Unhex(hs.Val())
+		assert.Tf(t, err == nil, "%q no error %v", hs.Val(), err)
+		assert.Tf(t, bytes.Equal(b.Val(), in), "round trip %x got %x", in, b.Val())
+	}
+
+	b, err := Unhex(" 0xDEADbeef ")
+	assert.Tf(t, err == nil && bytes.Equal(b.Val(), []byte{0xde, 0xad, 0xbe, 0xef}), "%x %v", b.Val(), err)
+	assert.T(t, Hex(NewStringValue("abc")).Val() == "616263")
+	assert.T(t, Hex(NewByteSliceValue([]byte{0xAB})).Val() == "ab")
+
+	// odd length and non hex chars are errors, not panics
+	for _, bad := range []string{"abc", "0x1", "zz", "de ad"} {
+		_, err := Unhex(bad)
+		assert.Tf(t, err != nil, "%q should be an error", bad)
+	}
+}
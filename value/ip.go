@@ -0,0 +1,35 @@
+package value
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// IPInCIDR is the ip address in the CIDR subnet, IPv4 or IPv6
+//
+//     IPInCIDR("10.1.2.3", "10.0.0.0/8")         =>  true, nil
+//     IPInCIDR("2001:db8::1", "2001:db8::/32")   =>  true, nil
+//     IPInCIDR("10.1.2", "10.0.0.0/8")           =>  false, error
+//
+func IPInCIDR(ip, cidr string) (bool, error) {
+	addr := net.ParseIP(strings.TrimSpace(ip))
+	if addr == nil {
+		return false, fmt.Errorf("Invalid ip address %q", ip)
+	}
+	_, subnet, err := net.ParseCIDR(strings.TrimSpace(cidr))
+	if err != nil {
+		return false, fmt.Errorf("Invalid cidr %q", cidr)
+	}
+	return subnet.Contains(addr), nil
+}
+
+// IPInCIDRValue is IPInCIDR returning a BoolValue, or ErrorValue
+//  for malformed ip or cidr, for use by the vm
+func IPInCIDRValue(ip, cidr string) Value {
+	in, err := IPInCIDR(ip, cidr)
+	if err != nil {
+		return NewErrorValue(err.Error())
+	}
+	return NewBoolValue(in)
+}
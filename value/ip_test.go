@@ -0,0 +1,48 @@
+package value
+
+import (
+	"testing"
+
+	"github.com/bmizerany/assert"
+)
+
+func TestIPInCIDR(t *testing.T) {
+	tests := []struct {
+		ip, cidr string
+		in       bool
+	}{
+		{"10.1.2.3", "10.0.0.0/8", true},
+		{"11.1.2.3", "10.0.0.0/8", false},
+		{"192.168.1.255", "192.168.1.0/24", true},
+		{"192.168.2.1", "192.168.1.0/24", false},
+		{" 10.1.2.3 ", "10.0.0.0/8", true},
+		{"2001:db8::1", "2001:db8::/32", true},
+		{"2001:db9::1", "2001:db8::/32", false},
+		// ipv4 is never in an ipv6 subnet, and vice versa
+		{"10.1.2.3", "2001:db8::/32", false},
+		{"2001:db8::1", "10.0.0.0/8", false},
+	}
+	for _, test := range tests {
+		in, err := IPInCIDR(test.ip, test.cidr)
+		assert.Tf(t, err == nil, "%s %s no error %v", test.ip, test.cidr, err)
+		assert.Tf(t, in == test.in, "%s in %s expected %v", test.ip, test.cidr, test.in)
+	}
+
+	// malformed inputs are errors, not panics
+	for _, bad := range [][2]string{
+		{"10.1.2", "10.0.0.0/8"},
+		{"not an ip", "10.0.0.0/8"},
+		{"", "10.0.0.0/8"},
+		{"10.1.2.3", "10.0.0.0"},
+		{"10.1.2.3", "10.0.0.0/33"},
+		{"10.1.2.3", ""},
+	} {
+		in, err := IPInCIDR(bad[0], bad[1])
+		assert.Tf(t, err != nil && !in, "%s %s should error", bad[0], bad[1])
+	}
+
+	v := IPInCIDRValue("10.1.2.3", "10.0.0.0/8")
+	assert.Tf(t, v.Type() == BoolType && v.Value() == true, "%#v", v)
+	v = IPInCIDRValue("10.1.2", "10.0.0.0/8")
+	assert.Tf(t, v.Type() == ErrorType, "malformed ip should be ErrorValue %#v", v)
+}
@@ -0,0 +1,144 @@
+package value
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var (
+	_ Value = (*JsonValue)(nil)
+	_ Map   = (*JsonValue)(nil)
+)
+
+// JsonValue is a json document, ie a whole nested document per row of a
+//  source, that is kept as its raw bytes and only parsed on access.
+//  JsonPath() decodes only the objects/arrays along the requested path,
+//  so a narrow projection of a wide document does not pay for converting
+//  the entire tree to MapValue/SliceValue as NewValueJSON() does.
+type JsonValue struct {
+	raw json.RawMessage
+	rv  reflect.Value
+}
+
+// A lazily parsed json document of raw bytes, which are not validated
+//  until accessed
+func NewJsonValue(raw []byte) JsonValue {
+	return JsonValue{raw: raw, rv: reflect.ValueOf(raw)}
+}
+
+func (m JsonValue) Err() bool                    { return false }
+func (m JsonValue) Type() ValueType              { return JsonType }
+func (m JsonValue) Rv() reflect.Value            { return m.rv }
+func (m JsonValue) Raw() []byte                  { return m.raw }
+func (m JsonValue) Value() interface{}           { return m.Parse().Value() }
+func (m JsonValue) MarshalJSON() ([]byte, error) { return m.raw, nil }
+func (m JsonValue) ToString() string             { return string(m.raw) }
+func (m JsonValue) Nil() bool {
+	raw := bytes.TrimSpace(m.raw)
+	return len(raw) == 0 || string(raw) == "null"
+}
+
+// Parse the entire document, see NewValueJSON(), an invalid document
+//  is an ErrorValue
+func (m JsonValue) Parse() Value {
+	if m.Nil() {
+		return NilValueVal
+	}
+	dec := json.NewDecoder(bytes.NewReader(m.raw))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return NewErrorValue(err.Error())
+	}
+	return NewValueJSON(v)
+}
+
+// MapValue of a document that is an object, empty for any other document
+func (m JsonValue) MapValue() MapValue {
+	if mv, ok := m.Parse().(MapValue); ok {
+		return mv
+	}
+	return EmptyMapValue
+}
+
+// Get a top level key of a document that is an object
+func (m JsonValue) Get(key string) (Value, bool) {
+	return m.JsonPath(key)
+}
+
+// JsonPath gets the value at a path of object keys and array indexes
+//  separated by dots, array indexes may also be bracketed:
+//
+//     user.addresses.0.city
+//     user.addresses[0].city
+//
+//  Only the objects and arrays along the path are decoded.  Objects and
+//  arrays found at the path are returned as JsonValue so they are also
+//  parsed lazily, scalars as their Value.  False if the path does not
+//  exist or the document is not valid json.
+func (m JsonValue) JsonPath(path string) (Value, bool) {
+	raw := bytes.TrimSpace(m.raw)
+	for _, part := range splitJsonPath(path) {
+		if len(raw) == 0 {
+			return nil, false
+		}
+		switch raw[0] {
+		case '{':
+			var obj map[string]json.RawMessage
+			if err := json.Unmarshal(raw, &obj); err != nil {
+				return nil, false
+			}
+			next, ok := obj[part]
+			if !ok {
+				return nil, false
+			}
+			raw = bytes.TrimSpace(next)
+		case '[':
+			i, err := strconv.Atoi(part)
+			if err != nil {
+				return nil, false
+			}
+			var arr []json.RawMessage
+			if err := json.Unmarshal(raw, &arr); err != nil {
+				return nil, false
+			}
+			if i < 0 || i >= len(arr) {
+				return nil, false
+			}
+			raw = bytes.TrimSpace(arr[i])
+		default:
+			return nil, false
+		}
+	}
+	return jsonRawValue(raw)
+}
+
+func jsonRawValue(raw []byte) (Value, bool) {
+	if len(raw) == 0 {
+		return nil, false
+	}
+	switch raw[0] {
+	case '{', '[':
+		return NewJsonValue(raw), true
+	}
+	v := NewJsonValue(raw).Parse()
+	if v.Err() {
+		return nil, false
+	}
+	return v, true
+}
+
+func splitJsonPath(path string) []string {
+	path = strings.Replace(path, "]", "", -1)
+	path = strings.Replace(path, "[", ".", -1)
+	parts := make([]string, 0)
+	for _, part := range strings.Split(path, ".") {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
@@ -0,0 +1,93 @@
+package value
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/bmizerany/assert"
+)
+
+/*
+Benchmark accessing one path of a deep document, eagerly converting the
+  whole document vs lazily parsing only the path
+
+go test -bench="Json" --run="Json"
+*/
+
+func TestJsonValue(t *testing.T) {
+	doc := NewJsonValue([]byte(`{"user":{"name":"bob","age":32,"score":1.5,
+		"addresses":[{"city":"sf"},{"city":"nyc"}]},"active":true,"x":null}`))
+	assert.T(t, doc.Type() == JsonType && doc.Type().String() == "json")
+	assert.T(t, !doc.Nil() && NewJsonValue(nil).Nil() && NewJsonValue([]byte(" null")).Nil())
+
+	v, ok := doc.JsonPath("user.name")
+	assert.Tf(t, ok && v.Value() == "bob", "%v", v)
+	v, ok = doc.JsonPath("user.age")
+	assert.Tf(t, ok && v.Type() == IntType && v.Value() == int64(32), "%#v", v)
+	v, ok = doc.JsonPath("user.score")
+	assert.Tf(t, ok && v.Type() == NumberType, "%#v", v)
+	v, ok = doc.JsonPath("active")
+	assert.Tf(t, ok && v.Value() == true, "%v", v)
+	v, ok = doc.JsonPath("x")
+	assert.Tf(t, ok && v.Type() == NilType, "%v", v)
+	v, ok = doc.JsonPath("user.addresses[1].city")
+	assert.Tf(t, ok && v.Value() == "nyc", "%v", v)
+	v, ok = doc.JsonPath("user.addresses.0.city")
+	assert.Tf(t, ok && v.Value() == "sf", "%v", v)
+
+	// objects and arrays stay lazy
+	v, ok = doc.JsonPath("user.addresses")
+	assert.Tf(t, ok && v.Type() == JsonType, "%#v", v)
+	v, ok = v.(JsonValue).JsonPath("0.city")
+	assert.Tf(t, ok && v.Value() == "sf", "%v", v)
+
+	for _, path := range []string{"user.zip", "user.addresses.5", "user.addresses.city", "user.name.first"} {
+		_, ok = doc.JsonPath(path)
+		assert.Tf(t, !ok, "path should not exist %q", path)
+	}
+	_, ok = NewJsonValue([]byte(`{"user":`)).JsonPath("user")
+	assert.T(t, !ok)
+
+	// the whole document
+	mv := doc.MapValue()
+	assert.Tf(t, len(mv.Val()) == 3, "%v", mv)
+	by, err := json.Marshal(NewJsonValue([]byte(`{"a":[1,2]}`)))
+	assert.Tf(t, err == nil && string(by) == `{"a":[1,2]}`, "%s %v", by, err)
+	assert.T(t, NewJsonValue([]byte(`{"a":`)).Parse().Err())
+}
+
+func benchJsonDoc() []byte {
+	fields := make([]string, 0, 50)
+	for i := 0; i < 50; i++ {
+		fields = append(fields, fmt.Sprintf(`"field%d":{"a":[1,2,3,{"b":"hello %d"}],"c":{"d":{"e":%d}}}`, i, i, i))
+	}
+	return []byte(`{"doc":{` + strings.Join(fields, ",") + `,"target":{"deep":{"value":42}}}}`)
+}
+
+func BenchmarkJsonEager(b *testing.B) {
+	raw := benchJsonDoc()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var v interface{}
+		json.Unmarshal(raw, &v)
+		mv := NewValueJSON(v).(MapValue)
+		doc, _ := mv.Get("doc")
+		target, _ := doc.(MapValue).Get("target")
+		deep, _ := target.(MapValue).Get("deep")
+		if val, _ := deep.(MapValue).Get("value"); val.Value() != int64(42) {
+			b.Fatalf("wrong value %v", val)
+		}
+	}
+}
+
+func BenchmarkJsonLazy(b *testing.B) {
+	raw := benchJsonDoc()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if val, _ := NewJsonValue(raw).JsonPath("doc.target.deep.value"); val.Value() != int64(42) {
+			b.Fatalf("wrong value %v", val)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package value
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// EncodeKey is a canonical byte encoding of a value for use as a map key
+//  (as string(key)) in join, distinct and group by.  The first byte is the
+//  ValueType so values of different types never collide, ie int 1 and
+//  string "1".  Scalars sort in the same order as their values within a
+//  type, ints and numbers are big endian with the sign flipped, NaN is a
+//  single value, and -0 is 0.  Slices and maps are length prefixed
+//  elements, maps in key order, structs are their EqualityKey().  See
+//  DecodeKey() for the reverse, structs can't be decoded.
+func EncodeKey(v Value) []byte {
+	return appendKey(make([]byte, 0, 16), v)
+}
+
+func appendKey(buf []byte, v Value) []byte {
+	if v == nil {
+		return append(buf, byte(NilType))
+	}
+	switch vt := v.(type) {
+	case NilValue:
+		return append(buf, byte(NilType))
+	case ErrorValue:
+		return append(append(buf, byte(ErrorType)), vt.Val()...)
+	case IntValue:
+		return appendUint64(append(buf, byte(IntType)), uint64(vt.Val())^(1<<63))
+	case NumberValue:
+		return appendUint64(append(buf, byte(NumberType)), floatKey(vt.Val()))
+	case BoolValue:
+		if vt.Val() {
+			return append(buf, byte(BoolType), 1)
+		}
+		return append(buf, byte(BoolType), 0)
+	case TimeValue:
+		return appendUint64(append(buf, byte(TimeType)), uint64(vt.Val().UnixNano())^(1<<63))
+	case DurationValue:
+		return appendUint64(append(buf, byte(DurationType)), uint64(vt.Val())^(1<<63))
+	case ByteSliceValue:
+		return append(append(buf, byte(ByteSliceType)), vt.Val()...)
+	case StringsValue:
+		buf = append(buf, byte(StringsType))
+		for _, s := range vt.Val() {
+			buf = appendLenPrefixed(buf, []byte(s))
+		}
+		return buf
+	case SliceValue:
+		buf = append(buf, byte(SliceValueType))
+		for _, el := range vt.Val() {
+			buf = appendLenPrefixed(buf, EncodeKey(el))
+		}
+		return buf
+	case MapValue:
+		return appendMapKey(buf, vt.Type(), vt.Val())
+	case Map:
+		return appendMapKey(buf, v.Type(), vt.MapValue().Val())
+	case StructValue:
+		return append(append(buf, byte(StructType)), vt.EqualityKey()...)
+	}
+	// StringValue, DictStringValue
+	return append(append(buf, byte(v.Type())), v.ToString()...)
+}
+
+func appendMapKey(buf []byte, vt ValueType, mv map[string]Value) []byte {
+	keys := make([]string, 0, len(mv))
+	for k := range mv {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	buf = append(buf, byte(vt))
+	for _, k := range keys {
+		buf = appendLenPrefixed(buf, []byte(k))
+		buf = appendLenPrefixed(buf, EncodeKey(mv[k]))
+	}
+	return buf
+}
+
+// DecodeKey decodes a key from EncodeKey().  Times are UTC, and dictionary
+//  strings decode to StringValue.
+func DecodeKey(key []byte) (Value, error) {
+	if len(key) == 0 {
+		return nil, fmt.Errorf("empty key")
+	}
+	vt, body := ValueType(key[0]), key[1:]
+	switch vt {
+	case NilType:
+		return NilValueVal, nil
+	case ErrorType:
+		return NewErrorValue(string(body)), nil
+	case StringType:
+		return NewStringValue(string(body)), nil
+	case ByteSliceType:
+		return NewByteSliceValue(append([]byte(nil), body...)), nil
+	case BoolType:
+		if len(body) != 1 {
+			return nil, fmt.Errorf("invalid bool key %v", key)
+		}
+		return NewBoolValue(body[0] == 1), nil
+	case IntType, NumberType, TimeType, DurationType:
+		if len(body) != 8 {
+			return nil, fmt.Errorf("invalid %s key %v", vt, key)
+		}
+		bits := binary.BigEndian.Uint64(body)
+		switch vt {
+		case IntType:
+			return NewIntValue(int64(bits ^ (1 << 63))), nil
+		case NumberType:
+			return NewNumberValue(floatFromKey(bits)), nil
+		case TimeType:
+			return NewTimeValue(time.Unix(0, int64(bits^(1<<63))).UTC()), nil
+		}
+		return NewDurationValue(time.Duration(int64(bits ^ (1 << 63)))), nil
+	case StringsType:
+		parts, err := splitLenPrefixed(body)
+		if err != nil {
+			return nil, err
+		}
+		strs := make([]string, len(parts))
+		for i, p := range parts {
+			strs[i] = string(p)
+		}
+		return NewStringsValue(strs), nil
+	case SliceValueType:
+		parts, err := splitLenPrefixed(body)
+		if err != nil {
+			return nil, err
+		}
+		vals := make([]Value, len(parts))
+		for i, p := range parts {
+			if vals[i], err = DecodeKey(p); err != nil {
+				return nil, err
+			}
+		}
+		return NewSliceValues(vals), nil
+	case MapValueType, MapIntType, MapStringType, MapNumberType, MapBoolType:
+		return decodeMapKey(vt, body)
+	}
+	return nil, fmt.Errorf("cannot decode key of type %s", vt)
+}
+
+func decodeMapKey(vt ValueType, body []byte) (Value, error) {
+	parts, err := splitLenPrefixed(body)
+	if err != nil {
+		return nil, err
+	}
+	if len(parts)%2 != 0 {
+		return nil, fmt.Errorf("invalid %s key, odd number of parts", vt)
+	}
+	mv := make(map[string]Value, len(parts)/2)
+	for i := 0; i < len(parts); i += 2 {
+		if mv[string(parts[i])], err = DecodeKey(parts[i+1]); err != nil {
+			return nil, err
+		}
+	}
+	switch vt {
+	case MapIntType:
+		m := make(map[string]int64, len(mv))
+		for k, v := range mv {
+			iv, ok := v.(IntValue)
+			if !ok {
+				return nil, fmt.Errorf("invalid %s key, %q is %s", vt, k, v.Type())
+			}
+			m[k] = iv.Val()
+		}
+		return NewMapIntValue(m), nil
+	case MapStringType:
+		m := make(map[string]string, len(mv))
+		for k, v := range mv {
+			m[k] = v.ToString()
+		}
+		return NewMapStringValue(m), nil
+	case MapNumberType:
+		m := make(map[string]float64, len(mv))
+		for k, v := range mv {
+			nv, ok := v.(NumberValue)
+			if !ok {
+				return nil, fmt.Errorf("invalid %s key, %q is %s", vt, k, v.Type())
+			}
+			m[k] = nv.Val()
+		}
+		return NewMapNumberValue(m), nil
+	case MapBoolType:
+		m := make(map[string]bool, len(mv))
+		for k, v := range mv {
+			bv, ok := v.(BoolValue)
+			if !ok {
+				return nil, fmt.Errorf("invalid %s key, %q is %s", vt, k, v.Type())
+			}
+			m[k] = bv.Val()
+		}
+		return NewMapBoolValue(m), nil
+	}
+	return MapValue{v: mv, rv: reflect.ValueOf(mv)}, nil
+}
+
+// floatKey is an order preserving encoding of a float, positive floats
+//  have the sign bit set and negative floats have all bits flipped
+func floatKey(f float64) uint64 {
+	if f == 0 {
+		f = 0 // normalize -0
+	} else if math.IsNaN(f) {
+		f = math.NaN()
+	}
+	bits := math.Float64bits(f)
+	if bits&(1<<63) != 0 {
+		return ^bits
+	}
+	return bits | (1 << 63)
+}
+
+func floatFromKey(bits uint64) float64 {
+	if bits&(1<<63) != 0 {
+		return math.Float64frombits(bits &^ (1 << 63))
+	}
+	return math.Float64frombits(^bits)
+}
+
+func appendUint64(buf []byte, bits uint64) []byte {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], bits)
+	return append(buf, b[:]...)
+}
+
+func appendLenPrefixed(buf, b []byte) []byte {
+	var lb [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(lb[:], uint64(len(b)))
+	return append(append(buf, lb[:n]...), b...)
+}
+
+func splitLenPrefixed(body []byte) ([][]byte, error) {
+	parts := make([][]byte, 0)
+	for len(body) > 0 {
+		l, n := binary.Uvarint(body)
+		if n <= 0 || uint64(len(body)-n) < l {
+			return nil, fmt.Errorf("invalid length prefixed key")
+		}
+		parts = append(parts, body[n:n+int(l)])
+		body = body[n+int(l):]
+	}
+	return parts, nil
+}
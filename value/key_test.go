@@ -0,0 +1,82 @@
+package value
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+)
+
+func TestEncodeKeyRoundTrip(t *testing.T) {
+	ts := time.Date(2015, 7, 4, 1, 2, 3, 4, time.UTC)
+	vals := []Value{
+		NilValueVal,
+		NewErrorValue("bad"),
+		NewIntValue(-5),
+		NewIntValue(math.MaxInt64),
+		NewNumberValue(-2.5),
+		NewNumberValue(math.Inf(1)),
+		BoolValueTrue,
+		BoolValueFalse,
+		NewTimeValue(ts),
+		NewDurationValue(-time.Minute),
+		NewStringValue(""),
+		NewStringValue("héllo"),
+		NewByteSliceValue([]byte{0, 1, 2}),
+		NewStringsValue([]string{"a", "", "bc"}),
+		NewSliceValues([]Value{NewIntValue(1), NewStringValue("a"), NewSliceValues([]Value{BoolValueTrue})}),
+		NewMapValue(map[string]interface{}{"a": int64(1), "b": "x"}),
+		NewMapIntValue(map[string]int64{"a": 1, "b": -2}),
+		NewMapStringValue(map[string]string{"a": "x"}),
+		NewMapNumberValue(map[string]float64{"a": 1.5}),
+		NewMapBoolValue(map[string]bool{"a": true}),
+	}
+	for _, v := range vals {
+		out, err := DecodeKey(EncodeKey(v))
+		assert.Tf(t, err == nil, "decode %v error %v", v, err)
+		assert.Tf(t, out.Type() == v.Type(), "%s round trip got %s", v.Type(), out.Type())
+		switch vt := v.(type) {
+		case TimeValue:
+			assert.Tf(t, out.(TimeValue).Val().Equal(vt.Val()), "%v", out)
+			continue
+		case SliceValue, MapValue:
+			// nested values, compare re-encoded
+			assert.Tf(t, bytes.Equal(EncodeKey(out), EncodeKey(v)), "%s round trip expected %v got %v", v.Type(), v, out)
+			continue
+		}
+		assert.Tf(t, reflect.DeepEqual(out.Value(), v.Value()), "%s round trip expected %v got %v", v.Type(), v.Value(), out.Value())
+	}
+
+	out, err := DecodeKey(EncodeKey(vals[14]))
+	assert.Tf(t, err == nil && out.ToString() == "1,a,true", "%v %v", out, err)
+
+	nan, err := DecodeKey(EncodeKey(NewNumberValue(math.NaN())))
+	assert.T(t, err == nil && math.IsNaN(nan.(NumberValue).Val()))
+	_, err = DecodeKey(nil)
+	assert.T(t, err != nil)
+	_, err = DecodeKey([]byte{byte(IntType), 1})
+	assert.T(t, err != nil)
+}
+
+func TestEncodeKeyCanonical(t *testing.T) {
+	// type aware, no collisions across types
+	assert.T(t, !bytes.Equal(EncodeKey(NewIntValue(1)), EncodeKey(NewStringValue("1"))))
+	assert.T(t, !bytes.Equal(EncodeKey(NewIntValue(1)), EncodeKey(NewNumberValue(1))))
+	assert.T(t, !bytes.Equal(EncodeKey(NewStringsValue([]string{"ab", "c"})), EncodeKey(NewStringsValue([]string{"a", "bc"}))))
+
+	assert.T(t, bytes.Equal(EncodeKey(NewNumberValue(math.Copysign(0, -1))), EncodeKey(NewNumberValue(0))))
+	assert.T(t, bytes.Equal(EncodeKey(NewNumberValue(math.NaN())), EncodeKey(NewNumberValue(-math.NaN()))))
+	dict := NewDictionary()
+	assert.T(t, bytes.Equal(EncodeKey(dict.Encode("a")), EncodeKey(NewStringValue("a"))))
+
+	// ordered within a type
+	ordered := []Value{NewNumberValue(math.Inf(-1)), NewNumberValue(-2.5), NewNumberValue(-1), NewNumberValue(0), NewNumberValue(0.5), NewNumberValue(3)}
+	for i := 1; i < len(ordered); i++ {
+		assert.Tf(t, bytes.Compare(EncodeKey(ordered[i-1]), EncodeKey(ordered[i])) < 0, "%v < %v", ordered[i-1], ordered[i])
+	}
+	assert.T(t, bytes.Compare(EncodeKey(NewIntValue(-1)), EncodeKey(NewIntValue(1))) < 0)
+	assert.T(t, bytes.Compare(EncodeKey(NewStringValue("a")), EncodeKey(NewStringValue("ab"))) < 0)
+}
@@ -0,0 +1,223 @@
+package value
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Math helpers operating on numeric values, returning a NumberValue
+//  or an ErrorValue for domain errors (ie, sqrt of negative number)
+
+// Abs absolute value
+func Abs(v NumericValue) Value {
+	return NewNumberValue(math.Abs(v.Float()))
+}
+
+// Ceil least integer value greater than or equal to v
+func Ceil(v NumericValue) Value {
+	return NewNumberValue(math.Ceil(v.Float()))
+}
+
+// Floor greatest integer value less than or equal to v
+func Floor(v NumericValue) Value {
+	return NewNumberValue(math.Floor(v.Float()))
+}
+
+// Round to given number of decimal places, half away from zero, see
+//  RoundPlaces()
+//
+//     Round(2.345, 2)  =>  2.35
+//     Round(-2.5, 0)   =>  -3
+//     Round(1250, -2)  =>  1300
+//
+func Round(v NumericValue, places int) Value {
+	return NewNumberValue(RoundPlaces(v.Float(), places))
+}
+
+// RoundPlaces rounds f to places decimal places, half away from zero (not
+//  bankers), negative places round to tens, hundreds etc as sql ROUND().
+//  The shortest decimal representation of f is rounded rather than its
+//  binary value, so 1.005 (binary 1.00499999...) rounds to 1.01 as
+//  displayed.
+//
+//     RoundPlaces(1.005, 2)   =>  1.01
+//     RoundPlaces(-0.5, 0)    =>  -1
+//     RoundPlaces(1250, -2)   =>  1300
+//     RoundPlaces(49, -2)     =>  0
+//
+func RoundPlaces(f float64, places int) float64 {
+	if f == 0 || math.IsNaN(f) || math.IsInf(f, 0) {
+		return f
+	}
+	// d.ddddde±xx is the decimal  0.dddddd x 10^(exp+1)
+	s := strconv.FormatFloat(math.Abs(f), 'e', -1, 64)
+	ei := strings.IndexByte(s, 'e')
+	exp, _ := strconv.Atoi(s[ei+1:])
+	digits := strings.Replace(s[:ei], ".", "", 1)
+	keep := exp + 1 + places
+	switch {
+	case keep >= len(digits):
+		// already has no more places
+		return f
+	case keep < 0:
+		return 0
+	}
+	rounded := []byte(digits[:keep])
+	if digits[keep] >= '5' {
+		i := keep - 1
+		for ; i >= 0; i-- {
+			if rounded[i] != '9' {
+				rounded[i]++
+				break
+			}
+			rounded[i] = '0'
+		}
+		if i < 0 {
+			// carried past the first digit, ie 9.99 => 10.0
+			rounded = append([]byte{'1'}, rounded...)
+			exp++
+		}
+	}
+	if len(rounded) == 0 {
+		return 0
+	}
+	r, _ := strconv.ParseFloat("0."+string(rounded)+"e"+strconv.Itoa(exp+1), 64)
+	return math.Copysign(r, f)
+}
+
+// Sqrt square root, error for negative numbers
+func Sqrt(v NumericValue) Value {
+	f := v.Float()
+	if f < 0 {
+		return NewErrorValuef("sqrt of negative number: %v", f)
+	}
+	return NewNumberValue(math.Sqrt(f))
+}
+
+// Pow v to the power of exp, error if result is not a real number
+//  ie pow(-8, 1/3)
+func Pow(v, exp NumericValue) Value {
+	f := math.Pow(v.Float(), exp.Float())
+	if math.IsNaN(f) && !math.IsNaN(v.Float()) && !math.IsNaN(exp.Float()) {
+		return NewErrorValuef("pow(%v, %v) is not a real number", v.Float(), exp.Float())
+	}
+	return NewNumberValue(f)
+}
+
+// Divide is a / b with sql semantics for a zero divisor, NULL rather
+//  than a panic (int) or Inf (number), or with strict an ErrorValue.
+//  Two ints are integer division, otherwise the operands are numbers.
+//  NULL operands are NULL.
+//
+//     Divide(7, 2, false)    =>  3
+//     Divide(7.0, 2, false)  =>  3.5
+//     Divide(7, 0, false)    =>  NULL
+//     Divide(7, 0.0, true)   =>  error
+//
+func Divide(a, b Value, strict bool) Value {
+	return divide("/", a, b, strict, func(x, y int64) int64 { return x / y }, func(x, y float64) float64 { return x / y })
+}
+
+// Modulo is the remainder a % b, with a zero divisor as Divide().  Two
+//  ints are the integer remainder, otherwise the operands are numbers,
+//  and the remainder is of their integer parts.
+func Modulo(a, b Value, strict bool) Value {
+	return divide("%", a, b, strict, func(x, y int64) int64 { return x % y }, func(x, y float64) float64 {
+		return float64(int64(x) % int64(y))
+	})
+}
+
+func divide(op string, a, b Value, strict bool, intFn func(x, y int64) int64, floatFn func(x, y float64) float64) Value {
+	if IsNull(a) || IsNull(b) {
+		return NilValueVal
+	}
+	ai, aIsInt := a.(IntValue)
+	bi, bIsInt := b.(IntValue)
+	if aIsInt && bIsInt {
+		if bi.Val() == 0 {
+			return divideByZero(op, strict)
+		}
+		if op == "/" && ai.Val() == math.MinInt64 && bi.Val() == -1 {
+			// overflows, the result is not representable as int64
+			return NewNumberValue(floatFn(ai.Float(), bi.Float()))
+		}
+		return NewIntValue(intFn(ai.Val(), bi.Val()))
+	}
+	an, aok := a.(NumericValue)
+	bn, bok := b.(NumericValue)
+	if !aok || !bok {
+		return NewErrorValuef("Invalid operands for %s: %s, %s", op, typeName(a), typeName(b))
+	}
+	x, y := an.Float(), bn.Float()
+	if math.IsNaN(x) || math.IsNaN(y) {
+		return NumberNaNValue
+	}
+	if y == 0 || (op == "%" && int64(y) == 0) {
+		return divideByZero(op, strict)
+	}
+	return NewNumberValue(floatFn(x, y))
+}
+
+func divideByZero(op string, strict bool) Value {
+	if !strict {
+		return NilValueVal
+	}
+	if op == "%" {
+		return NewErrorValue("Modulus by zero")
+	}
+	return NewErrorValue("Division by zero")
+}
+
+// AddChecked is a + b, false if the result overflows int64
+func AddChecked(a, b int64) (int64, bool) {
+	c := a + b
+	if (c > a) != (b > 0) {
+		return c, false
+	}
+	return c, true
+}
+
+// SubChecked is a - b, false if the result overflows int64
+func SubChecked(a, b int64) (int64, bool) {
+	c := a - b
+	if (c < a) != (b > 0) {
+		return c, false
+	}
+	return c, true
+}
+
+// MulChecked is a * b, false if the result overflows int64
+func MulChecked(a, b int64) (int64, bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+	c := a * b
+	if (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) || c/b != a {
+		return c, false
+	}
+	return c, true
+}
+
+// Negate is the arithmetic negation  -v  of a numeric value, keeping
+//  its type (int, number, duration).  NULL is NULL, and non-numeric
+//  values or negating math.MinInt64 (overflow) are an ErrorValue.
+func Negate(v Value) Value {
+	if IsNull(v) {
+		return NilValueVal
+	}
+	switch vt := v.(type) {
+	case IntValue:
+		if vt.Val() == math.MinInt64 {
+			return NewErrorValuef("negate of %d overflows int64", vt.Val())
+		}
+		return NewIntValue(-vt.Val())
+	case NumberValue:
+		return NewNumberValue(-vt.Float())
+	case DurationValue:
+		return NewDurationValue(-vt.Val())
+	case NumericValue:
+		return NewNumberValue(-vt.Float())
+	}
+	return NewErrorValuef("cannot negate non-numeric %s", v.Type())
+}
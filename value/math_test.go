@@ -0,0 +1,167 @@
+package value
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+)
+
+var mathTests = []struct {
+	name   string
+	result Value
+	expect float64
+}{
+	{"abs neg", Abs(NewNumberValue(-5.5)), 5.5},
+	{"abs int", Abs(NewIntValue(-3)), 3},
+	{"ceil", Ceil(NewNumberValue(5.2)), 6},
+	{"ceil neg", Ceil(NewNumberValue(-5.2)), -5},
+	{"floor", Floor(NewNumberValue(5.8)), 5},
+	{"floor neg", Floor(NewNumberValue(-5.2)), -6},
+	{"round", Round(NewNumberValue(5.5), 0), 6},
+	{"round neg", Round(NewNumberValue(-2.5), 0), -3},
+	{"round places", Round(NewNumberValue(2.345), 2), 2.35},
+	{"round neg places", Round(NewIntValue(1250), -2), 1300},
+	{"sqrt", Sqrt(NewIntValue(16)), 4},
+	{"pow", Pow(NewIntValue(2), NewIntValue(10)), 1024},
+	{"pow fraction", Pow(NewNumberValue(4), NewNumberValue(0.5)), 2},
+}
+
+func TestMath(t *testing.T) {
+	for _, mt := range mathTests {
+		nv, ok := mt.result.(NumberValue)
+		assert.Tf(t, ok, "%s: expected NumberValue but got %T %v", mt.name, mt.result, mt.result)
+		assert.Tf(t, math.Abs(nv.Float()-mt.expect) < 1e-9, "%s: expected %v but got %v", mt.name, mt.expect, nv.Float())
+	}
+
+	// domain errors
+	v := Sqrt(NewNumberValue(-4))
+	assert.Tf(t, v.Err() && v.Type() == ErrorType, "sqrt of negative should error %v", v)
+	v = Pow(NewNumberValue(-8), NewNumberValue(1.0/3))
+	assert.Tf(t, v.Err(), "pow of negative to fraction should error %v", v)
+
+	// NaN passes through, not an error
+	v = Round(NumberNaNValue, 2)
+	assert.T(t, !v.Err() && math.IsNaN(v.(NumberValue).Float()))
+}
+
+func TestRoundPlaces(t *testing.T) {
+	tests := []struct {
+		f      float64
+		places int
+		expect float64
+	}{
+		{2.5, 0, 3},
+		{-2.5, 0, -3},
+		{0.5, 0, 1},
+		{0.49, 0, 0},
+		{1.4999, 0, 1},
+		{9.5, 0, 10},
+		{1234.5678, 0, 1235},
+		{1.005, 2, 1.01},
+		{2.675, 2, 2.68},
+		{-1.005, 2, -1.01},
+		{1.004, 2, 1},
+		{9.995, 2, 10},
+		{0.125, 2, 0.13},
+		{0.001, 2, 0},
+		{3.14159, 2, 3.14},
+		{12, 2, 12},
+		{15, -1, 20},
+		{14, -1, 10},
+		{-15, -1, -20},
+		{1250, -2, 1300},
+		{49, -2, 0},
+		{50, -2, 100},
+		{4.9, -1, 0},
+	}
+	for _, test := range tests {
+		got := RoundPlaces(test.f, test.places)
+		assert.Tf(t, got == test.expect, "RoundPlaces(%v, %d) expected %v got %v", test.f, test.places, test.expect, got)
+	}
+	assert.T(t, math.IsNaN(RoundPlaces(math.NaN(), 2)))
+	assert.T(t, math.IsInf(RoundPlaces(math.Inf(-1), 2), -1))
+	assert.T(t, RoundPlaces(math.MaxFloat64, 2) == math.MaxFloat64)
+}
+
+func TestCheckedArithmetic(t *testing.T) {
+	c, ok := AddChecked(2, 3)
+	assert.T(t, ok && c == 5)
+	_, ok = AddChecked(math.MaxInt64, 1)
+	assert.T(t, !ok)
+	_, ok = AddChecked(math.MinInt64, -1)
+	assert.T(t, !ok)
+	c, ok = AddChecked(math.MaxInt64, -1)
+	assert.T(t, ok && c == math.MaxInt64-1)
+
+	c, ok = SubChecked(-2, 3)
+	assert.T(t, ok && c == -5)
+	_, ok = SubChecked(math.MinInt64, 1)
+	assert.T(t, !ok)
+	_, ok = SubChecked(0, math.MinInt64)
+	assert.T(t, !ok)
+
+	c, ok = MulChecked(-4, 5)
+	assert.T(t, ok && c == -20)
+	_, ok = MulChecked(math.MaxInt64/2+1, 2)
+	assert.T(t, !ok)
+	_, ok = MulChecked(-1, math.MinInt64)
+	assert.T(t, !ok)
+	c, ok = MulChecked(0, math.MinInt64)
+	assert.T(t, ok && c == 0)
+}
+
+func TestNegate(t *testing.T) {
+	v := Negate(NewIntValue(5))
+	assert.Tf(t, v.Type() == IntType && v.Value() == int64(-5), "int stays int %v", v)
+	v = Negate(NewIntValue(math.MaxInt64))
+	assert.Tf(t, v.Value() == int64(-math.MaxInt64), "%v", v)
+	v = Negate(NewIntValue(math.MinInt64))
+	assert.Tf(t, v.Err(), "overflow %v", v)
+
+	v = Negate(NewNumberValue(-2.5))
+	assert.Tf(t, v.Type() == NumberType && v.Value() == 2.5, "%v", v)
+	v = Negate(NewDurationValue(time.Minute))
+	assert.Tf(t, v.Value() == -time.Minute, "%v", v)
+
+	assert.T(t, IsNull(Negate(NilValueVal)))
+	assert.T(t, IsNull(Negate(nil)))
+
+	v = Negate(NewStringValue("5"))
+	assert.Tf(t, v.Err() && v.Type() == ErrorType, "strings are not negated %v", v)
+	assert.T(t, Negate(NewBoolValue(true)).Err())
+}
+
+func TestDivide(t *testing.T) {
+	v := Divide(NewIntValue(7), NewIntValue(2), false)
+	assert.Tf(t, v.Type() == IntType && v.Value() == int64(3), "int division %v", v)
+	v = Divide(NewNumberValue(7), NewIntValue(2), false)
+	assert.Tf(t, v.Type() == NumberType && v.Value() == 3.5, "%v", v)
+	v = Modulo(NewIntValue(7), NewIntValue(3), false)
+	assert.Tf(t, v.Value() == int64(1), "%v", v)
+	v = Modulo(NewNumberValue(7.5), NewIntValue(2), false)
+	assert.Tf(t, v.Value() == float64(1), "%v", v)
+	v = Divide(NewIntValue(math.MinInt64), NewIntValue(-1), false)
+	assert.Tf(t, v.Type() == NumberType && v.Value() == -float64(math.MinInt64), "overflow %v", v)
+
+	// zero divisors are NULL, or errors with strict
+	for _, zero := range []struct{ a, b Value }{
+		{NewIntValue(7), NewIntValue(0)},
+		{NewNumberValue(7.5), NewNumberValue(0)},
+		{NewIntValue(7), NewNumberValue(0)},
+	} {
+		v = Divide(zero.a, zero.b, false)
+		assert.Tf(t, IsNull(v), "%v / %v is null but got %v", zero.a, zero.b, v)
+		v = Modulo(zero.a, zero.b, false)
+		assert.Tf(t, IsNull(v), "%v %% %v is null but got %v", zero.a, zero.b, v)
+		v = Divide(zero.a, zero.b, true)
+		assert.Tf(t, v.Err() && v.ToString() == "Division by zero", "%v", v)
+		v = Modulo(zero.a, zero.b, true)
+		assert.Tf(t, v.Err() && v.ToString() == "Modulus by zero", "%v", v)
+	}
+	assert.T(t, IsNull(Modulo(NewIntValue(7), NewNumberValue(0.5), false)))
+
+	assert.T(t, IsNull(Divide(NilValueVal, NewIntValue(0), true)))
+	assert.T(t, Divide(NewStringValue("a"), NewIntValue(2), false).Err())
+}
@@ -0,0 +1,66 @@
+package value
+
+import (
+	"regexp"
+	"sync"
+)
+
+var (
+	// RegexCacheSize is max number of compiled patterns kept, when
+	//  full the cache is reset
+	RegexCacheSize = 1000
+
+	regexMu    sync.RWMutex
+	regexCache = make(map[string]*regexEntry)
+)
+
+// compiled pattern, or the compile error so invalid patterns
+//  are not re-compiled for every row either
+type regexEntry struct {
+	re  *regexp.Regexp
+	err error
+}
+
+// RegexMatch does s match the regular expression pattern, compiled
+//  patterns are cached as the same pattern is evaluated for every row
+//
+//     RegexMatch("aaron", "^a.*")  =>  true, nil
+//     RegexMatch("aaron", "a(")    =>  false, error
+//
+func RegexMatch(s, pattern string) (bool, error) {
+	re, err := regexCompile(pattern)
+	if err != nil {
+		return false, err
+	}
+	return re.MatchString(s), nil
+}
+
+// RegexMatchValue is RegexMatch returning a BoolValue, or ErrorValue
+//  for invalid patterns, for use by the vm
+func RegexMatchValue(s, pattern string) Value {
+	match, err := RegexMatch(s, pattern)
+	if err != nil {
+		return NewErrorValuef("invalid regex pattern %q: %v", pattern, err)
+	}
+	return NewBoolValue(match)
+}
+
+func regexCompile(pattern string) (*regexp.Regexp, error) {
+	regexMu.RLock()
+	entry, ok := regexCache[pattern]
+	regexMu.RUnlock()
+	if ok {
+		return entry.re, entry.err
+	}
+
+	re, err := regexp.Compile(pattern)
+	entry = &regexEntry{re: re, err: err}
+
+	regexMu.Lock()
+	if len(regexCache) >= RegexCacheSize {
+		regexCache = make(map[string]*regexEntry)
+	}
+	regexCache[pattern] = entry
+	regexMu.Unlock()
+	return re, err
+}
@@ -0,0 +1,51 @@
+package value
+
+import (
+	"testing"
+
+	"github.com/bmizerany/assert"
+)
+
+func TestRegexMatch(t *testing.T) {
+	match, err := RegexMatch("aaron", "^a.*")
+	assert.Tf(t, err == nil && match, "should match %v", err)
+	match, err = RegexMatch("bob", "^a.*")
+	assert.Tf(t, err == nil && !match, "should not match %v", err)
+
+	// invalid pattern is an error, not a panic
+	match, err = RegexMatch("aaron", "a(")
+	assert.Tf(t, err != nil && !match, "invalid pattern should error")
+
+	v := RegexMatchValue("aaron", "r.n$")
+	assert.Tf(t, v.Type() == BoolType && v.Value() == true, "%#v", v)
+	v = RegexMatchValue("aaron", "a(")
+	assert.Tf(t, v.Type() == ErrorType, "invalid pattern should be ErrorValue %#v", v)
+}
+
+func TestRegexMatchCache(t *testing.T) {
+	pattern := `^cache-[0-9]+$`
+	re1, err := regexCompile(pattern)
+	assert.Tf(t, err == nil, "no error %v", err)
+	re2, _ := regexCompile(pattern)
+	assert.Tf(t, re1 == re2, "second compile should be cache hit")
+
+	regexMu.RLock()
+	entry, ok := regexCache[pattern]
+	regexMu.RUnlock()
+	assert.Tf(t, ok && entry.re == re1, "pattern should be cached")
+
+	// invalid patterns are cached too
+	_, err1 := regexCompile("a(")
+	_, err2 := regexCompile("a(")
+	assert.Tf(t, err1 != nil && err1 == err2, "invalid pattern should be cached %v", err1)
+
+	// cache is reset once full
+	size := RegexCacheSize
+	defer func() { RegexCacheSize = size }()
+	RegexCacheSize = 1
+	RegexMatch("x", "^x$")
+	regexMu.RLock()
+	ct := len(regexCache)
+	regexMu.RUnlock()
+	assert.Tf(t, ct == 1, "cache should be bounded %d", ct)
+}
@@ -0,0 +1,72 @@
+package value
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+var (
+	_ Value = (*RowValue)(nil)
+	_ Map   = (*RowValue)(nil)
+)
+
+// RowValue is a multi-column result, ie of a table function, an ordered
+//  set of named values.  A projection expands a RowValue column into one
+//  output column per field, named by the field, rather than writing a
+//  single column:
+//
+//     SELECT user_id, split_name(name) FROM users
+//        =>  user_id, first, last
+//
+type RowValue struct {
+	cols []string
+	vals []Value
+	rv   reflect.Value
+}
+
+// A row of the named columns and their values, nil values are NilValue.
+//  The values are matched to the columns by position, missing values are
+//  NilValue and extra values are dropped.  The slices are copied, so the
+//  caller may re-use them.
+func NewRowValue(cols []string, vals []Value) RowValue {
+	rowCols := make([]string, len(cols))
+	copy(rowCols, cols)
+	rowVals := make([]Value, len(cols))
+	for i := range rowVals {
+		if i < len(vals) && vals[i] != nil {
+			rowVals[i] = vals[i]
+		} else {
+			rowVals[i] = NilValueVal
+		}
+	}
+	return RowValue{cols: rowCols, vals: rowVals, rv: reflect.ValueOf(rowVals)}
+}
+
+func (m RowValue) Nil() bool                    { return len(m.cols) == 0 }
+func (m RowValue) Err() bool                    { return false }
+func (m RowValue) Type() ValueType              { return RowType }
+func (m RowValue) Rv() reflect.Value            { return m.rv }
+func (m RowValue) Value() interface{}           { return m.MapValue().Val() }
+func (m RowValue) Columns() []string            { return m.cols }
+func (m RowValue) Values() []Value              { return m.vals }
+func (m RowValue) MarshalJSON() ([]byte, error) { return json.Marshal(m.MapValue().Val()) }
+func (m RowValue) ToString() string             { return fmt.Sprintf("%v", m.MapValue().Val()) }
+
+// Get the value of a column, false if there is no such column
+func (m RowValue) Get(col string) (Value, bool) {
+	for i, c := range m.cols {
+		if c == col {
+			return m.vals[i], true
+		}
+	}
+	return nil, false
+}
+
+func (m RowValue) MapValue() MapValue {
+	mv := make(map[string]Value, len(m.cols))
+	for i, col := range m.cols {
+		mv[col] = m.vals[i]
+	}
+	return MapValue{v: mv, rv: reflect.ValueOf(mv)}
+}
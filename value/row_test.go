@@ -0,0 +1,39 @@
+package value
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/bmizerany/assert"
+)
+
+func TestRowValue(t *testing.T) {
+	row := NewRowValue([]string{"first", "last"}, []Value{NewStringValue("bob"), nil})
+	assert.T(t, row.Type() == RowType && row.Type().String() == "row")
+	assert.T(t, len(row.Columns()) == 2 && !row.Nil())
+	v, ok := row.Get("first")
+	assert.T(t, ok && v.Value() == "bob")
+	v, ok = row.Get("last")
+	assert.Tf(t, ok && v.Type() == NilType, "nil values are NilValue %v", v)
+	_, ok = row.Get("middle")
+	assert.T(t, !ok)
+
+	by, err := json.Marshal(NewRowValue([]string{"a", "b"}, []Value{NewIntValue(1), NewStringValue("x")}))
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Tf(t, string(by) == `{"a":1,"b":"x"}`, "%s", by)
+
+	// the input is copied, not written to
+	vals := []Value{NewIntValue(1), nil}
+	row = NewRowValue([]string{"a", "b"}, vals)
+	assert.T(t, vals[1] == nil)
+	vals[0] = NewIntValue(2)
+	v, _ = row.Get("a")
+	assert.Tf(t, v.Value() == int64(1), "%v", v)
+
+	// missing values are NULL, extra values dropped
+	row = NewRowValue([]string{"a", "b"}, []Value{NewIntValue(1)})
+	v, ok = row.Get("b")
+	assert.Tf(t, ok && v.Type() == NilType, "%v", v)
+	row = NewRowValue([]string{"a"}, []Value{NewIntValue(1), NewIntValue(2)})
+	assert.Tf(t, len(row.Values()) == 1, "%v", row.Values())
+}
@@ -0,0 +1,53 @@
+package value
+
+import (
+	"strings"
+	"time"
+)
+
+// TruncTime truncates a time to the start of its year, month, day, hour,
+//  minute or second, in the time's own zone so a day starts at midnight
+//  in that zone not UTC (see TimeValue.InZone()).  Units are case
+//  insensitive, an unknown unit returns the zero time.
+//
+//     TruncTime(2015-07-04 12:34:56, "hour")   =>  2015-07-04 12:00:00
+//     TruncTime(2015-07-04 12:34:56, "month")  =>  2015-07-01 00:00:00
+//
+func TruncTime(t time.Time, unit string) time.Time {
+	loc := t.Location()
+	switch strings.ToLower(unit) {
+	case "year":
+		return time.Date(t.Year(), 1, 1, 0, 0, 0, 0, loc)
+	case "month":
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc)
+	case "day":
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	case "hour":
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, loc)
+	case "minute":
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, loc)
+	case "second":
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, loc)
+	}
+	return time.Time{}
+}
+
+// TimeEqualWithin is true if the times differ by at most tol, ie for
+//  timestamps of different sources that differ by sub-second noise.  A
+//  tol of 0 is exact equality.
+func TimeEqualWithin(a, b TimeValue, tol time.Duration) bool {
+	diff := a.v.Sub(b.v)
+	if diff < 0 {
+		diff = -diff
+	}
+	return diff <= tol
+}
+
+// Trunc is TruncTime() of this time, false for an unknown unit
+func (m TimeValue) Trunc(unit string) (TimeValue, bool) {
+	t := TruncTime(m.v, unit)
+	if t.IsZero() {
+		return TimeZeroValue, false
+	}
+	return NewTimeValue(t), true
+}
@@ -0,0 +1,51 @@
+package value
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+)
+
+func TestTruncTime(t *testing.T) {
+	ts := time.Date(2015, 7, 4, 12, 34, 56, 789, time.UTC)
+	tests := []struct {
+		unit string
+		want time.Time
+	}{
+		{"year", time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"month", time.Date(2015, 7, 1, 0, 0, 0, 0, time.UTC)},
+		{"day", time.Date(2015, 7, 4, 0, 0, 0, 0, time.UTC)},
+		{"HOUR", time.Date(2015, 7, 4, 12, 0, 0, 0, time.UTC)},
+		{"minute", time.Date(2015, 7, 4, 12, 34, 0, 0, time.UTC)},
+		{"second", time.Date(2015, 7, 4, 12, 34, 56, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got := TruncTime(ts, tt.unit)
+		assert.Tf(t, got.Equal(tt.want), "%s: expected %v got %v", tt.unit, tt.want, got)
+	}
+	assert.T(t, TruncTime(ts, "fortnight").IsZero())
+
+	// a day in the zone of the time, not utc
+	denver := time.FixedZone("MDT", -6*3600)
+	day, ok := NewTimeValue(ts).InZone(denver).Trunc("day")
+	assert.T(t, ok)
+	assert.Tf(t, day.Val().Equal(time.Date(2015, 7, 4, 6, 0, 0, 0, time.UTC)), "%v", day.Val())
+	day, ok = NewTimeValue(time.Date(2015, 7, 4, 3, 0, 0, 0, time.UTC)).InZone(denver).Trunc("day")
+	assert.Tf(t, day.Val().Equal(time.Date(2015, 7, 3, 6, 0, 0, 0, time.UTC)), "previous day in denver %v", day.Val())
+
+	_, ok = NewTimeValue(ts).Trunc("fortnight")
+	assert.T(t, !ok)
+}
+
+func TestTimeEqualWithin(t *testing.T) {
+	ts := NewTimeValue(time.Date(2015, 7, 4, 12, 0, 0, 0, time.UTC))
+	within := NewTimeValue(ts.Val().Add(400 * time.Millisecond))
+	outside := NewTimeValue(ts.Val().Add(-1500 * time.Millisecond))
+	assert.T(t, TimeEqualWithin(ts, within, time.Second))
+	assert.T(t, TimeEqualWithin(within, ts, time.Second))
+	assert.T(t, TimeEqualWithin(ts, NewTimeValue(ts.Val().Add(time.Second)), time.Second))
+	assert.T(t, !TimeEqualWithin(ts, outside, time.Second))
+	assert.T(t, !TimeEqualWithin(outside, ts, time.Second))
+	assert.T(t, TimeEqualWithin(ts, ts, 0) && !TimeEqualWithin(ts, within, 0))
+}
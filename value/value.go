@@ -5,9 +5,12 @@ import (
 	"fmt"
 	"math"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 
 	u "github.com/araddon/gou"
 )
@@ -29,6 +32,7 @@ var (
 	mapFloatRv  = reflect.ValueOf(map[string]float64{"hello": float64(1.1)})
 	mapBoolRv   = reflect.ValueOf(map[string]bool{"hello": true})
 	timeRv      = reflect.ValueOf(time.Time{})
+	durationRv  = reflect.ValueOf(time.Duration(0))
 	nilRv       = reflect.ValueOf(nil)
 
 	RV_ZERO     = reflect.Value{}
@@ -50,7 +54,8 @@ var (
 	TimeZeroValue       = NewTimeValue(time.Time{})
 	ErrValue            = NewErrorValue("")
 
-	_ Value = (StringValue)(EmptyStringValue)
+	_ Value        = (StringValue)(EmptyStringValue)
+	_ NumericValue = (*DurationValue)(nil)
 
 	// force some types to implement interfaces
 	_ Map = (MapIntValue)(EmptyMapIntValue)
@@ -72,6 +77,7 @@ const (
 	BoolType       ValueType = 12
 	TimeType       ValueType = 13
 	ByteSliceType  ValueType = 14
+	DurationType   ValueType = 15
 	StringType     ValueType = 20
 	StringsType    ValueType = 21
 	MapValueType   ValueType = 30
@@ -81,8 +87,40 @@ const (
 	MapBoolType    ValueType = 34
 	SliceValueType ValueType = 40
 	StructType     ValueType = 50
+	RowType        ValueType = 60
+	JsonType       ValueType = 70
+
+	// Types >= CustomTypeStart are reserved for extensions, see RegisterValueType()
+	CustomTypeStart ValueType = 100
+)
+
+var (
+	customTypeMu    sync.Mutex
+	customTypeNext  = CustomTypeStart
+	customTypeNames = make(map[ValueType]string)
+	customTypes     = make(map[string]ValueType)
 )
 
+// RegisterValueType reserves a unique ValueType for an extension value type
+//  (Decimal, Geo, etc) in the range >= CustomTypeStart, and the name is
+//  used by ValueType.String().  Registering the same name again returns the
+//  same ValueType.  Panics if the custom type range is exhausted.
+func RegisterValueType(name string) ValueType {
+	customTypeMu.Lock()
+	defer customTypeMu.Unlock()
+	if vt, ok := customTypes[name]; ok {
+		return vt
+	}
+	if customTypeNext == 0 {
+		panic(fmt.Sprintf("cannot register value type %q, no more custom types available", name))
+	}
+	vt := customTypeNext
+	customTypeNext++ // wraps to 0 after 255
+	customTypes[name] = vt
+	customTypeNames[vt] = name
+	return vt
+}
+
 func (m ValueType) String() string {
 	switch m {
 	case NilType:
@@ -101,6 +139,8 @@ func (m ValueType) String() string {
 		return "time"
 	case ByteSliceType:
 		return "[]byte"
+	case DurationType:
+		return "duration"
 	case StringType:
 		return "string"
 	case StringsType:
@@ -119,11 +159,94 @@ func (m ValueType) String() string {
 		return "[]value"
 	case StructType:
 		return "struct"
+	case RowType:
+		return "row"
+	case JsonType:
+		return "json"
 	default:
+		if m >= CustomTypeStart {
+			customTypeMu.Lock()
+			name, ok := customTypeNames[m]
+			customTypeMu.Unlock()
+			if ok {
+				return name
+			}
+		}
 		return "invalid"
 	}
 }
 
+// GoType is the native go type backing this ValueType, the inverse of
+//  NewValue(), ie IntType is int64.  Returns nil for types without a
+//  concrete backing type (nil, error, unknown, struct, custom)
+func (m ValueType) GoType() reflect.Type {
+	switch m {
+	case NumberType:
+		return floatRv.Type()
+	case IntType:
+		return int64Rv.Type()
+	case BoolType:
+		return boolRv.Type()
+	case TimeType:
+		return timeRv.Type()
+	case ByteSliceType:
+		return byteSliceRv.Type()
+	case DurationType:
+		return durationRv.Type()
+	case StringType:
+		return stringRv.Type()
+	case StringsType:
+		return stringsRv.Type()
+	case MapValueType:
+		return reflect.TypeOf(map[string]interface{}(nil))
+	case MapIntType:
+		return mapIntRv.Type()
+	case MapStringType:
+		return mapStringRv.Type()
+	case MapNumberType:
+		return mapFloatRv.Type()
+	case MapBoolType:
+		return mapBoolRv.Type()
+	case SliceValueType:
+		return reflect.TypeOf([]Value(nil))
+	}
+	return nil
+}
+
+// IsNumeric is true for the types that can be used in numeric operations
+//  such as sum/avg aggregation:  Number, Int, Time, Duration, Bool
+func (m ValueType) IsNumeric() bool {
+	switch m {
+	case NumberType, IntType, TimeType, DurationType, BoolType:
+		return true
+	}
+	return false
+}
+
+// IsNumeric is this Value one of the numeric types, see ValueType.IsNumeric()
+func IsNumeric(v Value) bool {
+	if v == nil {
+		return false
+	}
+	return v.Type().IsNumeric()
+}
+
+// IsNull is this sql NULL, a nil or NilValue.  Unlike Value.Nil() which is
+//  also true for empty values such as 0 or "", zero is not NULL.
+func IsNull(v Value) bool {
+	return v == nil || v.Type() == NilType
+}
+
+// IsZero is this a zero numeric or time value, false for NULL, see IsNull()
+func IsZero(v Value) bool {
+	if zv, ok := v.(interface {
+		IsZero() bool
+	}); ok {
+		return zv.IsZero()
+	}
+	return false
+}
+
 type emptyStruct struct{}
 
 type (
@@ -174,6 +297,10 @@ type (
 		v  time.Time
 		rv reflect.Value
 	}
+	DurationValue struct {
+		v  time.Duration
+		rv reflect.Value
+	}
 	StringsValue struct {
 		v  []string
 		rv reflect.Value
@@ -217,7 +344,34 @@ type (
 	NilValue struct{}
 )
 
-// Create a new Value type with native go value
+// small ints 0-255, bools and the empty string are interned by NewValue
+//  already boxed as Value, they are common (counts, flags, enums) so this
+//  avoids allocating per value, boxing an IntValue into Value allocates.
+const internIntMax = 255
+
+var (
+	internInts = func() []Value {
+		vals := make([]Value, internIntMax+1)
+		for i := range vals {
+			vals[i] = NewIntValue(int64(i))
+		}
+		return vals
+	}()
+	internTrue        Value = BoolValueTrue
+	internFalse       Value = BoolValueFalse
+	internEmptyString Value = EmptyStringValue
+)
+
+func newIntValueInterned(v int64) Value {
+	if v >= 0 && v <= internIntMax {
+		return internInts[v]
+	}
+	return NewIntValue(v)
+}
+
+// Create a new Value type with native go value.  Common scalars, bools,
+//  small ints and the empty string, are returned as shared interned
+//  values.
 func NewValue(goVal interface{}) Value {
 
 	switch val := goVal.(type) {
@@ -230,12 +384,15 @@ func NewValue(goVal interface{}) Value {
 	case float32:
 		return NewNumberValue(float64(val))
 	case int:
-		return NewIntValue(int64(val))
+		return newIntValueInterned(int64(val))
 	case int32:
-		return NewIntValue(int64(val))
+		return newIntValueInterned(int64(val))
 	case int64:
-		return NewIntValue(val)
+		return newIntValueInterned(val)
 	case string:
+		if val == "" {
+			return internEmptyString
+		}
 		return NewStringValue(val)
 	case []string:
 		return NewStringsValue(val)
@@ -243,12 +400,19 @@ func NewValue(goVal interface{}) Value {
 	// 	return NewByteSliceValue([]byte(val))
 	case []byte:
 		return NewByteSliceValue(val)
+	case []Value:
+		return NewSliceValues(val)
 	case bool:
-		return NewBoolValue(val)
+		if val {
+			return internTrue
+		}
+		return internFalse
 	case time.Time:
 		return NewTimeValue(val)
 	case *time.Time:
 		return NewTimeValue(*val)
+	case time.Duration:
+		return NewDurationValue(val)
 	case map[string]interface{}:
 		return NewMapValue(val)
 	case map[string]string:
@@ -275,6 +439,43 @@ func NewValue(goVal interface{}) Value {
 	return NilValueVal
 }
 
+// NewValueJSON is NewValue() for values decoded from json, where every
+//  number is a float64 (or json.Number with decoder UseNumber()).  Whole
+//  numbers are IntValue so integer columns (ids) keep their type, and
+//  nested objects and arrays are converted the same way.  With plain
+//  float64 decoding 5.0 is indistinguishable from 5, use json.Number to
+//  keep "5.0" a NumberValue.
+func NewValueJSON(goVal interface{}) Value {
+	switch val := goVal.(type) {
+	case json.Number:
+		if iv, err := val.Int64(); err == nil {
+			return newIntValueInterned(iv)
+		}
+		if fv, err := val.Float64(); err == nil {
+			return NewNumberValue(fv)
+		}
+		return NewStringValue(val.String())
+	case float64:
+		if val == math.Trunc(val) && val >= math.MinInt64 && val < math.MaxInt64 {
+			return newIntValueInterned(int64(val))
+		}
+		return NewNumberValue(val)
+	case map[string]interface{}:
+		mv := make(map[string]Value, len(val))
+		for k, v := range val {
+			mv[k] = NewValueJSON(v)
+		}
+		return MapValue{v: mv, rv: reflect.ValueOf(mv)}
+	case []interface{}:
+		vals := make([]Value, len(val))
+		for i, v := range val {
+			vals[i] = NewValueJSON(v)
+		}
+		return NewSliceValues(vals)
+	}
+	return NewValue(goVal)
+}
+
 func ValueTypeFromRT(rt reflect.Type) ValueType {
 	switch rt {
 	case reflect.TypeOf(NilValue{}):
@@ -285,6 +486,8 @@ func ValueTypeFromRT(rt reflect.Type) ValueType {
 		return IntType
 	case reflect.TypeOf(TimeValue{}):
 		return TimeType
+	case reflect.TypeOf(DurationValue{}):
+		return DurationType
 	case reflect.TypeOf(BoolValue{}):
 		return BoolType
 	case reflect.TypeOf(StringValue{}):
@@ -309,6 +512,10 @@ func ValueTypeFromRT(rt reflect.Type) ValueType {
 		return MapBoolType
 	case reflect.TypeOf(StructValue{}):
 		return StructType
+	case reflect.TypeOf(RowValue{}):
+		return RowType
+	case reflect.TypeOf(JsonValue{}):
+		return JsonType
 	case reflect.TypeOf(ErrorValue{}):
 		return ErrorType
 	//case rt.Kind().String() == "value.Value"
@@ -325,11 +532,50 @@ func ValueTypeFromRT(rt reflect.Type) ValueType {
 	return NilType
 }
 
+// ZeroValue is the zero/default Value of a type, for filling missing
+//  columns (outer joins, sparse rows).  Types without a zero (nil, error,
+//  unknown, struct and custom types) are NilValueVal, note it is a NULL
+//  unlike the others, see IsNull().  Maps are new empty maps.
+func ZeroValue(t ValueType) Value {
+	switch t {
+	case NumberType:
+		return NewNumberValue(0)
+	case IntType:
+		return NewIntValue(0)
+	case BoolType:
+		return BoolValueFalse
+	case TimeType:
+		return TimeZeroValue
+	case ByteSliceType:
+		return NewByteSliceValue([]byte{})
+	case DurationType:
+		return NewDurationValue(0)
+	case StringType:
+		return EmptyStringValue
+	case StringsType:
+		return EmptyStringsValue
+	case MapValueType:
+		return NewMapValue(make(map[string]interface{}))
+	case MapIntType:
+		return NewMapIntValue(make(map[string]int64))
+	case MapStringType:
+		return NewMapStringValue(make(map[string]string))
+	case MapNumberType:
+		return NewMapNumberValue(make(map[string]float64))
+	case MapBoolType:
+		return NewMapBoolValue(make(map[string]bool))
+	case SliceValueType:
+		return NewSliceValues([]Value{})
+	}
+	return NilValueVal
+}
+
 func NewNumberValue(v float64) NumberValue {
 	return NumberValue{v: v, rv: reflect.ValueOf(v)}
 }
 
 func (m NumberValue) Nil() bool                         { return m.v == 0 }
+func (m NumberValue) IsZero() bool                      { return m.v == 0 }
 func (m NumberValue) Err() bool                         { return false }
 func (m NumberValue) Type() ValueType                   { return NumberType }
 func (m NumberValue) Rv() reflect.Value                 { return m.rv }
@@ -346,6 +592,7 @@ func NewIntValue(v int64) IntValue {
 }
 
 func (m IntValue) Nil() bool                         { return m.v == 0 }
+func (m IntValue) IsZero() bool                      { return m.v == 0 }
 func (m IntValue) Err() bool                         { return false }
 func (m IntValue) Type() ValueType                   { return IntType }
 func (m IntValue) Rv() reflect.Value                 { return m.rv }
@@ -391,6 +638,12 @@ func (m StringValue) NumberValue() NumberValue           { fv, _ := ToFloat64(m.
 func (m StringValue) StringsValue() StringsValue         { return NewStringsValue([]string{m.v}) }
 func (m StringValue) ToString() string                   { return m.v }
 
+// Len is the length in bytes, sql LENGTH(), see RuneLen() for characters
+func (m StringValue) Len() int { return len(m.v) }
+
+// RuneLen is the length in characters (runes), sql CHAR_LENGTH()
+func (m StringValue) RuneLen() int { return utf8.RuneCountInString(m.v) }
+
 func (m StringValue) IntValue() IntValue {
 	iv, _ := ToInt64(m.Rv())
 	return NewIntValue(iv)
@@ -426,15 +679,77 @@ func (m StringsValue) IntValue() IntValue {
 }
 func (m StringsValue) ToString() string  { return strings.Join(m.v, ",") }
 func (m StringsValue) Strings() []string { return m.v }
+
+// Set of the distinct strings, case sensitive, see FoldSet() for case
+//  insensitive.  Ranging over the map is in random order, use SortedSet()
+//  where the order is visible.
 func (m StringsValue) Set() map[string]struct{} {
 	setvals := make(map[string]struct{})
 	for _, sv := range m.v {
-		// Are we sure about this ToLower?
-		//setvals[strings.ToLower(sv)] = EmptyStruct
 		setvals[sv] = EmptyStruct
 	}
 	return setvals
 }
+
+// FoldSet is Set() of the lower-cased strings, for case insensitive
+//  membership
+func (m StringsValue) FoldSet() map[string]struct{} {
+	setvals := make(map[string]struct{})
+	for _, sv := range m.v {
+		setvals[strings.ToLower(sv)] = EmptyStruct
+	}
+	return setvals
+}
+
+// SortedSet is the distinct strings of Set() in ascending order
+func (m StringsValue) SortedSet() []string {
+	set := m.Set()
+	vals := make([]string, 0, len(set))
+	for sv := range set {
+		vals = append(vals, sv)
+	}
+	sort.Strings(vals)
+	return vals
+}
+
+// OverlapsSet is true if the two share any string, see Set()
+func (m StringsValue) OverlapsSet(other StringsValue) bool {
+	set := m.Set()
+	for _, sv := range other.v {
+		if _, ok := set[sv]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// IsSubsetOf is true if every string is in other, duplicates and order
+//  are ignored, an empty StringsValue is a subset of anything
+func (m StringsValue) IsSubsetOf(other StringsValue) bool {
+	set := other.Set()
+	for _, sv := range m.v {
+		if _, ok := set[sv]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// EqualsSet is true if the two have the same distinct strings, in any
+//  order
+func (m StringsValue) EqualsSet(other StringsValue) bool {
+	return len(m.Set()) == len(other.Set()) && m.IsSubsetOf(other)
+}
+
+// Sort the strings in place, in ascending order
+func (m *StringsValue) Sort() { sort.Stable(sort.StringSlice(m.v)) }
+
+// Sorted returns a sorted copy, leaving the original ordering unchanged
+func (m StringsValue) Sorted() StringsValue {
+	sv := NewStringsValue(append([]string(nil), m.v...))
+	sv.Sort()
+	return sv
+}
 func (m StringsValue) SliceValue() []Value {
 	vs := make([]Value, len(m.v))
 	for i, v := range m.v {
@@ -477,6 +792,102 @@ func (m SliceValue) ToString() string {
 	return strings.Join(sv, ",")
 }
 
+// ToStrings converts each element to string using ToString(), nil
+//  elements become ""
+func (m SliceValue) ToStrings() []string {
+	sv := make([]string, len(m.v))
+	for i, val := range m.v {
+		if val == nil {
+			continue
+		}
+		sv[i] = val.ToString()
+	}
+	return sv
+}
+
+// ToStringsSkipNil same as ToStrings() but nil elements are skipped
+func (m SliceValue) ToStringsSkipNil() []string {
+	sv := make([]string, 0, len(m.v))
+	for _, val := range m.v {
+		if val == nil || val.Type() == NilType {
+			continue
+		}
+		sv = append(sv, val.ToString())
+	}
+	return sv
+}
+
+// ToStringsValue converts to a StringsValue, see ToStrings()
+func (m SliceValue) ToStringsValue() StringsValue { return NewStringsValue(m.ToStrings()) }
+
+// Filter returns a new slice of the elements for which pred is true
+func (m SliceValue) Filter(pred func(Value) bool) SliceValue {
+	vals := make([]Value, 0, len(m.v))
+	for _, val := range m.v {
+		if pred(val) {
+			vals = append(vals, val)
+		}
+	}
+	return NewSliceValues(vals)
+}
+
+// Map returns a new slice of fn applied to each element
+func (m SliceValue) Map(fn func(Value) Value) SliceValue {
+	vals := make([]Value, len(m.v))
+	for i, val := range m.v {
+		vals[i] = fn(val)
+	}
+	return NewSliceValues(vals)
+}
+
+// Reduce folds the elements left to right into an accumulator starting
+//  with init, an empty slice returns init
+func (m SliceValue) Reduce(fn func(acc, v Value) Value, init Value) Value {
+	acc := init
+	for _, val := range m.v {
+		acc = fn(acc, val)
+	}
+	return acc
+}
+
+// Index returns the element at i, false if out of range
+func (m SliceValue) Index(i int) (Value, bool) {
+	if i < 0 || i >= len(m.v) {
+		return nil, false
+	}
+	return m.v[i], true
+}
+
+// OfType returns a new slice of only the elements of given type, for
+//  heterogeneous (json) arrays, nil elements are NilType
+func (m SliceValue) OfType(t ValueType) SliceValue {
+	return m.Filter(func(val Value) bool {
+		if val == nil {
+			return t == NilType
+		}
+		return val.Type() == t
+	})
+}
+
+// Flatten returns a new slice with the elements of each element that is
+//  itself a SliceValue in its place, scalars are kept as is.  Only one
+//  level is flattened, deeper nested slices remain slices:
+//
+//     [[1,2],[3,4]]     =>  [1,2,3,4]
+//     [1,[2,[3]],4]     =>  [1,2,[3],4]
+//
+func (m SliceValue) Flatten() SliceValue {
+	vals := make([]Value, 0, len(m.v))
+	for _, val := range m.v {
+		if sv, ok := val.(SliceValue); ok {
+			vals = append(vals, sv.v...)
+			continue
+		}
+		vals = append(vals, val)
+	}
+	return NewSliceValues(vals)
+}
+
 func (m *SliceValue) Append(v Value)              { m.v = append(m.v, v) }
 func (m SliceValue) MarshalJSON() ([]byte, error) { return json.Marshal(m.v) }
 func (m SliceValue) Len() int                     { return len(m.v) }
@@ -519,6 +930,11 @@ func (m MapValue) MapFloat() map[string]float64 {
 	}
 	return mv
 }
+// Get returns the value for key, false if it doesn't exist
+func (m MapValue) Get(key string) (Value, bool) {
+	v, ok := m.v[key]
+	return v, ok
+}
 func (m MapValue) MapString() map[string]string {
 	mv := make(map[string]string, len(m.v))
 	for n, v := range m.v {
@@ -624,6 +1040,19 @@ func (m MapIntValue) SliceValue() []Value {
 	return vs
 }
 
+// MergeAdd returns a new map of the sum of values for keys in both maps,
+//  and the union of keys only in one, neither map is modified
+func (m MapIntValue) MergeAdd(other MapIntValue) MapIntValue {
+	mv := make(map[string]int64, len(m.v)+len(other.v))
+	for k, v := range m.v {
+		mv[k] = v
+	}
+	for k, v := range other.v {
+		mv[k] += v
+	}
+	return NewMapIntValue(mv)
+}
+
 func NewMapNumberValue(v map[string]float64) MapNumberValue {
 	return MapNumberValue{v: v, rv: reflect.ValueOf(v)}
 }
@@ -687,6 +1116,19 @@ func (m MapBoolValue) SliceValue() []Value {
 	return vs
 }
 
+// Comparable may be implemented by the struct wrapped in a StructValue
+//  to supply its own equality and ordering, so struct columns can be
+//  joined, grouped and sorted on.  Without it struct values are equal if
+//  their json encodings are.
+type Comparable interface {
+	// CompareTo returns -1, 0, 1 for less than, equal to, greater than
+	//  the other wrapped struct
+	CompareTo(other interface{}) int
+	// EqualityKey is the same for all values that compare equal, and is
+	//  the hash key of the value in join and group by
+	EqualityKey() string
+}
+
 func NewStructValue(v interface{}) StructValue {
 	return StructValue{v: v, rv: reflect.ValueOf(v)}
 }
@@ -698,14 +1140,122 @@ func (m StructValue) Rv() reflect.Value                 { return m.rv }
 func (m StructValue) CanCoerce(toRv reflect.Value) bool { return false }
 func (m StructValue) Value() interface{}                { return m.v }
 func (m StructValue) Val() interface{}                  { return m.v }
-func (m StructValue) MarshalJSON() ([]byte, error)      { return json.Marshal(m.v) }
 func (m StructValue) ToString() string                  { return fmt.Sprintf("%v", m.v) }
 
+// EqualityKey is the key struct values are joined and grouped by, from
+//  Comparable if the struct implements it, else its json encoding
+func (m StructValue) EqualityKey() string {
+	if c, ok := m.v.(Comparable); ok {
+		return c.EqualityKey()
+	}
+	if by, err := m.MarshalJSON(); err == nil {
+		return string(by)
+	}
+	return m.ToString()
+}
+
+// Compare to another struct value returning -1, 0, 1, with Comparable if
+//  the struct implements it, else by EqualityKey().  CompareTo is only
+//  called with the same type, structs of different types are ordered by
+//  their type name.
+func (m StructValue) Compare(other StructValue) int {
+	if reflect.TypeOf(m.v) != reflect.TypeOf(other.v) {
+		if c := strings.Compare(fmt.Sprintf("%T", m.v), fmt.Sprintf("%T", other.v)); c != 0 {
+			return c
+		}
+		return strings.Compare(m.EqualityKey(), other.EqualityKey())
+	}
+	if c, ok := m.v.(Comparable); ok {
+		return c.CompareTo(other.v)
+	}
+	return strings.Compare(m.EqualityKey(), other.EqualityKey())
+}
+
+// Equal is true if Compare() is 0
+func (m StructValue) Equal(other StructValue) bool { return m.Compare(other) == 0 }
+
+// MarshalJSON of the underlying struct, returns error instead of
+//  recursing forever if the struct is self-referential (cyclic)
+//  or nested deeper than StructMaxDepth
+func (m StructValue) MarshalJSON() ([]byte, error) {
+	if err := checkCycle(m.rv, make(map[uintptr]bool), 0); err != nil {
+		return nil, err
+	}
+	return json.Marshal(m.v)
+}
+
+// StructMaxDepth is the max nesting depth of a StructValue that will be
+//  marshaled to json
+var StructMaxDepth = 200
+
+// checkCycle walks the json visible fields of rv, and errors if a pointer
+//  map or slice refers back to one of its parents
+func checkCycle(rv reflect.Value, parents map[uintptr]bool, depth int) error {
+	if depth > StructMaxDepth {
+		return fmt.Errorf("struct exceeds max depth %d for json marshal", StructMaxDepth)
+	}
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice:
+		if rv.IsNil() {
+			return nil
+		}
+		if rv.Kind() == reflect.Slice && rv.Len() == 0 {
+			return nil
+		}
+		ptr := rv.Pointer()
+		if parents[ptr] {
+			return fmt.Errorf("cannot marshal cyclic struct of type %v", rv.Type())
+		}
+		parents[ptr] = true
+		defer delete(parents, ptr)
+		switch rv.Kind() {
+		case reflect.Ptr:
+			return checkCycle(rv.Elem(), parents, depth+1)
+		case reflect.Map:
+			for _, key := range rv.MapKeys() {
+				if err := checkCycle(rv.MapIndex(key), parents, depth+1); err != nil {
+					return err
+				}
+			}
+		case reflect.Slice:
+			for i := 0; i < rv.Len(); i++ {
+				if err := checkCycle(rv.Index(i), parents, depth+1); err != nil {
+					return err
+				}
+			}
+		}
+	case reflect.Interface:
+		if !rv.IsNil() {
+			return checkCycle(rv.Elem(), parents, depth+1)
+		}
+	case reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			if err := checkCycle(rv.Index(i), parents, depth+1); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		rt := rv.Type()
+		for i := 0; i < rv.NumField(); i++ {
+			f := rt.Field(i)
+			if f.PkgPath != "" || f.Tag.Get("json") == "-" {
+				// un-exported, or ignored fields are not marshaled
+				continue
+			}
+			if err := checkCycle(rv.Field(i), parents, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 func NewTimeValue(t time.Time) TimeValue {
 	return TimeValue{v: t, rv: reflect.ValueOf(t)}
 }
 
 func (m TimeValue) Nil() bool                         { return m.v.IsZero() }
+func (m TimeValue) IsZero() bool                      { return m.v.IsZero() }
 func (m TimeValue) Err() bool                         { return false }
 func (m TimeValue) Type() ValueType                   { return TimeType }
 func (m TimeValue) Rv() reflect.Value                 { return m.rv }
@@ -718,6 +1268,49 @@ func (m TimeValue) Float() float64                    { return float64(m.v.UnixN
 func (m TimeValue) Int() int64                        { return m.v.UnixNano() / 1e6 }
 func (m TimeValue) Time() time.Time                   { return m.v }
 
+// Sub returns the duration m-t
+func (m TimeValue) Sub(t TimeValue) DurationValue { return NewDurationValue(m.v.Sub(t.v)) }
+
+// InZone is the same instant in loc, so Int()/Float() are unchanged but
+//  calendar fields (day, hour) are in loc, ie for truncating to a day
+func (m TimeValue) InZone(loc *time.Location) TimeValue { return NewTimeValue(m.v.In(loc)) }
+
+// Duration, such as the difference of two times.  Numeric values
+//  Float()/Int() and Rv() are in milliseconds, same as TimeValue, Val()
+//  is the time.Duration
+//
+//     NewDurationValue(90 * time.Minute).ToString()  =>  "1h30m"
+//     NewDurationValue(90 * time.Minute).Int()       =>  5400000
+//
+func NewDurationValue(d time.Duration) DurationValue {
+	return DurationValue{v: d, rv: reflect.ValueOf(int64(d / time.Millisecond))}
+}
+
+func (m DurationValue) Nil() bool                         { return false }
+func (m DurationValue) IsZero() bool                      { return m.v == 0 }
+func (m DurationValue) Err() bool                         { return false }
+func (m DurationValue) Type() ValueType                   { return DurationType }
+func (m DurationValue) Rv() reflect.Value                 { return m.rv }
+func (m DurationValue) CanCoerce(toRv reflect.Value) bool { return CanCoerce(durationRv, toRv) }
+func (m DurationValue) Value() interface{}                { return m.v }
+func (m DurationValue) Val() time.Duration                { return m.v }
+func (m DurationValue) MarshalJSON() ([]byte, error)      { return json.Marshal(m.ToString()) }
+func (m DurationValue) Float() float64                    { return float64(m.v) / float64(time.Millisecond) }
+func (m DurationValue) Int() int64                        { return int64(m.v / time.Millisecond) }
+
+// ToString is time.Duration String() without trailing zero units, ie
+//  1h30m instead of 1h30m0s
+func (m DurationValue) ToString() string {
+	s := m.v.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = s[:len(s)-2]
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = s[:len(s)-2]
+	}
+	return s
+}
+
 func NewErrorValue(v string) ErrorValue {
 	return ErrorValue{v: v, rv: reflect.ValueOf(v)}
 }
@@ -751,5 +1344,5 @@ func (m NilValue) Rv() reflect.Value                 { return nilRv }
 func (m NilValue) CanCoerce(toRv reflect.Value) bool { return false }
 func (m NilValue) Value() interface{}                { return nil }
 func (m NilValue) Val() interface{}                  { return nil }
-func (m NilValue) MarshalJSON() ([]byte, error)      { return nil, nil }
+func (m NilValue) MarshalJSON() ([]byte, error)      { return []byte("null"), nil }
 func (m NilValue) ToString() string                  { return "" }
@@ -0,0 +1,540 @@
+package value
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+)
+
+var numericTests = []struct {
+	v       Value
+	numeric bool
+}{
+	{NewNumberValue(1.5), true},
+	{NewIntValue(1), true},
+	{NewBoolValue(true), true},
+	{NewTimeValue(time.Now()), true},
+	{NewStringValue("22.5"), false},
+	{NewStringsValue([]string{"a"}), false},
+	{NewByteSliceValue([]byte("a")), false},
+	{NewSliceValues([]Value{NewIntValue(1)}), false},
+	{NewMapValue(map[string]interface{}{"a": 1}), false},
+	{NewMapIntValue(map[string]int64{"a": 1}), false},
+	{NewMapStringValue(map[string]string{"a": "b"}), false},
+	{NewMapNumberValue(map[string]float64{"a": 1.1}), false},
+	{NewMapBoolValue(map[string]bool{"a": true}), false},
+	{NewStructValue(struct{}{}), false},
+	{NewErrorValue("bad"), false},
+	{NilValueVal, false},
+}
+
+func TestIsNumeric(t *testing.T) {
+	for _, nt := range numericTests {
+		assert.Tf(t, IsNumeric(nt.v) == nt.numeric, "expected IsNumeric=%v for %T", nt.numeric, nt.v)
+		assert.Tf(t, nt.v.Type().IsNumeric() == nt.numeric, "expected %s.IsNumeric()=%v", nt.v.Type(), nt.numeric)
+	}
+	assert.T(t, IsNumeric(nil) == false)
+	assert.T(t, UnknownType.IsNumeric() == false)
+}
+
+func TestIsZeroIsNull(t *testing.T) {
+	zeros := []Value{NewIntValue(0), NewNumberValue(0), NewTimeValue(time.Time{}), NewDurationValue(0)}
+	for _, v := range zeros {
+		assert.Tf(t, IsZero(v), "%T should be zero", v)
+		assert.Tf(t, !IsNull(v), "zero %T is not null", v)
+	}
+	nonZeros := []Value{NewIntValue(-1), NewNumberValue(0.5), NewTimeValue(time.Now()), NewDurationValue(time.Second)}
+	for _, v := range nonZeros {
+		assert.Tf(t, !IsZero(v), "%v should not be zero", v)
+	}
+	for _, v := range []Value{nil, NilValueVal} {
+		assert.Tf(t, IsNull(v), "%v should be null", v)
+		assert.Tf(t, !IsZero(v), "null %v is not zero", v)
+	}
+	assert.T(t, !IsZero(NewStringValue("")))
+	assert.T(t, !IsNull(NewStringValue("")))
+}
+
+func TestRegisterValueType(t *testing.T) {
+	decimal := RegisterValueType("decimal")
+	geo := RegisterValueType("geo")
+	assert.Tf(t, decimal >= CustomTypeStart, "custom type in reserved range %d", decimal)
+	assert.Tf(t, geo >= CustomTypeStart, "custom type in reserved range %d", geo)
+	assert.Tf(t, decimal != geo, "should be unique %d %d", decimal, geo)
+	assert.Tf(t, decimal.String() == "decimal", "%v", decimal.String())
+	assert.Tf(t, geo.String() == "geo", "%v", geo.String())
+	assert.T(t, RegisterValueType("decimal") == decimal)
+	assert.T(t, ValueType(CustomTypeStart-1).String() == "invalid")
+	assert.T(t, decimal.IsNumeric() == false)
+}
+
+func TestParseTime(t *testing.T) {
+	tm, ok := ParseTime("2015-07-04T12:00:00Z")
+	assert.T(t, ok)
+	assert.Tf(t, tm.Equal(time.Date(2015, 7, 4, 12, 0, 0, 0, time.UTC)), "%v", tm)
+	_, ok = ParseTime("not a date")
+	assert.T(t, !ok)
+	_, ok = ParseTime("")
+	assert.T(t, !ok)
+}
+
+func TestSliceValueToStrings(t *testing.T) {
+	ts := time.Date(2015, 7, 4, 12, 0, 0, 0, time.UTC)
+	sv := NewSliceValues([]Value{
+		NewStringValue("a"),
+		NewIntValue(5),
+		NewNumberValue(2.5),
+		NewBoolValue(true),
+		NilValueVal,
+		nil,
+		NewTimeValue(ts),
+	})
+	strs := sv.ToStrings()
+	assert.Tf(t, len(strs) == 7, "%v", strs)
+	assert.Tf(t, strs[0] == "a", "%v", strs)
+	assert.Tf(t, strs[1] == "5", "%v", strs)
+	assert.Tf(t, strs[2] == "2.5", "%v", strs)
+	assert.Tf(t, strs[3] == "true", "%v", strs)
+	assert.Tf(t, strs[4] == "" && strs[5] == "", "nil should be empty %v", strs)
+	assert.Tf(t, strs[6] == NewTimeValue(ts).ToString(), "%v", strs)
+
+	strs = sv.ToStringsSkipNil()
+	assert.Tf(t, len(strs) == 5, "should skip nil %v", strs)
+	assert.Tf(t, strs[4] == NewTimeValue(ts).ToString(), "%v", strs)
+
+	strsv := sv.ToStringsValue()
+	assert.Tf(t, strsv.Len() == 7, "%v", strsv)
+	assert.Tf(t, strsv.Val()[1] == "5", "%v", strsv)
+}
+
+func TestSliceValueOfType(t *testing.T) {
+	sv := NewSliceValues([]Value{
+		NewStringValue("a"),
+		NewIntValue(5),
+		NewNumberValue(2.5),
+		NewIntValue(7),
+		nil,
+		NilValueVal,
+		NewStringValue("b"),
+	})
+	ints := sv.OfType(IntType)
+	assert.Tf(t, ints.Len() == 2, "%v", ints)
+	assert.Tf(t, ints.Val()[0].Value() == int64(5) && ints.Val()[1].Value() == int64(7), "%v", ints)
+	assert.Tf(t, sv.Len() == 7, "original should be unchanged %v", sv.Len())
+
+	strs := sv.OfType(StringType)
+	assert.Tf(t, strs.ToString() == "a,b", "%v", strs.ToString())
+	assert.Tf(t, sv.OfType(NilType).Len() == 2, "%v", sv.OfType(NilType).Len())
+	assert.Tf(t, sv.OfType(TimeType).Len() == 0, "%v", sv.OfType(TimeType).Len())
+
+	numeric := sv.Filter(IsNumeric)
+	assert.Tf(t, numeric.Len() == 3, "%v", numeric)
+}
+
+func TestSliceValueMapReduce(t *testing.T) {
+	sv := NewSliceValues([]Value{NewIntValue(1), NewIntValue(2), NewNumberValue(3.5)})
+	doubled := sv.Map(func(v Value) Value {
+		return NewNumberValue(v.(NumericValue).Float() * 2)
+	})
+	assert.Tf(t, doubled.ToString() == "2,4,7", "%v", doubled.ToString())
+	assert.Tf(t, sv.Val()[0].Value() == int64(1), "original should be unchanged %v", sv)
+
+	sum := func(acc, v Value) Value {
+		return NewNumberValue(acc.(NumericValue).Float() + v.(NumericValue).Float())
+	}
+	total := doubled.Reduce(sum, NewNumberValue(0))
+	assert.Tf(t, total.Value() == float64(13), "%v", total)
+	empty := NewSliceValues(nil).Reduce(sum, NewIntValue(5))
+	assert.Tf(t, empty.Value() == int64(5), "empty reduce is init %v", empty)
+}
+
+func TestSliceValueFlatten(t *testing.T) {
+	ints := func(vals ...int64) SliceValue {
+		sv := NewSliceValues(nil)
+		for _, v := range vals {
+			sv.Append(NewIntValue(v))
+		}
+		return sv
+	}
+	nested := NewSliceValues([]Value{ints(1, 2), ints(3, 4)})
+	flat := nested.Flatten()
+	assert.Tf(t, flat.ToString() == "1,2,3,4", "%v", flat.ToString())
+	assert.Tf(t, nested.Len() == 2, "original should be unchanged %v", nested)
+
+	// scalars are kept, only one level is flattened
+	mixed := NewSliceValues([]Value{
+		NewIntValue(1),
+		NewSliceValues([]Value{NewIntValue(2), ints(3)}),
+		NewStringValue("a"),
+		ints(),
+	})
+	flat = mixed.Flatten()
+	assert.Tf(t, flat.Len() == 4, "%v", flat)
+	assert.Tf(t, flat.Val()[2].Type() == SliceValueType, "deeper slices are kept %v", flat.Val()[2])
+	assert.Tf(t, flat.Val()[3].ToString() == "a", "%v", flat.Val()[3])
+	assert.T(t, NewSliceValues(nil).Flatten().Len() == 0)
+}
+
+func TestStringsValueSort(t *testing.T) {
+	sv := NewStringsValue([]string{"c", "a", "B", "b", "a"})
+	sorted := sv.Sorted()
+	assert.Tf(t, strings.Join(sorted.Val(), ",") == "B,a,a,b,c", "%v", sorted.Val())
+	assert.Tf(t, strings.Join(sv.Val(), ",") == "c,a,B,b,a", "Sorted() should preserve original %v", sv.Val())
+
+	sv.Sort()
+	assert.Tf(t, strings.Join(sv.Val(), ",") == "B,a,a,b,c", "%v", sv.Val())
+	assert.Tf(t, sv.Rv().Index(0).String() == "B", "rv should see sorted %v", sv.Rv())
+
+	empty := NewStringsValue(nil)
+	empty.Sort()
+	assert.T(t, empty.Sorted().Len() == 0)
+}
+
+func TestStringValueLen(t *testing.T) {
+	sv := NewStringValue("naïve 日本")
+	assert.Tf(t, sv.Len() == 13, "bytes %d", sv.Len())
+	assert.Tf(t, sv.RuneLen() == 8, "runes %d", sv.RuneLen())
+	assert.T(t, EmptyStringValue.Len() == 0 && EmptyStringValue.RuneLen() == 0)
+}
+
+func TestStringsValueSets(t *testing.T) {
+	sv := NewStringsValue([]string{"c", "a", "B", "b", "a", "C"})
+	for i := 0; i < 10; i++ {
+		sorted := strings.Join(sv.SortedSet(), ",")
+		assert.Tf(t, sorted == "B,C,a,b,c", "SortedSet is distinct and ordered %v", sorted)
+	}
+	assert.Tf(t, len(sv.Set()) == 5, "%v", sv.Set())
+	fold := sv.FoldSet()
+	_, hasB := fold["b"]
+	assert.Tf(t, len(fold) == 3 && hasB, "FoldSet is case insensitive %v", fold)
+	assert.T(t, len(NewStringsValue(nil).SortedSet()) == 0)
+}
+
+func TestStringsValueSetCompare(t *testing.T) {
+	abc := NewStringsValue([]string{"a", "b", "c"})
+	ab := NewStringsValue([]string{"b", "a", "a"})
+	cab := NewStringsValue([]string{"c", "a", "b", "c"})
+	xy := NewStringsValue([]string{"x", "y"})
+	empty := NewStringsValue(nil)
+
+	assert.T(t, abc.OverlapsSet(ab) && ab.OverlapsSet(abc))
+	assert.T(t, !abc.OverlapsSet(xy) && !abc.OverlapsSet(empty))
+
+	assert.Tf(t, ab.IsSubsetOf(abc), "strict subset")
+	assert.Tf(t, !abc.IsSubsetOf(ab), "superset is not a subset")
+	assert.Tf(t, abc.IsSubsetOf(cab), "equal set is a subset")
+	assert.Tf(t, empty.IsSubsetOf(abc) && !xy.IsSubsetOf(abc), "")
+
+	assert.Tf(t, abc.EqualsSet(cab) && cab.EqualsSet(abc), "same set different order and dupes")
+	assert.Tf(t, !abc.EqualsSet(ab) && !ab.EqualsSet(abc), "strict subset is not equal")
+	assert.T(t, empty.EqualsSet(NewStringsValue([]string{})))
+}
+
+func TestMapIntValueMergeAdd(t *testing.T) {
+	m1 := NewMapIntValue(map[string]int64{"a": 1, "b": 2})
+	m2 := NewMapIntValue(map[string]int64{"b": 5, "c": 7})
+	merged := m1.MergeAdd(m2)
+	assert.Tf(t, len(merged.Val()) == 3, "%v", merged.Val())
+	assert.Tf(t, merged.Val()["a"] == 1, "%v", merged.Val())
+	assert.Tf(t, merged.Val()["b"] == 7, "overlapping keys sum %v", merged.Val())
+	assert.Tf(t, merged.Val()["c"] == 7, "%v", merged.Val())
+	assert.Tf(t, m1.Val()["b"] == 2 && len(m1.Val()) == 2, "should not modify %v", m1.Val())
+	assert.Tf(t, len(m2.Val()) == 2, "should not modify %v", m2.Val())
+	assert.T(t, merged.Rv().Len() == 3)
+
+	merged = EmptyMapIntValue.MergeAdd(m2)
+	assert.Tf(t, len(merged.Val()) == 2 && merged.Val()["c"] == 7, "%v", merged.Val())
+}
+
+type cyclicNode struct {
+	Name string
+	Next *cyclicNode
+}
+
+func TestStructValueCyclicJson(t *testing.T) {
+	// non cyclic, including shared pointer that is not a cycle
+	leaf := &cyclicNode{Name: "leaf"}
+	ok := NewStructValue(struct {
+		A, B *cyclicNode
+	}{leaf, leaf})
+	by, err := json.Marshal(ok)
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Tf(t, string(by) == `{"A":{"Name":"leaf","Next":null},"B":{"Name":"leaf","Next":null}}`, "%s", by)
+
+	a := &cyclicNode{Name: "a"}
+	b := &cyclicNode{Name: "b", Next: a}
+	a.Next = b
+	_, err = json.Marshal(NewStructValue(a))
+	assert.Tf(t, err != nil, "should error on cyclic struct")
+
+	self := &cyclicNode{Name: "self"}
+	self.Next = self
+	_, err = NewStructValue(self).MarshalJSON()
+	assert.Tf(t, err != nil, "should error on self referential struct")
+
+	m := map[string]interface{}{"name": "m"}
+	m["self"] = m
+	_, err = NewStructValue(m).MarshalJSON()
+	assert.Tf(t, err != nil, "should error on cyclic map")
+}
+
+func TestDurationValue(t *testing.T) {
+	d := NewDurationValue(90 * time.Minute)
+	assert.Tf(t, d.ToString() == "1h30m", "%v", d.ToString())
+	assert.Tf(t, NewDurationValue(2*time.Hour).ToString() == "2h", "%v", NewDurationValue(2*time.Hour).ToString())
+	assert.Tf(t, NewDurationValue(90*time.Second).ToString() == "1m30s", "%v", NewDurationValue(90*time.Second).ToString())
+	assert.Tf(t, NewDurationValue(1500*time.Millisecond).ToString() == "1.5s", "%v", NewDurationValue(1500*time.Millisecond).ToString())
+	assert.Tf(t, NewDurationValue(0).ToString() == "0s", "%v", NewDurationValue(0).ToString())
+
+	// numeric values are milliseconds
+	assert.Tf(t, d.Int() == 5400000, "%v", d.Int())
+	assert.Tf(t, d.Float() == 5400000, "%v", d.Float())
+	assert.Tf(t, NewDurationValue(1500*time.Microsecond).Float() == 1.5, "%v", NewDurationValue(1500*time.Microsecond).Float())
+	assert.T(t, IsNumeric(d))
+	assert.T(t, d.Type().String() == "duration")
+
+	nv := NewValue(90 * time.Minute)
+	assert.Tf(t, nv.Type() == DurationType, "NewValue should route duration %v", nv.Type())
+	iv, ok := ToInt64(d.Rv())
+	assert.Tf(t, ok && iv == 5400000, "%v", iv)
+	fv, ok := ToFloat64(d.Rv())
+	assert.Tf(t, ok && fv == d.Float(), "%v", fv)
+
+	t1 := NewTimeValue(time.Date(2015, 7, 4, 12, 0, 0, 0, time.UTC))
+	t2 := NewTimeValue(time.Date(2015, 7, 4, 10, 30, 0, 0, time.UTC))
+	diff := t1.Sub(t2)
+	assert.Tf(t, diff.Val() == 90*time.Minute, "%v", diff)
+	assert.Tf(t, t2.Sub(t1).ToString() == "-1h30m", "%v", t2.Sub(t1).ToString())
+
+	by, err := json.Marshal(d)
+	assert.Tf(t, err == nil && string(by) == `"1h30m"`, "%s %v", by, err)
+}
+
+func TestNewValueJSON(t *testing.T) {
+	var row map[string]interface{}
+	err := json.Unmarshal([]byte(`{"id":5,"price":5.5,"ids":[1,2.5],"user":{"age":42}}`), &row)
+	assert.T(t, err == nil)
+	mv, ok := NewValueJSON(row).(MapValue)
+	assert.Tf(t, ok, "object is a MapValue %T", NewValueJSON(row))
+	id, _ := mv.Get("id")
+	assert.Tf(t, id.Type() == IntType && id.Value() == int64(5), "whole number is int %#v", id)
+	price, _ := mv.Get("price")
+	assert.Tf(t, price.Type() == NumberType, "%#v", price)
+	ids, _ := mv.Get("ids")
+	assert.Tf(t, ids.(SliceValue).Val()[0].Type() == IntType && ids.(SliceValue).Val()[1].Type() == NumberType, "%#v", ids)
+	user, _ := mv.Get("user")
+	age, _ := user.(MapValue).Get("age")
+	assert.Tf(t, age.Type() == IntType, "nested %#v", age)
+	// NewValue() keeps json numbers as float
+	assert.T(t, NewValue(row["id"]).Type() == NumberType)
+
+	// json.Number keeps 5.0 a number
+	dec := json.NewDecoder(strings.NewReader(`{"id":5,"score":5.0}`))
+	dec.UseNumber()
+	row = nil
+	assert.T(t, dec.Decode(&row) == nil)
+	mv = NewValueJSON(row).(MapValue)
+	id, _ = mv.Get("id")
+	assert.Tf(t, id.Type() == IntType && id.Value() == int64(5), "%#v", id)
+	score, _ := mv.Get("score")
+	assert.Tf(t, score.Type() == NumberType && score.Value() == float64(5), "%#v", score)
+
+	assert.T(t, NewValueJSON(float64(1e20)).Type() == NumberType)
+	assert.T(t, NewValueJSON(math.NaN()).Type() == NumberType)
+	assert.T(t, NewValueJSON("5").Type() == StringType)
+}
+
+func TestValueJsonLogical(t *testing.T) {
+	// values embedded in a struct marshal as only their logical value,
+	// none of the reflect.Value internals
+	row := struct {
+		Num      NumberValue
+		Int      IntValue
+		Bool     BoolValue
+		Str      StringValue
+		Strs     StringsValue
+		Bytes    ByteSliceValue
+		Slice    SliceValue
+		Map      MapValue
+		MapInt   MapIntValue
+		MapNum   MapNumberValue
+		MapStr   MapStringValue
+		MapBool  MapBoolValue
+		Struct   StructValue
+		Time     TimeValue
+		Duration DurationValue
+		Err      ErrorValue
+		Nil      NilValue
+		Iface    Value
+		Ptr      *IntValue
+	}{
+		Num:      NewNumberValue(1.5),
+		Int:      NewIntValue(2),
+		Bool:     NewBoolValue(true),
+		Str:      NewStringValue("a"),
+		Strs:     NewStringsValue([]string{"a", "b"}),
+		Bytes:    NewByteSliceValue([]byte("by")),
+		Slice:    NewSliceValues([]Value{NewIntValue(1), NewStringValue("a")}),
+		Map:      NewMapValue(map[string]interface{}{"a": 1}),
+		MapInt:   NewMapIntValue(map[string]int64{"a": 1}),
+		MapNum:   NewMapNumberValue(map[string]float64{"a": 1.5}),
+		MapStr:   NewMapStringValue(map[string]string{"a": "b"}),
+		MapBool:  NewMapBoolValue(map[string]bool{"a": true}),
+		Struct:   NewStructValue(struct{ Name string }{"s"}),
+		Time:     NewTimeValue(time.Date(2015, 7, 4, 12, 0, 0, 0, time.UTC)),
+		Duration: NewDurationValue(90 * time.Minute),
+		Err:      NewErrorValue("bad"),
+		Iface:    NewStringValue("iface"),
+	}
+	iv := NewIntValue(3)
+	row.Ptr = &iv
+
+	by, err := json.Marshal(row)
+	assert.Tf(t, err == nil, "no error %v", err)
+	expected := `{"Num":1.5,"Int":2,"Bool":true,"Str":"a","Strs":["a","b"],"Bytes":"Ynk=",` +
+		`"Slice":[1,"a"],"Map":{"a":1},"MapInt":{"a":1},"MapNum":{"a":1.5},"MapStr":{"a":"b"},` +
+		`"MapBool":{"a":true},"Struct":{"Name":"s"},"Time":"2015-07-04T12:00:00Z","Duration":"1h30m",` +
+		`"Err":"bad","Nil":null,"Iface":"iface","Ptr":3}`
+	assert.Tf(t, string(by) == expected, "\n%s\n%s", by, expected)
+	assert.Tf(t, !strings.Contains(string(by), "rv"), "no reflect internals %s", by)
+
+	// round trips to plain json values
+	var out map[string]interface{}
+	err = json.Unmarshal(by, &out)
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Tf(t, out["Nil"] == nil && out["Int"] == float64(2), "%v", out)
+}
+
+func TestValueTypeGoType(t *testing.T) {
+	types := []ValueType{NumberType, IntType, BoolType, TimeType, ByteSliceType, DurationType,
+		StringType, StringsType, MapValueType, MapIntType, MapStringType, MapNumberType,
+		MapBoolType, SliceValueType}
+	for _, vt := range types {
+		rt := vt.GoType()
+		assert.Tf(t, rt != nil, "%s should have a go type", vt)
+		// native go value of that type round trips back to the ValueType
+		v := NewValue(reflect.Zero(rt).Interface())
+		assert.Tf(t, v.Type() == vt, "%s round trip got %s for %v", vt, v.Type(), rt)
+		assert.Tf(t, ValueTypeFromRT(reflect.TypeOf(v)) == vt, "%s from rt %v", vt, reflect.TypeOf(v))
+	}
+	assert.T(t, IntType.GoType() == reflect.TypeOf(int64(0)))
+	assert.T(t, TimeType.GoType() == reflect.TypeOf(time.Time{}))
+	for _, vt := range []ValueType{NilType, ErrorType, UnknownType, StructType, CustomTypeStart} {
+		assert.Tf(t, vt.GoType() == nil, "%s has no go type", vt)
+	}
+}
+
+func TestZeroValue(t *testing.T) {
+	types := []ValueType{NumberType, IntType, BoolType, TimeType, ByteSliceType, DurationType,
+		StringType, StringsType, MapValueType, MapIntType, MapStringType, MapNumberType,
+		MapBoolType, SliceValueType}
+	for _, vt := range types {
+		v := ZeroValue(vt)
+		assert.Tf(t, v.Type() == vt, "%s zero is of type %s", vt, v.Type())
+		assert.Tf(t, !IsNull(v), "%s zero is not NULL", vt)
+		// the zero of the native go type
+		zero := reflect.Zero(vt.GoType()).Interface()
+		assert.Tf(t, v.ToString() == NewValue(zero).ToString(), "%s zero %q", vt, v.ToString())
+	}
+	assert.T(t, ZeroValue(IntType).Value() == int64(0))
+	assert.T(t, ZeroValue(NumberType).Value() == float64(0))
+	assert.T(t, ZeroValue(BoolType).Value() == false)
+	assert.T(t, ZeroValue(StringType).Value() == "")
+	assert.T(t, ZeroValue(TimeType).(TimeValue).Val().IsZero())
+	assert.T(t, ZeroValue(DurationType).Value() == time.Duration(0))
+
+	m := ZeroValue(MapIntType).(MapIntValue)
+	m.Val()["a"] = 1
+	assert.Tf(t, len(ZeroValue(MapIntType).(MapIntValue).Val()) == 0, "maps are not shared")
+
+	for _, vt := range []ValueType{NilType, ErrorType, UnknownType, StructType, CustomTypeStart} {
+		assert.Tf(t, ZeroValue(vt) == NilValueVal, "%s has no zero", vt)
+	}
+}
+
+func TestNewValueInterned(t *testing.T) {
+	// interned values are the same shared value, so compare equal
+	assert.T(t, NewValue(5) == NewValue(int64(5)))
+	assert.T(t, NewValue(int32(0)) == NewValue(0))
+	assert.T(t, NewValue(255) == NewValue(int64(255)))
+	assert.T(t, NewValue(true) == BoolValueTrue)
+	assert.T(t, NewValue(false) == BoolValueFalse)
+	assert.T(t, NewValue("") == EmptyStringValue)
+
+	// outside the interned range are still correct values
+	for _, i := range []int64{-1, 256, 1 << 40} {
+		v := NewValue(i)
+		assert.Tf(t, v.Type() == IntType && v.Value() == i, "%v", v)
+		assert.Tf(t, v.Rv().Int() == i, "%v", v.Rv())
+	}
+	iv := NewValue(7).(IntValue)
+	assert.Tf(t, iv.Val() == 7 && iv.Rv().Int() == 7, "%v", iv)
+	assert.T(t, NewValue("a").ToString() == "a")
+
+	// interned values are already boxed, so NewValue does not allocate
+	var v Value
+	for _, goVal := range []interface{}{int64(7), 255, true, false, ""} {
+		allocs := testing.AllocsPerRun(100, func() { v = NewValue(goVal) })
+		assert.Tf(t, allocs == 0, "%T %v allocs %v", goVal, goVal, allocs)
+	}
+	allocs := testing.AllocsPerRun(100, func() { v = NewValue(int64(100000)) })
+	assert.Tf(t, allocs > 0, "not interned allocs %v", allocs)
+	_ = v
+}
+
+var benchValue Value
+
+func BenchmarkNewValueInterned(b *testing.B) {
+	vals := []interface{}{int64(1), int64(200), true, false, ""}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, v := range vals {
+			benchValue = NewValue(v)
+		}
+	}
+}
+
+func BenchmarkNewValue(b *testing.B) {
+	vals := []interface{}{int64(1), int64(200), int64(100000), true, "", "hello", 1.5}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, v := range vals {
+			benchValue = NewValue(v)
+		}
+	}
+}
+
+type structPoint struct{ X, Y int }
+
+// compares only on X
+type structXOnly struct{ X, Y int }
+
+func (m structXOnly) CompareTo(other interface{}) int { return m.X - other.(structXOnly).X }
+func (m structXOnly) EqualityKey() string             { return fmt.Sprintf("%d", m.X) }
+
+func TestStructValueCompare(t *testing.T) {
+	// without a comparator equal if their json is
+	a, b, c := NewStructValue(structPoint{1, 2}), NewStructValue(structPoint{1, 2}), NewStructValue(structPoint{1, 3})
+	assert.T(t, a.Equal(b) && !a.Equal(c))
+	assert.Tf(t, a.EqualityKey() == `{"X":1,"Y":2}`, "%s", a.EqualityKey())
+	assert.T(t, string(EncodeKey(a)) == string(EncodeKey(b)))
+	assert.T(t, string(EncodeKey(a)) != string(EncodeKey(c)))
+
+	x1, x2, x3 := NewStructValue(structXOnly{1, 2}), NewStructValue(structXOnly{1, 5}), NewStructValue(structXOnly{2, 0})
+	assert.T(t, x1.Equal(x2) && !x1.Equal(x3))
+	assert.T(t, Compare(x1, x3) < 0 && Compare(x3, x2) > 0 && Compare(x1, x2) == 0)
+	assert.T(t, string(EncodeKey(x1)) == string(EncodeKey(x2)))
+
+	// CompareTo is not called with another type, they are ordered by type
+	assert.T(t, x1.Compare(a) != 0 && x1.Compare(a) == -a.Compare(x1))
+	assert.T(t, !x1.Equal(a) && !a.Equal(x1))
+	assert.T(t, x1.Compare(NewStructValue(nil)) != 0)
+}
@@ -4,6 +4,9 @@ import (
 	//u "github.com/araddon/gou"
 	"reflect"
 	"testing"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/value"
 )
 
 /*
@@ -174,3 +177,47 @@ func BenchmarkReflectionOurType5(b *testing.B) {
 		}
 	}
 }
+
+/*
+Compiled expressions vs walking the expression tree per row
+
+go test -bench="Eval"
+
+most of the gain is from func args typed at compile time, making the
+  reflect Call() cheaper, rather than from not walking the tree
+
+*/
+var benchEvalExpr = `toint(str5) + int5 * 2 > 10 AND user_id == "abc"`
+
+func benchEvalCtx() *datasource.ContextSimple {
+	return datasource.NewContextSimpleData(map[string]value.Value{
+		"int5":    value.NewIntValue(5),
+		"str5":    value.NewStringValue("5"),
+		"user_id": value.NewStringValue("abc"),
+	})
+}
+
+func BenchmarkEvalInterpreted(b *testing.B) {
+	exprVm, err := NewVm(benchEvalExpr)
+	if err != nil {
+		b.Fatal(err)
+	}
+	ctx := benchEvalCtx()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Eval(ctx, exprVm.Tree.Root)
+	}
+}
+
+func BenchmarkEvalCompiled(b *testing.B) {
+	exprVm, err := NewVm(benchEvalExpr)
+	if err != nil {
+		b.Fatal(err)
+	}
+	compiled := Compile(exprVm.Tree.Root)
+	ctx := benchEvalCtx()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		compiled(ctx)
+	}
+}
@@ -0,0 +1,147 @@
+package vm
+
+import (
+	"reflect"
+
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/value"
+)
+
+// Compile an expression once into an EvaluatorFunc, for evaluating the
+//  same expression against many rows (projection).  Literals, boolean
+//  identities and func arg handling are resolved at compile time instead
+//  of re-walking the tree per row, the results are identical to Eval().
+//  Nodes without a compiled form (unary, tri, multi-arg) use Eval().
+func Compile(arg expr.Node) EvaluatorFunc {
+	switch node := arg.(type) {
+	case nil:
+		return func(ctx expr.EvalContext) (value.Value, bool) { return nil, true }
+	case *expr.NumberNode:
+		v, ok := numberNodeToValue(node)
+		return func(ctx expr.EvalContext) (value.Value, bool) { return v, ok }
+	case *expr.StringNode:
+		text := node.Text
+		return func(ctx expr.EvalContext) (value.Value, bool) { return value.NewStringValue(text), true }
+	case *expr.IdentityNode:
+		return compileIdentity(node)
+	case *expr.BinaryNode:
+		left, right := Compile(node.Args[0]), Compile(node.Args[1])
+		return func(ctx expr.EvalContext) (value.Value, bool) {
+			ar, aok := left(ctx)
+			br, bok := right(ctx)
+			return operateBinary(evalOptions(ctx), node, ar, aok, br, bok)
+		}
+	case *expr.FuncNode:
+		return compileFunc(node)
+	}
+	return func(ctx expr.EvalContext) (value.Value, bool) { return Eval(ctx, arg) }
+}
+
+func compileIdentity(node *expr.IdentityNode) EvaluatorFunc {
+	if node.IsBooleanIdentity() {
+		bv := value.NewBoolValue(node.Bool())
+		return func(ctx expr.EvalContext) (value.Value, bool) { return bv, true }
+	}
+	return func(ctx expr.EvalContext) (value.Value, bool) { return walkIdentity(ctx, node) }
+}
+
+// compiled func arg, see walkFunc() for how each arg type is evaluated
+type funcArgFunc func(ctx expr.EvalContext) interface{}
+
+var (
+	valueType       = reflect.TypeOf((*value.Value)(nil)).Elem()
+	evalContextType = reflect.TypeOf((*expr.EvalContext)(nil)).Elem()
+)
+
+func compileFunc(node *expr.FuncNode) EvaluatorFunc {
+	args := make([]funcArgFunc, len(node.Args))
+	for i, a := range node.Args {
+		args[i] = compileFuncArg(a)
+		if args[i] == nil {
+			// unknown arg type, walkFunc() panics on evaluation
+			return func(ctx expr.EvalContext) (value.Value, bool) { return walkFunc(ctx, node) }
+		}
+	}
+	// args passed as a value.Value param are made interface typed here,
+	//  saving reflect Call() from checking assignability on every call
+	ft := node.F.F.Type()
+	ctxTyped := ft.NumIn() > 0 && ft.In(0) == evalContextType
+	asValue := make([]bool, len(args))
+	for i := range args {
+		if !ft.IsVariadic() && i+1 < ft.NumIn() {
+			asValue[i] = ft.In(i+1) == valueType
+		}
+	}
+	return func(ctx expr.EvalContext) (value.Value, bool) {
+		funcArgs := make([]reflect.Value, len(args)+1)
+		if ctxTyped {
+			funcArgs[0] = reflect.ValueOf(&ctx).Elem()
+		} else {
+			funcArgs[0] = funcCtxArg(ctx)
+		}
+		for i, argFn := range args {
+			v := argFn(ctx)
+			if dv, ok := v.(value.DictStringValue); ok {
+				v = dv.StringValue()
+			}
+			if vv, ok := v.(value.Value); ok && asValue[i] {
+				funcArgs[i+1] = reflect.ValueOf(&vv).Elem()
+			} else {
+				funcArgs[i+1] = funcArg(node.Args[i], v)
+			}
+		}
+		return callFunc(node, funcArgs)
+	}
+}
+
+func compileFuncArg(a expr.Node) funcArgFunc {
+	constArg := func(v value.Value) funcArgFunc {
+		return func(ctx expr.EvalContext) interface{} { return v }
+	}
+	// args whose evaluation is not ok are passed as nil, they are valid
+	nilNotOk := func(fn EvaluatorFunc) funcArgFunc {
+		return func(ctx expr.EvalContext) interface{} {
+			v, ok := fn(ctx)
+			if !ok {
+				return value.NewNilValue()
+			}
+			return v
+		}
+	}
+	switch t := a.(type) {
+	case *expr.StringNode:
+		return constArg(value.NewStringValue(t.Text))
+	case *expr.IdentityNode:
+		if t.IsBooleanIdentity() {
+			return constArg(value.NewBoolValue(t.Bool()))
+		}
+		key := t.Text
+		return func(ctx expr.EvalContext) interface{} {
+			v, ok := getIdentity(ctx, key)
+			if !ok || v == nil {
+				return value.NewNilValue()
+			}
+			return v
+		}
+	case *expr.NumberNode:
+		v, _ := numberNodeToValue(t)
+		return constArg(v)
+	case *expr.FuncNode:
+		return nilNotOk(compileFunc(t))
+	case *expr.UnaryNode:
+		return nilNotOk(func(ctx expr.EvalContext) (value.Value, bool) { return walkUnary(ctx, t) })
+	case *expr.BinaryNode:
+		fn := Compile(t)
+		return func(ctx expr.EvalContext) interface{} {
+			var v interface{}
+			v, _ = fn(ctx)
+			return v
+		}
+	case *expr.ValueNode:
+		return func(ctx expr.EvalContext) interface{} {
+			var v interface{} = t.Value
+			return v
+		}
+	}
+	return nil
+}
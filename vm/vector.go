@@ -0,0 +1,70 @@
+package vm
+
+import (
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/value"
+)
+
+// EvalVector evaluates an expression over a batch of rows, column at a
+//  time rather than row at a time:  each node of the expression is
+//  dispatched once per batch, literals are evaluated once, and binary
+//  operators are applied across the vectors of their args.  The results
+//  are the same as Eval() of each row, a row whose evaluation is not ok
+//  is nil, and an ok nil is value.NilValueVal.
+//
+//  ctx is the context of the whole batch, used for the nodes that do not
+//  depend on a row (literals), and may be nil.
+func EvalVector(ctx expr.EvalContext, node expr.Node, rows []expr.ContextReader) []value.Value {
+	vec := evalVector(ctx, node, rows)
+	vals := make([]value.Value, len(rows))
+	for i, r := range vec {
+		switch {
+		case !r.ok:
+			// not ok
+		case r.v == nil:
+			vals[i] = value.NilValueVal
+		default:
+			vals[i] = r.v
+		}
+	}
+	return vals
+}
+
+// a single row result of Eval()
+type evalResult struct {
+	v  value.Value
+	ok bool
+}
+
+func evalVector(ctx expr.EvalContext, node expr.Node, rows []expr.ContextReader) []evalResult {
+	vec := make([]evalResult, len(rows))
+	switch n := node.(type) {
+	case nil, *expr.NumberNode, *expr.StringNode:
+		v, ok := Eval(ctx, node)
+		for i := range vec {
+			vec[i] = evalResult{v, ok}
+		}
+	case *expr.IdentityNode:
+		if n.IsBooleanIdentity() {
+			bv := value.NewBoolValue(n.Bool())
+			for i := range vec {
+				vec[i] = evalResult{bv, true}
+			}
+			break
+		}
+		for i, row := range rows {
+			vec[i].v, vec[i].ok = walkIdentity(row, n)
+		}
+	case *expr.BinaryNode:
+		left, right := evalVector(ctx, n.Args[0], rows), evalVector(ctx, n.Args[1], rows)
+		for i := range vec {
+			vec[i].v, vec[i].ok = operateBinary(evalOptions(rows[i]), n, left[i].v, left[i].ok, right[i].v, right[i].ok)
+		}
+	default:
+		fn := Compile(node)
+		for i, row := range rows {
+			vec[i].v, vec[i].ok = fn(row)
+		}
+	}
+	return vec
+}
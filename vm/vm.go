@@ -6,6 +6,8 @@ import (
 	"math"
 	"reflect"
 	"runtime"
+	"strconv"
+	"strings"
 	"time"
 
 	u "github.com/araddon/gou"
@@ -23,6 +25,9 @@ var (
 
 	SchemaInfoEmpty = &NoSchema{}
 
+	// the options of a context without any, see evalOptions()
+	defaultOptions = &expr.EvalOptions{}
+
 	// our DataTypes we support, a limited sub-set of go
 	floatRv   = reflect.ValueOf(float64(1.2))
 	int64Rv   = reflect.ValueOf(int64(1))
@@ -173,6 +178,10 @@ func Evaluator(arg expr.Node) EvaluatorFunc {
 		return func(ctx expr.EvalContext) (value.Value, bool) { return walkTri(ctx, argVal) }
 	case *expr.MultiArgNode:
 		return func(ctx expr.EvalContext) (value.Value, bool) { return walkMulti(ctx, argVal) }
+	case *expr.ValueNode:
+		return func(ctx expr.EvalContext) (value.Value, bool) { return argVal.Value, true }
+	case *expr.ParamNode:
+		return func(ctx expr.EvalContext) (value.Value, bool) { return walkParam(ctx, argVal) }
 	default:
 		u.Errorf("Unknonwn node type:  %T", argVal)
 		panic(ErrUnknownNodeType)
@@ -199,6 +208,10 @@ func Eval(ctx expr.EvalContext, arg expr.Node) (value.Value, bool) {
 		return walkIdentity(ctx, argVal)
 	case *expr.StringNode:
 		return value.NewStringValue(argVal.Text), true
+	case *expr.ValueNode:
+		return argVal.Value, true
+	case *expr.ParamNode:
+		return walkParam(ctx, argVal)
 	case nil:
 		return nil, true
 	default:
@@ -207,13 +220,47 @@ func Eval(ctx expr.EvalContext, arg expr.Node) (value.Value, bool) {
 	}
 }
 
+// walkParam reads the value bound to a param of a prepared statement
+//  from the context, which must be an expr.ParamReader
+func walkParam(ctx expr.EvalContext, node *expr.ParamNode) (value.Value, bool) {
+	reader, ok := ctx.(expr.ParamReader)
+	if !ok {
+		u.Warnf("no params bound for %s, context %T", node, ctx)
+		return nil, false
+	}
+	v, ok := reader.Param(node)
+	if !ok {
+		return nil, false
+	}
+	if v == nil {
+		return value.NilValueVal, true
+	}
+	return v, true
+}
+
 func (e *State) Walk(arg expr.Node) (value.Value, bool) {
 	return Eval(e.ContextReader, arg)
 }
 
+// evalOptions are the options of the query being evaluated, read from the
+//  context if it is an expr.OptionsReader, else the defaults
+func evalOptions(ctx expr.EvalContext) *expr.EvalOptions {
+	if reader, ok := ctx.(expr.OptionsReader); ok {
+		if opts := reader.EvalOptions(); opts != nil {
+			return opts
+		}
+	}
+	return defaultOptions
+}
+
 func walkBinary(ctx expr.EvalContext, node *expr.BinaryNode) (value.Value, bool) {
 	ar, aok := Eval(ctx, node.Args[0])
 	br, bok := Eval(ctx, node.Args[1])
+	return operateBinary(evalOptions(ctx), node, ar, aok, br, bok)
+}
+
+// operateBinary applies the operator of node to its evaluated args
+func operateBinary(opts *expr.EvalOptions, node *expr.BinaryNode, ar value.Value, aok bool, br value.Value, bok bool) (value.Value, bool) {
 	if !aok || !bok {
 		// If !aok, but token is a Negate?
 		u.Debugf("walkBinary not ok: op=%s %v  l:%v  r:%v  %T  %T", node.Operator, node, ar, br, ar, br)
@@ -233,28 +280,52 @@ func walkBinary(ctx expr.EvalContext, node *expr.BinaryNode) (value.Value, bool)
 	// }
 	//u.Debugf("node.Args: %#v", node.Args)
 	//u.Debugf("walkBinary: %v  l:%v  r:%v  %T  %T", node, ar, br, ar, br)
+	ar, br = unwrapDict(ar), unwrapDict(br)
+	// sql null propagation, arithmetic with a null operand is null (not
+	//  an error, and not coerced to 0)
+	if isArithmetic(node.Operator) && (value.IsNull(ar) || value.IsNull(br)) {
+		return value.NilValueVal, true
+	}
+	// a 1 length array operates as its element, longer arrays are never
+	//  equal to a scalar
+	if sa, sb, ok := value.ScalarArgs(ar, br); ok {
+		ar, br = sa, sb
+	} else {
+		switch node.Operator.T {
+		case lex.TokenEqualEqual, lex.TokenEqual:
+			return value.BoolValueFalse, true
+		case lex.TokenNE:
+			return value.BoolValueTrue, true
+		}
+		return value.NewErrorValuef("cannot compare multi-value array to scalar in %s", node), false
+	}
+	switch node.Operator.T {
+	case lex.TokenBitAnd, lex.TokenBitOr, lex.TokenBitXor, lex.TokenLShift, lex.TokenRShift:
+		n := operateBits(node.Operator, ar, br)
+		return n, !n.Err()
+	}
 	switch at := ar.(type) {
 	case value.IntValue:
 		switch bt := br.(type) {
 		case value.IntValue:
 			//u.Debugf("doing operate ints  %v %v  %v", at, node.Operator.V, bt)
-			n := operateInts(node.Operator, at, bt)
-			return n, true
+			n := operateInts(opts, node.Operator, at, bt)
+			return n, !n.Err()
 		case value.NumberValue:
 			//u.Debugf("doing operate ints/numbers  %v %v  %v", at, node.Operator.V, bt)
-			n := operateNumbers(node.Operator, at.NumberValue(), bt)
-			return n, true
+			n := operateNumbers(opts, node.Operator, at.NumberValue(), bt)
+			return n, !n.Err()
 		default:
 			u.Errorf("unknown type:  %T %v", bt, bt)
 		}
 	case value.NumberValue:
 		switch bt := br.(type) {
 		case value.IntValue:
-			n := operateNumbers(node.Operator, at, bt.NumberValue())
-			return n, true
+			n := operateNumbers(opts, node.Operator, at, bt.NumberValue())
+			return n, !n.Err()
 		case value.NumberValue:
-			n := operateNumbers(node.Operator, at, bt)
-			return n, true
+			n := operateNumbers(opts, node.Operator, at, bt)
+			return n, !n.Err()
 		default:
 			u.Errorf("unknown type:  %T %v", bt, bt)
 		}
@@ -294,11 +365,49 @@ func walkBinary(ctx expr.EvalContext, node *expr.BinaryNode) (value.Value, bool)
 			u.Warnf("br: %#v", br)
 			u.Errorf("at?%T  %v  coerce?%v bt? %T     %v", at, at.Value(), at.CanCoerce(stringRv), bt, bt.Value())
 		}
+	case value.TimeValue:
+		switch bt := br.(type) {
+		case value.TimeValue:
+			return operateTime(opts, node.Operator, at.Val(), bt.Val())
+		case value.StringValue:
+			// created > "2015-01-01"
+			bv, ok := value.ParseTime(bt.Val())
+			if !ok {
+				return value.NewErrorValuef("could not parse %q as date in %s", bt.Val(), node), false
+			}
+			return operateTime(opts, node.Operator, at.Val(), bv)
+		case nil, value.NilValue:
+			return nil, false
+		default:
+			return value.NewErrorValuef("unsupported time comparison %T in %s", br, node), false
+		}
 	case value.StringValue:
 		switch bt := br.(type) {
 		case value.StringValue:
 			// Nice, both strings
-			return operateStrings(node.Operator, at, bt), true
+			if opts.NumericStrings && isComparison(node.Operator) {
+				if af, ok := value.NumericString(at); ok {
+					if bf, ok := value.NumericString(bt); ok {
+						return operateNumbers(opts, node.Operator, value.NewNumberValue(af), value.NewNumberValue(bf)), true
+					}
+				}
+			}
+			if isArithmetic(node.Operator) {
+				// untyped sources (csv) have numeric columns as strings
+				//    price * item_count
+				if n, ok := operateNumericStrings(opts, node.Operator, at, bt); ok {
+					return n, !n.Err()
+				}
+			}
+			n := operateStrings(node.Operator, at, bt)
+			return n, !n.Err()
+		case value.TimeValue:
+			// "2015-01-01" < created
+			av, ok := value.ParseTime(at.Val())
+			if !ok {
+				return value.NewErrorValuef("could not parse %q as date in %s", at.Val(), node), false
+			}
+			return operateTime(opts, node.Operator, av, bt.Val())
 		case nil, value.NilValue:
 			switch node.Operator.T {
 			case lex.TokenEqualEqual, lex.TokenEqual:
@@ -337,13 +446,13 @@ func walkBinary(ctx expr.EvalContext, node *expr.BinaryNode) (value.Value, bool)
 			if at.CanCoerce(int64Rv) {
 				switch bt := br.(type) {
 				case value.StringValue:
-					n := operateNumbers(node.Operator, at.NumberValue(), bt.NumberValue())
+					n := operateNumbers(opts, node.Operator, at.NumberValue(), bt.NumberValue())
 					return n, true
 				case value.IntValue:
-					n := operateNumbers(node.Operator, at.NumberValue(), bt.NumberValue())
+					n := operateNumbers(opts, node.Operator, at.NumberValue(), bt.NumberValue())
 					return n, true
 				case value.NumberValue:
-					n := operateNumbers(node.Operator, at.NumberValue(), bt)
+					n := operateNumbers(opts, node.Operator, at.NumberValue(), bt)
 					return n, true
 				default:
 					u.Errorf("at?%T  %v  coerce?%v bt? %T     %v", at, at.Value(), at.CanCoerce(stringRv), bt, bt.Value())
@@ -397,7 +506,75 @@ func walkIdentity(ctx expr.EvalContext, node *expr.IdentityNode) (value.Value, b
 		return value.NewStringValue(node.Text), true
 	}
 	//u.Debugf("walkIdentity() node=%T  %v", node, node)
-	return ctx.Get(node.Text)
+	return getIdentity(ctx, node.Text)
+}
+
+// getIdentity reads a column from the context, or if there is no such
+//  column an element of a slice or map column  tags[0]
+func getIdentity(ctx expr.EvalContext, name string) (value.Value, bool) {
+	v, ok := ctx.Get(name)
+	if (!ok || v == nil) && strings.HasSuffix(name, "]") {
+		return walkIndexedIdentity(ctx, name)
+	}
+	return v, ok
+}
+
+// walkIndexedIdentity resolves an element of a slice or map column
+//  such as  tags[0]  or  attrs["color"], a missing index or key is nil
+func walkIndexedIdentity(ctx expr.EvalContext, name string) (value.Value, bool) {
+	open := strings.IndexByte(name, '[')
+	if open < 1 {
+		return nil, false
+	}
+	v, ok := ctx.Get(name[:open])
+	if !ok || v == nil {
+		return nil, false
+	}
+	for rest := name[open:]; rest != ""; {
+		end := strings.IndexByte(rest, ']')
+		if rest[0] != '[' || end < 0 {
+			return nil, false
+		}
+		idx := rest[1:end]
+		rest = rest[end+1:]
+		if v, ok = indexValue(v, idx); !ok {
+			return value.NilValueVal, true
+		}
+	}
+	if v == nil {
+		return value.NilValueVal, true
+	}
+	return v, true
+}
+
+func indexValue(v value.Value, idx string) (value.Value, bool) {
+	if len(idx) > 1 && (idx[0] == '"' || idx[0] == '\'') {
+		key := idx[1 : len(idx)-1]
+		switch vt := v.(type) {
+		case value.MapValue:
+			return vt.Get(key)
+		case value.JsonValue:
+			return vt.JsonPath(key)
+		case value.Map:
+			return vt.MapValue().Get(key)
+		}
+		return nil, false
+	}
+	i, err := strconv.Atoi(idx)
+	if err != nil {
+		return nil, false
+	}
+	switch vt := v.(type) {
+	case value.SliceValue:
+		return vt.Index(i)
+	case value.JsonValue:
+		return vt.JsonPath(idx)
+	case value.StringsValue:
+		if i >= 0 && i < vt.Len() {
+			return value.NewStringValue(vt.Val()[i]), true
+		}
+	}
+	return nil, false
 }
 
 func walkUnary(ctx expr.EvalContext, node *expr.UnaryNode) (value.Value, bool) {
@@ -424,8 +601,8 @@ func walkUnary(ctx expr.EvalContext, node *expr.UnaryNode) (value.Value, bool) {
 			panic(ErrUnknownNodeType)
 		}
 	case lex.TokenMinus:
-		if an, aok := a.(value.NumericValue); aok {
-			return value.NewNumberValue(-an.Float()), true
+		if v := value.Negate(a); !v.Err() {
+			return v, true
 		}
 	case lex.TokenExists:
 		switch a.(type) {
@@ -511,6 +688,7 @@ func walkMulti(ctx expr.EvalContext, node *expr.MultiArgNode) (value.Value, bool
 		//u.Debugf("Could not evaluate args, %#v", node.Args[0])
 		return value.BoolValueFalse, false
 	}
+	a = unwrapDict(a)
 	if node.Operator.T != lex.TokenIN {
 		u.Warnf("walk multiarg not implemented for node type %#v", node)
 		return value.NilValueVal, false
@@ -532,7 +710,7 @@ func walkMulti(ctx expr.EvalContext, node *expr.MultiArgNode) (value.Value, bool
 		}
 
 		for _, val := range sval.SliceValue() {
-			match, err := value.Equal(val, a)
+			match, err := value.Equal(unwrapDict(val), a)
 			if err != nil {
 				// Couldn't compare values
 				u.Debugf("IN: couldn't compare %s and %s", val, a)
@@ -550,7 +728,7 @@ func walkMulti(ctx expr.EvalContext, node *expr.MultiArgNode) (value.Value, bool
 		v, ok := Eval(ctx, node.Args[i])
 		if ok && v != nil {
 			//u.Debugf("in? %v %v", a, v)
-			if eq, err := value.Equal(a, v); eq && err == nil {
+			if eq, err := value.Equal(a, unwrapDict(v)); eq && err == nil {
 				return value.NewBoolValue(true), true
 			}
 		} else {
@@ -560,6 +738,15 @@ func walkMulti(ctx expr.EvalContext, node *expr.MultiArgNode) (value.Value, bool
 	return value.BoolValueFalse, true
 }
 
+// dictionary encoded strings operate as, and are passed to funcs as,
+//  plain strings
+func unwrapDict(v value.Value) value.Value {
+	if dv, ok := v.(value.DictStringValue); ok {
+		return dv.StringValue()
+	}
+	return v
+}
+
 func walkFunc(ctx expr.EvalContext, node *expr.FuncNode) (value.Value, bool) {
 
 	//u.Debugf("walkFunc node: %v", node.StringAST())
@@ -568,12 +755,7 @@ func walkFunc(ctx expr.EvalContext, node *expr.FuncNode) (value.Value, bool) {
 	// is this Context
 	var ok bool
 	funcArgs := make([]reflect.Value, 0)
-	if ctx != nil {
-		funcArgs = append(funcArgs, reflect.ValueOf(ctx))
-	} else {
-		var nilArg expr.EvalContext
-		funcArgs = append(funcArgs, reflect.ValueOf(&nilArg).Elem())
-	}
+	funcArgs = append(funcArgs, funcCtxArg(ctx))
 	for _, a := range node.Args {
 
 		//u.Debugf("arg %v  %T %v", a, a, a)
@@ -588,12 +770,14 @@ func walkFunc(ctx expr.EvalContext, node *expr.FuncNode) (value.Value, bool) {
 			if t.IsBooleanIdentity() {
 				v = value.NewBoolValue(t.Bool())
 			} else {
-				v, ok = ctx.Get(t.Text)
+				iv, ok := getIdentity(ctx, t.Text)
 				//u.Infof("%#v", ctx.Row())
-				//u.Debugf("get '%s'? %T %v %v", t.String(), v, v, ok)
-				if !ok {
+				//u.Debugf("get '%s'? %T %v %v", t.String(), iv, iv, ok)
+				if !ok || iv == nil {
 					// nil arguments are valid
 					v = value.NewNilValue()
+				} else {
+					v = iv
 				}
 			}
 
@@ -618,29 +802,52 @@ func walkFunc(ctx expr.EvalContext, node *expr.FuncNode) (value.Value, bool) {
 			v, ok = walkBinary(ctx, t)
 		case *expr.ValueNode:
 			v = t.Value
+		case *expr.ParamNode:
+			v, ok = walkParam(ctx, t)
+			if !ok {
+				v = value.NewNilValue()
+			}
 		default:
 			panic(fmt.Errorf("expr: unknown func arg type"))
 		}
 
-		if v == nil {
-			//u.Warnf("Nil vals?  %v  %T  arg:%T", v, v, a)
-			// What do we do with Nil Values?
-			switch a.(type) {
-			case *expr.StringNode: // String Literal
-				u.Warnf("NOT IMPLEMENTED T:%T v:%v", a, a)
-			case *expr.IdentityNode: // Identity node = lookup in context
-				v = value.NewStringValue("")
-			default:
-				u.Warnf("un-handled type:  %v  %T", v, v)
-			}
+		funcArgs = append(funcArgs, funcArg(a, v))
+	}
+	return callFunc(node, funcArgs)
+}
 
-			funcArgs = append(funcArgs, reflect.ValueOf(v))
-		} else {
-			//u.Debugf(`found func arg:  "%v"  %T  arg:%T`, v, v, a)
-			funcArgs = append(funcArgs, reflect.ValueOf(v))
-		}
+// the context as first arg of a func, a typed nil if there is none
+func funcCtxArg(ctx expr.EvalContext) reflect.Value {
+	if ctx != nil {
+		return reflect.ValueOf(ctx)
+	}
+	var nilArg expr.EvalContext
+	return reflect.ValueOf(&nilArg).Elem()
+}
 
+// funcArg is the reflect value of evaluated func arg a
+func funcArg(a expr.Node, v interface{}) reflect.Value {
+	if dv, ok := v.(value.DictStringValue); ok {
+		v = dv.StringValue()
 	}
+	if v == nil {
+		//u.Warnf("Nil vals?  %v  %T  arg:%T", v, v, a)
+		// What do we do with Nil Values?
+		switch a.(type) {
+		case *expr.StringNode: // String Literal
+			u.Warnf("NOT IMPLEMENTED T:%T v:%v", a, a)
+		case *expr.IdentityNode: // Identity node = lookup in context
+			v = value.NewStringValue("")
+		default:
+			u.Warnf("un-handled type:  %v  %T", v, v)
+		}
+	}
+	//u.Debugf(`found func arg:  "%v"  %T  arg:%T`, v, v, a)
+	return reflect.ValueOf(v)
+}
+
+// callFunc calls the func of node with its context and evaluated args
+func callFunc(node *expr.FuncNode, funcArgs []reflect.Value) (value.Value, bool) {
 	// Get the result of calling our Function (Value,bool)
 	//u.Debugf("Calling func:%v(%v) %v", node.F.Name, funcArgs, node.F.F)
 	fnRet := node.F.F.Call(funcArgs)
@@ -654,7 +861,7 @@ func walkFunc(ctx expr.EvalContext, node *expr.FuncNode) (value.Value, bool) {
 	return fnRet[0].Interface().(value.Value), true
 }
 
-func operateNumbers(op lex.Token, av, bv value.NumberValue) value.Value {
+func operateNumbers(opts *expr.EvalOptions, op lex.Token, av, bv value.NumberValue) value.Value {
 	switch op.T {
 	case lex.TokenPlus, lex.TokenStar, lex.TokenMultiply, lex.TokenDivide, lex.TokenMinus,
 		lex.TokenModulus:
@@ -673,10 +880,10 @@ func operateNumbers(op lex.Token, av, bv value.NumberValue) value.Value {
 	case lex.TokenMinus: // -
 		return value.NewNumberValue(a - b)
 	case lex.TokenDivide: //    /
-		return value.NewNumberValue(a / b)
+		return value.Divide(av, bv, opts.StrictDivision)
 	case lex.TokenModulus: //    %
 		// is this even valid?   modulus on floats?
-		return value.NewNumberValue(float64(int64(a) % int64(b)))
+		return value.Modulo(av, bv, opts.StrictDivision)
 
 	// Below here are Boolean Returns
 	case lex.TokenEqualEqual, lex.TokenEqual: //  ==
@@ -766,7 +973,87 @@ func operateStrings(op lex.Token, av, bv value.StringValue) value.Value {
 	return value.NewErrorValuef("unsupported operator for strings: %s", op.T)
 }
 
-func operateInts(op lex.Token, av, bv value.IntValue) value.Value {
+// is the operator one of the comparisons == != > >= < <=
+func isComparison(op lex.Token) bool {
+	switch op.T {
+	case lex.TokenEqualEqual, lex.TokenEqual, lex.TokenNE, lex.TokenGT, lex.TokenGE, lex.TokenLT, lex.TokenLE:
+		return true
+	}
+	return false
+}
+
+// is the operator one of the arithmetic + - * / %
+func isArithmetic(op lex.Token) bool {
+	switch op.T {
+	case lex.TokenPlus, lex.TokenMinus, lex.TokenMultiply, lex.TokenStar, lex.TokenDivide, lex.TokenModulus:
+		return true
+	}
+	return false
+}
+
+// arithmetic of two strings that parse as numbers, ints if both are
+//  ints, false if either is not numeric
+func operateNumericStrings(opts *expr.EvalOptions, op lex.Token, a, b value.StringValue) (value.Value, bool) {
+	ai, aerr := strconv.ParseInt(strings.TrimSpace(a.Val()), 10, 64)
+	bi, berr := strconv.ParseInt(strings.TrimSpace(b.Val()), 10, 64)
+	if aerr == nil && berr == nil {
+		return operateInts(opts, op, value.NewIntValue(ai), value.NewIntValue(bi)), true
+	}
+	af, aok := value.NumericString(a)
+	bf, bok := value.NumericString(b)
+	if !aok || !bok {
+		return nil, false
+	}
+	return operateNumbers(opts, op, value.NewNumberValue(af), value.NewNumberValue(bf)), true
+}
+
+// operateTime compares two times, only the comparison operators and
+//  minus (a DurationValue) are supported
+func operateTime(opts *expr.EvalOptions, op lex.Token, a, b time.Time) (value.Value, bool) {
+	switch op.T {
+	case lex.TokenMinus: //  -
+		return value.NewDurationValue(a.Sub(b)), true
+	case lex.TokenEqualEqual, lex.TokenEqual: //  ==
+		return value.NewBoolValue(timeEqual(opts, a, b)), true
+	case lex.TokenNE: //  !=
+		return value.NewBoolValue(!timeEqual(opts, a, b)), true
+	case lex.TokenGT: //  >
+		return value.NewBoolValue(a.After(b)), true
+	case lex.TokenGE: //  >=
+		return value.NewBoolValue(a.After(b) || a.Equal(b)), true
+	case lex.TokenLT: //  <
+		return value.NewBoolValue(a.Before(b)), true
+	case lex.TokenLE: //  <=
+		return value.NewBoolValue(a.Before(b) || a.Equal(b)), true
+	}
+	return value.NewErrorValuef("unsupported operator for time: %s", op.T), false
+}
+
+func timeEqual(opts *expr.EvalOptions, a, b time.Time) bool {
+	if opts.TimeTolerance > 0 {
+		return value.TimeEqualWithin(value.NewTimeValue(a), value.NewTimeValue(b), opts.TimeTolerance)
+	}
+	return a.Equal(b)
+}
+
+// integer bitwise operators, operands are coerced to int64
+func operateBits(op lex.Token, a, b value.Value) value.Value {
+	switch op.T {
+	case lex.TokenBitAnd: // &
+		return value.And(a, b)
+	case lex.TokenBitOr: // |
+		return value.Or(a, b)
+	case lex.TokenBitXor: // ^
+		return value.Xor(a, b)
+	case lex.TokenLShift: // <<
+		return value.Shl(a, b)
+	case lex.TokenRShift: // >>
+		return value.Shr(a, b)
+	}
+	return value.NewErrorValuef("unsupported bitwise operator: %s", op.T)
+}
+
+func operateInts(opts *expr.EvalOptions, op lex.Token, av, bv value.IntValue) value.Value {
 	//if math.IsNaN(a) || math.IsNaN(b) {
 	//	return math.NaN()
 	//}
@@ -783,16 +1070,14 @@ func operateInts(op lex.Token, av, bv value.IntValue) value.Value {
 		//r = a - b
 		return value.NewIntValue(a - b)
 	case lex.TokenDivide: //    /
-		//r = a / b
 		//u.Debugf("divide:   %v / %v = %v", a, b, a/b)
-		return value.NewIntValue(a / b)
+		return value.Divide(av, bv, opts.StrictDivision)
 	case lex.TokenModulus: //    %
-		//r = a / b
 		//u.Debugf("modulus:   %v / %v = %v", a, b, a/b)
-		return value.NewIntValue(a % b)
+		return value.Modulo(av, bv, opts.StrictDivision)
 
 	// Below here are Boolean Returns
-	case lex.TokenEqualEqual: //  ==
+	case lex.TokenEqualEqual, lex.TokenEqual: //  ==
 		if a == b {
 			return value.BoolValueTrue
 		} else {
@@ -5,6 +5,7 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/araddon/dateparse"
 	u "github.com/araddon/gou"
@@ -36,6 +37,7 @@ func init() {
 	expr.FuncAdd("toint", ToInt)
 	expr.FuncAdd("yy", Yy)
 	expr.FuncAdd("exists", Exists)
+	expr.FuncAdd("strlen", Strlen)
 }
 
 var (
@@ -50,8 +52,15 @@ var (
 		"bvalf":   value.NewBoolValue(false),
 		"user_id": value.NewStringValue("abc"),
 		"urls":    value.NewStringsValue([]string{"abc", "123"}),
+		"ids5":    value.NewStringsValue([]string{"5"}),
 		"hits":    value.NewMapIntValue(map[string]int64{"google.com": 5, "bing.com": 1}),
+		"created": value.NewTimeValue(time.Date(2015, 7, 4, 12, 0, 0, 0, time.UTC)),
+		"status":  statusDict.Encode("abc"),
+		"tags":    value.NewSliceValues([]value.Value{value.NewStringValue("a"), value.NewIntValue(2)}),
+		"attrs":   value.NewMapValue(map[string]interface{}{"color": "red"}),
+		"doc":     value.NewJsonValue([]byte(`{"user":{"city":"sf","ids":[4,5]}}`)),
 	})
+	statusDict = value.NewDictionary()
 
 	// list of tests
 	vmTests = []vmTest{
@@ -89,6 +98,16 @@ var (
 		vmt("binary string LIKE", `user_id LIKE "*bc"`, true, noError),
 		vmt("binary string LIKE", `user_id LIKE "\*bc"`, false, noError),
 
+		// Binary Time compared to string dates
+		vmt("binary time > string date", `created > "2015-01-01"`, true, noError),
+		vmt("binary time < string date", `created < "2015-01-01"`, false, noError),
+		vmt("binary time <= string date", `created <= "2015-07-04T12:00:00Z"`, true, noError),
+		vmt("binary time >= string date", `created >= "2015/07/01"`, true, noError),
+		vmt("binary string date < time", `"2015-01-01" < created`, true, noError),
+		vmt("binary time - string date", `created - "2015-07-04T10:30:00Z"`, 90*time.Minute, noError),
+		vmtall("binary time err on invalid date", `created > "not a date"`, nil, parseOk, evalError),
+		vmtall("binary time err on invalid date", `"abc" < created`, nil, parseOk, evalError),
+
 		// Binary Bool
 		vmt("binary bool ==", `bvalt == true`, true, noError),
 		vmt("binary bool =", `bvalt = true`, true, noError),
@@ -103,6 +122,9 @@ var (
 		vmt("general int addition", `5 + 4`, int64(9), noError),
 		vmt("general float addition", `5.2 + 4`, float64(9.2), noError),
 		vmt("associative math", `(4 + 5) / 2`, int64(4), noError),
+		vmt("unary minus int", `-int5`, int64(-5), noError),
+		vmt("unary minus float", `-(int5 * 1.5)`, float64(-7.5), noError),
+		vmtall("unary minus string", `-str5`, nil, parseOk, evalError),
 		vmt("boolean ?", `6 > 5`, true, noError),
 		vmt("boolean ?", `6 > 5.5`, true, noError),
 		vmt("boolean ?", `6 == 6`, true, noError),
@@ -113,6 +135,19 @@ var (
 		vmt("boolean ?", `bvalf == true`, false, noError),
 		vmt("boolean ?", `!(bvalf == true)`, true, noError),
 
+		// Integer bitwise, int5 = 0101
+		vmt("bitwise and", `int5 & 4`, int64(4), noError),
+		vmt("bitwise and mask", `int5 & 4 = 4`, true, noError),
+		vmt("bitwise and mask", `int5 & 2 == 2`, false, noError),
+		vmt("bitwise or", `int5 | 2`, int64(7), noError),
+		vmt("bitwise xor", `int5 ^ 1`, int64(4), noError),
+		vmt("bitwise shift left", `1 << 3`, int64(8), noError),
+		vmt("bitwise shift right", `int5 >> 1`, int64(2), noError),
+		vmt("bitwise masked shift", `(int5 & 6) >> 1`, int64(2), noError),
+		vmt("bitwise float coerce", `5.9 & 4`, int64(4), noError),
+		vmt("int modulus", `int5 % 3`, int64(2), noError),
+		vmtall("bitwise err on string", `user_id & 1`, nil, parseOk, evalError),
+
 		vmt("exists ?", `EXISTS int5`, true, noError),
 		vmt("exists ?", `EXISTS not_a_field`, false, noError),
 		vmt("exists ?", `EXISTS bvalt`, true, noError),
@@ -133,6 +168,40 @@ var (
 
 		// context lookups? simple
 		vmt("ctx lookup ", `user_id`, "abc", noError),
+		vmt("ctx dictionary string", `status`, "abc", noError),
+		vmt("ctx dictionary string eq", `status == "abc"`, true, noError),
+		vmt("ctx dictionary string eq string", `status == user_id`, true, noError),
+		vmt("ctx dictionary string ne", `"abd" != status`, true, noError),
+		vmt("ctx dictionary string in", `status IN ("x", "abc")`, true, noError),
+		vmt("ctx dictionary string func arg", `strlen(status)`, int64(3), noError),
+
+		// arithmetic of numeric strings, ie untyped csv columns
+		vmt("numeric strings *", `str5 * str5`, int64(25), noError),
+		vmt("numeric strings /", `str5 / "2.0"`, float64(2.5), noError),
+		vmtall("non-numeric strings *", `str5 * user_id`, nil, parseOk, evalError),
+
+		// Indexing into slice/map columns, missing elements are nil
+		vmt("ctx slice index", `tags[0]`, "a", noError),
+		vmt("ctx slice index expr", `tags[1] + 1`, int64(3), noError),
+		vmt("ctx strings index", `urls[1]`, "123", noError),
+		vmt("ctx slice index func arg", `toint(tags[1])`, int64(2), noError),
+		vmt("ctx slice index func args", `eq(tags[0], "a")`, true, noError),
+
+		// a single element array compares as its element
+		vmt("array scalar ==", `int5 == ids5`, true, noError),
+		vmt("array scalar ==", `ids5 == 5`, true, noError),
+		vmt("array scalar >", `ids5 > 4.5`, true, noError),
+		vmt("array scalar !=", `ids5 != int5`, false, noError),
+		vmt("array scalar str", `ids5 == "5"`, true, noError),
+		vmt("array multi ==", `int5 == urls`, false, noError),
+		vmt("array multi !=", `urls != "abc"`, true, noError),
+		vmtall("array multi >", `urls > 5`, nil, parseOk, evalError),
+		vmt("ctx slice index out of range", `exists(tags[2])`, false, noError),
+		vmt("ctx map key", `attrs["color"] == "red"`, true, noError),
+		vmt("ctx map missing key", `exists(attrs["size"])`, false, noError),
+		vmt("ctx json path", `doc["user"]["city"] == "sf"`, true, noError),
+		vmt("ctx json path index", `doc["user"]["ids"][1] + 1`, int64(6), noError),
+		vmt("ctx json missing path", `exists(doc["user"]["zip"])`, false, noError),
 
 		// functional syntax
 		vmt("eq/toint types", `eq(toint(int5),5)`, true, noError),
@@ -201,6 +270,80 @@ func TestRunExpr(t *testing.T) {
 	}
 }
 
+func TestCompileMatchesEval(t *testing.T) {
+	for _, test := range vmTests {
+		exprVm, err := NewVm(test.qlText)
+		if err != nil {
+			continue
+		}
+		compiled := Compile(exprVm.Tree.Root)
+		ev, eok := Eval(test.context, exprVm.Tree.Root)
+		cv, cok := compiled(test.context)
+		if eok != cok {
+			t.Errorf("%s -- %v: ok eval=%v compiled=%v", test.name, test.qlText, eok, cok)
+		}
+		if ev == nil || cv == nil {
+			if ev != nil || cv != nil {
+				t.Errorf("%s -- %v: eval=%v compiled=%v", test.name, test.qlText, ev, cv)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(ev.Value(), cv.Value()) {
+			t.Errorf("%s -- %v: eval=%v compiled=%v", test.name, test.qlText, ev, cv)
+		}
+	}
+}
+
+func TestEvalVector(t *testing.T) {
+	rows := make([]expr.ContextReader, 0)
+	for i := 0; i < 20; i++ {
+		row := map[string]value.Value{
+			"int5":    value.NewIntValue(int64(i)),
+			"str5":    value.NewStringValue(strings.Repeat("a", i%3)),
+			"user_id": value.NewStringValue("abc"),
+		}
+		if i%4 == 0 {
+			delete(row, "int5")
+		}
+		rows = append(rows, datasource.NewContextSimpleData(row))
+	}
+	rows = append(rows, msgContext)
+	for _, exprText := range []string{
+		`int5 * 2 + 1`,
+		`int5 > 10 AND user_id == "abc"`,
+		`str5 == "aa"`,
+		`toint(int5) + 1.5`,
+		`5 + 4`,
+		`true`,
+		`!exists(int5)`,
+		`int5 BETWEEN 3 AND 7`,
+	} {
+		exprVm, err := NewVm(exprText)
+		if err != nil {
+			t.Fatalf("%s: %v", exprText, err)
+		}
+		vals := EvalVector(nil, exprVm.Tree.Root, rows)
+		if len(vals) != len(rows) {
+			t.Fatalf("%s: expected %d values got %d", exprText, len(rows), len(vals))
+		}
+		for i, row := range rows {
+			v, ok := Eval(row, exprVm.Tree.Root)
+			switch {
+			case !ok:
+				if vals[i] != nil {
+					t.Errorf("%s row %d: not ok should be nil got %v", exprText, i, vals[i])
+				}
+			case v == nil:
+				if vals[i] == nil || vals[i].Type() != value.NilType {
+					t.Errorf("%s row %d: expected NilValue got %v", exprText, i, vals[i])
+				}
+			case vals[i] == nil || !reflect.DeepEqual(v.Value(), vals[i].Value()):
+				t.Errorf("%s row %d: row by row %v vector %v", exprText, i, v, vals[i])
+			}
+		}
+	}
+}
+
 //  Equal function?  returns true if items are equal
 //
 //      eq(item,5)
@@ -271,6 +414,11 @@ func Exists(ctx expr.EvalContext, item interface{}) (value.BoolValue, bool) {
 	return value.BoolValueFalse, true
 }
 
+// a func of a typed string arg
+func Strlen(ctx expr.EvalContext, item value.StringValue) (value.IntValue, bool) {
+	return value.NewIntValue(int64(len(item.Val()))), true
+}
+
 func Yy(ctx expr.EvalContext, item value.Value) (value.IntValue, bool) {
 
 	//u.Info("yy:   %T", item)
@@ -311,3 +459,203 @@ func vmtall(name, qltext string, result interface{}, parseOk, evalOk bool) vmTes
 func vmtctx(name, qltext string, result interface{}, c expr.ContextReader, ok bool) vmTest {
 	return vmTest{name: name, qlText: qltext, context: c, result: result, parseok: ok, evalok: ok}
 }
+
+func TestNumericStrings(t *testing.T) {
+	ctx := datasource.NewContextSimpleData(map[string]value.Value{
+		"qty":  value.NewStringValue("100"),
+		"name": value.NewStringValue("abc"),
+	})
+	tests := []struct {
+		qlText          string
+		lexical, number interface{} // nil is an eval error
+	}{
+		{`qty > "9"`, nil, true},
+		{`qty < "9"`, nil, false},
+		{`qty == "100.0"`, false, true},
+		{`qty != "100.0"`, true, false},
+		{`qty == "100"`, true, true},
+		{`name == "abc"`, true, true},
+		{`name > "9"`, nil, nil},
+	}
+	for _, numeric := range []bool{false, true} {
+		optsCtx := datasource.NewContextOptions(ctx, &expr.EvalOptions{NumericStrings: numeric})
+		for _, test := range tests {
+			exprVm, err := NewVm(test.qlText)
+			if err != nil {
+				t.Fatalf("%s: %v", test.qlText, err)
+			}
+			expect := test.lexical
+			if numeric {
+				expect = test.number
+			}
+			v, ok := Eval(optsCtx, exprVm.Tree.Root)
+			switch {
+			case expect == nil:
+				if ok && v != nil && !v.Err() {
+					t.Errorf("numeric=%v %s: expected error got %v", numeric, test.qlText, v)
+				}
+			case v == nil || v.Value() != expect:
+				t.Errorf("numeric=%v %s: expected %v got %v", numeric, test.qlText, expect, v)
+			}
+		}
+	}
+}
+
+func TestTimeTolerance(t *testing.T) {
+	ts := time.Date(2015, 7, 4, 12, 0, 0, 0, time.UTC)
+	ctx := datasource.NewContextSimpleData(map[string]value.Value{
+		"a":    value.NewTimeValue(ts),
+		"near": value.NewTimeValue(ts.Add(300 * time.Millisecond)),
+		"far":  value.NewTimeValue(ts.Add(2 * time.Second)),
+	})
+	tests := []struct {
+		qlText          string
+		exact, tolerant bool
+	}{
+		{`a == near`, false, true},
+		{`a != near`, true, false},
+		{`a == far`, false, false},
+		{`a != far`, true, true},
+		{`a < near`, true, true},
+	}
+	for _, tol := range []time.Duration{0, time.Second} {
+		// the tolerance is an option of the context, not process wide
+		optsCtx := datasource.NewContextOptions(ctx, &expr.EvalOptions{TimeTolerance: tol})
+		for _, test := range tests {
+			exprVm, err := NewVm(test.qlText)
+			if err != nil {
+				t.Fatalf("%s: %v", test.qlText, err)
+			}
+			expect := test.exact
+			if tol > 0 {
+				expect = test.tolerant
+			}
+			if v, ok := Eval(optsCtx, exprVm.Tree.Root); !ok || v.Value() != expect {
+				t.Errorf("tolerance=%v %s: expected %v got %v", tol, test.qlText, expect, v)
+			}
+			if v, ok := Compile(exprVm.Tree.Root)(optsCtx); !ok || v.Value() != expect {
+				t.Errorf("compiled tolerance=%v %s: expected %v got %v", tol, test.qlText, expect, v)
+			}
+		}
+	}
+}
+
+// a row context with the values bound to params
+type paramContext struct {
+	*datasource.ContextSimple
+	*expr.Params
+}
+
+func TestEvalParams(t *testing.T) {
+	exprVm, err := NewVm(`age > ? AND name == :name AND toint(?) == 1`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	row := datasource.NewContextSimpleData(map[string]value.Value{
+		"age":  value.NewIntValue(30),
+		"name": value.NewStringValue("bob"),
+	})
+	tests := []struct {
+		args   []value.Value
+		name   string
+		result bool
+	}{
+		{[]value.Value{value.NewIntValue(21), value.NewStringValue("1")}, "bob", true},
+		{[]value.Value{value.NewIntValue(40), value.NewStringValue("1")}, "bob", false},
+		{[]value.Value{value.NewIntValue(21), value.NewStringValue("1")}, "ann", false},
+		{[]value.Value{value.NewIntValue(21), value.NewStringValue("2")}, "bob", false},
+	}
+	for _, test := range tests {
+		params := expr.NewParams(test.args...)
+		params.Named["name"] = value.NewStringValue(test.name)
+		v, ok := Eval(&paramContext{row, params}, exprVm.Tree.Root)
+		if !ok || v.Value() != test.result {
+			t.Errorf("%v %s: expected %v got %v ok=%v", test.args, test.name, test.result, v, ok)
+		}
+		// params are still read through a context carrying options
+		optsCtx := datasource.NewContextOptions(&paramContext{row, params}, &expr.EvalOptions{})
+		if v, ok := Eval(optsCtx, exprVm.Tree.Root); !ok || v.Value() != test.result {
+			t.Errorf("%v %s: with options expected %v got %v ok=%v", test.args, test.name, test.result, v, ok)
+		}
+	}
+
+	// without bound params
+	if v, ok := Eval(row, exprVm.Tree.Root); ok && v.Value() == true {
+		t.Errorf("expected unbound params to not match got %v", v)
+	}
+}
+
+func TestNullArithmetic(t *testing.T) {
+	ctx := datasource.NewContextSimpleData(map[string]value.Value{
+		"int5":   value.NewIntValue(5),
+		"num5":   value.NewNumberValue(5.5),
+		"nullv":  value.NilValueVal,
+		"str5":   value.NewStringValue("5"),
+		"nullv2": value.NilValueVal,
+	})
+	for _, qlText := range []string{
+		`int5 + nullv`,
+		`nullv + int5`,
+		`int5 - nullv`,
+		`nullv - num5`,
+		`num5 * nullv`,
+		`nullv * int5`,
+		`int5 / nullv`,
+		`nullv / num5`,
+		`int5 % nullv`,
+		`nullv + nullv2`,
+		`(int5 + nullv) * 2`,
+		`str5 + nullv`,
+	} {
+		exprVm, err := NewVm(qlText)
+		if err != nil {
+			t.Fatalf("%s: %v", qlText, err)
+		}
+		v, ok := Eval(ctx, exprVm.Tree.Root)
+		if !ok || v == nil || v.Type() != value.NilType {
+			t.Errorf("%s: expected null got %v ok=%v", qlText, v, ok)
+		}
+	}
+	// comparisons with null are not arithmetic
+	exprVm, _ := NewVm(`nullv == nullv2`)
+	if v, ok := Eval(ctx, exprVm.Tree.Root); !ok || v.Value() != true {
+		t.Errorf("nullv == nullv2: expected true got %v", v)
+	}
+}
+
+func TestDivideByZero(t *testing.T) {
+	ctx := datasource.NewContextSimpleData(map[string]value.Value{
+		"int5": value.NewIntValue(5),
+		"num5": value.NewNumberValue(5.5),
+		"zero": value.NewIntValue(0),
+	})
+	for _, strict := range []bool{false, true} {
+		optsCtx := datasource.NewContextOptions(ctx, &expr.EvalOptions{StrictDivision: strict})
+		for _, qlText := range []string{
+			`int5 / zero`,
+			`10 / zero`,
+			`num5 / 0.0`,
+			`int5 / 0.0`,
+			`num5 / zero`,
+			`int5 % zero`,
+			`num5 % zero`,
+			`num5 % 0.5`,
+		} {
+			exprVm, err := NewVm(qlText)
+			if err != nil {
+				t.Fatalf("%s: %v", qlText, err)
+			}
+			v, ok := Eval(optsCtx, exprVm.Tree.Root)
+			switch {
+			case strict && (ok || v == nil || !v.Err()):
+				t.Errorf("strict %s: expected error got %v ok=%v", qlText, v, ok)
+			case !strict && (!ok || v == nil || v.Type() != value.NilType):
+				t.Errorf("%s: expected null got %v ok=%v", qlText, v, ok)
+			}
+		}
+	}
+	exprVm, _ := NewVm(`int5 / 2`)
+	if v, ok := Eval(ctx, exprVm.Tree.Root); !ok || v.Value() != int64(2) {
+		t.Errorf("int5 / 2: expected 2 got %v", v)
+	}
+}